@@ -0,0 +1,695 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/codec"
+)
+
+type GenesisResponse struct {
+	GenesisTime           common.Timestamp `json:"genesis_time"`
+	GenesisValidatorsRoot common.Root      `json:"genesis_validators_root"`
+	GenesisForkVersion    common.Version   `json:"genesis_fork_version"`
+}
+
+func (s *Server) handleGenesis(w http.ResponseWriter, r *http.Request, p params) error {
+	genesis := s.chain.Genesis()
+	return writeData(w, &GenesisResponse{
+		GenesisTime:           genesis.Time,
+		GenesisValidatorsRoot: genesis.ValidatorsRoot,
+		GenesisForkVersion:    s.spec.GENESIS_FORK_VERSION,
+	})
+}
+
+type RootResponse struct {
+	Root common.Root `json:"root"`
+}
+
+func (s *Server) handleStateRoot(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.stateEntry(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	root, err := entry.StateRoot()
+	if err != nil {
+		return err
+	}
+	return writeData(w, &RootResponse{Root: root})
+}
+
+func (s *Server) handleStateFork(w http.ResponseWriter, r *http.Request, p params) error {
+	state, err := s.state(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	fork, err := state.Fork()
+	if err != nil {
+		return err
+	}
+	return writeData(w, &fork)
+}
+
+type FinalityCheckpoints struct {
+	PreviousJustified common.Checkpoint `json:"previous_justified"`
+	CurrentJustified  common.Checkpoint `json:"current_justified"`
+	Finalized         common.Checkpoint `json:"finalized"`
+}
+
+func (s *Server) handleFinalityCheckpoints(w http.ResponseWriter, r *http.Request, p params) error {
+	state, err := s.state(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	var out FinalityCheckpoints
+	if out.PreviousJustified, err = state.PreviousJustifiedCheckpoint(); err != nil {
+		return err
+	}
+	if out.CurrentJustified, err = state.CurrentJustifiedCheckpoint(); err != nil {
+		return err
+	}
+	if out.Finalized, err = state.FinalizedCheckpoint(); err != nil {
+		return err
+	}
+	return writeData(w, &out)
+}
+
+// ValidatorStatus is the status of a validator, as defined by the Beacon API.
+type ValidatorStatus string
+
+const (
+	StatusPendingInitialized ValidatorStatus = "pending_initialized"
+	StatusPendingQueued      ValidatorStatus = "pending_queued"
+	StatusActiveOngoing      ValidatorStatus = "active_ongoing"
+	StatusActiveExiting      ValidatorStatus = "active_exiting"
+	StatusActiveSlashed      ValidatorStatus = "active_slashed"
+	StatusExitedUnslashed    ValidatorStatus = "exited_unslashed"
+	StatusExitedSlashed      ValidatorStatus = "exited_slashed"
+	StatusWithdrawalPossible ValidatorStatus = "withdrawal_possible"
+	StatusWithdrawalDone     ValidatorStatus = "withdrawal_done"
+)
+
+// Matches checks if the status matches the filter: either the exact status,
+// or the general status ("pending", "active", "exited" or "withdrawal").
+func (vs ValidatorStatus) Matches(filter string) bool {
+	return string(vs) == filter || strings.HasPrefix(string(vs), filter+"_")
+}
+
+// ComputeValidatorStatus computes the status of the validator at the given epoch.
+func ComputeValidatorStatus(v *phase0.Validator, balance common.Gwei, epoch common.Epoch) ValidatorStatus {
+	if epoch < v.ActivationEpoch {
+		if v.ActivationEligibilityEpoch == common.FAR_FUTURE_EPOCH {
+			return StatusPendingInitialized
+		}
+		return StatusPendingQueued
+	}
+	if epoch < v.ExitEpoch {
+		if v.ExitEpoch == common.FAR_FUTURE_EPOCH {
+			return StatusActiveOngoing
+		}
+		if v.Slashed {
+			return StatusActiveSlashed
+		}
+		return StatusActiveExiting
+	}
+	if epoch < v.WithdrawableEpoch {
+		if v.Slashed {
+			return StatusExitedSlashed
+		}
+		return StatusExitedUnslashed
+	}
+	if balance != 0 {
+		return StatusWithdrawalPossible
+	}
+	return StatusWithdrawalDone
+}
+
+type ValidatorResponse struct {
+	Index     common.ValidatorIndex `json:"index"`
+	Balance   common.Gwei           `json:"balance"`
+	Status    ValidatorStatus       `json:"status"`
+	Validator *phase0.Validator     `json:"validator"`
+}
+
+type ValidatorBalance struct {
+	Index   common.ValidatorIndex `json:"index"`
+	Balance common.Gwei           `json:"balance"`
+}
+
+func toValidator(v common.Validator) (*phase0.Validator, error) {
+	var out phase0.Validator
+	var err error
+	if out.Pubkey, err = v.Pubkey(); err != nil {
+		return nil, err
+	}
+	if out.WithdrawalCredentials, err = v.WithdrawalCredentials(); err != nil {
+		return nil, err
+	}
+	var flat common.FlatValidator
+	if err := v.Flatten(&flat); err != nil {
+		return nil, err
+	}
+	out.EffectiveBalance = flat.EffectiveBalance
+	out.Slashed = flat.Slashed
+	out.ActivationEligibilityEpoch = flat.ActivationEligibilityEpoch
+	out.ActivationEpoch = flat.ActivationEpoch
+	out.ExitEpoch = flat.ExitEpoch
+	out.WithdrawableEpoch = flat.WithdrawableEpoch
+	return &out, nil
+}
+
+// validatorIndices resolves the validator ids, indices or pubkeys, to indices.
+// Unknown validators are omitted. If there are no ids, all validators are returned.
+func validatorIndices(state common.BeaconState, ids []string) ([]common.ValidatorIndex, error) {
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	count, err := vals.ValidatorCount()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		out := make([]common.ValidatorIndex, count)
+		for i := range out {
+			out[i] = common.ValidatorIndex(i)
+		}
+		return out, nil
+	}
+	var pubkeys map[common.BLSPubkey]common.ValidatorIndex
+	out := make([]common.ValidatorIndex, 0, len(ids))
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "0x") {
+			index, err := parseUint("validator id", id)
+			if err != nil {
+				return nil, err
+			}
+			if index < count {
+				out = append(out, common.ValidatorIndex(index))
+			}
+			continue
+		}
+		var pub common.BLSPubkey
+		if err := pub.UnmarshalText([]byte(id)); err != nil {
+			return nil, badRequest("invalid validator pubkey %q: %v", id, err)
+		}
+		if pubkeys == nil {
+			pubkeys = make(map[common.BLSPubkey]common.ValidatorIndex, count)
+			next := vals.Iter()
+			for i := common.ValidatorIndex(0); ; i++ {
+				v, ok, err := next()
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					break
+				}
+				vPub, err := v.Pubkey()
+				if err != nil {
+					return nil, err
+				}
+				pubkeys[vPub] = i
+			}
+		}
+		if index, ok := pubkeys[pub]; ok {
+			out = append(out, index)
+		}
+	}
+	return out, nil
+}
+
+// queryList collects the values of the query parameter, both repeated and comma-separated.
+func queryList(r *http.Request, name string) []string {
+	var out []string
+	for _, v := range r.URL.Query()[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+func (s *Server) validators(r *http.Request, stateID string, ids []string, statuses []string) ([]ValidatorResponse, error) {
+	state, err := s.state(r.Context(), stateID)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	epoch := s.spec.SlotToEpoch(slot)
+	indices, err := validatorIndices(state, ids)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := state.Balances()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ValidatorResponse, 0, len(indices))
+	for _, index := range indices {
+		v, err := vals.Validator(index)
+		if err != nil {
+			return nil, err
+		}
+		val, err := toValidator(v)
+		if err != nil {
+			return nil, err
+		}
+		bal, err := balances.GetBalance(index)
+		if err != nil {
+			return nil, err
+		}
+		status := ComputeValidatorStatus(val, bal, epoch)
+		if len(statuses) > 0 {
+			match := false
+			for _, filter := range statuses {
+				if status.Matches(filter) {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		out = append(out, ValidatorResponse{Index: index, Balance: bal, Status: status, Validator: val})
+	}
+	return out, nil
+}
+
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request, p params) error {
+	out, err := s.validators(r, p["state_id"], queryList(r, "id"), queryList(r, "status"))
+	if err != nil {
+		return err
+	}
+	return writeData(w, out)
+}
+
+func (s *Server) handleValidator(w http.ResponseWriter, r *http.Request, p params) error {
+	out, err := s.validators(r, p["state_id"], []string{p["validator_id"]}, nil)
+	if err != nil {
+		return err
+	}
+	if len(out) == 0 {
+		return notFound("unknown validator %s", p["validator_id"])
+	}
+	return writeData(w, &out[0])
+}
+
+func (s *Server) handleValidatorBalances(w http.ResponseWriter, r *http.Request, p params) error {
+	state, err := s.state(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	indices, err := validatorIndices(state, queryList(r, "id"))
+	if err != nil {
+		return err
+	}
+	balances, err := state.Balances()
+	if err != nil {
+		return err
+	}
+	out := make([]ValidatorBalance, 0, len(indices))
+	for _, index := range indices {
+		bal, err := balances.GetBalance(index)
+		if err != nil {
+			return err
+		}
+		out = append(out, ValidatorBalance{Index: index, Balance: bal})
+	}
+	return writeData(w, out)
+}
+
+type Committee struct {
+	Index      common.CommitteeIndex   `json:"index"`
+	Slot       common.Slot             `json:"slot"`
+	Validators []common.ValidatorIndex `json:"validators"`
+}
+
+func (s *Server) handleCommittees(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.stateEntry(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	epc, err := entry.EpochsContext(r.Context())
+	if err != nil {
+		return err
+	}
+	epoch := s.spec.SlotToEpoch(entry.Step().Slot())
+	q := r.URL.Query()
+	if v := q.Get("epoch"); v != "" {
+		e, err := parseUint("epoch", v)
+		if err != nil {
+			return err
+		}
+		epoch = common.Epoch(e)
+	}
+	var shuffling *common.ShufflingEpoch
+	for _, sh := range []*common.ShufflingEpoch{epc.PreviousEpoch, epc.CurrentEpoch, epc.NextEpoch} {
+		if sh != nil && sh.Epoch == epoch {
+			shuffling = sh
+			break
+		}
+	}
+	if shuffling == nil {
+		return badRequest("epoch %d is out of range of the state", epoch)
+	}
+	var filterIndex, filterSlot *uint64
+	if v := q.Get("index"); v != "" {
+		index, err := parseUint("committee index", v)
+		if err != nil {
+			return err
+		}
+		filterIndex = &index
+	}
+	if v := q.Get("slot"); v != "" {
+		slot, err := parseUint("slot", v)
+		if err != nil {
+			return err
+		}
+		filterSlot = &slot
+	}
+	startSlot, err := s.spec.EpochStartSlot(epoch)
+	if err != nil {
+		return badRequest("invalid epoch %d: %v", epoch, err)
+	}
+	out := make([]Committee, 0)
+	for i, slotComms := range shuffling.Committees {
+		slot := startSlot + common.Slot(i)
+		if filterSlot != nil && common.Slot(*filterSlot) != slot {
+			continue
+		}
+		for index, committee := range slotComms {
+			if filterIndex != nil && *filterIndex != uint64(index) {
+				continue
+			}
+			out = append(out, Committee{Index: common.CommitteeIndex(index), Slot: slot, Validators: committee})
+		}
+	}
+	return writeData(w, out)
+}
+
+type SyncCommitteeResponse struct {
+	Validators          []common.ValidatorIndex   `json:"validators"`
+	ValidatorAggregates [][]common.ValidatorIndex `json:"validator_aggregates"`
+}
+
+func (s *Server) handleSyncCommittees(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.stateEntry(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	epc, err := entry.EpochsContext(r.Context())
+	if err != nil {
+		return err
+	}
+	if epc.CurrentSyncCommittee == nil {
+		return badRequest("state has no sync committees")
+	}
+	stateEpoch := s.spec.SlotToEpoch(entry.Step().Slot())
+	epoch := stateEpoch
+	if v := r.URL.Query().Get("epoch"); v != "" {
+		e, err := parseUint("epoch", v)
+		if err != nil {
+			return err
+		}
+		epoch = common.Epoch(e)
+	}
+	period := uint64(s.spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+	var committee *common.IndexedSyncCommittee
+	switch uint64(epoch) / period {
+	case uint64(stateEpoch) / period:
+		committee = epc.CurrentSyncCommittee
+	case uint64(stateEpoch)/period + 1:
+		committee = epc.NextSyncCommittee
+	}
+	if committee == nil {
+		return badRequest("epoch %d is out of range of the sync committees of the state", epoch)
+	}
+	out := SyncCommitteeResponse{
+		Validators:          committee.Indices,
+		ValidatorAggregates: make([][]common.ValidatorIndex, 0, common.SYNC_COMMITTEE_SUBNET_COUNT),
+	}
+	for subnet := uint64(0); subnet < common.SYNC_COMMITTEE_SUBNET_COUNT; subnet++ {
+		_, indices, err := committee.Subcommittee(s.spec, subnet)
+		if err != nil {
+			return err
+		}
+		out.ValidatorAggregates = append(out.ValidatorAggregates, indices)
+	}
+	return writeData(w, &out)
+}
+
+type SignedHeader struct {
+	Message   common.BeaconBlockHeader `json:"message"`
+	Signature common.BLSSignature      `json:"signature"`
+}
+
+type HeaderResponse struct {
+	Root      common.Root  `json:"root"`
+	Canonical bool         `json:"canonical"`
+	Header    SignedHeader `json:"header"`
+}
+
+// blockHeader gets the header of the block of the entry.
+// If the block itself is not available, e.g. for the anchor or genesis block,
+// the header is taken from the state, and the signature is left empty.
+func (s *Server) blockHeader(r *http.Request, entry beacon.ChainEntry) (*HeaderResponse, error) {
+	root, err := entry.BlockRoot()
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := s.isCanonical(entry)
+	if err != nil {
+		return nil, err
+	}
+	out := &HeaderResponse{Root: root, Canonical: canonical}
+	benv, err := entry.Block()
+	if err != nil {
+		return nil, err
+	}
+	if benv != nil {
+		out.Header.Message = benv.BeaconBlockHeader
+		out.Header.Signature = benv.Signature
+		return out, nil
+	}
+	state, err := entry.State(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	header, err := state.LatestBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	if header.StateRoot == (common.Root{}) {
+		if header.StateRoot, err = entry.StateRoot(); err != nil {
+			return nil, err
+		}
+	}
+	out.Header.Message = *header
+	return out, nil
+}
+
+func (s *Server) handleHeaders(w http.ResponseWriter, r *http.Request, p params) error {
+	q := r.URL.Query()
+	var parentRoot *common.Root
+	var slot *common.Slot
+	if v := q.Get("parent_root"); v != "" {
+		root, err := parseRoot(v)
+		if err != nil {
+			return err
+		}
+		parentRoot = &root
+	}
+	if v := q.Get("slot"); v != "" {
+		sl, err := parseUint("slot", v)
+		if err != nil {
+			return err
+		}
+		slot = (*common.Slot)(&sl)
+	}
+	var entries []beacon.ChainEntry
+	if parentRoot == nil && slot == nil {
+		head, err := s.blockEntry("head")
+		if err != nil {
+			return err
+		}
+		entries = append(entries, head)
+	} else {
+		found, err := s.chain.Search(parentRoot, slot)
+		if err != nil {
+			return err
+		}
+		for _, e := range found {
+			if e.Step().Block() {
+				entries = append(entries, e.ChainEntry)
+			}
+		}
+	}
+	out := make([]*HeaderResponse, 0, len(entries))
+	for _, entry := range entries {
+		header, err := s.blockHeader(r, entry)
+		if err != nil {
+			return err
+		}
+		out = append(out, header)
+	}
+	return writeData(w, out)
+}
+
+func (s *Server) handleHeader(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.blockEntry(p["block_id"])
+	if err != nil {
+		return err
+	}
+	header, err := s.blockHeader(r, entry)
+	if err != nil {
+		return err
+	}
+	return writeData(w, header)
+}
+
+func (s *Server) handleBlockRoot(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.blockEntry(p["block_id"])
+	if err != nil {
+		return err
+	}
+	root, err := entry.BlockRoot()
+	if err != nil {
+		return err
+	}
+	return writeData(w, &RootResponse{Root: root})
+}
+
+const sszContentType = "application/octet-stream"
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request, p params) error {
+	entry, err := s.blockEntry(p["block_id"])
+	if err != nil {
+		return err
+	}
+	benv, err := entry.Block()
+	if err != nil {
+		return err
+	}
+	if benv == nil {
+		return notFound("block %s is not available", p["block_id"])
+	}
+	block, err := beacon.EnvelopeToSignedBeaconBlock(benv)
+	if err != nil {
+		return err
+	}
+	version, err := ForkName(s.spec, s.spec.SlotToEpoch(benv.Slot))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Eth-Consensus-Version", version)
+	if strings.Contains(r.Header.Get("Accept"), sszContentType) {
+		w.Header().Set("Content-Type", sszContentType)
+		w.WriteHeader(http.StatusOK)
+		return block.Serialize(s.spec, codec.NewEncodingWriter(w))
+	}
+	writeJSON(w, http.StatusOK, &Response{Version: version, Data: block})
+	return nil
+}
+
+// maxBlockSize limits the size of submitted blocks
+const maxBlockSize = 10 << 20
+
+func (s *Server) handleSubmitBlock(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.importer == nil {
+		return &Error{Code: http.StatusNotImplemented, Message: "block submission is not supported"}
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBlockSize))
+	if err != nil {
+		return badRequest("failed to read block: %v", err)
+	}
+	isSSZ := strings.HasPrefix(r.Header.Get("Content-Type"), sszContentType)
+	// The fork of the block is determined by its slot, the slot is read before decoding the full block.
+	var slot common.Slot
+	if isSSZ {
+		// A signed block starts with the offset of the message and the signature, followed by the slot of the message.
+		if len(data) < 4+96+8 {
+			return badRequest("block is too short: %d bytes", len(data))
+		}
+		slot = common.Slot(binary.LittleEndian.Uint64(data[4+96 : 4+96+8]))
+	} else {
+		var peek struct {
+			Message struct {
+				Slot common.Slot `json:"slot"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal(data, &peek); err != nil {
+			return badRequest("invalid block: %v", err)
+		}
+		slot = peek.Message.Slot
+	}
+	digest := s.decoder.ForkDigest(s.spec.SlotToEpoch(slot))
+	alloc, err := s.decoder.BlockAllocator(digest)
+	if err != nil {
+		return badRequest("unsupported fork of block at slot %d: %v", slot, err)
+	}
+	block := alloc()
+	if isSSZ {
+		err = block.Deserialize(s.spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data))))
+	} else {
+		err = json.Unmarshal(data, block)
+	}
+	if err != nil {
+		return badRequest("failed to decode block: %v", err)
+	}
+	benv := block.Envelope(s.spec, digest)
+
+	prevHead, err := s.chain.Head()
+	if err != nil {
+		return err
+	}
+	prevFinalized := s.chain.FinalizedCheckpoint()
+	if err := s.importer.AddBlock(r.Context(), benv, true); err != nil {
+		return badRequest("failed to import block %s: %v", benv.BlockRoot, err)
+	}
+	if err := s.publishImport(benv, prevHead, prevFinalized); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// handleDebugState serves the full state. Only SSZ encoding is supported, the state views do not implement JSON encoding.
+func (s *Server) handleDebugState(w http.ResponseWriter, r *http.Request, p params) error {
+	if !strings.Contains(r.Header.Get("Accept"), sszContentType) {
+		return &Error{Code: http.StatusNotAcceptable, Message: "states are only available as " + sszContentType}
+	}
+	state, err := s.state(r.Context(), p["state_id"])
+	if err != nil {
+		return err
+	}
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	version, err := ForkName(s.spec, s.spec.SlotToEpoch(slot))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Eth-Consensus-Version", version)
+	w.Header().Set("Content-Type", sszContentType)
+	w.WriteHeader(http.StatusOK)
+	return state.Serialize(codec.NewEncodingWriter(w))
+}
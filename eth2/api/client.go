@@ -0,0 +1,499 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/codec"
+)
+
+// Client is a client of the standard eth2 Beacon Node API.
+// Blocks and states are requested as SSZ, and decoded with the fork of the response.
+type Client struct {
+	endpoint string
+	spec     *common.Spec
+	http     *http.Client
+
+	mu sync.Mutex
+	// decoder is initialized with the genesis validators root of the node, on first use
+	decoder *beacon.ForkDecoder
+}
+
+// NewClient creates a client for the Beacon API at the given HTTP endpoint, e.g. "http://localhost:5052".
+// If the http client is nil, http.DefaultClient is used.
+func NewClient(endpoint string, spec *common.Spec, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{endpoint: strings.TrimSuffix(endpoint, "/"), spec: spec, http: httpClient}
+}
+
+// do sends the request, and returns the response if successful.
+// Error responses are returned as *Error. The caller must close the body of the response.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values,
+	accept string, contentType string, body []byte) (*http.Response, error) {
+	u := c.endpoint + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s request failed: %w", method, path, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s %s error response: %w", method, path, err)
+		}
+		var apiErr Error
+		if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Message == "" {
+			apiErr = Error{Message: strings.TrimSpace(string(data))}
+		}
+		apiErr.Code = resp.StatusCode
+		return nil, &apiErr
+	}
+	return resp, nil
+}
+
+// get requests the JSON data of the path, and decodes it into dst.
+func (c *Client) get(ctx context.Context, path string, query url.Values, dst interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query, "application/json", "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&Response{Data: dst}); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", path, err)
+	}
+	return nil
+}
+
+// post sends the JSON encoding of the body to the path.
+func (c *Client) post(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %v", path, err)
+	}
+	resp, err := c.do(ctx, http.MethodPost, path, nil, "", "application/json", data)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) Genesis(ctx context.Context) (*GenesisResponse, error) {
+	var out GenesisResponse
+	if err := c.get(ctx, "/eth/v1/beacon/genesis", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// forkDecoder gets the fork decoder of the chain of the node, the genesis is requested the first time.
+func (c *Client) forkDecoder(ctx context.Context) (*beacon.ForkDecoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.decoder == nil {
+		genesis, err := c.Genesis(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get genesis validators root: %w", err)
+		}
+		c.decoder = beacon.NewForkDecoder(c.spec, genesis.GenesisValidatorsRoot)
+	}
+	return c.decoder, nil
+}
+
+// forkDigest gets the digest of the fork, by the fork name of a versioned response.
+func forkDigest(d *beacon.ForkDecoder, version string) (common.ForkDigest, error) {
+	switch version {
+	case "phase0":
+		return d.Genesis, nil
+	case "altair":
+		return d.Altair, nil
+	case "bellatrix":
+		return d.Bellatrix, nil
+	case "capella":
+		return d.Capella, nil
+	case "sharding":
+		return d.Sharding, nil
+	default:
+		return common.ForkDigest{}, fmt.Errorf("unknown fork version %q", version)
+	}
+}
+
+func (c *Client) StateRoot(ctx context.Context, stateID string) (common.Root, error) {
+	var out RootResponse
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/root", nil, &out); err != nil {
+		return common.Root{}, err
+	}
+	return out.Root, nil
+}
+
+func (c *Client) StateFork(ctx context.Context, stateID string) (*common.Fork, error) {
+	var out common.Fork
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/fork", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) FinalityCheckpoints(ctx context.Context, stateID string) (*FinalityCheckpoints, error) {
+	var out FinalityCheckpoints
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/finality_checkpoints", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Validators gets the validators of the state, optionally filtered by ids (indices or pubkeys) and statuses.
+func (c *Client) Validators(ctx context.Context, stateID string, ids []string, statuses []ValidatorStatus) ([]ValidatorResponse, error) {
+	q := make(url.Values)
+	if len(ids) > 0 {
+		q.Set("id", strings.Join(ids, ","))
+	}
+	if len(statuses) > 0 {
+		names := make([]string, len(statuses))
+		for i, st := range statuses {
+			names[i] = string(st)
+		}
+		q.Set("status", strings.Join(names, ","))
+	}
+	var out []ValidatorResponse
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/validators", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Validator gets a single validator of the state, by index or pubkey.
+func (c *Client) Validator(ctx context.Context, stateID string, id string) (*ValidatorResponse, error) {
+	var out ValidatorResponse
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/validators/"+id, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) ValidatorBalances(ctx context.Context, stateID string, ids []string) ([]ValidatorBalance, error) {
+	q := make(url.Values)
+	if len(ids) > 0 {
+		q.Set("id", strings.Join(ids, ","))
+	}
+	var out []ValidatorBalance
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/validator_balances", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Committees gets the beacon committees of the state, the filters are optional.
+func (c *Client) Committees(ctx context.Context, stateID string,
+	epoch *common.Epoch, index *common.CommitteeIndex, slot *common.Slot) ([]Committee, error) {
+	q := make(url.Values)
+	if epoch != nil {
+		q.Set("epoch", strconv.FormatUint(uint64(*epoch), 10))
+	}
+	if index != nil {
+		q.Set("index", strconv.FormatUint(uint64(*index), 10))
+	}
+	if slot != nil {
+		q.Set("slot", strconv.FormatUint(uint64(*slot), 10))
+	}
+	var out []Committee
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/committees", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SyncCommittees gets the sync committee of the epoch, or of the epoch of the state if nil.
+func (c *Client) SyncCommittees(ctx context.Context, stateID string, epoch *common.Epoch) (*SyncCommitteeResponse, error) {
+	q := make(url.Values)
+	if epoch != nil {
+		q.Set("epoch", strconv.FormatUint(uint64(*epoch), 10))
+	}
+	var out SyncCommitteeResponse
+	if err := c.get(ctx, "/eth/v1/beacon/states/"+stateID+"/sync_committees", q, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Headers gets the headers of the blocks at the slot and/or with the parent root.
+// If both are nil, the header of the head block is returned.
+func (c *Client) Headers(ctx context.Context, slot *common.Slot, parentRoot *common.Root) ([]HeaderResponse, error) {
+	q := make(url.Values)
+	if slot != nil {
+		q.Set("slot", strconv.FormatUint(uint64(*slot), 10))
+	}
+	if parentRoot != nil {
+		q.Set("parent_root", parentRoot.String())
+	}
+	var out []HeaderResponse
+	if err := c.get(ctx, "/eth/v1/beacon/headers", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) Header(ctx context.Context, blockID string) (*HeaderResponse, error) {
+	var out HeaderResponse
+	if err := c.get(ctx, "/eth/v1/beacon/headers/"+blockID, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) BlockRoot(ctx context.Context, blockID string) (common.Root, error) {
+	var out RootResponse
+	if err := c.get(ctx, "/eth/v1/beacon/blocks/"+blockID+"/root", nil, &out); err != nil {
+		return common.Root{}, err
+	}
+	return out.Root, nil
+}
+
+// Block gets the signed block, decoded as the block type of its fork.
+// SSZ is preferred, JSON responses are decoded too.
+func (c *Client) Block(ctx context.Context, blockID string) (beacon.OpaqueBlock, error) {
+	decoder, err := c.forkDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path := "/eth/v2/beacon/blocks/" + blockID
+	resp, err := c.do(ctx, http.MethodGet, path, nil, sszContentType+", application/json;q=0.9", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", path, err)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), sszContentType) {
+		digest, err := forkDigest(decoder, resp.Header.Get("Eth-Consensus-Version"))
+		if err != nil {
+			return nil, err
+		}
+		alloc, err := decoder.BlockAllocator(digest)
+		if err != nil {
+			return nil, err
+		}
+		block := alloc()
+		if err := block.Deserialize(c.spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+			return nil, fmt.Errorf("failed to decode block: %v", err)
+		}
+		return block, nil
+	}
+	var versioned struct {
+		Version string          `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &versioned); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %v", path, err)
+	}
+	digest, err := forkDigest(decoder, versioned.Version)
+	if err != nil {
+		return nil, err
+	}
+	alloc, err := decoder.BlockAllocator(digest)
+	if err != nil {
+		return nil, err
+	}
+	block := alloc()
+	if err := json.Unmarshal(versioned.Data, block); err != nil {
+		return nil, fmt.Errorf("failed to decode block: %v", err)
+	}
+	return block, nil
+}
+
+// SubmitBlock publishes the signed block, the block is sent as SSZ.
+func (c *Client) SubmitBlock(ctx context.Context, block common.SpecObj) error {
+	var buf bytes.Buffer
+	if err := block.Serialize(c.spec, codec.NewEncodingWriter(&buf)); err != nil {
+		return fmt.Errorf("failed to encode block: %v", err)
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/eth/v1/beacon/blocks", nil, "", sszContentType, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// State gets the full state, decoded as the state type of its fork. States are only requested as SSZ.
+func (c *Client) State(ctx context.Context, stateID string) (common.BeaconState, error) {
+	decoder, err := c.forkDecoder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path := "/eth/v2/debug/beacon/states/" + stateID
+	resp, err := c.do(ctx, http.MethodGet, path, nil, sszContentType, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	digest, err := forkDigest(decoder, resp.Header.Get("Eth-Consensus-Version"))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %w", path, err)
+	}
+	state, err := decoder.DecodeState(digest, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data))))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state: %v", err)
+	}
+	return state, nil
+}
+
+// PoolAttestations gets the pooled attestations, the filters are optional.
+func (c *Client) PoolAttestations(ctx context.Context, slot *common.Slot, index *common.CommitteeIndex) ([]phase0.Attestation, error) {
+	q := make(url.Values)
+	if slot != nil {
+		q.Set("slot", strconv.FormatUint(uint64(*slot), 10))
+	}
+	if index != nil {
+		q.Set("committee_index", strconv.FormatUint(uint64(*index), 10))
+	}
+	var out []phase0.Attestation
+	if err := c.get(ctx, "/eth/v1/beacon/pool/attestations", q, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SubmitAttestations publishes the attestations.
+// If some fail, an *Error with the failures of the individual attestations is returned.
+func (c *Client) SubmitAttestations(ctx context.Context, atts []phase0.Attestation) error {
+	return c.post(ctx, "/eth/v1/beacon/pool/attestations", atts)
+}
+
+func (c *Client) PoolAttesterSlashings(ctx context.Context) ([]phase0.AttesterSlashing, error) {
+	var out []phase0.AttesterSlashing
+	if err := c.get(ctx, "/eth/v1/beacon/pool/attester_slashings", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) SubmitAttesterSlashing(ctx context.Context, sl *phase0.AttesterSlashing) error {
+	return c.post(ctx, "/eth/v1/beacon/pool/attester_slashings", sl)
+}
+
+func (c *Client) PoolProposerSlashings(ctx context.Context) ([]phase0.ProposerSlashing, error) {
+	var out []phase0.ProposerSlashing
+	if err := c.get(ctx, "/eth/v1/beacon/pool/proposer_slashings", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) SubmitProposerSlashing(ctx context.Context, sl *phase0.ProposerSlashing) error {
+	return c.post(ctx, "/eth/v1/beacon/pool/proposer_slashings", sl)
+}
+
+func (c *Client) PoolVoluntaryExits(ctx context.Context) ([]phase0.SignedVoluntaryExit, error) {
+	var out []phase0.SignedVoluntaryExit
+	if err := c.get(ctx, "/eth/v1/beacon/pool/voluntary_exits", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) SubmitVoluntaryExit(ctx context.Context, exit *phase0.SignedVoluntaryExit) error {
+	return c.post(ctx, "/eth/v1/beacon/pool/voluntary_exits", exit)
+}
+
+// SubmitSyncCommitteeMessages publishes the sync committee messages.
+// If some fail, an *Error with the failures of the individual messages is returned.
+func (c *Client) SubmitSyncCommitteeMessages(ctx context.Context, msgs []altair.SyncCommitteeMessage) error {
+	return c.post(ctx, "/eth/v1/beacon/pool/sync_committees", msgs)
+}
+
+// Event is an event of the event stream.
+type Event struct {
+	Topic string
+	Data  json.RawMessage
+}
+
+// Decode the data of the event, e.g. into a *HeadEvent for a head event.
+func (ev *Event) Decode(dst interface{}) error {
+	if err := json.Unmarshal(ev.Data, dst); err != nil {
+		return fmt.Errorf("failed to decode %s event: %v", ev.Topic, err)
+	}
+	return nil
+}
+
+// SubscribeEvents subscribes to the topics of the event stream, and calls fn for each event.
+// It blocks until the context is canceled, the stream ends, or fn returns an error.
+// Canceling the context is not returned as an error.
+func (c *Client) SubscribeEvents(ctx context.Context, topics []string, fn func(ev *Event) error) error {
+	q := url.Values{"topics": {strings.Join(topics, ",")}}
+	resp, err := c.do(ctx, http.MethodGet, "/eth/v1/events", q, "text/event-stream", "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 4096), maxBlockSize)
+	var topic string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// an empty line dispatches the event
+			if topic != "" || len(data) > 0 {
+				if err := fn(&Event{Topic: topic, Data: data}); err != nil {
+					return err
+				}
+			}
+			topic, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment, used to keep the connection alive
+		case strings.HasPrefix(line, "event:"):
+			topic = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("event stream failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestClient(t *testing.T) {
+	n := newTestNode(t)
+	c := NewClient(n.server.URL, n.spec, nil)
+	ctx := context.Background()
+
+	genesis, err := c.Genesis(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genesis.GenesisTime != 1000 || genesis.GenesisValidatorsRoot != n.chain.Genesis().ValidatorsRoot {
+		t.Fatalf("unexpected genesis: %+v", genesis)
+	}
+
+	vals, err := c.Validators(ctx, "head", []string{"3", n.pubkeys[5].String()}, []ValidatorStatus{"active"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 2 || vals[0].Index != 3 || vals[1].Validator.Pubkey != n.pubkeys[5] {
+		t.Fatalf("unexpected validators: %+v", vals)
+	}
+	val, err := c.Validator(ctx, "genesis", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val.Status != StatusActiveOngoing || val.Balance != n.spec.MAX_EFFECTIVE_BALANCE {
+		t.Fatalf("unexpected validator: %+v", val)
+	}
+	_, err = c.Validator(ctx, "head", "1000")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 404 {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	checkpoints, err := c.FinalityCheckpoints(ctx, "head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoints.Finalized.Epoch != 0 {
+		t.Fatalf("unexpected finalized checkpoint: %+v", checkpoints.Finalized)
+	}
+	slot := common.Slot(3)
+	committees, err := c.Committees(ctx, "head", nil, nil, &slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(committees) == 0 || committees[0].Slot != slot {
+		t.Fatalf("unexpected committees: %+v", committees)
+	}
+
+	// subscribe before the block is submitted, events are not replayed
+	events := make(chan *Event, 8)
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_ = c.SubscribeEvents(subCtx, []string{TopicBlock}, func(ev *Event) error {
+			events <- ev
+			return nil
+		})
+		close(events)
+	}()
+	// the stream is established once the server subscribed to the feed
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		n.api.events.mu.Lock()
+		subs := len(n.api.events.subs)
+		n.api.events.mu.Unlock()
+		if subs > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event subscription timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	block := n.buildBlock(t, 1)
+	blockRoot := block.Message.HashTreeRoot(n.spec, tree.GetHashFn())
+	if err := c.SubmitBlock(ctx, block); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		var blockEv BlockEvent
+		if err := ev.Decode(&blockEv); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Topic != TopicBlock || blockEv.Block != blockRoot || blockEv.Slot != 1 {
+			t.Fatalf("unexpected event %s: %s", ev.Topic, ev.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no block event")
+	}
+	// vote for the block to make it the head
+	for i := range n.keys {
+		n.chain.ProcessAttestation(common.ValidatorIndex(i), blockRoot, 1, 0)
+	}
+	invalid := n.buildBlock(t, 2)
+	invalid.Signature = common.BLSSignature{}
+	if err := c.SubmitBlock(ctx, invalid); !errors.As(err, &apiErr) || apiErr.Code != 400 {
+		t.Fatalf("expected bad request error, got %v", err)
+	}
+
+	got, err := c.Block(ctx, blockRoot.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotBlock, ok := got.(*phase0.SignedBeaconBlock)
+	if !ok || gotBlock.Message.HashTreeRoot(n.spec, tree.GetHashFn()) != blockRoot || gotBlock.Signature != block.Signature {
+		t.Fatalf("unexpected block: %T", got)
+	}
+	root, err := c.BlockRoot(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != blockRoot {
+		t.Fatalf("expected block root %s, got %s", blockRoot, root)
+	}
+	headers, err := c.Headers(ctx, &block.Message.Slot, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 1 || headers[0].Root != blockRoot {
+		t.Fatalf("unexpected headers: %+v", headers)
+	}
+
+	stateRoot, err := c.StateRoot(ctx, "genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := c.State(ctx, "genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := state.HashTreeRoot(tree.GetHashFn()); got != stateRoot {
+		t.Fatalf("expected state root %s, got %s", stateRoot, got)
+	}
+	fork, err := c.StateFork(ctx, "genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.CurrentVersion != n.spec.GENESIS_FORK_VERSION {
+		t.Fatalf("unexpected fork: %+v", fork)
+	}
+
+	exit := &phase0.SignedVoluntaryExit{Message: phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 4}}
+	if err := c.SubmitVoluntaryExit(ctx, exit); err != nil {
+		t.Fatal(err)
+	}
+	exits, err := c.PoolVoluntaryExits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exits) != 1 || exits[0].Message.ValidatorIndex != 4 {
+		t.Fatalf("unexpected exits: %+v", exits)
+	}
+	err = c.SubmitAttestations(ctx, []phase0.Attestation{{AggregationBits: phase0.AttestationBits{0x01}, Data: phase0.AttestationData{Slot: 1}}})
+	if !errors.As(err, &apiErr) || len(apiErr.Failures) != 1 || apiErr.Failures[0].Index != 0 {
+		t.Fatalf("expected attestation failure, got %v", err)
+	}
+	atts, err := c.PoolAttestations(ctx, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atts) != 0 {
+		t.Fatalf("expected no attestations, got %d", len(atts))
+	}
+
+	cancel()
+	for range events {
+	}
+}
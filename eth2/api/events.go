@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Event topics of the event stream
+const (
+	TopicHead                 = "head"
+	TopicBlock                = "block"
+	TopicAttestation          = "attestation"
+	TopicVoluntaryExit        = "voluntary_exit"
+	TopicFinalizedCheckpoint  = "finalized_checkpoint"
+	TopicChainReorg           = "chain_reorg"
+	TopicContributionAndProof = "contribution_and_proof"
+)
+
+var knownTopics = map[string]bool{
+	TopicHead:                 true,
+	TopicBlock:                true,
+	TopicAttestation:          true,
+	TopicVoluntaryExit:        true,
+	TopicFinalizedCheckpoint:  true,
+	TopicChainReorg:           true,
+	TopicContributionAndProof: true,
+}
+
+type HeadEvent struct {
+	Slot            common.Slot `json:"slot"`
+	Block           common.Root `json:"block"`
+	State           common.Root `json:"state"`
+	EpochTransition bool        `json:"epoch_transition"`
+}
+
+type BlockEvent struct {
+	Slot  common.Slot `json:"slot"`
+	Block common.Root `json:"block"`
+}
+
+type FinalizedCheckpointEvent struct {
+	Block common.Root  `json:"block"`
+	State common.Root  `json:"state"`
+	Epoch common.Epoch `json:"epoch"`
+}
+
+type ChainReorgEvent struct {
+	Slot         common.Slot  `json:"slot"`
+	Depth        uint64       `json:"depth,string"`
+	OldHeadBlock common.Root  `json:"old_head_block"`
+	NewHeadBlock common.Root  `json:"new_head_block"`
+	OldHeadState common.Root  `json:"old_head_state"`
+	NewHeadState common.Root  `json:"new_head_state"`
+	Epoch        common.Epoch `json:"epoch"`
+}
+
+type event struct {
+	topic string
+	data  []byte
+}
+
+// eventBufferSize is the number of events that are buffered per subscriber.
+// Events are dropped for subscribers that do not keep up.
+const eventBufferSize = 64
+
+type eventSub struct {
+	topics map[string]bool
+	ch     chan event
+}
+
+type eventFeed struct {
+	mu   sync.Mutex
+	subs map[*eventSub]struct{}
+}
+
+func newEventFeed() *eventFeed {
+	return &eventFeed{subs: make(map[*eventSub]struct{})}
+}
+
+func (f *eventFeed) subscribe(topics map[string]bool) *eventSub {
+	sub := &eventSub{topics: topics, ch: make(chan event, eventBufferSize)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subs[sub] = struct{}{}
+	return sub
+}
+
+func (f *eventFeed) unsubscribe(sub *eventSub) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.subs, sub)
+}
+
+func (f *eventFeed) publish(ev event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for sub := range f.subs {
+		if !sub.topics[ev.topic] {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
+
+// Publish sends the event to the subscribers of the topic of the event stream.
+// Block, head and finalized checkpoint events of blocks that are submitted to the API,
+// and attestation and voluntary exit events of submitted operations, are published by the server itself.
+func (s *Server) Publish(topic string, data interface{}) error {
+	if !knownTopics[topic] {
+		return fmt.Errorf("unknown event topic %q", topic)
+	}
+	enc, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", topic, err)
+	}
+	s.events.publish(event{topic: topic, data: enc})
+	return nil
+}
+
+// publishImport publishes the events of a newly imported block
+func (s *Server) publishImport(benv *common.BeaconBlockEnvelope,
+	prevHead beacon.ChainEntry, prevFinalized common.Checkpoint) error {
+	if err := s.Publish(TopicBlock, &BlockEvent{Slot: benv.Slot, Block: benv.BlockRoot}); err != nil {
+		return err
+	}
+	head, err := s.chain.Head()
+	if err != nil {
+		return err
+	}
+	headRoot, err := head.BlockRoot()
+	if err != nil {
+		return err
+	}
+	prevHeadRoot, err := prevHead.BlockRoot()
+	if err != nil {
+		return err
+	}
+	if headRoot != prevHeadRoot {
+		stateRoot, err := head.StateRoot()
+		if err != nil {
+			return err
+		}
+		slot := head.Step().Slot()
+		ev := &HeadEvent{
+			Slot:            slot,
+			Block:           headRoot,
+			State:           stateRoot,
+			EpochTransition: s.spec.SlotToEpoch(slot) != s.spec.SlotToEpoch(prevHead.Step().Slot()),
+		}
+		if err := s.Publish(TopicHead, ev); err != nil {
+			return err
+		}
+	}
+	if fin := s.chain.FinalizedCheckpoint(); fin != prevFinalized {
+		entry, err := s.chain.Finalized()
+		if err != nil {
+			return err
+		}
+		stateRoot, err := entry.StateRoot()
+		if err != nil {
+			return err
+		}
+		if err := s.Publish(TopicFinalizedCheckpoint, &FinalizedCheckpointEvent{Block: fin.Root, State: stateRoot, Epoch: fin.Epoch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request, p params) error {
+	topics := make(map[string]bool)
+	for _, topic := range queryList(r, "topics") {
+		if !knownTopics[topic] {
+			return badRequest("unknown event topic %q", topic)
+		}
+		topics[topic] = true
+	}
+	if len(topics) == 0 {
+		return badRequest("no event topics")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return &Error{Code: http.StatusInternalServerError, Message: "streaming is not supported"}
+	}
+	sub := s.events.subscribe(topics)
+	defer s.events.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case ev := <-sub.ch:
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.topic, ev.data); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
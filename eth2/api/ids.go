@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+func parseRoot(v string) (common.Root, error) {
+	var root common.Root
+	if err := root.UnmarshalText([]byte(v)); err != nil {
+		return common.Root{}, badRequest("invalid root %q: %v", v, err)
+	}
+	return root, nil
+}
+
+func parseUint(name string, v string) (uint64, error) {
+	out, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, badRequest("invalid %s %q", name, v)
+	}
+	return out, nil
+}
+
+// canonSlotEntry finds the canonical entry at the slot, transitioning the latest canonical block
+// before the slot to the slot if the slot is empty.
+func (s *Server) canonSlotEntry(ctx context.Context, slot common.Slot) (beacon.ChainEntry, error) {
+	head, err := s.chain.Head()
+	if err != nil {
+		return nil, err
+	}
+	if slot > head.Step().Slot() {
+		return nil, notFound("slot %d is after the head", slot)
+	}
+	for _, block := range []bool{true, false} {
+		if entry, ok := s.chain.ByCanonStep(common.AsStep(slot, block)); ok && entry != nil {
+			return entry, nil
+		}
+	}
+	for prev := slot; prev > 0; {
+		prev--
+		entry, ok := s.chain.ByCanonStep(common.AsStep(prev, true))
+		if !ok {
+			break
+		}
+		if entry == nil {
+			continue
+		}
+		root, err := entry.BlockRoot()
+		if err != nil {
+			return nil, err
+		}
+		return s.chain.Towards(ctx, root, slot)
+	}
+	return nil, notFound("no canonical state at slot %d", slot)
+}
+
+// stateEntry resolves the state id: "head", "genesis", "finalized", "justified", a slot, or a state root.
+func (s *Server) stateEntry(ctx context.Context, stateID string) (beacon.ChainEntry, error) {
+	switch stateID {
+	case "head":
+		return s.chain.Head()
+	case "genesis":
+		entry, ok := s.chain.ByCanonStep(common.AsStep(common.GENESIS_SLOT, true))
+		if !ok || entry == nil {
+			return nil, notFound("genesis state is not available")
+		}
+		return entry, nil
+	case "finalized":
+		return s.chain.Finalized()
+	case "justified":
+		return s.chain.Justified()
+	}
+	if strings.HasPrefix(stateID, "0x") {
+		root, err := parseRoot(stateID)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := s.chain.ByStateRoot(root)
+		if !ok {
+			return nil, notFound("unknown state root %s", root)
+		}
+		return entry, nil
+	}
+	slot, err := parseUint("state id", stateID)
+	if err != nil {
+		return nil, err
+	}
+	return s.canonSlotEntry(ctx, common.Slot(slot))
+}
+
+func (s *Server) state(ctx context.Context, stateID string) (common.BeaconState, error) {
+	entry, err := s.stateEntry(ctx, stateID)
+	if err != nil {
+		return nil, err
+	}
+	return entry.State(ctx)
+}
+
+// blockEntry resolves the block id: "head", "genesis", "finalized", "justified", a slot, or a block root.
+// The entry is the post-block entry of the block.
+func (s *Server) blockEntry(blockID string) (beacon.ChainEntry, error) {
+	var root common.Root
+	switch blockID {
+	case "head":
+		head, err := s.chain.Head()
+		if err != nil {
+			return nil, err
+		}
+		if root, err = head.BlockRoot(); err != nil {
+			return nil, err
+		}
+	case "genesis":
+		entry, ok := s.chain.ByCanonStep(common.AsStep(common.GENESIS_SLOT, true))
+		if !ok || entry == nil {
+			return nil, notFound("genesis block is not available")
+		}
+		return entry, nil
+	case "finalized":
+		root = s.chain.FinalizedCheckpoint().Root
+	case "justified":
+		root = s.chain.JustifiedCheckpoint().Root
+	default:
+		if strings.HasPrefix(blockID, "0x") {
+			var err error
+			if root, err = parseRoot(blockID); err != nil {
+				return nil, err
+			}
+		} else {
+			slot, err := parseUint("block id", blockID)
+			if err != nil {
+				return nil, err
+			}
+			entry, ok := s.chain.ByCanonStep(common.AsStep(common.Slot(slot), true))
+			if !ok || entry == nil {
+				return nil, notFound("no canonical block at slot %d", slot)
+			}
+			return entry, nil
+		}
+	}
+	entry, ok := s.chain.ByBlock(root)
+	if !ok {
+		return nil, notFound("unknown block %s", root)
+	}
+	return entry, nil
+}
+
+// isCanonical checks if the block of the entry is the canonical block at its slot
+func (s *Server) isCanonical(entry beacon.ChainEntry) (bool, error) {
+	canon, ok := s.chain.ByCanonStep(common.AsStep(entry.Step().Slot(), true))
+	if !ok || canon == nil {
+		return false, nil
+	}
+	canonRoot, err := canon.BlockRoot()
+	if err != nil {
+		return false, err
+	}
+	root, err := entry.BlockRoot()
+	if err != nil {
+		return false, err
+	}
+	return canonRoot == root, nil
+}
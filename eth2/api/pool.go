@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/pool"
+)
+
+func poolUnavailable(name string) *Error {
+	return &Error{Code: http.StatusNotImplemented, Message: name + " pool is not available"}
+}
+
+// submitEach adds each item of the submission, and collects the failures of individual items.
+func submitEach(count int, add func(i int) error) error {
+	var failures []IndexedError
+	for i := 0; i < count; i++ {
+		if err := add(i); err != nil {
+			failures = append(failures, IndexedError{Index: uint64(i), Message: err.Error()})
+		}
+	}
+	if len(failures) > 0 {
+		return &Error{Code: http.StatusBadRequest, Message: "some items failed to be submitted", Failures: failures}
+	}
+	return nil
+}
+
+func (s *Server) handlePoolAttestations(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.Attestations == nil {
+		return poolUnavailable("attestation")
+	}
+	q := r.URL.Query()
+	var opts []pool.AttSearchOption
+	if v := q.Get("slot"); v != "" {
+		slot, err := parseUint("slot", v)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pool.WithSlot(common.Slot(slot)))
+	}
+	if v := q.Get("committee_index"); v != "" {
+		index, err := parseUint("committee index", v)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pool.WithCommittee(common.CommitteeIndex(index)))
+	}
+	out := s.pools.Attestations.Search(opts...)
+	if out == nil {
+		out = []*phase0.Attestation{}
+	}
+	return writeData(w, out)
+}
+
+// committee gets the committee of the attestation data, from the shuffling of the head.
+func (s *Server) committee(ctx context.Context, data *phase0.AttestationData) (common.CommitteeIndices, error) {
+	head, err := s.chain.Head()
+	if err != nil {
+		return nil, err
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return epc.GetBeaconCommittee(data.Slot, data.Index)
+}
+
+func (s *Server) handleSubmitAttestations(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.Attestations == nil {
+		return poolUnavailable("attestation")
+	}
+	var atts []phase0.Attestation
+	if err := decodeBody(r, &atts); err != nil {
+		return err
+	}
+	err := submitEach(len(atts), func(i int) error {
+		att := &atts[i]
+		committee, err := s.committee(r.Context(), &att.Data)
+		if err != nil {
+			return err
+		}
+		if err := s.pools.Attestations.AddAttestation(r.Context(), att, committee); err != nil {
+			return err
+		}
+		return s.Publish(TopicAttestation, att)
+	})
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) handlePoolAttesterSlashings(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.AttesterSlashings == nil {
+		return poolUnavailable("attester slashing")
+	}
+	out := s.pools.AttesterSlashings.All()
+	if out == nil {
+		out = []*phase0.AttesterSlashing{}
+	}
+	return writeData(w, out)
+}
+
+func (s *Server) handleSubmitAttesterSlashing(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.AttesterSlashings == nil {
+		return poolUnavailable("attester slashing")
+	}
+	var sl phase0.AttesterSlashing
+	if err := decodeBody(r, &sl); err != nil {
+		return err
+	}
+	if err := s.pools.AttesterSlashings.AddAttesterSlashing(r.Context(), &sl); err != nil {
+		return badRequest("invalid attester slashing: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) handlePoolProposerSlashings(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.ProposerSlashings == nil {
+		return poolUnavailable("proposer slashing")
+	}
+	out := s.pools.ProposerSlashings.All()
+	if out == nil {
+		out = []*phase0.ProposerSlashing{}
+	}
+	return writeData(w, out)
+}
+
+func (s *Server) handleSubmitProposerSlashing(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.ProposerSlashings == nil {
+		return poolUnavailable("proposer slashing")
+	}
+	var sl phase0.ProposerSlashing
+	if err := decodeBody(r, &sl); err != nil {
+		return err
+	}
+	if err := s.pools.ProposerSlashings.AddProposerSlashing(r.Context(), &sl); err != nil {
+		return badRequest("invalid proposer slashing: %v", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) handlePoolVoluntaryExits(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.VoluntaryExits == nil {
+		return poolUnavailable("voluntary exit")
+	}
+	out := s.pools.VoluntaryExits.All()
+	if out == nil {
+		out = []*phase0.SignedVoluntaryExit{}
+	}
+	return writeData(w, out)
+}
+
+func (s *Server) handleSubmitVoluntaryExit(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.VoluntaryExits == nil {
+		return poolUnavailable("voluntary exit")
+	}
+	var exit phase0.SignedVoluntaryExit
+	if err := decodeBody(r, &exit); err != nil {
+		return err
+	}
+	if err := s.pools.VoluntaryExits.AddVoluntaryExit(r.Context(), &exit); err != nil {
+		return badRequest("invalid voluntary exit: %v", err)
+	}
+	if err := s.Publish(TopicVoluntaryExit, &exit); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) handleSubmitSyncCommitteeMessages(w http.ResponseWriter, r *http.Request, p params) error {
+	if s.pools.SyncCommittees == nil {
+		return poolUnavailable("sync committee")
+	}
+	var msgs []altair.SyncCommitteeMessage
+	if err := decodeBody(r, &msgs); err != nil {
+		return err
+	}
+	err := submitEach(len(msgs), func(i int) error {
+		return s.pools.SyncCommittees.AddSyncCommitteeMessage(r.Context(), &msgs[i])
+	})
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
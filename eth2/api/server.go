@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/pool"
+)
+
+// BlockImporter imports the blocks that are submitted to the API, e.g. *chain.HotChain.
+type BlockImporter interface {
+	AddBlock(ctx context.Context, benv *common.BeaconBlockEnvelope, validateResult bool) error
+}
+
+// Pools are the operation pools that are served by the API. Nil pools are not served.
+type Pools struct {
+	Attestations      *pool.AttestationPool
+	AttesterSlashings *pool.AttesterSlashingPool
+	ProposerSlashings *pool.ProposerSlashingPool
+	VoluntaryExits    *pool.VoluntaryExitPool
+	SyncCommittees    *pool.SyncCommitteePool
+}
+
+// Server serves the standard eth2 Beacon Node API, see https://github.com/ethereum/beacon-APIs
+type Server struct {
+	spec     *common.Spec
+	chain    beacon.Chain
+	pools    Pools
+	importer BlockImporter
+	decoder  *beacon.ForkDecoder
+	events   *eventFeed
+	routes   []route
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates an API server on top of the chain.
+// The importer is optional, block submissions are refused if it is nil.
+func NewServer(spec *common.Spec, chain beacon.Chain, pools Pools, importer BlockImporter) *Server {
+	s := &Server{
+		spec:     spec,
+		chain:    chain,
+		pools:    pools,
+		importer: importer,
+		decoder:  beacon.NewForkDecoder(spec, chain.Genesis().ValidatorsRoot),
+		events:   newEventFeed(),
+	}
+	s.routes = []route{
+		{"GET", "/eth/v1/beacon/genesis", s.handleGenesis},
+		{"GET", "/eth/v1/beacon/states/{state_id}/root", s.handleStateRoot},
+		{"GET", "/eth/v1/beacon/states/{state_id}/fork", s.handleStateFork},
+		{"GET", "/eth/v1/beacon/states/{state_id}/finality_checkpoints", s.handleFinalityCheckpoints},
+		{"GET", "/eth/v1/beacon/states/{state_id}/validators", s.handleValidators},
+		{"GET", "/eth/v1/beacon/states/{state_id}/validators/{validator_id}", s.handleValidator},
+		{"GET", "/eth/v1/beacon/states/{state_id}/validator_balances", s.handleValidatorBalances},
+		{"GET", "/eth/v1/beacon/states/{state_id}/committees", s.handleCommittees},
+		{"GET", "/eth/v1/beacon/states/{state_id}/sync_committees", s.handleSyncCommittees},
+		{"GET", "/eth/v1/beacon/headers", s.handleHeaders},
+		{"GET", "/eth/v1/beacon/headers/{block_id}", s.handleHeader},
+		{"POST", "/eth/v1/beacon/blocks", s.handleSubmitBlock},
+		{"GET", "/eth/v2/beacon/blocks/{block_id}", s.handleBlock},
+		{"GET", "/eth/v1/beacon/blocks/{block_id}/root", s.handleBlockRoot},
+		{"GET", "/eth/v2/debug/beacon/states/{state_id}", s.handleDebugState},
+		{"GET", "/eth/v1/beacon/pool/attestations", s.handlePoolAttestations},
+		{"POST", "/eth/v1/beacon/pool/attestations", s.handleSubmitAttestations},
+		{"GET", "/eth/v1/beacon/pool/attester_slashings", s.handlePoolAttesterSlashings},
+		{"POST", "/eth/v1/beacon/pool/attester_slashings", s.handleSubmitAttesterSlashing},
+		{"GET", "/eth/v1/beacon/pool/proposer_slashings", s.handlePoolProposerSlashings},
+		{"POST", "/eth/v1/beacon/pool/proposer_slashings", s.handleSubmitProposerSlashing},
+		{"GET", "/eth/v1/beacon/pool/voluntary_exits", s.handlePoolVoluntaryExits},
+		{"POST", "/eth/v1/beacon/pool/voluntary_exits", s.handleSubmitVoluntaryExit},
+		{"POST", "/eth/v1/beacon/pool/sync_committees", s.handleSubmitSyncCommitteeMessages},
+		{"GET", "/eth/v1/events", s.handleEvents},
+	}
+	return s
+}
+
+// params are the values of the path parameters of a route, by name
+type params map[string]string
+
+type handlerFn func(w http.ResponseWriter, r *http.Request, p params) error
+
+type route struct {
+	method  string
+	pattern string
+	handler handlerFn
+}
+
+// match the path against the route pattern, path parameters are enclosed in braces.
+func (rt *route) match(path string) (params, bool) {
+	patternParts := strings.Split(strings.Trim(rt.pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	var p params
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			if p == nil {
+				p = make(params)
+			}
+			p[part[1:len(part)-1]] = pathParts[i]
+		} else if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	methodMismatch := false
+	for i := range s.routes {
+		rt := &s.routes[i]
+		p, ok := rt.match(r.URL.Path)
+		if !ok {
+			continue
+		}
+		if rt.method != r.Method {
+			methodMismatch = true
+			continue
+		}
+		if err := rt.handler(w, r, p); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+	if methodMismatch {
+		writeError(w, &Error{Code: http.StatusMethodNotAllowed, Message: fmt.Sprintf("method %s not allowed", r.Method)})
+	} else {
+		writeError(w, &Error{Code: http.StatusNotFound, Message: fmt.Sprintf("unknown route %s", r.URL.Path)})
+	}
+}
+
+// Error is the error response of the API.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	// Failures of the individual items of a submission, if any.
+	Failures []IndexedError `json:"failures,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+type IndexedError struct {
+	Index   uint64 `json:"index"`
+	Message string `json:"message"`
+}
+
+func badRequest(format string, args ...interface{}) *Error {
+	return &Error{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) *Error {
+	return &Error{Code: http.StatusNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = &Error{Code: http.StatusInternalServerError, Message: err.Error()}
+	}
+	writeJSON(w, apiErr.Code, apiErr)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Response wraps the data of all successful API responses.
+type Response struct {
+	// Fork name of the data, only used for versioned responses.
+	Version string      `json:"version,omitempty"`
+	Data    interface{} `json:"data"`
+}
+
+func writeData(w http.ResponseWriter, data interface{}) error {
+	writeJSON(w, http.StatusOK, &Response{Data: data})
+	return nil
+}
+
+// ForkName is the lower-case name of the fork of the epoch, as used in versioned API responses.
+// An error is returned for epochs of forks that have no API representation, like sharding.
+func ForkName(spec *common.Spec, epoch common.Epoch) (string, error) {
+	if epoch < spec.ALTAIR_FORK_EPOCH {
+		return "phase0", nil
+	} else if epoch < spec.BELLATRIX_FORK_EPOCH {
+		return "altair", nil
+	} else if epoch < spec.CAPELLA_FORK_EPOCH {
+		return "bellatrix", nil
+	} else if epoch < spec.SHARDING_FORK_EPOCH {
+		return "capella", nil
+	} else {
+		return "", fmt.Errorf("unknown fork at epoch %d", epoch)
+	}
+}
+
+func decodeBody(r *http.Request, dst interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	return nil
+}
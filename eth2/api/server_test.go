@@ -0,0 +1,430 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/chain"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/pool"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+)
+
+type testNode struct {
+	spec    *common.Spec
+	keys    []*blsu.SecretKey
+	pubkeys []common.BLSPubkey
+	chain   *chain.HotChain
+	pools   Pools
+	api     *Server
+	server  *httptest.Server
+}
+
+func newTestNode(t *testing.T) *testNode {
+	spec := configs.Minimal
+	n := &testNode{spec: spec}
+	rawKeys := make([][32]byte, 64)
+	vals := make([]phase0.KickstartValidatorData, len(rawKeys))
+	for i := range rawKeys {
+		rawKeys[i][31] = byte(i + 1)
+		sk := new(blsu.SecretKey)
+		if err := sk.Deserialize(&rawKeys[i]); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.keys = append(n.keys, sk)
+		n.pubkeys = append(n.pubkeys, pub.Serialize())
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	genesis, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 1000, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.chain, err = chain.NewHotChain(spec, genesis, epc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.pools = Pools{
+		Attestations:      pool.NewAttestationPool(spec),
+		AttesterSlashings: pool.NewAttesterSlashingPool(spec),
+		ProposerSlashings: pool.NewProposerSlashingPool(spec),
+		VoluntaryExits:    pool.NewVoluntaryExitPool(spec),
+		SyncCommittees:    pool.NewSyncCommitteePool(spec),
+	}
+	n.api = NewServer(spec, n.chain, n.pools, n.chain)
+	n.server = httptest.NewServer(n.api)
+	t.Cleanup(n.server.Close)
+	return n
+}
+
+func (n *testNode) sign(index common.ValidatorIndex, root common.Root, domType common.BLSDomainType, slot common.Slot) common.BLSSignature {
+	dom := common.ComputeDomain(domType, n.spec.ForkVersion(slot), n.chain.Genesis().ValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(root, dom)
+	return blsu.Sign(n.keys[index], signingRoot[:]).Serialize()
+}
+
+// buildBlock builds and signs a block on top of the head block
+func (n *testNode) buildBlock(t *testing.T, slot common.Slot) *phase0.SignedBeaconBlock {
+	ctx := context.Background()
+	headEntry, err := n.chain.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headRoot, _ := headEntry.BlockRoot()
+	head, ok := n.chain.ByBlock(headRoot)
+	if !ok {
+		t.Fatal("missing head block")
+	}
+	state, err := head.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := epc.LoadProposers(state); err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := n.spec.SlotToEpoch(slot)
+	reveal := n.sign(proposer, epoch.HashTreeRoot(tree.GetHashFn()), common.DOMAIN_RANDAO, slot)
+	block, err := beacon.BuildBlock(ctx, n.spec, epc, state, slot, reveal, common.Root{}, &beacon.BlockSources{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := block.(*phase0.BeaconBlock)
+	return &phase0.SignedBeaconBlock{
+		Message:   *b,
+		Signature: n.sign(proposer, b.HashTreeRoot(n.spec, tree.GetHashFn()), common.DOMAIN_BEACON_PROPOSER, slot),
+	}
+}
+
+func (n *testNode) get(t *testing.T, path string, expectCode int, dst interface{}) {
+	t.Helper()
+	resp, err := http.Get(n.server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != expectCode {
+		t.Fatalf("GET %s: expected status %d, got %d: %s", path, expectCode, resp.StatusCode, body)
+	}
+	if dst != nil {
+		if err := json.Unmarshal(body, &Response{Data: dst}); err != nil {
+			t.Fatalf("GET %s: failed to decode response: %v", path, err)
+		}
+	}
+}
+
+func (n *testNode) post(t *testing.T, path string, contentType string, body []byte, expectCode int) {
+	t.Helper()
+	resp, err := http.Post(n.server.URL+path, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectCode {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("POST %s: expected status %d, got %d: %s", path, expectCode, resp.StatusCode, msg)
+	}
+}
+
+func TestStateEndpoints(t *testing.T) {
+	n := newTestNode(t)
+
+	resp, err := http.Get(n.server.URL + "/eth/v1/beacon/genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	// numbers are encoded as decimal strings, roots and versions as 0x-prefixed hex
+	if !strings.Contains(string(raw), `"genesis_time":"1000"`) ||
+		!strings.Contains(string(raw), `"genesis_fork_version":"0x00000001"`) {
+		t.Fatalf("unexpected genesis response: %s", raw)
+	}
+
+	head, err := n.chain.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRoot, _ := head.StateRoot()
+	var root RootResponse
+	n.get(t, "/eth/v1/beacon/states/head/root", 200, &root)
+	if root.Root != expectedRoot {
+		t.Fatalf("expected state root %s, got %s", expectedRoot, root.Root)
+	}
+	n.get(t, "/eth/v1/beacon/states/"+expectedRoot.String()+"/root", 200, &root)
+	n.get(t, "/eth/v1/beacon/states/0/root", 200, &root)
+	if root.Root != expectedRoot {
+		t.Fatalf("expected genesis state root %s, got %s", expectedRoot, root.Root)
+	}
+	n.get(t, "/eth/v1/beacon/states/unknown/root", 400, nil)
+	n.get(t, "/eth/v1/beacon/states/100/root", 404, nil)
+
+	var fork common.Fork
+	n.get(t, "/eth/v1/beacon/states/genesis/fork", 200, &fork)
+	if fork.CurrentVersion != n.spec.GENESIS_FORK_VERSION {
+		t.Fatalf("unexpected fork: %v", fork)
+	}
+	var checkpoints FinalityCheckpoints
+	n.get(t, "/eth/v1/beacon/states/finalized/finality_checkpoints", 200, &checkpoints)
+
+	var validators []ValidatorResponse
+	n.get(t, "/eth/v1/beacon/states/head/validators?status=active", 200, &validators)
+	if len(validators) != len(n.pubkeys) {
+		t.Fatalf("expected %d active validators, got %d", len(n.pubkeys), len(validators))
+	}
+	if validators[3].Status != StatusActiveOngoing || validators[3].Validator.Pubkey != n.pubkeys[3] {
+		t.Fatalf("unexpected validator: %+v", validators[3])
+	}
+	n.get(t, "/eth/v1/beacon/states/head/validators?status=pending,exited", 200, &validators)
+	if len(validators) != 0 {
+		t.Fatalf("expected no pending or exited validators, got %d", len(validators))
+	}
+	n.get(t, "/eth/v1/beacon/states/head/validators?id=5&id="+n.pubkeys[7].String(), 200, &validators)
+	if len(validators) != 2 || validators[0].Index != 5 || validators[1].Index != 7 {
+		t.Fatalf("unexpected validators: %+v", validators)
+	}
+	var validator ValidatorResponse
+	n.get(t, "/eth/v1/beacon/states/head/validators/"+n.pubkeys[9].String(), 200, &validator)
+	if validator.Index != 9 || validator.Balance != n.spec.MAX_EFFECTIVE_BALANCE {
+		t.Fatalf("unexpected validator: %+v", validator)
+	}
+	n.get(t, "/eth/v1/beacon/states/head/validators/1000", 404, nil)
+	var balances []ValidatorBalance
+	n.get(t, "/eth/v1/beacon/states/head/validator_balances?id=1,2", 200, &balances)
+	if len(balances) != 2 || balances[1].Index != 2 {
+		t.Fatalf("unexpected balances: %+v", balances)
+	}
+
+	var committees []Committee
+	n.get(t, "/eth/v1/beacon/states/head/committees?epoch=1", 200, &committees)
+	total := 0
+	for _, c := range committees {
+		total += len(c.Validators)
+	}
+	if total != len(n.pubkeys) {
+		t.Fatalf("expected all %d validators in committees, got %d", len(n.pubkeys), total)
+	}
+	n.get(t, "/eth/v1/beacon/states/head/committees?slot=2&index=0", 200, &committees)
+	if len(committees) != 1 || committees[0].Slot != 2 || committees[0].Index != 0 {
+		t.Fatalf("unexpected committees: %+v", committees)
+	}
+	n.get(t, "/eth/v1/beacon/states/head/committees?epoch=5", 400, nil)
+	// phase0 states have no sync committees
+	n.get(t, "/eth/v1/beacon/states/head/sync_committees", 400, nil)
+
+	n.get(t, "/eth/v1/unknown", 404, nil)
+	n.post(t, "/eth/v1/beacon/genesis", "application/json", nil, 405)
+}
+
+func TestBlockEndpoints(t *testing.T) {
+	n := newTestNode(t)
+
+	// the genesis block is not stored, its header is available from the state
+	var header HeaderResponse
+	n.get(t, "/eth/v1/beacon/headers/genesis", 200, &header)
+	if !header.Canonical || header.Header.Message.Slot != 0 {
+		t.Fatalf("unexpected genesis header: %+v", header)
+	}
+	genesisRoot := header.Root
+	n.get(t, "/eth/v2/beacon/blocks/genesis", 404, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", n.server.URL+"/eth/v1/events?topics=block,voluntary_exit", nil)
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if stream.StatusCode != 200 {
+		t.Fatalf("unexpected event stream status %d", stream.StatusCode)
+	}
+
+	block := n.buildBlock(t, 1)
+	blockRoot := block.Message.HashTreeRoot(n.spec, tree.GetHashFn())
+	var buf bytes.Buffer
+	if err := block.Serialize(n.spec, codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	n.post(t, "/eth/v1/beacon/blocks", "application/octet-stream", buf.Bytes(), 200)
+
+	lines := bufio.NewScanner(stream.Body)
+	var events []string
+	for len(events) < 1 && lines.Scan() {
+		if line := lines.Text(); strings.HasPrefix(line, "event: ") {
+			events = append(events, line[len("event: "):])
+		} else if strings.HasPrefix(line, "data: ") && !strings.Contains(line, blockRoot.String()) {
+			t.Fatalf("unexpected event data: %s", line)
+		}
+	}
+	if len(events) != 1 || events[0] != TopicBlock {
+		t.Fatalf("unexpected events: %v", events)
+	}
+
+	// Without votes the head may stay at the empty slot after the parent, vote for the block to make it the head.
+	for i := range n.keys {
+		n.chain.ProcessAttestation(common.ValidatorIndex(i), blockRoot, 1, 0)
+	}
+
+	var root RootResponse
+	n.get(t, "/eth/v1/beacon/blocks/head/root", 200, &root)
+	if root.Root != blockRoot {
+		t.Fatalf("expected head block %s, got %s", blockRoot, root.Root)
+	}
+	var headers []HeaderResponse
+	n.get(t, "/eth/v1/beacon/headers?parent_root="+genesisRoot.String(), 200, &headers)
+	if len(headers) != 1 || headers[0].Root != blockRoot || !headers[0].Canonical || headers[0].Header.Signature != block.Signature {
+		t.Fatalf("unexpected headers: %+v", headers)
+	}
+
+	var got phase0.SignedBeaconBlock
+	resp, err := http.Get(n.server.URL + "/eth/v2/beacon/blocks/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versioned Response
+	versioned.Data = &got
+	if err := json.NewDecoder(resp.Body).Decode(&versioned); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if versioned.Version != "phase0" || got.Message.HashTreeRoot(n.spec, tree.GetHashFn()) != blockRoot {
+		t.Fatalf("unexpected block response, version %q", versioned.Version)
+	}
+	req, _ = http.NewRequest("GET", n.server.URL+"/eth/v2/beacon/blocks/"+blockRoot.String(), nil)
+	req.Header.Set("Accept", "application/octet-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(raw, buf.Bytes()) || resp.Header.Get("Eth-Consensus-Version") != "phase0" {
+		t.Fatal("unexpected SSZ block response")
+	}
+
+	// JSON submissions are decoded the same way, invalid blocks are refused
+	block2 := n.buildBlock(t, 2)
+	block2.Signature = common.BLSSignature{}
+	data, _ := json.Marshal(block2)
+	n.post(t, "/eth/v1/beacon/blocks", "application/json", data, 400)
+	block2 = n.buildBlock(t, 2)
+	data, _ = json.Marshal(block2)
+	n.post(t, "/eth/v1/beacon/blocks", "application/json", data, 200)
+	n.get(t, "/eth/v1/beacon/blocks/2/root", 200, &root)
+	if root.Root != block2.Message.HashTreeRoot(n.spec, tree.GetHashFn()) {
+		t.Fatal("expected JSON submitted block at slot 2")
+	}
+}
+
+func TestPoolEndpoints(t *testing.T) {
+	n := newTestNode(t)
+
+	exit := phase0.SignedVoluntaryExit{Message: phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 4}}
+	data, _ := json.Marshal(&exit)
+	n.post(t, "/eth/v1/beacon/pool/voluntary_exits", "application/json", data, 200)
+	n.post(t, "/eth/v1/beacon/pool/voluntary_exits", "application/json", data, 400)
+	var exits []phase0.SignedVoluntaryExit
+	n.get(t, "/eth/v1/beacon/pool/voluntary_exits", 200, &exits)
+	if len(exits) != 1 || exits[0].Message.ValidatorIndex != 4 {
+		t.Fatalf("unexpected exits: %+v", exits)
+	}
+
+	head, err := n.chain.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headRoot, _ := head.BlockRoot()
+	epc, err := head.EpochsContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	committee, err := epc.GetBeaconCommittee(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newBits := func() phase0.AttestationBits {
+		bits := make(phase0.AttestationBits, (len(committee)>>3)+1)
+		bits.SetBit(uint64(len(committee)), true)
+		return bits
+	}
+	bits := newBits()
+	bits.SetBit(0, true)
+	atts := []phase0.Attestation{
+		{AggregationBits: bits, Data: phase0.AttestationData{Slot: 1, Index: 0, BeaconBlockRoot: headRoot}},
+		// empty attestations are refused by the pool
+		{AggregationBits: newBits(), Data: phase0.AttestationData{Slot: 1}},
+	}
+	data, _ = json.Marshal(atts)
+	resp, err := http.Post(n.server.URL+"/eth/v1/beacon/pool/attestations", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var apiErr Error
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if apiErr.Code != 400 || len(apiErr.Failures) != 1 || apiErr.Failures[0].Index != 1 {
+		t.Fatalf("unexpected submission error: %+v", apiErr)
+	}
+	var pooled []phase0.Attestation
+	n.get(t, "/eth/v1/beacon/pool/attestations?slot=1", 200, &pooled)
+	if len(pooled) != 1 || pooled[0].Data.BeaconBlockRoot != headRoot {
+		t.Fatalf("unexpected pooled attestations: %+v", pooled)
+	}
+	n.get(t, "/eth/v1/beacon/pool/attestations?slot=2", 200, &pooled)
+	if len(pooled) != 0 {
+		t.Fatalf("expected no attestations at slot 2, got %d", len(pooled))
+	}
+
+	var slashings []phase0.ProposerSlashing
+	n.get(t, "/eth/v1/beacon/pool/proposer_slashings", 200, &slashings)
+	if len(slashings) != 0 {
+		t.Fatalf("expected no proposer slashings, got %d", len(slashings))
+	}
+}
+
+func TestForkName(t *testing.T) {
+	spec := *configs.Mainnet
+	spec.ALTAIR_FORK_EPOCH = 1
+	spec.BELLATRIX_FORK_EPOCH = 2
+	spec.CAPELLA_FORK_EPOCH = 3
+	spec.SHARDING_FORK_EPOCH = 4
+	for epoch, expected := range []string{"phase0", "altair", "bellatrix", "capella"} {
+		name, err := ForkName(&spec, common.Epoch(epoch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected {
+			t.Errorf("epoch %d: expected fork %q, got %q", epoch, expected, name)
+		}
+	}
+	if name, err := ForkName(&spec, 4); err == nil {
+		t.Errorf("expected error for unknown fork, got %q", name)
+	}
+}
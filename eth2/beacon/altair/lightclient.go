@@ -67,6 +67,8 @@ func (lcs *LightClientSnapshot) HashTreeRoot(spec *common.Spec, hFn tree.HashFn)
 // This is padded to 32, a depth of 5 bits
 const syncCommitteeProofLen = 5
 
+const CURRENT_SYNC_COMMITTEE_INDEX = tree.Gindex64((1 << syncCommitteeProofLen) | _currentSyncCommittee)
+
 const NEXT_SYNC_COMMITTEE_INDEX = tree.Gindex64((1 << syncCommitteeProofLen) | _nextSyncCommittee)
 
 var SyncCommitteeProofBranchType = VectorType(RootType, syncCommitteeProofLen)
@@ -134,6 +136,62 @@ func (fb FinalizedRootProofBranch) HashTreeRoot(hFn tree.HashFn) common.Root {
 	}, finalizedRootProofLen)
 }
 
+func LightClientBootstrapType(spec *common.Spec) *ContainerTypeDef {
+	return ContainerType("LightClientBootstrap", []FieldDef{
+		{"header", common.BeaconBlockHeaderType},
+		{"current_sync_committee", common.SyncCommitteeType(spec)},
+		{"current_sync_committee_branch", SyncCommitteeProofBranchType},
+	})
+}
+
+type LightClientBootstrap struct {
+	// Beacon block header that the light client trusts
+	Header common.BeaconBlockHeader `yaml:"header" json:"header"`
+	// Current sync committee corresponding to the header
+	CurrentSyncCommittee       common.SyncCommittee     `yaml:"current_sync_committee" json:"current_sync_committee"`
+	CurrentSyncCommitteeBranch SyncCommitteeProofBranch `yaml:"current_sync_committee_branch" json:"current_sync_committee_branch"`
+}
+
+func (lcb *LightClientBootstrap) Deserialize(spec *common.Spec, dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(
+		&lcb.Header,
+		spec.Wrap(&lcb.CurrentSyncCommittee),
+		&lcb.CurrentSyncCommitteeBranch,
+	)
+}
+
+func (lcb *LightClientBootstrap) Serialize(spec *common.Spec, w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(
+		&lcb.Header,
+		spec.Wrap(&lcb.CurrentSyncCommittee),
+		&lcb.CurrentSyncCommitteeBranch,
+	)
+}
+
+func (lcb *LightClientBootstrap) ByteLength(spec *common.Spec) uint64 {
+	return codec.ContainerLength(
+		&lcb.Header,
+		spec.Wrap(&lcb.CurrentSyncCommittee),
+		&lcb.CurrentSyncCommitteeBranch,
+	)
+}
+
+func (lcb *LightClientBootstrap) FixedLength(spec *common.Spec) uint64 {
+	return codec.ContainerLength(
+		&lcb.Header,
+		spec.Wrap(&lcb.CurrentSyncCommittee),
+		&lcb.CurrentSyncCommitteeBranch,
+	)
+}
+
+func (lcb *LightClientBootstrap) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) common.Root {
+	return hFn.HashTreeRoot(
+		&lcb.Header,
+		spec.Wrap(&lcb.CurrentSyncCommittee),
+		&lcb.CurrentSyncCommitteeBranch,
+	)
+}
+
 func LightClientUpdateType(spec *common.Spec) *ContainerTypeDef {
 	return ContainerType("SyncCommittee", []FieldDef{
 		{"attested_header", common.BeaconBlockHeaderType},
@@ -142,7 +200,7 @@ func LightClientUpdateType(spec *common.Spec) *ContainerTypeDef {
 		{"finalized_header", common.BeaconBlockHeaderType},
 		{"finality_branch", FinalizedRootProofBranchType},
 		{"sync_aggregate", SyncAggregateType(spec)},
-		{"fork_version", common.VersionType},
+		{"signature_slot", common.SlotType},
 	})
 }
 
@@ -157,8 +215,8 @@ type LightClientUpdate struct {
 	FinalityBranch  FinalizedRootProofBranch `yaml:"finality_branch" json:"finality_branch"`
 	// Sync committee aggregate signature
 	SyncAggregate SyncAggregate `yaml:"sync_aggregate" json:"sync_aggregate"`
-	// Fork version for the aggregate signature
-	ForkVersion common.Version `yaml:"fork_version" json:"fork_version"`
+	// Slot at which the aggregate signature was created (untrusted)
+	SignatureSlot common.Slot `yaml:"signature_slot" json:"signature_slot"`
 }
 
 func (lcu *LightClientUpdate) Deserialize(spec *common.Spec, dr *codec.DecodingReader) error {
@@ -169,7 +227,7 @@ func (lcu *LightClientUpdate) Deserialize(spec *common.Spec, dr *codec.DecodingR
 		&lcu.FinalizedHeader,
 		&lcu.FinalityBranch,
 		spec.Wrap(&lcu.SyncAggregate),
-		&lcu.ForkVersion,
+		&lcu.SignatureSlot,
 	)
 }
 
@@ -181,7 +239,7 @@ func (lcu *LightClientUpdate) Serialize(spec *common.Spec, w *codec.EncodingWrit
 		&lcu.FinalizedHeader,
 		&lcu.FinalityBranch,
 		spec.Wrap(&lcu.SyncAggregate),
-		&lcu.ForkVersion,
+		&lcu.SignatureSlot,
 	)
 }
 
@@ -193,7 +251,7 @@ func (lcu *LightClientUpdate) ByteLength(spec *common.Spec) uint64 {
 		&lcu.FinalizedHeader,
 		&lcu.FinalityBranch,
 		spec.Wrap(&lcu.SyncAggregate),
-		&lcu.ForkVersion,
+		&lcu.SignatureSlot,
 	)
 }
 
@@ -205,7 +263,7 @@ func (lcu *LightClientUpdate) FixedLength(spec *common.Spec) uint64 {
 		&lcu.FinalizedHeader,
 		&lcu.FinalityBranch,
 		spec.Wrap(&lcu.SyncAggregate),
-		&lcu.ForkVersion,
+		&lcu.SignatureSlot,
 	)
 }
 
@@ -217,6 +275,6 @@ func (lcu *LightClientUpdate) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) c
 		&lcu.FinalizedHeader,
 		&lcu.FinalityBranch,
 		spec.Wrap(&lcu.SyncAggregate),
-		&lcu.ForkVersion,
+		&lcu.SignatureSlot,
 	)
 }
@@ -2,10 +2,8 @@ package altair
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
-	blsu "github.com/protolambda/bls12-381-util"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/ztyp/bitfields"
 	"github.com/protolambda/ztyp/codec"
@@ -83,14 +81,10 @@ func ProcessSyncAggregate(ctx context.Context, spec *common.Spec, epc *common.Ep
 		return fmt.Errorf("missing current sync committee info in EPC")
 	}
 
-	participantPubkeys := make([]*blsu.Pubkey, 0, spec.SYNC_COMMITTEE_SIZE)
+	participantPubkeys := make([]*common.CachedPubkey, 0, spec.SYNC_COMMITTEE_SIZE)
 	for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
 		if agg.SyncCommitteeBits.GetBit(i) {
-			pub, err := epc.CurrentSyncCommittee.CachedPubkeys[i].Pubkey()
-			if err != nil {
-				return fmt.Errorf("failed to decode cached pubkey in sync-committee: %v", err)
-			}
-			participantPubkeys = append(participantPubkeys, pub)
+			participantPubkeys = append(participantPubkeys, epc.CurrentSyncCommittee.CachedPubkeys[i])
 		}
 	}
 
@@ -103,13 +97,13 @@ func ProcessSyncAggregate(ctx context.Context, spec *common.Spec, epc *common.Ep
 	if err != nil {
 		return err
 	}
-	signingRoot := common.ComputeSigningRoot(blockRoot, domain)
-	sig, err := agg.SyncCommitteeSignature.Signature()
-	if err != nil {
-		return fmt.Errorf("failed to decode and sub-group check sync committee signature: %v", err)
-	}
-	if !blsu.Eth2FastAggregateVerify(participantPubkeys, signingRoot[:], sig) {
-		return errors.New("invalid sync committee signature")
+	if err := common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     participantPubkeys,
+		SigningRoot: common.ComputeSigningRoot(blockRoot, domain),
+		Signature:   agg.SyncCommitteeSignature,
+		Description: "invalid sync committee signature",
+	}); err != nil {
+		return err
 	}
 
 	// Compute participant and proposer rewards
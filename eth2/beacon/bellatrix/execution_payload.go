@@ -69,10 +69,10 @@ func ProcessExecutionPayload(ctx context.Context, spec *common.Spec, state Execu
 			slot, genesisTime, expectedTime, executionPayload.Timestamp)
 	}
 
-	if valid, err := engine.ExecutePayload(ctx, executionPayload); err != nil {
+	if status, err := engine.ExecutePayload(ctx, executionPayload); err != nil {
 		return fmt.Errorf("unexpected problem in execution engine when inserting block %s (height %d), err: %v",
 			executionPayload.BlockHash, executionPayload.BlockNumber, err)
-	} else if !valid {
+	} else if status == common.PayloadExecutionInvalid {
 		return fmt.Errorf("execution engine says payload is invalid: %s (height %d)",
 			executionPayload.BlockHash, executionPayload.BlockNumber)
 	}
@@ -0,0 +1,591 @@
+package beacon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/pool"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// Eth1Source provides the eth1 data of new blocks.
+type Eth1Source interface {
+	// Eth1Vote returns the eth1 data to vote for, in a block on top of the given state.
+	Eth1Vote(ctx context.Context, state common.BeaconState) (common.Eth1Data, error)
+	// Deposits returns count deposits, starting at the given deposit index,
+	// with proofs against the deposit root of the given eth1 data.
+	Deposits(ctx context.Context, eth1Data common.Eth1Data, start common.DepositIndex, count uint64) ([]common.Deposit, error)
+}
+
+// BlockSources are the sources of the operations and data that BuildBlock packs into a block.
+// Nil pools are skipped, leaving the corresponding block contents empty.
+type BlockSources struct {
+	Attestations      *pool.AttestationPool
+	AttesterSlashings *pool.AttesterSlashingPool
+	ProposerSlashings *pool.ProposerSlashingPool
+	VoluntaryExits    *pool.VoluntaryExitPool
+	SyncCommittees    *pool.SyncCommitteePool
+
+	// AttestationPackingTime limits the time spent on packing attestations. No limit if zero.
+	AttestationPackingTime time.Duration
+
+	// Eth1 provides the eth1 vote and deposits.
+	// If nil, the block votes for the current eth1 data of the state, and cannot include deposits.
+	Eth1 Eth1Source
+
+	// Execution builds the execution payloads of Bellatrix blocks.
+	Execution common.ExecutionEngine
+	// FeeRecipient is suggested to the execution engine, to receive the fees of the execution payload.
+	FeeRecipient common.Eth1Address
+	// SafeBlockHash and FinalizedBlockHash are passed to the execution engine,
+	// with the fork-choice update that starts building the payload.
+	SafeBlockHash      common.Hash32
+	FinalizedBlockHash common.Hash32
+	// TerminalBlockHash is the PoW block to build the first execution payload on, if the merge transition is not complete yet.
+	// If zero, blocks before the merge transition have an empty execution payload.
+	TerminalBlockHash common.Hash32
+}
+
+// BuildBlock builds an unsigned block for the given slot, on top of the pre-state: the post-state of the parent block.
+// The block is processed on a copy of the pre-state and epochs context, to compute the state root.
+// The block type matches the fork of the slot: *phase0.BeaconBlock, *altair.BeaconBlock or *bellatrix.BeaconBlock.
+// Capella blocks cannot be built yet: the execution engine cannot build payloads with withdrawals.
+// The packed operations stay in their pools, the built block may never be imported:
+// see BlockSources.OnBlockImport and BlockSources.OnFinalized to remove them.
+func BuildBlock(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, preState common.BeaconState,
+	slot common.Slot, randaoReveal common.BLSSignature, graffiti common.Root, sources *BlockSources) (common.SpecObj, error) {
+	stateCopy, err := preState.CopyState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy pre-state: %v", err)
+	}
+	epc = epc.Clone()
+	state := &StandardUpgradeableBeaconState{BeaconState: stateCopy}
+	if err := common.ProcessSlots(ctx, spec, epc, state, slot); err != nil {
+		return nil, fmt.Errorf("failed to process slots: %v", err)
+	}
+	proposer, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		return nil, err
+	}
+	// The state root of the latest header is filled in by the slot processing
+	parent, err := state.LatestBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	parentRoot := parent.HashTreeRoot(tree.GetHashFn())
+
+	var eth1Vote common.Eth1Data
+	if sources.Eth1 != nil {
+		eth1Vote, err = sources.Eth1.Eth1Vote(ctx, state)
+	} else {
+		eth1Vote, err = state.Eth1Data()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get eth1 vote: %v", err)
+	}
+	deposits, err := packDeposits(ctx, spec, state, eth1Vote, sources.Eth1)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	// The operations are each validated against the same state by the pools, but may conflict with each other:
+	// a validator cannot be slashed twice, and cannot exit after being slashed in the same block.
+	// Operations that conflict with earlier picks get a negative reward estimate, for the pools not to pack them.
+	slashed := make(map[common.ValidatorIndex]struct{})
+	var proposerSlashings phase0.ProposerSlashings
+	if sources.ProposerSlashings != nil {
+		packed, err := sources.ProposerSlashings.Pack(epc, state, func(sl *phase0.ProposerSlashing) int {
+			reward, err := slashingReward(spec, vals, []common.ValidatorIndex{sl.SignedHeader1.Message.ProposerIndex})
+			if err != nil {
+				return -1
+			}
+			return int(reward)
+		}, uint(spec.MAX_PROPOSER_SLASHINGS))
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack proposer slashings: %v", err)
+		}
+		for _, sl := range packed {
+			slashed[sl.SignedHeader1.Message.ProposerIndex] = struct{}{}
+			proposerSlashings = append(proposerSlashings, *sl)
+		}
+	}
+	var attesterSlashings phase0.AttesterSlashings
+	if sources.AttesterSlashings != nil {
+		packed, err := sources.AttesterSlashings.Pack(epc, state, func(sl *phase0.AttesterSlashing) int {
+			indices, err := newlySlashable(sl, vals, epc.CurrentEpoch.Epoch, slashed)
+			if err != nil || len(indices) == 0 {
+				return -1
+			}
+			reward, err := slashingReward(spec, vals, indices)
+			if err != nil {
+				return -1
+			}
+			return int(reward)
+		}, uint(spec.MAX_ATTESTER_SLASHINGS))
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack attester slashings: %v", err)
+		}
+		for _, sl := range packed {
+			indices, err := newlySlashable(sl, vals, epc.CurrentEpoch.Epoch, slashed)
+			if err != nil {
+				return nil, err
+			}
+			// The pool only avoids overlap between the attester slashings themselves:
+			// the slashing must slash at least one validator that is not slashed by any of the earlier picks.
+			if len(indices) == 0 {
+				continue
+			}
+			for _, i := range indices {
+				slashed[i] = struct{}{}
+			}
+			attesterSlashings = append(attesterSlashings, *sl)
+		}
+	}
+	var exits phase0.VoluntaryExits
+	if sources.VoluntaryExits != nil {
+		// Exits do not earn a reward: the exits that have been valid for the longest time are picked first.
+		packed, err := sources.VoluntaryExits.Pack(epc, state, func(exit *phase0.SignedVoluntaryExit) int {
+			// Slashed validators have initiated their exit already
+			if _, ok := slashed[exit.Message.ValidatorIndex]; ok {
+				return -1
+			}
+			return int(epc.CurrentEpoch.Epoch - exit.Message.Epoch)
+		}, uint(spec.MAX_VOLUNTARY_EXITS))
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack voluntary exits: %v", err)
+		}
+		for _, exit := range packed {
+			exits = append(exits, *exit)
+		}
+	}
+	var attestations phase0.Attestations
+	if sources.Attestations != nil {
+		attestations, err = packAttestations(ctx, spec, epc, state, slot, sources)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pack attestations: %v", err)
+		}
+	}
+
+	var body common.SpecObj
+	switch s := state.BeaconState.(type) {
+	case *phase0.BeaconStateView:
+		body = &phase0.BeaconBlockBody{
+			RandaoReveal:      randaoReveal,
+			Eth1Data:          eth1Vote,
+			Graffiti:          graffiti,
+			ProposerSlashings: proposerSlashings,
+			AttesterSlashings: attesterSlashings,
+			Attestations:      attestations,
+			Deposits:          deposits,
+			VoluntaryExits:    exits,
+		}
+	case *altair.BeaconStateView:
+		syncAggregate, err := packSyncAggregate(ctx, spec, epc, slot, parentRoot, sources.SyncCommittees)
+		if err != nil {
+			return nil, err
+		}
+		body = &altair.BeaconBlockBody{
+			RandaoReveal:      randaoReveal,
+			Eth1Data:          eth1Vote,
+			Graffiti:          graffiti,
+			ProposerSlashings: proposerSlashings,
+			AttesterSlashings: attesterSlashings,
+			Attestations:      attestations,
+			Deposits:          deposits,
+			VoluntaryExits:    exits,
+			SyncAggregate:     *syncAggregate,
+		}
+	case *bellatrix.BeaconStateView:
+		syncAggregate, err := packSyncAggregate(ctx, spec, epc, slot, parentRoot, sources.SyncCommittees)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := buildExecutionPayload(ctx, spec, s, slot, sources)
+		if err != nil {
+			return nil, err
+		}
+		body = &bellatrix.BeaconBlockBody{
+			RandaoReveal:      randaoReveal,
+			Eth1Data:          eth1Vote,
+			Graffiti:          graffiti,
+			ProposerSlashings: proposerSlashings,
+			AttesterSlashings: attesterSlashings,
+			Attestations:      attestations,
+			Deposits:          deposits,
+			VoluntaryExits:    exits,
+			SyncAggregate:     *syncAggregate,
+			ExecutionPayload:  *payload,
+		}
+	case *capella.BeaconStateView:
+		// The payload attributes have no withdrawals, and the engine only gets Bellatrix payloads.
+		return nil, errors.New("block production is not supported for capella yet")
+	default:
+		return nil, fmt.Errorf("block production is not supported for state type %T", s)
+	}
+
+	fork, err := state.Fork()
+	if err != nil {
+		return nil, err
+	}
+	genesisValRoot, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		return nil, err
+	}
+	benv := &common.BeaconBlockEnvelope{
+		ForkDigest: common.ComputeForkDigest(fork.CurrentVersion, genesisValRoot),
+		BeaconBlockHeader: common.BeaconBlockHeader{
+			Slot:          slot,
+			ProposerIndex: proposer,
+			ParentRoot:    parentRoot,
+			BodyRoot:      body.HashTreeRoot(spec, tree.GetHashFn()),
+		},
+		Body: body,
+	}
+	// The block is not signed yet, and the state root is what we are computing: skip those validations.
+	if err := common.PostSlotTransition(ctx, spec, epc, state, benv, false); err != nil {
+		return nil, fmt.Errorf("failed to process built block: %v", err)
+	}
+	benv.StateRoot = state.HashTreeRoot(tree.GetHashFn())
+	benv.BlockRoot = benv.BeaconBlockHeader.HashTreeRoot(tree.GetHashFn())
+	return EnvelopeToBeaconBlock(benv)
+}
+
+// OnBlockImport removes the slashings and voluntary exits that are included in the body of an imported block
+// from the pools. Operations of other blocks, e.g. blocks that are built but not imported, stay in the pools.
+func (bs *BlockSources) OnBlockImport(body common.SpecObj) error {
+	var proposerSlashings phase0.ProposerSlashings
+	var attesterSlashings phase0.AttesterSlashings
+	var exits phase0.VoluntaryExits
+	switch b := body.(type) {
+	case *phase0.BeaconBlockBody:
+		proposerSlashings, attesterSlashings, exits = b.ProposerSlashings, b.AttesterSlashings, b.VoluntaryExits
+	case *altair.BeaconBlockBody:
+		proposerSlashings, attesterSlashings, exits = b.ProposerSlashings, b.AttesterSlashings, b.VoluntaryExits
+	case *bellatrix.BeaconBlockBody:
+		proposerSlashings, attesterSlashings, exits = b.ProposerSlashings, b.AttesterSlashings, b.VoluntaryExits
+	case *capella.BeaconBlockBody:
+		proposerSlashings, attesterSlashings, exits = b.ProposerSlashings, b.AttesterSlashings, b.VoluntaryExits
+	default:
+		return fmt.Errorf("unrecognized block body type: %T", body)
+	}
+	if bs.ProposerSlashings != nil {
+		for i := range proposerSlashings {
+			bs.ProposerSlashings.Remove(&proposerSlashings[i])
+		}
+	}
+	if bs.AttesterSlashings != nil {
+		for i := range attesterSlashings {
+			bs.AttesterSlashings.Remove(&attesterSlashings[i])
+		}
+	}
+	if bs.VoluntaryExits != nil {
+		for i := range exits {
+			bs.VoluntaryExits.Remove(&exits[i])
+		}
+	}
+	return nil
+}
+
+// OnFinalized prunes the slashings and voluntary exits that cannot be included in any block anymore,
+// according to the new finalized state.
+func (bs *BlockSources) OnFinalized(finalized common.BeaconState) error {
+	if bs.ProposerSlashings != nil {
+		if err := bs.ProposerSlashings.Prune(finalized); err != nil {
+			return fmt.Errorf("failed to prune proposer slashings: %w", err)
+		}
+	}
+	if bs.AttesterSlashings != nil {
+		if err := bs.AttesterSlashings.Prune(finalized); err != nil {
+			return fmt.Errorf("failed to prune attester slashings: %w", err)
+		}
+	}
+	if bs.VoluntaryExits != nil {
+		if err := bs.VoluntaryExits.Prune(finalized); err != nil {
+			return fmt.Errorf("failed to prune voluntary exits: %w", err)
+		}
+	}
+	return nil
+}
+
+// slashingReward estimates the reward of the proposer for slashing the given validators.
+// The proposer is also the whistleblower, and receives the full whistleblower reward of each slashed validator.
+func slashingReward(spec *common.Spec, vals common.ValidatorRegistry, indices []common.ValidatorIndex) (common.Gwei, error) {
+	var total common.Gwei
+	for _, i := range indices {
+		v, err := vals.Validator(i)
+		if err != nil {
+			return 0, err
+		}
+		balance, err := v.EffectiveBalance()
+		if err != nil {
+			return 0, err
+		}
+		total += balance / common.Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT)
+	}
+	return total, nil
+}
+
+// newlySlashable returns the validators that the attester slashing slashes in the given epoch,
+// excluding the validators that are already slashed by other operations of the block.
+func newlySlashable(sl *phase0.AttesterSlashing, vals common.ValidatorRegistry, epoch common.Epoch,
+	slashed map[common.ValidatorIndex]struct{}) (out []common.ValidatorIndex, err error) {
+	common.ValidatorSet(sl.Attestation1.AttestingIndices).ZigZagJoin(common.ValidatorSet(sl.Attestation2.AttestingIndices), func(i common.ValidatorIndex) {
+		if err != nil {
+			return
+		}
+		if _, ok := slashed[i]; ok {
+			return
+		}
+		if valid, e := vals.IsValidIndex(i); e != nil {
+			err = e
+			return
+		} else if !valid {
+			return
+		}
+		v, e := vals.Validator(i)
+		if e != nil {
+			err = e
+			return
+		}
+		if ok, e := phase0.IsSlashable(v, epoch); e != nil {
+			err = e
+		} else if ok {
+			out = append(out, i)
+		}
+	}, nil)
+	return
+}
+
+// packDeposits fetches the deposits that the block must include: all pending deposits, up to MAX_DEPOSITS.
+func packDeposits(ctx context.Context, spec *common.Spec, state common.BeaconState,
+	eth1Vote common.Eth1Data, src Eth1Source) (phase0.Deposits, error) {
+	eth1Data, err := state.Eth1Data()
+	if err != nil {
+		return nil, err
+	}
+	// The eth1 vote is processed before the deposits, and changes the eth1 data of the state if it is the majority.
+	votes, err := state.Eth1DataVotes()
+	if err != nil {
+		return nil, err
+	}
+	count, err := votes.Count(eth1Vote)
+	if err != nil {
+		return nil, err
+	}
+	period := uint64(spec.EPOCHS_PER_ETH1_VOTING_PERIOD) * uint64(spec.SLOTS_PER_EPOCH)
+	if (count+1)<<1 > period {
+		eth1Data = eth1Vote
+	}
+	depIndex, err := state.Eth1DepositIndex()
+	if err != nil {
+		return nil, err
+	}
+	if eth1Data.DepositCount <= depIndex {
+		return nil, nil
+	}
+	n := uint64(eth1Data.DepositCount - depIndex)
+	if n > spec.MAX_DEPOSITS {
+		n = spec.MAX_DEPOSITS
+	}
+	if src == nil {
+		return nil, fmt.Errorf("block must include %d deposits, but there is no eth1 source", n)
+	}
+	deposits, err := src.Deposits(ctx, eth1Data, depIndex, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deposits: %v", err)
+	}
+	if uint64(len(deposits)) != n {
+		return nil, fmt.Errorf("expected %d deposits from eth1 source, got %d", n, len(deposits))
+	}
+	return deposits, nil
+}
+
+// packAttestations packs the attestations of the previous and current epoch, that can be included at the given slot.
+// The state must be processed to the slot already.
+func packAttestations(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState,
+	slot common.Slot, sources *BlockSources) (phase0.Attestations, error) {
+	var included func(epoch common.Epoch, index common.ValidatorIndex) bool
+	if s, ok := state.(altair.AltairLikeBeaconState); ok {
+		prev, err := s.PreviousEpochParticipation()
+		if err != nil {
+			return nil, err
+		}
+		curr, err := s.CurrentEpochParticipation()
+		if err != nil {
+			return nil, err
+		}
+		// Validators with a timely target vote cannot add much reward anymore.
+		included = func(epoch common.Epoch, index common.ValidatorIndex) bool {
+			participation := curr
+			if epoch == epc.PreviousEpoch.Epoch {
+				participation = prev
+			}
+			flags, err := participation.GetFlags(index)
+			return err == nil && flags&altair.TIMELY_TARGET_FLAG != 0
+		}
+	}
+	prevJustified, err := state.PreviousJustifiedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	currJustified, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	// Attestations vote for the correct head if they match the canonical block root at their slot.
+	blockRootAt := func(slot common.Slot) (common.Root, error) {
+		return common.GetBlockRootAtSlot(spec, state, slot)
+	}
+	var deadline time.Time
+	if sources.AttestationPackingTime > 0 {
+		deadline = time.Now().Add(sources.AttestationPackingTime)
+	}
+	epochs := []common.Epoch{epc.CurrentEpoch.Epoch}
+	// The previous and current epoch are the same at genesis
+	if epc.PreviousEpoch.Epoch < epc.CurrentEpoch.Epoch {
+		epochs = []common.Epoch{epc.PreviousEpoch.Epoch, epc.CurrentEpoch.Epoch}
+	}
+	var out phase0.Attestations
+	for _, epoch := range epochs {
+		source := currJustified
+		if epoch < epc.CurrentEpoch.Epoch {
+			source = prevJustified
+		}
+		startSlot, err := spec.EpochStartSlot(epoch)
+		if err != nil {
+			return nil, err
+		}
+		// No attestations of the current epoch can be included in the first slot of the epoch.
+		if startSlot >= slot {
+			continue
+		}
+		targetRoot, err := common.GetBlockRoot(spec, state, epoch)
+		if err != nil {
+			return nil, err
+		}
+		maxTime := time.Duration(0)
+		if !deadline.IsZero() {
+			if maxTime = time.Until(deadline); maxTime <= 0 {
+				break
+			}
+		}
+		packed, err := sources.Attestations.Packing(ctx, slot, source, common.Checkpoint{Epoch: epoch, Root: targetRoot},
+			blockRootAt, spec.MAX_ATTESTATIONS-uint64(len(out)), maxTime, included)
+		if err != nil {
+			return nil, err
+		}
+		for _, att := range packed {
+			if att.Data.Target.Epoch != epoch || spec.SlotToEpoch(att.Data.Slot) != epoch ||
+				att.Data.Slot+spec.MIN_ATTESTATION_INCLUSION_DELAY > slot || slot > att.Data.Slot+spec.SLOTS_PER_EPOCH {
+				continue
+			}
+			out = append(out, att)
+		}
+		if uint64(len(out)) >= spec.MAX_ATTESTATIONS {
+			break
+		}
+	}
+	return out, nil
+}
+
+// packSyncAggregate packs the sync committee messages of the previous slot, that vote for the parent block.
+func packSyncAggregate(ctx context.Context, spec *common.Spec, epc *common.EpochsContext,
+	slot common.Slot, parentRoot common.Root, sp *pool.SyncCommitteePool) (*altair.SyncAggregate, error) {
+	if sp == nil {
+		return emptySyncAggregate(spec), nil
+	}
+	if epc.CurrentSyncCommittee == nil {
+		return nil, errors.New("missing current sync committee in epochs context")
+	}
+	out, err := sp.PackAggregate(ctx, slot-1, parentRoot, epc.CurrentSyncCommittee.Indices)
+	if errors.Is(err, pool.UnavailableSlotErr) {
+		// The pool does not buffer the previous slot (e.g. it was not reset to the current slot):
+		// there is nothing to pack, the block can still be built with an empty aggregate.
+		return emptySyncAggregate(spec), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack sync aggregate: %v", err)
+	}
+	return out, nil
+}
+
+// emptySyncAggregate has no participants, and the point at infinity as signature.
+func emptySyncAggregate(spec *common.Spec) *altair.SyncAggregate {
+	out := &altair.SyncAggregate{
+		SyncCommitteeBits: make(altair.SyncCommitteeBits, (spec.SYNC_COMMITTEE_SIZE+7)/8),
+	}
+	out.SyncCommitteeSignature[0] = 0xc0
+	return out
+}
+
+// buildExecutionPayload gets a new execution payload from the execution engine,
+// or an empty payload if the merge transition is not complete and there is no terminal block to build on.
+func buildExecutionPayload(ctx context.Context, spec *common.Spec, state *bellatrix.BeaconStateView,
+	slot common.Slot, sources *BlockSources) (*common.ExecutionPayload, error) {
+	completed, err := state.IsTransitionCompleted()
+	if err != nil {
+		return nil, err
+	}
+	var parentHash common.Hash32
+	if completed {
+		header, err := state.LatestExecutionPayloadHeader()
+		if err != nil {
+			return nil, err
+		}
+		parentHash, err = header.BlockHash()
+		if err != nil {
+			return nil, err
+		}
+	} else if sources.TerminalBlockHash != (common.Hash32{}) {
+		parentHash = sources.TerminalBlockHash
+	} else {
+		return new(common.ExecutionPayload), nil
+	}
+	if sources.Execution == nil {
+		return nil, errors.New("no execution engine to build the execution payload with")
+	}
+	genesisTime, err := state.GenesisTime()
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := spec.TimeAtSlot(slot, genesisTime)
+	if err != nil {
+		return nil, err
+	}
+	mixes, err := state.RandaoMixes()
+	if err != nil {
+		return nil, err
+	}
+	mix, err := mixes.GetRandomMix(spec.SlotToEpoch(slot))
+	if err != nil {
+		return nil, err
+	}
+	attributes := &common.PayloadAttributes{
+		Timestamp:             timestamp,
+		PrevRandao:            common.Bytes32(mix),
+		SuggestedFeeRecipient: sources.FeeRecipient,
+	}
+	id, err := sources.Execution.NotifyForkchoiceUpdated(ctx, parentHash,
+		sources.SafeBlockHash, sources.FinalizedBlockHash, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start building execution payload: %v", err)
+	}
+	if id == nil {
+		return nil, errors.New("execution engine did not start building an execution payload")
+	}
+	payload, err := sources.Execution.GetPayload(ctx, *id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution payload: %v", err)
+	}
+	return payload, nil
+}
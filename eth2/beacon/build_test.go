@@ -0,0 +1,367 @@
+package beacon_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/engine"
+	"github.com/protolambda/zrnt/eth2/pool"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+type signFn func(index common.ValidatorIndex, root common.Root, domType common.BLSDomainType, slot common.Slot) *blsu.Signature
+
+// testGenesis creates a genesis state with 64 validators, and a function to sign with the validator keys.
+func testGenesis(t *testing.T, spec *common.Spec) (common.BeaconState, *common.EpochsContext, common.Root, signFn) {
+	keys := make([]*blsu.SecretKey, 64)
+	rawKeys := make([][32]byte, len(keys))
+	vals := make([]phase0.KickstartValidatorData, len(keys))
+	for i := range keys {
+		rawKeys[i][31] = byte(i + 1)
+		keys[i] = new(blsu.SecretKey)
+		if err := keys[i].Deserialize(&rawKeys[i]); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(keys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	genesis, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 1000, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genValRoot, err := genesis.GenesisValidatorsRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(index common.ValidatorIndex, root common.Root, domType common.BLSDomainType, slot common.Slot) *blsu.Signature {
+		dom := common.ComputeDomain(domType, spec.ForkVersion(slot), genValRoot)
+		signingRoot := common.ComputeSigningRoot(root, dom)
+		return blsu.Sign(keys[index], signingRoot[:])
+	}
+	return genesis, epc, genValRoot, sign
+}
+
+func TestBuildBlock(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	spec.BELLATRIX_FORK_EPOCH = 2
+	spec.TERMINAL_TOTAL_DIFFICULTY = view.MustUint256("1")
+	// The PoW genesis block is the terminal block
+	mock := engine.NewMockEngine(&spec, 0, 1)
+	terminal, ok := mock.TerminalBlock()
+	if !ok {
+		t.Fatal("expected terminal PoW block")
+	}
+	spec.ExecutionEngine = mock
+	genesis, epc, genValRoot, sign := testGenesis(t, &spec)
+
+	ctx := context.Background()
+	sources := &beacon.BlockSources{
+		Attestations:      pool.NewAttestationPool(&spec),
+		AttesterSlashings: pool.NewAttesterSlashingPool(&spec),
+		ProposerSlashings: pool.NewProposerSlashingPool(&spec),
+		VoluntaryExits:    pool.NewVoluntaryExitPool(&spec),
+		SyncCommittees:    pool.NewSyncCommitteePool(&spec),
+		Execution:         mock,
+		FeeRecipient:      common.Eth1Address{0xaa},
+		TerminalBlockHash: terminal,
+	}
+	hFn := tree.GetHashFn()
+	state := &beacon.StandardUpgradeableBeaconState{BeaconState: genesis}
+	// The proposer of the next block is known after processing the slots
+	proposerAt := func(slot common.Slot) (common.ValidatorIndex, error) {
+		stateCopy, err := state.CopyState()
+		if err != nil {
+			return 0, err
+		}
+		epcCopy := epc.Clone()
+		if err := common.ProcessSlots(ctx, &spec, epcCopy, &beacon.StandardUpgradeableBeaconState{BeaconState: stateCopy}, slot); err != nil {
+			return 0, err
+		}
+		return epcCopy.GetBeaconProposer(slot)
+	}
+	packedAttestations := 0
+	syncParticipants := 0
+	for slot := common.Slot(1); slot < common.Slot(5)*spec.SLOTS_PER_EPOCH; slot++ {
+		epoch := spec.SlotToEpoch(slot)
+		sources.SyncCommittees.Reset(slot)
+		proposer, err := proposerAt(slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reveal := sign(proposer, epoch.HashTreeRoot(hFn), common.DOMAIN_RANDAO, slot).Serialize()
+		block, err := beacon.BuildBlock(ctx, &spec, epc, state.BeaconState, slot, reveal, common.Root{0x42}, sources)
+		if err != nil {
+			t.Fatalf("slot %d: failed to build block: %v", slot, err)
+		}
+		sig := sign(proposer, block.HashTreeRoot(&spec, hFn), common.DOMAIN_BEACON_PROPOSER, slot).Serialize()
+		digest := common.ComputeForkDigest(spec.ForkVersion(slot), genValRoot)
+		var benv *common.BeaconBlockEnvelope
+		switch b := block.(type) {
+		case *phase0.BeaconBlock:
+			if epoch >= spec.ALTAIR_FORK_EPOCH {
+				t.Fatalf("slot %d: unexpected phase0 block", slot)
+			}
+			packedAttestations += len(b.Body.Attestations)
+			benv = (&phase0.SignedBeaconBlock{Message: *b, Signature: sig}).Envelope(&spec, digest)
+		case *altair.BeaconBlock:
+			if epoch != spec.ALTAIR_FORK_EPOCH {
+				t.Fatalf("slot %d: unexpected altair block", slot)
+			}
+			packedAttestations += len(b.Body.Attestations)
+			benv = (&altair.SignedBeaconBlock{Message: *b, Signature: sig}).Envelope(&spec, digest)
+		case *bellatrix.BeaconBlock:
+			if epoch < spec.BELLATRIX_FORK_EPOCH {
+				t.Fatalf("slot %d: unexpected bellatrix block", slot)
+			}
+			payload := &b.Body.ExecutionPayload
+			if payload.BlockHash == (common.Hash32{}) || payload.FeeRecipient != sources.FeeRecipient {
+				t.Fatalf("slot %d: unexpected execution payload: %v", slot, payload)
+			}
+			packedAttestations += len(b.Body.Attestations)
+			for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
+				if b.Body.SyncAggregate.SyncCommitteeBits.GetBit(i) {
+					syncParticipants++
+				}
+			}
+			benv = (&bellatrix.SignedBeaconBlock{Message: *b, Signature: sig}).Envelope(&spec, digest)
+		default:
+			t.Fatalf("slot %d: unexpected block type %T", slot, block)
+		}
+		if err := common.StateTransition(ctx, &spec, epc, state, benv, true); err != nil {
+			t.Fatalf("slot %d: failed to apply built block: %v", slot, err)
+		}
+
+		// Every validator attests to the new block, for the next blocks to pack.
+		source, err := state.CurrentJustifiedCheckpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		targetRoot := benv.BlockRoot
+		if startSlot, _ := spec.EpochStartSlot(epoch); startSlot != slot {
+			if targetRoot, err = common.GetBlockRoot(&spec, state, epoch); err != nil {
+				t.Fatal(err)
+			}
+		}
+		count, err := epc.GetCommitteeCountPerSlot(epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for index := common.CommitteeIndex(0); index < common.CommitteeIndex(count); index++ {
+			committee, err := epc.GetBeaconCommittee(slot, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := phase0.AttestationData{
+				Slot:            slot,
+				Index:           index,
+				BeaconBlockRoot: benv.BlockRoot,
+				Source:          source,
+				Target:          common.Checkpoint{Epoch: epoch, Root: targetRoot},
+			}
+			bits := make(phase0.AttestationBits, len(committee)/8+1)
+			var sigs []*blsu.Signature
+			for i, vi := range committee {
+				bits.SetBit(uint64(i), true)
+				sigs = append(sigs, sign(vi, data.HashTreeRoot(hFn), common.DOMAIN_BEACON_ATTESTER, slot))
+			}
+			bits.SetBit(uint64(len(committee)), true)
+			agg, err := blsu.Aggregate(sigs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			att := &phase0.Attestation{AggregationBits: bits, Data: data, Signature: agg.Serialize()}
+			if err := sources.Attestations.AddAttestation(ctx, att, committee); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The sync committee votes for the new block, for the next block to pack.
+		if epoch >= spec.ALTAIR_FORK_EPOCH {
+			for _, vi := range epc.CurrentSyncCommittee.Indices {
+				msg := &altair.SyncCommitteeMessage{
+					Slot:            slot,
+					BeaconBlockRoot: benv.BlockRoot,
+					ValidatorIndex:  vi,
+					Signature:       sign(vi, benv.BlockRoot, common.DOMAIN_SYNC_COMMITTEE, slot).Serialize(),
+				}
+				if err := sources.SyncCommittees.AddSyncCommitteeMessage(ctx, msg); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	if packedAttestations == 0 {
+		t.Fatal("expected attestations to be packed")
+	}
+	if syncParticipants == 0 {
+		t.Fatal("expected sync committee messages to be packed")
+	}
+	finalized, err := state.FinalizedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if finalized.Epoch < 2 {
+		t.Fatalf("expected chain with full participation to finalize, got finalized epoch %d", finalized.Epoch)
+	}
+}
+
+func TestBuildBlockConflicts(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	// validators can exit right away
+	spec.SHARD_COMMITTEE_PERIOD = 0
+	genesis, epc, _, sign := testGenesis(t, &spec)
+	ctx := context.Background()
+	hFn := tree.GetHashFn()
+	sources := &beacon.BlockSources{
+		AttesterSlashings: pool.NewAttesterSlashingPool(&spec),
+		ProposerSlashings: pool.NewProposerSlashingPool(&spec),
+		VoluntaryExits:    pool.NewVoluntaryExitPool(&spec),
+		// the sync committee pool is never reset, and does not buffer any slot
+		SyncCommittees: pool.NewSyncCommitteePool(&spec),
+	}
+
+	signedHeader := func(index common.ValidatorIndex, bodyRoot common.Root) common.SignedBeaconBlockHeader {
+		header := common.BeaconBlockHeader{Slot: 1, ProposerIndex: index, BodyRoot: bodyRoot}
+		return common.SignedBeaconBlockHeader{
+			Message:   header,
+			Signature: sign(index, header.HashTreeRoot(hFn), common.DOMAIN_BEACON_PROPOSER, 1).Serialize(),
+		}
+	}
+	indexedAttestation := func(blockRoot common.Root, indices ...common.ValidatorIndex) phase0.IndexedAttestation {
+		data := phase0.AttestationData{Slot: 1, BeaconBlockRoot: blockRoot}
+		var sigs []*blsu.Signature
+		for _, i := range indices {
+			sigs = append(sigs, sign(i, data.HashTreeRoot(hFn), common.DOMAIN_BEACON_ATTESTER, 1))
+		}
+		agg, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return phase0.IndexedAttestation{AttestingIndices: indices, Data: data, Signature: agg.Serialize()}
+	}
+	// validator 3 is slashed as proposer, and also double voted with validator 4
+	proposerSlashing := &phase0.ProposerSlashing{
+		SignedHeader1: signedHeader(3, common.Root{0x01}),
+		SignedHeader2: signedHeader(3, common.Root{0x02}),
+	}
+	if err := sources.ProposerSlashings.AddProposerSlashing(ctx, proposerSlashing); err != nil {
+		t.Fatal(err)
+	}
+	attesterSlashing := &phase0.AttesterSlashing{
+		Attestation1: indexedAttestation(common.Root{0x01}, 3, 4),
+		Attestation2: indexedAttestation(common.Root{0x02}, 3, 4),
+	}
+	// only slashes validator 3, which is slashed by the proposer slashing already
+	redundantSlashing := &phase0.AttesterSlashing{
+		Attestation1: indexedAttestation(common.Root{0x03}, 3),
+		Attestation2: indexedAttestation(common.Root{0x04}, 3),
+	}
+	for _, sl := range []*phase0.AttesterSlashing{attesterSlashing, redundantSlashing} {
+		if err := sources.AttesterSlashings.AddAttesterSlashing(ctx, sl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exit := func(index common.ValidatorIndex) *phase0.SignedVoluntaryExit {
+		msg := phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: index}
+		return &phase0.SignedVoluntaryExit{
+			Message:   msg,
+			Signature: sign(index, msg.HashTreeRoot(hFn), common.DOMAIN_VOLUNTARY_EXIT, 1).Serialize(),
+		}
+	}
+	// the exit of the slashed validator conflicts with the slashing, the other exit does not
+	for _, i := range []common.ValidatorIndex{3, 5} {
+		if err := sources.VoluntaryExits.AddVoluntaryExit(ctx, exit(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reveal := func(slot common.Slot) common.BLSSignature {
+		stateCopy, err := genesis.CopyState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		epcCopy := epc.Clone()
+		if err := common.ProcessSlots(ctx, &spec, epcCopy, &beacon.StandardUpgradeableBeaconState{BeaconState: stateCopy}, slot); err != nil {
+			t.Fatal(err)
+		}
+		proposer, err := epcCopy.GetBeaconProposer(slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sign(proposer, spec.SlotToEpoch(slot).HashTreeRoot(hFn), common.DOMAIN_RANDAO, slot).Serialize()
+	}
+	block, err := beacon.BuildBlock(ctx, &spec, epc, genesis, 1, reveal(1), common.Root{}, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := &block.(*phase0.BeaconBlock).Body
+	if len(body.ProposerSlashings) != 1 || len(body.AttesterSlashings) != 1 || len(body.VoluntaryExits) != 1 {
+		t.Fatalf("unexpected operations: %d proposer slashings, %d attester slashings, %d exits",
+			len(body.ProposerSlashings), len(body.AttesterSlashings), len(body.VoluntaryExits))
+	}
+	if body.AttesterSlashings[0].Attestation1.Data.BeaconBlockRoot != (common.Root{0x01}) {
+		t.Fatal("expected the attester slashing that slashes another validator")
+	}
+	if body.VoluntaryExits[0].Message.ValidatorIndex != 5 {
+		t.Fatalf("expected exit of validator 5, got %d", body.VoluntaryExits[0].Message.ValidatorIndex)
+	}
+	// the operations stay in the pools until the block is imported
+	if n := len(sources.ProposerSlashings.All()) + len(sources.AttesterSlashings.All()) + len(sources.VoluntaryExits.All()); n != 5 {
+		t.Fatalf("expected built block to leave the pools unchanged, %d operations remaining", n)
+	}
+	if err := sources.OnBlockImport(body); err != nil {
+		t.Fatal(err)
+	}
+	// only the included operations are removed from the pools
+	if n := len(sources.ProposerSlashings.All()); n != 0 {
+		t.Fatalf("expected included proposer slashing to be removed, %d remaining", n)
+	}
+	if remaining := sources.AttesterSlashings.All(); len(remaining) != 1 || remaining[0] != redundantSlashing {
+		t.Fatalf("expected only the redundant attester slashing to remain, %d remaining", len(remaining))
+	}
+	if remaining := sources.VoluntaryExits.All(); len(remaining) != 1 || remaining[0].Message.ValidatorIndex != 3 {
+		t.Fatalf("expected only the conflicting exit to remain, %d remaining", len(remaining))
+	}
+
+	// the first altair block gets an empty sync aggregate, the pool has nothing for the previous slot
+	block, err = beacon.BuildBlock(ctx, &spec, epc, genesis, spec.SLOTS_PER_EPOCH, reveal(spec.SLOTS_PER_EPOCH), common.Root{}, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := &block.(*altair.BeaconBlock).Body.SyncAggregate
+	if agg.SyncCommitteeSignature != (common.BLSSignature{0xc0}) {
+		t.Fatalf("expected empty sync aggregate signature, got %s", agg.SyncCommitteeSignature)
+	}
+	for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
+		if agg.SyncCommitteeBits.GetBit(i) {
+			t.Fatalf("unexpected sync committee participant %d", i)
+		}
+	}
+}
+
+func TestBuildBlockCapella(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	spec.BELLATRIX_FORK_EPOCH = 1
+	spec.CAPELLA_FORK_EPOCH = 1
+	genesis, epc, _, _ := testGenesis(t, &spec)
+	_, err := beacon.BuildBlock(context.Background(), &spec, epc, genesis, spec.SLOTS_PER_EPOCH,
+		common.BLSSignature{}, common.Root{}, &beacon.BlockSources{})
+	if err == nil || !strings.Contains(err.Error(), "not supported for capella") {
+		t.Fatalf("expected capella block production to be rejected, got %v", err)
+	}
+}
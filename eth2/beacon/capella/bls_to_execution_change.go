@@ -0,0 +1,186 @@
+package capella
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/util/hashing"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	. "github.com/protolambda/ztyp/view"
+)
+
+var BLSToExecutionChangeType = ContainerType("BLSToExecutionChange", []FieldDef{
+	{"validator_index", common.ValidatorIndexType},
+	{"from_bls_pubkey", common.BLSPubkeyType},
+	{"to_execution_address", common.Eth1AddressType},
+})
+
+type BLSToExecutionChange struct {
+	ValidatorIndex     common.ValidatorIndex `json:"validator_index" yaml:"validator_index"`
+	FromBLSPubKey      common.BLSPubkey      `json:"from_bls_pubkey" yaml:"from_bls_pubkey"`
+	ToExecutionAddress common.Eth1Address    `json:"to_execution_address" yaml:"to_execution_address"`
+}
+
+func (c *BLSToExecutionChange) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&c.ValidatorIndex, &c.FromBLSPubKey, &c.ToExecutionAddress)
+}
+
+func (c *BLSToExecutionChange) Serialize(w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(&c.ValidatorIndex, &c.FromBLSPubKey, &c.ToExecutionAddress)
+}
+
+func (c *BLSToExecutionChange) ByteLength() uint64 {
+	return BLSToExecutionChangeType.TypeByteLength()
+}
+
+func (*BLSToExecutionChange) FixedLength() uint64 {
+	return BLSToExecutionChangeType.TypeByteLength()
+}
+
+func (c *BLSToExecutionChange) HashTreeRoot(hFn tree.HashFn) common.Root {
+	return hFn.HashTreeRoot(&c.ValidatorIndex, &c.FromBLSPubKey, &c.ToExecutionAddress)
+}
+
+var SignedBLSToExecutionChangeType = ContainerType("SignedBLSToExecutionChange", []FieldDef{
+	{"message", BLSToExecutionChangeType},
+	{"signature", common.BLSSignatureType},
+})
+
+type SignedBLSToExecutionChange struct {
+	Message   BLSToExecutionChange `json:"message" yaml:"message"`
+	Signature common.BLSSignature  `json:"signature" yaml:"signature"`
+}
+
+func (c *SignedBLSToExecutionChange) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&c.Message, &c.Signature)
+}
+
+func (c *SignedBLSToExecutionChange) Serialize(w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(&c.Message, &c.Signature)
+}
+
+func (c *SignedBLSToExecutionChange) ByteLength() uint64 {
+	return SignedBLSToExecutionChangeType.TypeByteLength()
+}
+
+func (*SignedBLSToExecutionChange) FixedLength() uint64 {
+	return SignedBLSToExecutionChangeType.TypeByteLength()
+}
+
+func (c *SignedBLSToExecutionChange) HashTreeRoot(hFn tree.HashFn) common.Root {
+	return hFn.HashTreeRoot(&c.Message, c.Signature)
+}
+
+func BlockSignedBLSToExecutionChangesType(spec *common.Spec) ListTypeDef {
+	return ListType(SignedBLSToExecutionChangeType, spec.MAX_BLS_TO_EXECUTION_CHANGES)
+}
+
+type SignedBLSToExecutionChanges []SignedBLSToExecutionChange
+
+func (li *SignedBLSToExecutionChanges) Deserialize(spec *common.Spec, dr *codec.DecodingReader) error {
+	return dr.List(func() codec.Deserializable {
+		i := len(*li)
+		*li = append(*li, SignedBLSToExecutionChange{})
+		return &(*li)[i]
+	}, SignedBLSToExecutionChangeType.TypeByteLength(), spec.MAX_BLS_TO_EXECUTION_CHANGES)
+}
+
+func (li SignedBLSToExecutionChanges) Serialize(spec *common.Spec, w *codec.EncodingWriter) error {
+	return w.List(func(i uint64) codec.Serializable {
+		return &li[i]
+	}, SignedBLSToExecutionChangeType.TypeByteLength(), uint64(len(li)))
+}
+
+func (li SignedBLSToExecutionChanges) ByteLength(spec *common.Spec) (out uint64) {
+	return SignedBLSToExecutionChangeType.TypeByteLength() * uint64(len(li))
+}
+
+func (*SignedBLSToExecutionChanges) FixedLength(*common.Spec) uint64 {
+	return 0
+}
+
+func (li SignedBLSToExecutionChanges) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) common.Root {
+	length := uint64(len(li))
+	return hFn.ComplexListHTR(func(i uint64) tree.HTR {
+		if i < length {
+			return &li[i]
+		}
+		return nil
+	}, length, spec.MAX_BLS_TO_EXECUTION_CHANGES)
+}
+
+func ProcessBLSToExecutionChanges(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, ops []SignedBLSToExecutionChange) error {
+	for i := range ops {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := ProcessBLSToExecutionChange(ctx, spec, epc, state, &ops[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidateBLSToExecutionChange(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedChange *SignedBLSToExecutionChange) error {
+	change := &signedChange.Message
+	vals, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	if valid, err := vals.IsValidIndex(change.ValidatorIndex); err != nil {
+		return err
+	} else if !valid {
+		return errors.New("invalid BLS to execution change validator index")
+	}
+	validator, err := vals.Validator(change.ValidatorIndex)
+	if err != nil {
+		return err
+	}
+	creds, err := validator.WithdrawalCredentials()
+	if err != nil {
+		return err
+	}
+	if creds[0] != common.BLS_WITHDRAWAL_PREFIX {
+		return fmt.Errorf("validator %d does not have BLS withdrawal credentials", change.ValidatorIndex)
+	}
+	pubHash := hashing.Hash(change.FromBLSPubKey[:])
+	if !bytes.Equal(creds[1:], pubHash[1:]) {
+		return fmt.Errorf("BLS pubkey %s does not match withdrawal credentials of validator %d",
+			change.FromBLSPubKey, change.ValidatorIndex)
+	}
+	genesisValidatorsRoot, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		return err
+	}
+	// Fork-agnostic domain, since address changes are valid across forks
+	domain := common.ComputeDomain(common.DOMAIN_BLS_TO_EXECUTION_CHANGE, spec.GENESIS_FORK_VERSION, genesisValidatorsRoot)
+	return common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     []*common.CachedPubkey{{Compressed: change.FromBLSPubKey}},
+		SigningRoot: common.ComputeSigningRoot(change.HashTreeRoot(tree.GetHashFn()), domain),
+		Signature:   signedChange.Signature,
+		Description: "BLS to execution change signature could not be verified",
+	})
+}
+
+func ProcessBLSToExecutionChange(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedChange *SignedBLSToExecutionChange) error {
+	if err := ValidateBLSToExecutionChange(ctx, spec, epc, state, signedChange); err != nil {
+		return err
+	}
+	change := &signedChange.Message
+	vals, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	validator, err := vals.Validator(change.ValidatorIndex)
+	if err != nil {
+		return err
+	}
+	var creds common.Root
+	creds[0] = common.ETH1_ADDRESS_WITHDRAWAL_PREFIX
+	copy(creds[12:], change.ToExecutionAddress[:])
+	return validator.SetWithdrawalCredentials(creds)
+}
@@ -0,0 +1,88 @@
+package capella
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+type ExecutionTrackingBeaconState interface {
+	common.BeaconState
+
+	LatestExecutionPayloadHeader() (*ExecutionPayloadHeaderView, error)
+	SetLatestExecutionPayloadHeader(h *ExecutionPayloadHeader) error
+}
+
+func ProcessExecutionPayload(ctx context.Context, spec *common.Spec, state ExecutionTrackingBeaconState, executionPayload *ExecutionPayload, engine ExecutionEngine) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if engine == nil {
+		return errors.New("nil execution engine")
+	}
+
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+
+	completed := true
+	if s, ok := state.(ExecutionUpgradeBeaconState); ok {
+		var err error
+		completed, err = s.IsTransitionCompleted()
+		if err != nil {
+			return err
+		}
+	}
+	if completed {
+		latestExecHeader, err := state.LatestExecutionPayloadHeader()
+		if err != nil {
+			return err
+		}
+		parentHash, err := latestExecHeader.BlockHash()
+		if err != nil {
+			return fmt.Errorf("failed to read previous header: %v", err)
+		}
+		if executionPayload.ParentHash != parentHash {
+			return fmt.Errorf("expected parent hash %s in execution payload, but got %s",
+				parentHash, executionPayload.ParentHash)
+		}
+	}
+
+	// verify random
+	mixes, err := state.RandaoMixes()
+	if err != nil {
+		return err
+	}
+	expectedMix, err := mixes.GetRandomMix(spec.SlotToEpoch(slot))
+	if err != nil {
+		return err
+	}
+	if executionPayload.PrevRandao != expectedMix {
+		return fmt.Errorf("invalid random data %s, expected %s", executionPayload.PrevRandao, expectedMix)
+	}
+
+	// verify timestamp
+	genesisTime, err := state.GenesisTime()
+	if err != nil {
+		return err
+	}
+	if expectedTime, err := spec.TimeAtSlot(slot, genesisTime); err != nil {
+		return fmt.Errorf("slot or genesis time in state is corrupt, cannot compute time: %v", err)
+	} else if executionPayload.Timestamp != expectedTime {
+		return fmt.Errorf("state at slot %d, genesis time %d, expected execution payload time %d, but got %d",
+			slot, genesisTime, expectedTime, executionPayload.Timestamp)
+	}
+
+	if status, err := engine.ExecuteCapellaPayload(ctx, executionPayload); err != nil {
+		return fmt.Errorf("unexpected problem in execution engine when inserting block %s (height %d), err: %v",
+			executionPayload.BlockHash, executionPayload.BlockNumber, err)
+	} else if status == common.PayloadExecutionInvalid {
+		return fmt.Errorf("execution engine says payload is invalid: %s (height %d)",
+			executionPayload.BlockHash, executionPayload.BlockNumber)
+	}
+
+	return state.SetLatestExecutionPayloadHeader(executionPayload.Header(spec))
+}
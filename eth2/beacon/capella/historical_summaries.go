@@ -0,0 +1,132 @@
+package capella
+
+import (
+	"context"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	. "github.com/protolambda/ztyp/view"
+)
+
+var HistoricalSummaryType = ContainerType("HistoricalSummary", []FieldDef{
+	{"block_summary_root", RootType},
+	{"state_summary_root", RootType},
+})
+
+// HistoricalSummary replaces the HistoricalBatch root in the state:
+// the block and state roots are summarized separately, so block roots can be proven without the state roots.
+type HistoricalSummary struct {
+	BlockSummaryRoot common.Root `json:"block_summary_root" yaml:"block_summary_root"`
+	StateSummaryRoot common.Root `json:"state_summary_root" yaml:"state_summary_root"`
+}
+
+func (s *HistoricalSummary) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&s.BlockSummaryRoot, &s.StateSummaryRoot)
+}
+
+func (s *HistoricalSummary) Serialize(w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(&s.BlockSummaryRoot, &s.StateSummaryRoot)
+}
+
+func (s *HistoricalSummary) ByteLength() uint64 {
+	return 32 * 2
+}
+
+func (*HistoricalSummary) FixedLength() uint64 {
+	return 32 * 2
+}
+
+func (s *HistoricalSummary) HashTreeRoot(hFn tree.HashFn) common.Root {
+	return hFn.HashTreeRoot(&s.BlockSummaryRoot, &s.StateSummaryRoot)
+}
+
+func (s *HistoricalSummary) View() *ContainerView {
+	a, b := RootView(s.BlockSummaryRoot), RootView(s.StateSummaryRoot)
+	c, _ := HistoricalSummaryType.FromFields(&a, &b)
+	return c
+}
+
+type HistoricalSummaries []HistoricalSummary
+
+func (a *HistoricalSummaries) Deserialize(spec *common.Spec, dr *codec.DecodingReader) error {
+	return dr.List(func() codec.Deserializable {
+		i := len(*a)
+		*a = append(*a, HistoricalSummary{})
+		return &(*a)[i]
+	}, HistoricalSummaryType.TypeByteLength(), spec.HISTORICAL_ROOTS_LIMIT)
+}
+
+func (a HistoricalSummaries) Serialize(spec *common.Spec, w *codec.EncodingWriter) error {
+	return w.List(func(i uint64) codec.Serializable {
+		return &a[i]
+	}, HistoricalSummaryType.TypeByteLength(), uint64(len(a)))
+}
+
+func (a HistoricalSummaries) ByteLength(spec *common.Spec) (out uint64) {
+	return HistoricalSummaryType.TypeByteLength() * uint64(len(a))
+}
+
+func (*HistoricalSummaries) FixedLength(*common.Spec) uint64 {
+	return 0 // it's a list, no fixed length
+}
+
+func (li HistoricalSummaries) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) common.Root {
+	length := uint64(len(li))
+	return hFn.ComplexListHTR(func(i uint64) tree.HTR {
+		if i < length {
+			return &li[i]
+		}
+		return nil
+	}, length, spec.HISTORICAL_ROOTS_LIMIT)
+}
+
+func HistoricalSummariesType(spec *common.Spec) ListTypeDef {
+	return ListType(HistoricalSummaryType, spec.HISTORICAL_ROOTS_LIMIT)
+}
+
+type HistoricalSummariesView struct{ *ComplexListView }
+
+func AsHistoricalSummaries(v View, err error) (*HistoricalSummariesView, error) {
+	c, err := AsComplexList(v, err)
+	return &HistoricalSummariesView{c}, err
+}
+
+func (h *HistoricalSummariesView) Append(summary HistoricalSummary) error {
+	return h.ComplexListView.Append(summary.View())
+}
+
+type HistoricalSummariesBeaconState interface {
+	common.BeaconState
+
+	HistoricalSummaries() (*HistoricalSummariesView, error)
+}
+
+// ProcessHistoricalSummariesUpdate replaces the historical roots update of earlier forks:
+// the historical roots list is frozen, and a summary is appended to the historical summaries instead.
+func ProcessHistoricalSummariesUpdate(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state HistoricalSummariesBeaconState) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Set historical block root accumulator.
+	if epc.NextEpoch.Epoch%spec.SlotToEpoch(spec.SLOTS_PER_HISTORICAL_ROOT) == 0 {
+		summaries, err := state.HistoricalSummaries()
+		if err != nil {
+			return err
+		}
+		blockRoots, err := state.BlockRoots()
+		if err != nil {
+			return err
+		}
+		stateRoots, err := state.StateRoots()
+		if err != nil {
+			return err
+		}
+		hFn := tree.GetHashFn()
+		return summaries.Append(HistoricalSummary{
+			BlockSummaryRoot: blockRoots.HashTreeRoot(hFn),
+			StateSummaryRoot: stateRoots.HashTreeRoot(hFn),
+		})
+	}
+	return nil
+}
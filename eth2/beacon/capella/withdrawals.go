@@ -0,0 +1,183 @@
+package capella
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+type WithdrawalsBeaconState interface {
+	common.BeaconState
+
+	NextWithdrawalIndex() (common.WithdrawalIndex, error)
+	SetNextWithdrawalIndex(index common.WithdrawalIndex) error
+	NextWithdrawalValidatorIndex() (common.ValidatorIndex, error)
+	SetNextWithdrawalValidatorIndex(index common.ValidatorIndex) error
+}
+
+// HasEth1WithdrawalCredential checks if the withdrawal credentials commit to an execution-layer address.
+func HasEth1WithdrawalCredential(creds common.Root) bool {
+	return creds[0] == common.ETH1_ADDRESS_WITHDRAWAL_PREFIX
+}
+
+// IsFullyWithdrawableValidator checks if the full balance of the validator can be withdrawn at the given epoch.
+func IsFullyWithdrawableValidator(val common.Validator, balance common.Gwei, epoch common.Epoch) (bool, error) {
+	creds, err := val.WithdrawalCredentials()
+	if err != nil {
+		return false, err
+	}
+	withdrawableEpoch, err := val.WithdrawableEpoch()
+	if err != nil {
+		return false, err
+	}
+	return HasEth1WithdrawalCredential(creds) && withdrawableEpoch <= epoch && balance > 0, nil
+}
+
+// IsPartiallyWithdrawableValidator checks if the validator has a balance in excess of the max effective balance
+// that can be withdrawn.
+func IsPartiallyWithdrawableValidator(spec *common.Spec, val common.Validator, balance common.Gwei) (bool, error) {
+	creds, err := val.WithdrawalCredentials()
+	if err != nil {
+		return false, err
+	}
+	effBalance, err := val.EffectiveBalance()
+	if err != nil {
+		return false, err
+	}
+	return HasEth1WithdrawalCredential(creds) &&
+		effBalance == spec.MAX_EFFECTIVE_BALANCE && balance > spec.MAX_EFFECTIVE_BALANCE, nil
+}
+
+// GetExpectedWithdrawals sweeps the validator registry, starting at the next withdrawal validator index,
+// to find the withdrawals that the next execution payload must include.
+func GetExpectedWithdrawals(spec *common.Spec, state WithdrawalsBeaconState) (common.Withdrawals, error) {
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	epoch := spec.SlotToEpoch(slot)
+	withdrawalIndex, err := state.NextWithdrawalIndex()
+	if err != nil {
+		return nil, err
+	}
+	validatorIndex, err := state.NextWithdrawalValidatorIndex()
+	if err != nil {
+		return nil, err
+	}
+	validators, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := state.Balances()
+	if err != nil {
+		return nil, err
+	}
+	validatorCount, err := validators.ValidatorCount()
+	if err != nil {
+		return nil, err
+	}
+	bound := validatorCount
+	if bound > spec.MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP {
+		bound = spec.MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP
+	}
+	var withdrawals common.Withdrawals
+	for i := uint64(0); i < bound; i++ {
+		val, err := validators.Validator(validatorIndex)
+		if err != nil {
+			return nil, err
+		}
+		balance, err := balances.GetBalance(validatorIndex)
+		if err != nil {
+			return nil, err
+		}
+		creds, err := val.WithdrawalCredentials()
+		if err != nil {
+			return nil, err
+		}
+		var address common.Eth1Address
+		copy(address[:], creds[12:])
+		if fully, err := IsFullyWithdrawableValidator(val, balance, epoch); err != nil {
+			return nil, err
+		} else if fully {
+			withdrawals = append(withdrawals, common.Withdrawal{
+				Index:          withdrawalIndex,
+				ValidatorIndex: validatorIndex,
+				Address:        address,
+				Amount:         balance,
+			})
+			withdrawalIndex += 1
+		} else if partially, err := IsPartiallyWithdrawableValidator(spec, val, balance); err != nil {
+			return nil, err
+		} else if partially {
+			withdrawals = append(withdrawals, common.Withdrawal{
+				Index:          withdrawalIndex,
+				ValidatorIndex: validatorIndex,
+				Address:        address,
+				Amount:         balance - spec.MAX_EFFECTIVE_BALANCE,
+			})
+			withdrawalIndex += 1
+		}
+		if uint64(len(withdrawals)) == spec.MAX_WITHDRAWALS_PER_PAYLOAD {
+			break
+		}
+		validatorIndex = common.ValidatorIndex((uint64(validatorIndex) + 1) % validatorCount)
+	}
+	return withdrawals, nil
+}
+
+func ProcessWithdrawals(ctx context.Context, spec *common.Spec, state WithdrawalsBeaconState, executionPayload *ExecutionPayload) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	expected, err := GetExpectedWithdrawals(spec, state)
+	if err != nil {
+		return err
+	}
+	if len(executionPayload.Withdrawals) != len(expected) {
+		return fmt.Errorf("expected %d withdrawals in execution payload, but got %d",
+			len(expected), len(executionPayload.Withdrawals))
+	}
+	balances, err := state.Balances()
+	if err != nil {
+		return err
+	}
+	for i := range expected {
+		if executionPayload.Withdrawals[i] != expected[i] {
+			return fmt.Errorf("withdrawal %d does not match expected withdrawal %d", i, expected[i].Index)
+		}
+		if err := common.DecreaseBalance(balances, expected[i].ValidatorIndex, expected[i].Amount); err != nil {
+			return err
+		}
+	}
+	if len(expected) != 0 {
+		if err := state.SetNextWithdrawalIndex(expected[len(expected)-1].Index + 1); err != nil {
+			return err
+		}
+	}
+	validators, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	validatorCount, err := validators.ValidatorCount()
+	if err != nil {
+		return err
+	}
+	if validatorCount == 0 {
+		return errors.New("cannot sweep empty validator registry for withdrawals")
+	}
+	var nextValidatorIndex common.ValidatorIndex
+	if uint64(len(expected)) == spec.MAX_WITHDRAWALS_PER_PAYLOAD {
+		// Next sweep starts after the latest withdrawal's validator index
+		nextValidatorIndex = common.ValidatorIndex((uint64(expected[len(expected)-1].ValidatorIndex) + 1) % validatorCount)
+	} else {
+		// Advance sweep by the max length of the sweep if there was not a full set of withdrawals
+		current, err := state.NextWithdrawalValidatorIndex()
+		if err != nil {
+			return err
+		}
+		nextValidatorIndex = common.ValidatorIndex((uint64(current) + spec.MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP) % validatorCount)
+	}
+	return state.SetNextWithdrawalValidatorIndex(nextValidatorIndex)
+}
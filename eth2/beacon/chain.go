@@ -25,6 +25,8 @@ type ChainEntry interface {
 	//   with ProcessSlots(slot), but without any block processing.
 	// - if not IsEmpty: post-block processing (if any block), excl. latest-header update of next slot.
 	State(ctx context.Context) (common.BeaconState, error)
+	// Block of this entry, nil if the entry is an empty slot, or if the block is not available (e.g. the anchor).
+	Block() (*common.BeaconBlockEnvelope, error)
 }
 
 type SearchEntry struct {
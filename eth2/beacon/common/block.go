@@ -1,10 +1,6 @@
 package common
 
-import (
-	"bytes"
-
-	blsu "github.com/protolambda/bls12-381-util"
-)
+import "bytes"
 
 type BeaconBlockEnvelope struct {
 	ForkDigest ForkDigest
@@ -28,25 +24,28 @@ func (b *BeaconBlockEnvelope) VerifySignature(spec *Spec, genesisValidatorsRoot
 }
 
 func (b *BeaconBlockEnvelope) VerifySignatureVersioned(spec *Spec, version Version, genesisValidatorsRoot Root, proposer ValidatorIndex, cachedPub *CachedPubkey) bool {
+	set, ok := b.SignatureSetVersioned(version, genesisValidatorsRoot, proposer, cachedPub)
+	return ok && set.Verify() == nil
+}
+
+// SignatureSetVersioned returns the signature set of the block signature, to verify later,
+// or false if the block is not from the given proposer or fork.
+func (b *BeaconBlockEnvelope) SignatureSetVersioned(version Version, genesisValidatorsRoot Root, proposer ValidatorIndex, cachedPub *CachedPubkey) (*SignatureSet, bool) {
 	if b.ProposerIndex != proposer {
-		return false
+		return nil, false
 	}
 	forkRoot := ComputeForkDataRoot(version, genesisValidatorsRoot)
 	// Sanity check fork digest
 	if !bytes.Equal(forkRoot[0:4], b.ForkDigest[:]) {
-		return false
-	}
-	pub, err := cachedPub.Pubkey()
-	if err != nil {
-		return false
+		return nil, false
 	}
 	dom := ComputeDomain(DOMAIN_BEACON_PROPOSER, version, genesisValidatorsRoot)
-	signingRoot := ComputeSigningRoot(b.BlockRoot, dom)
-	sig, err := b.Signature.Signature()
-	if err != nil {
-		return false
-	}
-	return blsu.Verify(pub, signingRoot[:], sig)
+	return &SignatureSet{
+		Pubkeys:     []*CachedPubkey{cachedPub},
+		SigningRoot: ComputeSigningRoot(b.BlockRoot, dom),
+		Signature:   b.Signature,
+		Description: "block has invalid signature",
+	}, true
 }
 
 type EnvelopeBuilder interface {
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	blsu "github.com/protolambda/bls12-381-util"
+)
+
+var (
+	infinityPubkey    = BLSPubkey{0xc0}
+	infinitySignature = BLSSignature{0xc0}
+)
+
+// SignatureSet is a signature over the signing root, by the aggregate of the pubkeys.
+type SignatureSet struct {
+	Pubkeys     []*CachedPubkey
+	SigningRoot Root
+	Signature   BLSSignature
+	// Description is the error message if the signature is invalid.
+	Description string
+}
+
+func (s *SignatureSet) pubkeys() ([]*blsu.Pubkey, error) {
+	out := make([]*blsu.Pubkey, 0, len(s.Pubkeys))
+	for _, p := range s.Pubkeys {
+		pub, err := p.Pubkey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize cached pubkey: %v", err)
+		}
+		out = append(out, pub)
+	}
+	return out, nil
+}
+
+// Verify checks the signature set on its own.
+func (s *SignatureSet) Verify() error {
+	pubkeys, err := s.pubkeys()
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.Description, err)
+	}
+	sig, err := s.Signature.Signature()
+	if err != nil {
+		return fmt.Errorf("%s: failed to deserialize and sub-group check signature: %v", s.Description, err)
+	}
+	if !blsu.Eth2FastAggregateVerify(pubkeys, s.SigningRoot[:], sig) {
+		return errors.New(s.Description)
+	}
+	return nil
+}
+
+// batchable returns false for the edge cases of signature verification that a batch does not cover:
+// the empty set, and identity pubkeys and signatures.
+func (s *SignatureSet) batchable() bool {
+	if len(s.Pubkeys) == 0 || s.Signature == infinitySignature {
+		return false
+	}
+	for _, p := range s.Pubkeys {
+		if p.Compressed == infinityPubkey {
+			return false
+		}
+	}
+	return true
+}
+
+// SignatureBatch collects signature sets, to verify them all at once in a single randomized batch.
+type SignatureBatch struct {
+	mu   sync.Mutex
+	sets []*SignatureSet
+}
+
+func NewSignatureBatch() *SignatureBatch {
+	return &SignatureBatch{}
+}
+
+// Add the signature set to the batch, without verifying it.
+func (b *SignatureBatch) Add(set *SignatureSet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sets = append(b.sets, set)
+}
+
+// Len returns the number of signature sets in the batch.
+func (b *SignatureBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sets)
+}
+
+// Verify verifies all signature sets of the batch.
+// If the batch is invalid, the sets are verified one by one, to return the error of the first invalid set.
+func (b *SignatureBatch) Verify() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.sets) == 0 {
+		return nil
+	}
+	pubkeys := make([]*blsu.Pubkey, 0, len(b.sets))
+	messages := make([][]byte, 0, len(b.sets))
+	signatures := make([]*blsu.Signature, 0, len(b.sets))
+	valid := true
+	for _, set := range b.sets {
+		setPubkeys, err := set.pubkeys()
+		if err != nil {
+			valid = false
+			break
+		}
+		pub, err := blsu.AggregatePubkeys(setPubkeys)
+		if err != nil {
+			valid = false
+			break
+		}
+		sig, err := set.Signature.Signature()
+		if err != nil {
+			valid = false
+			break
+		}
+		signingRoot := set.SigningRoot
+		pubkeys = append(pubkeys, pub)
+		messages = append(messages, signingRoot[:])
+		signatures = append(signatures, sig)
+	}
+	if valid {
+		if ok, err := blsu.SignatureSetVerify(pubkeys, messages, signatures); err == nil && ok {
+			return nil
+		}
+	}
+	for _, set := range b.sets {
+		if err := set.Verify(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type signatureBatchKey struct{}
+
+// WithSignatureBatch returns a context that makes VerifySignatureSet collect signature sets into the batch,
+// instead of verifying them immediately. The caller is responsible for verifying the batch afterwards.
+func WithSignatureBatch(ctx context.Context, b *SignatureBatch) context.Context {
+	return context.WithValue(ctx, signatureBatchKey{}, b)
+}
+
+// SignatureBatchFromContext returns the batch of the context, or nil if signatures are verified immediately.
+func SignatureBatchFromContext(ctx context.Context) *SignatureBatch {
+	b, _ := ctx.Value(signatureBatchKey{}).(*SignatureBatch)
+	return b
+}
+
+// VerifySignatureSet verifies the signature set, or adds it to the signature batch of the context, if any.
+func VerifySignatureSet(ctx context.Context, set *SignatureSet) error {
+	if b := SignatureBatchFromContext(ctx); b != nil && set.batchable() {
+		b.Add(set)
+		return nil
+	}
+	return set.Verify()
+}
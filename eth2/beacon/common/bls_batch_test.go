@@ -0,0 +1,102 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+)
+
+func testSignatureSet(t *testing.T, seed byte, n int, root Root) *SignatureSet {
+	set := &SignatureSet{SigningRoot: root, Description: "invalid test signature"}
+	var sigs []*blsu.Signature
+	for i := 0; i < n; i++ {
+		var raw [32]byte
+		raw[30] = seed
+		raw[31] = byte(i + 1)
+		var sk blsu.SecretKey
+		if err := sk.Deserialize(&raw); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(&sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.Pubkeys = append(set.Pubkeys, &CachedPubkey{Compressed: pub.Serialize()})
+		sigs = append(sigs, blsu.Sign(&sk, root[:]))
+	}
+	sig, err := blsu.Aggregate(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Signature = sig.Serialize()
+	return set
+}
+
+func TestSignatureBatch(t *testing.T) {
+	batch := NewSignatureBatch()
+	ctx := WithSignatureBatch(context.Background(), batch)
+	for i := 0; i < 5; i++ {
+		set := testSignatureSet(t, byte(i), i+1, Root{byte(i)})
+		if err := VerifySignatureSet(ctx, set); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if batch.Len() != 5 {
+		t.Fatalf("expected 5 deferred signature sets, got %d", batch.Len())
+	}
+	if err := batch.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A set signed over another root is only detected when verifying the batch.
+	bad := testSignatureSet(t, 10, 3, Root{0xaa})
+	bad.SigningRoot = Root{0xbb}
+	bad.Description = "culprit"
+	if err := VerifySignatureSet(ctx, bad); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySignatureSet(ctx, testSignatureSet(t, 11, 2, Root{0xcc})); err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Verify(); err == nil || err.Error() != "culprit" {
+		t.Fatalf("expected error of invalid set, got %v", err)
+	}
+
+	// Without a batch in the context, sets are verified immediately.
+	if err := VerifySignatureSet(context.Background(), bad); err == nil {
+		t.Fatal("expected invalid set to be rejected")
+	}
+}
+
+func TestSignatureBatchEdgeCases(t *testing.T) {
+	batch := NewSignatureBatch()
+	ctx := WithSignatureBatch(context.Background(), batch)
+	// Empty sets with the infinity signature are valid, and verified immediately.
+	empty := &SignatureSet{Signature: infinitySignature, Description: "empty"}
+	if err := VerifySignatureSet(ctx, empty); err != nil {
+		t.Fatal(err)
+	}
+	// The infinity signature is never valid for pubkeys, even if they aggregate to the infinity pubkey.
+	infinity := testSignatureSet(t, 1, 1, Root{1})
+	infinity.Signature = infinitySignature
+	if err := VerifySignatureSet(ctx, infinity); err == nil {
+		t.Fatal("expected infinity signature to be rejected")
+	}
+	// Identity pubkeys are not accepted in aggregates.
+	identity := testSignatureSet(t, 1, 2, Root{1})
+	identity.Pubkeys = append(identity.Pubkeys, &CachedPubkey{Compressed: infinityPubkey})
+	if err := VerifySignatureSet(ctx, identity); err == nil {
+		t.Fatal("expected set with identity pubkey to be rejected")
+	}
+	if batch.Len() != 0 {
+		t.Fatalf("expected edge cases to not be batched, got %d sets", batch.Len())
+	}
+	// Undecodable signatures fail the batch.
+	malformed := testSignatureSet(t, 1, 1, Root{1})
+	malformed.Signature[5] ^= 0xff
+	batch.Add(malformed)
+	if err := batch.Verify(); err == nil {
+		t.Fatal("expected malformed signature to fail the batch")
+	}
+}
@@ -133,16 +133,24 @@ func (v *ExecutionPayloadHeaderView) Timestamp() (Timestamp, error) {
 	return AsTimestamp(v.Get(9))
 }
 
+func (v *ExecutionPayloadHeaderView) ExtraData() (ExtraData, error) {
+	extraV, err := AsExtraData(v.Get(10))
+	if err != nil {
+		return nil, err
+	}
+	return extraV.Raw()
+}
+
 func (v *ExecutionPayloadHeaderView) BaseFeePerGas() (Uint256View, error) {
-	return AsUint256(v.Get(10))
+	return AsUint256(v.Get(11))
 }
 
 func (v *ExecutionPayloadHeaderView) BlockHash() (Hash32, error) {
-	return AsRoot(v.Get(11))
+	return AsRoot(v.Get(12))
 }
 
 func (v *ExecutionPayloadHeaderView) TransactionsRoot() (Root, error) {
-	return AsRoot(v.Get(12))
+	return AsRoot(v.Get(13))
 }
 
 func AsExecutionPayloadHeader(v View, err error) (*ExecutionPayloadHeaderView, error) {
@@ -367,7 +375,67 @@ func (ep *ExecutionPayload) Header(spec *Spec) *ExecutionPayloadHeader {
 	}
 }
 
+// PayloadID identifies a payload build process in the execution engine.
+type PayloadID [8]byte
+
+func (id PayloadID) MarshalText() ([]byte, error) {
+	return conv.BytesMarshalText(id[:])
+}
+
+func (id PayloadID) String() string {
+	return "0x" + hex.EncodeToString(id[:])
+}
+
+func (id *PayloadID) UnmarshalText(text []byte) error {
+	if id == nil {
+		return errors.New("cannot decode into nil payload ID")
+	}
+	return conv.FixedBytesUnmarshalText(id[:], text)
+}
+
+// PayloadAttributes are the beacon-chain inputs for the execution engine to build a payload with.
+type PayloadAttributes struct {
+	Timestamp             Timestamp   `json:"timestamp" yaml:"timestamp"`
+	PrevRandao            Bytes32     `json:"prev_randao" yaml:"prev_randao"`
+	SuggestedFeeRecipient Eth1Address `json:"suggested_fee_recipient" yaml:"suggested_fee_recipient"`
+}
+
+// PayloadExecutionStatus is the result of inserting a payload into the execution engine.
+type PayloadExecutionStatus uint8
+
+const (
+	// PayloadExecutionValid is the status of payloads that are fully verified by the execution engine.
+	PayloadExecutionValid PayloadExecutionStatus = iota
+	// PayloadExecutionOptimistic is the status of payloads that the engine could not verify yet,
+	// e.g. because it is syncing. The block may be imported optimistically, but is not verified.
+	PayloadExecutionOptimistic
+	// PayloadExecutionInvalid is the status of payloads that the engine found to be invalid.
+	PayloadExecutionInvalid
+)
+
+func (s PayloadExecutionStatus) String() string {
+	switch s {
+	case PayloadExecutionValid:
+		return "valid"
+	case PayloadExecutionOptimistic:
+		return "optimistic"
+	case PayloadExecutionInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
 type ExecutionEngine interface {
-	ExecutePayload(ctx context.Context, executionPayload *ExecutionPayload) (valid bool, err error)
-	// TODO: remaining interface parts
+	// ExecutePayload inserts the payload into the execution engine.
+	// The payload is only considered invalid if the engine says so,
+	// payloads that cannot be verified yet are reported as optimistic.
+	ExecutePayload(ctx context.Context, executionPayload *ExecutionPayload) (PayloadExecutionStatus, error)
+	// NotifyForkchoiceUpdated updates the fork-choice of the execution engine.
+	// If attributes are specified, the engine starts building a payload on top of the head,
+	// and the ID of the build process is returned.
+	NotifyForkchoiceUpdated(ctx context.Context, headBlockHash Hash32, safeBlockHash Hash32, finalizedBlockHash Hash32,
+		attributes *PayloadAttributes) (*PayloadID, error)
+	// GetPayload retrieves the payload of a build process that was started with NotifyForkchoiceUpdated.
+	GetPayload(ctx context.Context, payloadID PayloadID) (*ExecutionPayload, error)
 }
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"testing"
+
+	. "github.com/protolambda/ztyp/view"
+)
+
+func TestExecutionPayloadHeaderView(t *testing.T) {
+	header := ExecutionPayloadHeader{
+		Timestamp:        9,
+		ExtraData:        ExtraData{0x0a},
+		BaseFeePerGas:    Uint256View{11},
+		BlockHash:        Hash32{0x0c},
+		TransactionsRoot: Root{0x0d},
+	}
+	v := header.View()
+	ts, err := v.Timestamp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != header.Timestamp {
+		t.Errorf("unexpected timestamp: %d", ts)
+	}
+	baseFee, err := v.BaseFeePerGas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseFee != header.BaseFeePerGas {
+		t.Errorf("unexpected base fee: %s", baseFee.String())
+	}
+	blockHash, err := v.BlockHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockHash != header.BlockHash {
+		t.Errorf("unexpected block hash: %s", blockHash)
+	}
+	txsRoot, err := v.TransactionsRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txsRoot != header.TransactionsRoot {
+		t.Errorf("unexpected transactions root: %s", txsRoot)
+	}
+}
@@ -311,3 +311,74 @@ func (i *Goodbye) UnmarshalJSON(b []byte) error {
 func (i Goodbye) String() string {
 	return Uint64View(i).String()
 }
+
+// MAX_REQUEST_BLOCKS is the maximum number of blocks in a single req/resp request.
+const MAX_REQUEST_BLOCKS = 1024
+
+// MAX_CHUNK_SIZE is the maximum allowed size of uncompressed req/resp chunked responses.
+const MAX_CHUNK_SIZE = 10 * (1 << 20)
+
+type BeaconBlocksByRangeRequest struct {
+	StartSlot Slot       `json:"start_slot" yaml:"start_slot"`
+	Count     Uint64View `json:"count" yaml:"count"`
+	Step      Uint64View `json:"step" yaml:"step"`
+}
+
+func (r *BeaconBlocksByRangeRequest) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&r.StartSlot, &r.Count, &r.Step)
+}
+
+func (r *BeaconBlocksByRangeRequest) Serialize(w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(&r.StartSlot, &r.Count, &r.Step)
+}
+
+const BeaconBlocksByRangeRequestByteLen = 8 + 8 + 8
+
+func (r BeaconBlocksByRangeRequest) ByteLength() uint64 {
+	return BeaconBlocksByRangeRequestByteLen
+}
+
+func (*BeaconBlocksByRangeRequest) FixedLength() uint64 {
+	return BeaconBlocksByRangeRequestByteLen
+}
+
+func (r *BeaconBlocksByRangeRequest) HashTreeRoot(hFn tree.HashFn) Root {
+	return hFn.HashTreeRoot(&r.StartSlot, &r.Count, &r.Step)
+}
+
+func (r *BeaconBlocksByRangeRequest) String() string {
+	return fmt.Sprintf("BeaconBlocksByRange(start_slot: %d, count: %d, step: %d)", r.StartSlot, r.Count, r.Step)
+}
+
+// BeaconBlocksByRootRequest is a list of block roots, up to MAX_REQUEST_BLOCKS.
+type BeaconBlocksByRootRequest []Root
+
+func (r *BeaconBlocksByRootRequest) Deserialize(dr *codec.DecodingReader) error {
+	return tree.ReadRootsLimited(dr, (*[]Root)(r), MAX_REQUEST_BLOCKS)
+}
+
+func (r BeaconBlocksByRootRequest) Serialize(w *codec.EncodingWriter) error {
+	return tree.WriteRoots(w, r)
+}
+
+func (r BeaconBlocksByRootRequest) ByteLength() uint64 {
+	return uint64(len(r)) * 32
+}
+
+func (BeaconBlocksByRootRequest) FixedLength() uint64 {
+	return 0
+}
+
+func (r BeaconBlocksByRootRequest) HashTreeRoot(hFn tree.HashFn) Root {
+	length := uint64(len(r))
+	return hFn.ComplexListHTR(func(i uint64) tree.HTR {
+		if i < length {
+			return &r[i]
+		}
+		return nil
+	}, length, MAX_REQUEST_BLOCKS)
+}
+
+func (r BeaconBlocksByRootRequest) String() string {
+	return fmt.Sprintf("BeaconBlocksByRoot(count: %d)", len(r))
+}
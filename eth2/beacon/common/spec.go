@@ -14,6 +14,7 @@ const TARGET_AGGREGATORS_PER_COMMITTEE = 16
 const RANDOM_SUBNETS_PER_VALIDATOR = 1
 const EPOCHS_PER_RANDOM_SUBNET_SUBSCRIPTION = 256
 const BLS_WITHDRAWAL_PREFIX = 0
+const ETH1_ADDRESS_WITHDRAWAL_PREFIX = 1
 const SYNC_COMMITTEE_SUBNET_COUNT = 4
 const TARGET_AGGREGATORS_PER_SYNC_SUBCOMMITTEE = 16
 
@@ -31,6 +32,9 @@ var DOMAIN_SYNC_COMMITTEE = BLSDomainType{0x07, 0x00, 0x00, 0x00}
 var DOMAIN_SYNC_COMMITTEE_SELECTION_PROOF = BLSDomainType{0x08, 0x00, 0x00, 0x00}
 var DOMAIN_CONTRIBUTION_AND_PROOF = BLSDomainType{0x09, 0x00, 0x00, 0x00}
 
+// Capella
+var DOMAIN_BLS_TO_EXECUTION_CHANGE = BLSDomainType{0x0A, 0x00, 0x00, 0x00}
+
 // Sharding
 var DOMAIN_SHARD_BLOB = BLSDomainType{0x80, 0x00, 0x00, 0x00}
 
@@ -109,6 +113,17 @@ type BellatrixPreset struct {
 	MAX_EXTRA_DATA_BYTES                       uint64 `yaml:"MAX_EXTRA_DATA_BYTES" json:"MAX_EXTRA_DATA_BYTES"`
 }
 
+type CapellaPreset struct {
+	// Max operations per block
+	MAX_BLS_TO_EXECUTION_CHANGES uint64 `yaml:"MAX_BLS_TO_EXECUTION_CHANGES" json:"MAX_BLS_TO_EXECUTION_CHANGES"`
+
+	// Execution
+	MAX_WITHDRAWALS_PER_PAYLOAD uint64 `yaml:"MAX_WITHDRAWALS_PER_PAYLOAD" json:"MAX_WITHDRAWALS_PER_PAYLOAD"`
+
+	// Withdrawals processing
+	MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP uint64 `yaml:"MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP" json:"MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP"`
+}
+
 type ShardingPreset struct {
 	// Misc.
 	MAX_SHARDS                          uint64 `yaml:"MAX_SHARDS" json:"MAX_SHARDS"`
@@ -145,6 +160,10 @@ type Config struct {
 	BELLATRIX_FORK_VERSION Version `yaml:"BELLATRIX_FORK_VERSION" json:"BELLATRIX_FORK_VERSION"`
 	BELLATRIX_FORK_EPOCH   Epoch   `yaml:"BELLATRIX_FORK_EPOCH" json:"BELLATRIX_FORK_EPOCH"`
 
+	// Capella
+	CAPELLA_FORK_VERSION Version `yaml:"CAPELLA_FORK_VERSION" json:"CAPELLA_FORK_VERSION"`
+	CAPELLA_FORK_EPOCH   Epoch   `yaml:"CAPELLA_FORK_EPOCH" json:"CAPELLA_FORK_EPOCH"`
+
 	// Sharding
 	SHARDING_FORK_VERSION Version `yaml:"SHARDING_FORK_VERSION" json:"SHARDING_FORK_VERSION"`
 	SHARDING_FORK_EPOCH   Epoch   `yaml:"SHARDING_FORK_EPOCH" json:"SHARDING_FORK_EPOCH"`
@@ -252,6 +271,7 @@ type Spec struct {
 	Phase0Preset    `json:",inline" yaml:",inline"`
 	AltairPreset    `json:",inline" yaml:",inline"`
 	BellatrixPreset `json:",inline" yaml:",inline"`
+	CapellaPreset   `json:",inline" yaml:",inline"`
 	ShardingPreset  `json:",inline" yaml:",inline"`
 	Config          `json:",inline" yaml:",inline"`
 	Setup           `json:",inline" yaml:",inline"`
@@ -288,8 +308,10 @@ func (spec *Spec) ForkVersion(slot Slot) Version {
 		return spec.GENESIS_FORK_VERSION
 	} else if epoch < spec.BELLATRIX_FORK_EPOCH {
 		return spec.ALTAIR_FORK_VERSION
-	} else if epoch < spec.SHARDING_FORK_EPOCH {
+	} else if epoch < spec.CAPELLA_FORK_EPOCH {
 		return spec.BELLATRIX_FORK_VERSION
+	} else if epoch < spec.SHARDING_FORK_EPOCH {
+		return spec.CAPELLA_FORK_VERSION
 	} else {
 		return spec.SHARDING_FORK_VERSION
 	}
@@ -101,6 +101,7 @@ func ProcessSlots(ctx context.Context, spec *Spec, epc *EpochsContext, state Upg
 // StateTransition to the slot of the given block, then process the block.
 // Returns an error if the slot is older or equal to what the state is already at.
 // Mutates the state, does not copy.
+// If the context has a SignatureBatch, the signatures are collected into it, and the caller has to verify the batch.
 func StateTransition(ctx context.Context, spec *Spec, epc *EpochsContext, state UpgradeableBeaconState, benv *BeaconBlockEnvelope, validateResult bool) error {
 	if err := ProcessSlots(ctx, spec, epc, state, benv.Slot); err != nil {
 		return err
@@ -140,9 +141,13 @@ func PostSlotTransition(ctx context.Context, spec *Spec, epc *EpochsContext, sta
 		if !ok {
 			return fmt.Errorf("unknown pubkey for proposer %d", proposer)
 		}
-		if !benv.VerifySignatureVersioned(spec, fork.CurrentVersion, genValRoot, proposer, pub) {
+		set, ok := benv.SignatureSetVersioned(fork.CurrentVersion, genValRoot, proposer, pub)
+		if !ok {
 			return errors.New("block has invalid signature")
 		}
+		if err := VerifySignatureSet(ctx, set); err != nil {
+			return err
+		}
 	}
 	if err := state.ProcessBlock(ctx, spec, epc, benv); err != nil {
 		return err
@@ -4,6 +4,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	. "github.com/protolambda/ztyp/view"
 )
 
 type WithdrawalPrefix [1]byte
@@ -29,3 +33,117 @@ func (p *WithdrawalPrefix) UnmarshalText(text []byte) error {
 	_, err := hex.Decode(p[:], text)
 	return err
 }
+
+const WithdrawalIndexType = Uint64Type
+
+// Index of a withdrawal, counting all withdrawals since the Capella fork
+type WithdrawalIndex Uint64View
+
+func AsWithdrawalIndex(v View, err error) (WithdrawalIndex, error) {
+	i, err := AsUint64(v, err)
+	return WithdrawalIndex(i), err
+}
+
+func (i *WithdrawalIndex) Deserialize(dr *codec.DecodingReader) error {
+	return (*Uint64View)(i).Deserialize(dr)
+}
+
+func (i WithdrawalIndex) Serialize(w *codec.EncodingWriter) error {
+	return w.WriteUint64(uint64(i))
+}
+
+func (WithdrawalIndex) ByteLength() uint64 {
+	return 8
+}
+
+func (WithdrawalIndex) FixedLength() uint64 {
+	return 8
+}
+
+func (i WithdrawalIndex) HashTreeRoot(hFn tree.HashFn) Root {
+	return Uint64View(i).HashTreeRoot(hFn)
+}
+
+func (e WithdrawalIndex) MarshalJSON() ([]byte, error) {
+	return Uint64View(e).MarshalJSON()
+}
+
+func (e *WithdrawalIndex) UnmarshalJSON(b []byte) error {
+	return ((*Uint64View)(e)).UnmarshalJSON(b)
+}
+
+func (e WithdrawalIndex) String() string {
+	return Uint64View(e).String()
+}
+
+var WithdrawalType = ContainerType("Withdrawal", []FieldDef{
+	{"index", WithdrawalIndexType},
+	{"validator_index", ValidatorIndexType},
+	{"address", Eth1AddressType},
+	{"amount", GweiType},
+})
+
+type Withdrawal struct {
+	Index          WithdrawalIndex `json:"index" yaml:"index"`
+	ValidatorIndex ValidatorIndex  `json:"validator_index" yaml:"validator_index"`
+	Address        Eth1Address     `json:"address" yaml:"address"`
+	Amount         Gwei            `json:"amount" yaml:"amount"`
+}
+
+func (s *Withdrawal) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&s.Index, &s.ValidatorIndex, &s.Address, &s.Amount)
+}
+
+func (s *Withdrawal) Serialize(w *codec.EncodingWriter) error {
+	return w.FixedLenContainer(&s.Index, &s.ValidatorIndex, &s.Address, &s.Amount)
+}
+
+func (s *Withdrawal) ByteLength() uint64 {
+	return WithdrawalType.TypeByteLength()
+}
+
+func (s *Withdrawal) FixedLength() uint64 {
+	return WithdrawalType.TypeByteLength()
+}
+
+func (s *Withdrawal) HashTreeRoot(hFn tree.HashFn) Root {
+	return hFn.HashTreeRoot(&s.Index, &s.ValidatorIndex, &s.Address, &s.Amount)
+}
+
+func WithdrawalsType(spec *Spec) ListTypeDef {
+	return ListType(WithdrawalType, spec.MAX_WITHDRAWALS_PER_PAYLOAD)
+}
+
+type Withdrawals []Withdrawal
+
+func (ws *Withdrawals) Deserialize(spec *Spec, dr *codec.DecodingReader) error {
+	return dr.List(func() codec.Deserializable {
+		i := len(*ws)
+		*ws = append(*ws, Withdrawal{})
+		return &((*ws)[i])
+	}, WithdrawalType.TypeByteLength(), spec.MAX_WITHDRAWALS_PER_PAYLOAD)
+}
+
+func (ws Withdrawals) Serialize(spec *Spec, w *codec.EncodingWriter) error {
+	return w.List(func(i uint64) codec.Serializable {
+		return &ws[i]
+	}, WithdrawalType.TypeByteLength(), uint64(len(ws)))
+}
+
+func (ws Withdrawals) ByteLength(spec *Spec) (out uint64) {
+	return WithdrawalType.TypeByteLength() * uint64(len(ws))
+}
+
+func (ws *Withdrawals) FixedLength(*Spec) uint64 {
+	return 0
+}
+
+func (ws Withdrawals) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
+	length := uint64(len(ws))
+	return hFn.ComplexListHTR(func(i uint64) tree.HTR {
+		if i < length {
+			return &ws[i]
+		}
+		return nil
+	}, length, spec.MAX_WITHDRAWALS_PER_PAYLOAD)
+}
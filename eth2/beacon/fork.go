@@ -6,8 +6,10 @@ import (
 
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/codec"
 )
 
 type ForkDecoder struct {
@@ -15,6 +17,7 @@ type ForkDecoder struct {
 	Genesis   common.ForkDigest
 	Altair    common.ForkDigest
 	Bellatrix common.ForkDigest
+	Capella   common.ForkDigest
 	Sharding  common.ForkDigest
 	// TODO more forks
 }
@@ -25,6 +28,7 @@ func NewForkDecoder(spec *common.Spec, genesisValRoot common.Root) *ForkDecoder
 		Genesis:   common.ComputeForkDigest(spec.GENESIS_FORK_VERSION, genesisValRoot),
 		Altair:    common.ComputeForkDigest(spec.ALTAIR_FORK_VERSION, genesisValRoot),
 		Bellatrix: common.ComputeForkDigest(spec.BELLATRIX_FORK_VERSION, genesisValRoot),
+		Capella:   common.ComputeForkDigest(spec.CAPELLA_FORK_VERSION, genesisValRoot),
 		Sharding:  common.ComputeForkDigest(spec.SHARDING_FORK_VERSION, genesisValRoot),
 	}
 }
@@ -42,6 +46,8 @@ func (d *ForkDecoder) BlockAllocator(digest common.ForkDigest) (func() OpaqueBlo
 		return func() OpaqueBlock { return new(altair.SignedBeaconBlock) }, nil
 	case d.Bellatrix:
 		return func() OpaqueBlock { return new(bellatrix.SignedBeaconBlock) }, nil
+	case d.Capella:
+		return func() OpaqueBlock { return new(capella.SignedBeaconBlock) }, nil
 	//case d.Sharding:
 	//	return new(sharding.SignedBeaconBlock), nil
 	default:
@@ -49,13 +55,30 @@ func (d *ForkDecoder) BlockAllocator(digest common.ForkDigest) (func() OpaqueBlo
 	}
 }
 
+func (d *ForkDecoder) DecodeState(digest common.ForkDigest, dr *codec.DecodingReader) (common.BeaconState, error) {
+	switch digest {
+	case d.Genesis:
+		return phase0.AsBeaconStateView(phase0.BeaconStateType(d.Spec).Deserialize(dr))
+	case d.Altair:
+		return altair.AsBeaconStateView(altair.BeaconStateType(d.Spec).Deserialize(dr))
+	case d.Bellatrix:
+		return bellatrix.AsBeaconStateView(bellatrix.BeaconStateType(d.Spec).Deserialize(dr))
+	case d.Capella:
+		return capella.AsBeaconStateView(capella.BeaconStateType(d.Spec).Deserialize(dr))
+	default:
+		return nil, fmt.Errorf("unrecognized fork digest: %s", digest)
+	}
+}
+
 func (d *ForkDecoder) ForkDigest(epoch common.Epoch) common.ForkDigest {
 	if epoch < d.Spec.ALTAIR_FORK_EPOCH {
 		return d.Genesis
 	} else if epoch < d.Spec.BELLATRIX_FORK_EPOCH {
 		return d.Altair
-	} else if epoch < d.Spec.SHARDING_FORK_EPOCH {
+	} else if epoch < d.Spec.CAPELLA_FORK_EPOCH {
 		return d.Bellatrix
+	} else if epoch < d.Spec.SHARDING_FORK_EPOCH {
+		return d.Capella
 	} else {
 		return d.Sharding
 	}
@@ -87,6 +110,13 @@ func (s *StandardUpgradeableBeaconState) UpgradeMaybe(ctx context.Context, spec
 		}
 		s.BeaconState = post
 	}
+	if tpre, ok := s.BeaconState.(*bellatrix.BeaconStateView); ok && slot == common.Slot(spec.CAPELLA_FORK_EPOCH)*spec.SLOTS_PER_EPOCH {
+		post, err := capella.UpgradeToCapella(spec, epc, tpre)
+		if err != nil {
+			return fmt.Errorf("failed to upgrade bellatrix to capella state: %v", err)
+		}
+		s.BeaconState = post
+	}
 	//if slot == common.Slot(spec.SHARDING_FORK_EPOCH)*spec.SLOTS_PER_EPOCH {
 	// TODO: upgrade
 	//}
@@ -130,7 +160,58 @@ func EnvelopeToSignedBeaconBlock(benv *common.BeaconBlockEnvelope) (common.SpecO
 			},
 			Signature: benv.Signature,
 		}, nil
+	case *capella.BeaconBlockBody:
+		return &capella.SignedBeaconBlock{
+			Message: capella.BeaconBlock{
+				Slot:          benv.Slot,
+				ProposerIndex: benv.ProposerIndex,
+				ParentRoot:    benv.ParentRoot,
+				StateRoot:     benv.StateRoot,
+				Body:          *x,
+			},
+			Signature: benv.Signature,
+		}, nil
 	default:
 		return nil, fmt.Errorf("cannot convert beacon block envelope to full signed block, unrecognized body type: %T", x)
 	}
 }
+
+// EnvelopeToBeaconBlock converts the envelope to the unsigned block of the fork of the envelope body.
+func EnvelopeToBeaconBlock(benv *common.BeaconBlockEnvelope) (common.SpecObj, error) {
+	switch x := benv.Body.(type) {
+	case *phase0.BeaconBlockBody:
+		return &phase0.BeaconBlock{
+			Slot:          benv.Slot,
+			ProposerIndex: benv.ProposerIndex,
+			ParentRoot:    benv.ParentRoot,
+			StateRoot:     benv.StateRoot,
+			Body:          *x,
+		}, nil
+	case *altair.BeaconBlockBody:
+		return &altair.BeaconBlock{
+			Slot:          benv.Slot,
+			ProposerIndex: benv.ProposerIndex,
+			ParentRoot:    benv.ParentRoot,
+			StateRoot:     benv.StateRoot,
+			Body:          *x,
+		}, nil
+	case *bellatrix.BeaconBlockBody:
+		return &bellatrix.BeaconBlock{
+			Slot:          benv.Slot,
+			ProposerIndex: benv.ProposerIndex,
+			ParentRoot:    benv.ParentRoot,
+			StateRoot:     benv.StateRoot,
+			Body:          *x,
+		}, nil
+	case *capella.BeaconBlockBody:
+		return &capella.BeaconBlock{
+			Slot:          benv.Slot,
+			ProposerIndex: benv.ProposerIndex,
+			ParentRoot:    benv.ParentRoot,
+			StateRoot:     benv.StateRoot,
+			Body:          *x,
+		}, nil
+	default:
+		return nil, fmt.Errorf("cannot convert beacon block envelope to full block, unrecognized body type: %T", x)
+	}
+}
@@ -93,14 +93,14 @@ func ProcessAttestations(ctx context.Context, spec *common.Spec, epc *common.Epo
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if err := ProcessAttestation(spec, epc, state, &ops[i]); err != nil {
+		if err := ProcessAttestation(ctx, spec, epc, state, &ops[i]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func ProcessAttestation(spec *common.Spec, epc *common.EpochsContext, state Phase0PendingAttestationsBeaconState, attestation *Attestation) error {
+func ProcessAttestation(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state Phase0PendingAttestationsBeaconState, attestation *Attestation) error {
 	data := &attestation.Data
 
 	// Check slot
@@ -163,7 +163,7 @@ func ProcessAttestation(spec *common.Spec, epc *common.EpochsContext, state Phas
 	}
 	if indexedAtt, err := attestation.ConvertToIndexed(spec, committee); err != nil {
 		return fmt.Errorf("attestation could not be converted to an indexed attestation: %v", err)
-	} else if err := ValidateIndexedAttestation(spec, epc, state, indexedAtt); err != nil {
+	} else if err := ValidateIndexedAttestation(ctx, spec, epc, state, indexedAtt); err != nil {
 		return fmt.Errorf("attestation could not be verified in its indexed form: %v", err)
 	}
 
@@ -16,7 +16,7 @@ func ProcessAttesterSlashings(ctx context.Context, spec *common.Spec, epc *commo
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if err := ProcessAttesterSlashing(spec, epc, state, &ops[i]); err != nil {
+		if err := ProcessAttesterSlashing(ctx, spec, epc, state, &ops[i]); err != nil {
 			return err
 		}
 	}
@@ -48,6 +48,20 @@ func (a *AttesterSlashing) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) comm
 	return hFn.HashTreeRoot(spec.Wrap(&a.Attestation1), spec.Wrap(&a.Attestation2))
 }
 
+// EquivocatingIndices returns the validators that attested to both conflicting attestations,
+// or nil if the attestation data is not slashable.
+// The indexed attestations are not validated, see ProcessAttesterSlashing for full validation.
+func (a *AttesterSlashing) EquivocatingIndices() []common.ValidatorIndex {
+	if !IsSlashableAttestationData(&a.Attestation1.Data, &a.Attestation2.Data) {
+		return nil
+	}
+	var out []common.ValidatorIndex
+	common.ValidatorSet(a.Attestation1.AttestingIndices).ZigZagJoin(common.ValidatorSet(a.Attestation2.AttestingIndices), func(i common.ValidatorIndex) {
+		out = append(out, i)
+	}, nil)
+	return out
+}
+
 func BlockAttesterSlashingsType(spec *common.Spec) ListTypeDef {
 	return ListType(AttesterSlashingType(spec), spec.MAX_ATTESTER_SLASHINGS)
 }
@@ -96,7 +110,7 @@ func (li AttesterSlashings) HashTreeRoot(spec *common.Spec, hFn tree.HashFn) com
 	}, length, spec.MAX_ATTESTER_SLASHINGS)
 }
 
-func ProcessAttesterSlashing(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, attesterSlashing *AttesterSlashing) error {
+func ProcessAttesterSlashing(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, attesterSlashing *AttesterSlashing) error {
 	sa1 := &attesterSlashing.Attestation1
 	sa2 := &attesterSlashing.Attestation2
 
@@ -104,10 +118,10 @@ func ProcessAttesterSlashing(spec *common.Spec, epc *common.EpochsContext, state
 		return errors.New("attester slashing has no valid reasoning")
 	}
 
-	if err := ValidateIndexedAttestation(spec, epc, state, sa1); err != nil {
+	if err := ValidateIndexedAttestation(ctx, spec, epc, state, sa1); err != nil {
 		return errors.New("attestation 1 of attester slashing cannot be verified")
 	}
-	if err := ValidateIndexedAttestation(spec, epc, state, sa2); err != nil {
+	if err := ValidateIndexedAttestation(ctx, spec, epc, state, sa2); err != nil {
 		return errors.New("attestation 2 of attester slashing cannot be verified")
 	}
 
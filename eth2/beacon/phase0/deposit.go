@@ -144,7 +144,8 @@ func ProcessDeposit(spec *common.Spec, epc *common.EpochsContext, state common.B
 			// deposit is skipped, still valid block.
 			return nil
 		}
-		// Verify the deposit signature (proof of possession) which is not checked by the deposit contract
+		// Verify the deposit signature (proof of possession) which is not checked by the deposit contract.
+		// This is never deferred to a signature batch: an invalid deposit signature does not invalidate the block.
 		if !ignoreSignatureAndProof && !blsu.Verify(blsPub, signingRoot[:], sig) {
 			// invalid signatures are OK,
 			// the depositor will not receive anything because of their mistake,
@@ -1,11 +1,11 @@
 package phase0
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
 
-	blsu "github.com/protolambda/bls12-381-util"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -96,37 +96,30 @@ func ValidateIndexedAttestationNoSignature(spec *common.Spec, state common.Beaco
 	return nil
 }
 
-func ValidateIndexedAttestationSignature(spec *common.Spec, dom common.BLSDomain, pubCache *common.PubkeyCache, indexedAttestation *IndexedAttestation) error {
-	pubkeys := make([]*blsu.Pubkey, 0, len(indexedAttestation.AttestingIndices))
+func ValidateIndexedAttestationSignature(ctx context.Context, spec *common.Spec, dom common.BLSDomain, pubCache *common.PubkeyCache, indexedAttestation *IndexedAttestation) error {
+	pubkeys := make([]*common.CachedPubkey, 0, len(indexedAttestation.AttestingIndices))
 	for _, i := range indexedAttestation.AttestingIndices {
 		pub, ok := pubCache.Pubkey(i)
 		if !ok {
 			return fmt.Errorf("could not find pubkey for index %d", i)
 		}
-		blsPub, err := pub.Pubkey()
-		if err != nil {
-			return fmt.Errorf("failed to deserialize pubkey in cache: %v", err)
-		}
-		pubkeys = append(pubkeys, blsPub)
+		pubkeys = append(pubkeys, pub)
 	}
 	// empty attestation. (Double check, since this function is public, the user might not have validated if it's empty or not)
 	if len(pubkeys) <= 0 {
 		return errors.New("in phase 0 no empty attestation signatures are allowed")
 	}
 
-	signingRoot := common.ComputeSigningRoot(indexedAttestation.Data.HashTreeRoot(tree.GetHashFn()), dom)
-	sig, err := indexedAttestation.Signature.Signature()
-	if err != nil {
-		return fmt.Errorf("failed to deserialize and sub-group check indexed attestation signature: %v", err)
-	}
-	if !blsu.Eth2FastAggregateVerify(pubkeys, signingRoot[:], sig) {
-		return errors.New("could not verify BLS signature for indexed attestation")
-	}
-	return nil
+	return common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     pubkeys,
+		SigningRoot: common.ComputeSigningRoot(indexedAttestation.Data.HashTreeRoot(tree.GetHashFn()), dom),
+		Signature:   indexedAttestation.Signature,
+		Description: "could not verify BLS signature for indexed attestation",
+	})
 }
 
 // Verify validity of slashable_attestation fields.
-func ValidateIndexedAttestation(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, indexedAttestation *IndexedAttestation) error {
+func ValidateIndexedAttestation(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, indexedAttestation *IndexedAttestation) error {
 	if err := ValidateIndexedAttestationNoSignature(spec, state, indexedAttestation); err != nil {
 		return err
 	}
@@ -134,5 +127,5 @@ func ValidateIndexedAttestation(spec *common.Spec, epc *common.EpochsContext, st
 	if err != nil {
 		return err
 	}
-	return ValidateIndexedAttestationSignature(spec, dom, epc.ValidatorPubkeyCache, indexedAttestation)
+	return ValidateIndexedAttestationSignature(ctx, spec, dom, epc.ValidatorPubkeyCache, indexedAttestation)
 }
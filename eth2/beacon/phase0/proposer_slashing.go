@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 
-	blsu "github.com/protolambda/bls12-381-util"
-
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -87,7 +85,7 @@ func ProcessProposerSlashings(ctx context.Context, spec *common.Spec, epc *commo
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if err := ProcessProposerSlashing(spec, epc, state, &ops[i]); err != nil {
+		if err := ProcessProposerSlashing(ctx, spec, epc, state, &ops[i]); err != nil {
 			return err
 		}
 	}
@@ -110,7 +108,7 @@ func ValidateProposerSlashingNoSignature(spec *common.Spec, ps *ProposerSlashing
 	return nil
 }
 
-func ValidateProposerSlashing(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, ps *ProposerSlashing) error {
+func ValidateProposerSlashing(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, ps *ProposerSlashing) error {
 	if err := ValidateProposerSlashingNoSignature(spec, ps); err != nil {
 		return err
 	}
@@ -148,32 +146,25 @@ func ValidateProposerSlashing(spec *common.Spec, epc *common.EpochsContext, stat
 	if !ok {
 		return errors.New("could not find pubkey of proposer")
 	}
-	blsPub, err := pubkey.Pubkey()
-	if err != nil {
-		return err
-	}
-	sigRoot1 := common.ComputeSigningRoot(ps.SignedHeader1.Message.HashTreeRoot(tree.GetHashFn()), domain)
-	sigRoot2 := common.ComputeSigningRoot(ps.SignedHeader2.Message.HashTreeRoot(tree.GetHashFn()), domain)
-	sig1, err := ps.SignedHeader1.Signature.Signature()
-	if err != nil {
-		return err
-	}
-	sig2, err := ps.SignedHeader2.Signature.Signature()
-	if err != nil {
-		return err
-	}
 	// Verify signatures
-	if !blsu.Verify(blsPub, sigRoot1[:], sig1) {
-		return errors.New("proposer slashing header 1 has invalid BLS signature")
-	}
-	if !blsu.Verify(blsPub, sigRoot2[:], sig2) {
-		return errors.New("proposer slashing header 2 has invalid BLS signature")
+	if err := common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     []*common.CachedPubkey{pubkey},
+		SigningRoot: common.ComputeSigningRoot(ps.SignedHeader1.Message.HashTreeRoot(tree.GetHashFn()), domain),
+		Signature:   ps.SignedHeader1.Signature,
+		Description: "proposer slashing header 1 has invalid BLS signature",
+	}); err != nil {
+		return err
 	}
-	return nil
+	return common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     []*common.CachedPubkey{pubkey},
+		SigningRoot: common.ComputeSigningRoot(ps.SignedHeader2.Message.HashTreeRoot(tree.GetHashFn()), domain),
+		Signature:   ps.SignedHeader2.Signature,
+		Description: "proposer slashing header 2 has invalid BLS signature",
+	})
 }
 
-func ProcessProposerSlashing(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, ps *ProposerSlashing) error {
-	if err := ValidateProposerSlashing(spec, epc, state, ps); err != nil {
+func ProcessProposerSlashing(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, ps *ProposerSlashing) error {
+	if err := ValidateProposerSlashing(ctx, spec, epc, state, ps); err != nil {
 		return err
 	}
 	return SlashValidator(spec, epc, state, ps.SignedHeader1.Message.ProposerIndex, nil)
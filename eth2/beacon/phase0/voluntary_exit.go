@@ -3,9 +3,7 @@ package phase0
 import (
 	"context"
 	"errors"
-	"fmt"
 
-	blsu "github.com/protolambda/bls12-381-util"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -55,7 +53,7 @@ func ProcessVoluntaryExits(ctx context.Context, spec *common.Spec, epc *common.E
 		if err := ctx.Err(); err != nil {
 			return err
 		}
-		if err := ProcessVoluntaryExit(spec, epc, state, &ops[i]); err != nil {
+		if err := ProcessVoluntaryExit(ctx, spec, epc, state, &ops[i]); err != nil {
 			return err
 		}
 	}
@@ -123,7 +121,7 @@ var SignedVoluntaryExitType = ContainerType("SignedVoluntaryExit", []FieldDef{
 	{"signature", common.BLSSignatureType},
 })
 
-func ValidateVoluntaryExit(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedExit *SignedVoluntaryExit) error {
+func ValidateVoluntaryExit(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedExit *SignedVoluntaryExit) error {
 	exit := &signedExit.Message
 	currentEpoch := epc.CurrentEpoch.Epoch
 	vals, err := state.Validators()
@@ -173,24 +171,17 @@ func ValidateVoluntaryExit(spec *common.Spec, epc *common.EpochsContext, state c
 	if err != nil {
 		return err
 	}
-	sigRoot := common.ComputeSigningRoot(signedExit.Message.HashTreeRoot(tree.GetHashFn()), domain)
-	blsPub, err := pubkey.Pubkey()
-	if err != nil {
-		return fmt.Errorf("failed to deserialize cached pubkey: %v", err)
-	}
-	sig, err := signedExit.Signature.Signature()
-	if err != nil {
-		return fmt.Errorf("failed to deserialize and sub-group check exit signature: %v", err)
-	}
 	// Verify signature
-	if !blsu.Verify(blsPub, sigRoot[:], sig) {
-		return errors.New("voluntary exit signature could not be verified")
-	}
-	return nil
+	return common.VerifySignatureSet(ctx, &common.SignatureSet{
+		Pubkeys:     []*common.CachedPubkey{pubkey},
+		SigningRoot: common.ComputeSigningRoot(signedExit.Message.HashTreeRoot(tree.GetHashFn()), domain),
+		Signature:   signedExit.Signature,
+		Description: "voluntary exit signature could not be verified",
+	})
 }
 
-func ProcessVoluntaryExit(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedExit *SignedVoluntaryExit) error {
-	if err := ValidateVoluntaryExit(spec, epc, state, signedExit); err != nil {
+func ProcessVoluntaryExit(ctx context.Context, spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, signedExit *SignedVoluntaryExit) error {
+	if err := ValidateVoluntaryExit(ctx, spec, epc, state, signedExit); err != nil {
 		return err
 	}
 	return InitiateValidatorExit(spec, epc, state, signedExit.Message.ValidatorIndex)
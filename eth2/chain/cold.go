@@ -0,0 +1,828 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// Key prefixes of the cold chain data in the KV store.
+const (
+	// meta -> coldMeta
+	coldKeyMeta byte = 'm'
+	// block root -> coldBlockMeta
+	coldKeyBlockMeta byte = 'b'
+	// block root -> fork digest + SSZ signed block
+	coldKeyBlockData byte = 'd'
+	// slot -> last block root at or before the slot + byte if the block is at this slot
+	coldKeySlot byte = 's'
+	// state root -> block root
+	coldKeyStateRoot byte = 'r'
+	// slot -> fork digest + SSZ state
+	coldKeyState byte = 'S'
+)
+
+func rootKey(prefix byte, root common.Root) []byte {
+	var key [1 + 32]byte
+	key[0] = prefix
+	copy(key[1:], root[:])
+	return key[:]
+}
+
+func slotKey(prefix byte, slot common.Slot) []byte {
+	var key [1 + 8]byte
+	key[0] = prefix
+	// big-endian, to keep the keys ordered by slot in sorted backends
+	binary.BigEndian.PutUint64(key[1:], uint64(slot))
+	return key[:]
+}
+
+type coldMeta struct {
+	GenesisTime    common.Timestamp
+	GenesisValRoot common.Root
+	// First step in the chain
+	Start common.Step
+	// Step after the last block in the chain
+	End common.Step
+	// Last block root in the chain
+	Head common.Root
+	// Slots with a state snapshot, ascending
+	Snapshots []common.Slot
+}
+
+func (m *coldMeta) encode() []byte {
+	out := make([]byte, 8+32+8+8+32, 8+32+8+8+32+8*len(m.Snapshots))
+	binary.LittleEndian.PutUint64(out[0:8], uint64(m.GenesisTime))
+	copy(out[8:40], m.GenesisValRoot[:])
+	binary.LittleEndian.PutUint64(out[40:48], uint64(m.Start))
+	binary.LittleEndian.PutUint64(out[48:56], uint64(m.End))
+	copy(out[56:88], m.Head[:])
+	for _, s := range m.Snapshots {
+		out = append(out, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(out[len(out)-8:], uint64(s))
+	}
+	return out
+}
+
+func (m *coldMeta) decode(data []byte) error {
+	if len(data) < 88 || (len(data)-88)%8 != 0 {
+		return fmt.Errorf("invalid cold chain meta data length: %d", len(data))
+	}
+	m.GenesisTime = common.Timestamp(binary.LittleEndian.Uint64(data[0:8]))
+	copy(m.GenesisValRoot[:], data[8:40])
+	m.Start = common.Step(binary.LittleEndian.Uint64(data[40:48]))
+	m.End = common.Step(binary.LittleEndian.Uint64(data[48:56]))
+	copy(m.Head[:], data[56:88])
+	m.Snapshots = make([]common.Slot, 0, (len(data)-88)/8)
+	for i := 88; i < len(data); i += 8 {
+		m.Snapshots = append(m.Snapshots, common.Slot(binary.LittleEndian.Uint64(data[i:i+8])))
+	}
+	return nil
+}
+
+type coldBlockMeta struct {
+	Step       common.Step
+	ParentRoot common.Root
+	StateRoot  common.Root
+	Justified  common.Checkpoint
+	Finalized  common.Checkpoint
+	// False if the block contents are not available, e.g. for the anchor of the chain.
+	HasData bool
+}
+
+const coldBlockMetaSize = 8 + 32 + 32 + 40 + 40 + 1
+
+func (m *coldBlockMeta) encode() []byte {
+	out := make([]byte, coldBlockMetaSize)
+	binary.LittleEndian.PutUint64(out[0:8], uint64(m.Step))
+	copy(out[8:40], m.ParentRoot[:])
+	copy(out[40:72], m.StateRoot[:])
+	binary.LittleEndian.PutUint64(out[72:80], uint64(m.Justified.Epoch))
+	copy(out[80:112], m.Justified.Root[:])
+	binary.LittleEndian.PutUint64(out[112:120], uint64(m.Finalized.Epoch))
+	copy(out[120:152], m.Finalized.Root[:])
+	if m.HasData {
+		out[152] = 1
+	}
+	return out
+}
+
+func (m *coldBlockMeta) decode(data []byte) error {
+	if len(data) != coldBlockMetaSize {
+		return fmt.Errorf("invalid cold block meta data length: %d", len(data))
+	}
+	m.Step = common.Step(binary.LittleEndian.Uint64(data[0:8]))
+	copy(m.ParentRoot[:], data[8:40])
+	copy(m.StateRoot[:], data[40:72])
+	m.Justified.Epoch = common.Epoch(binary.LittleEndian.Uint64(data[72:80]))
+	copy(m.Justified.Root[:], data[80:112])
+	m.Finalized.Epoch = common.Epoch(binary.LittleEndian.Uint64(data[112:120]))
+	copy(m.Finalized.Root[:], data[120:152])
+	m.HasData = data[152] == 1
+	return nil
+}
+
+type ColdEntry struct {
+	cc         *ColdChain
+	step       common.Step
+	blockRoot  common.Root
+	parentRoot common.Root
+	hasData    bool
+
+	// guards the state root, which is computed lazily for empty slots
+	mu sync.Mutex
+	// zeroed if not known yet (empty slots)
+	stateRoot common.Root
+}
+
+var _ beacon.ChainEntry = (*ColdEntry)(nil)
+
+func (e *ColdEntry) Step() common.Step {
+	return e.step
+}
+
+func (e *ColdEntry) BlockRoot() (root common.Root, err error) {
+	return e.blockRoot, nil
+}
+
+func (e *ColdEntry) ParentRoot() (root common.Root, err error) {
+	return e.parentRoot, nil
+}
+
+// StateRoot of the entry. For empty slots this requires the state to be reconstructed.
+func (e *ColdEntry) StateRoot() (common.Root, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stateRoot != (common.Root{}) {
+		return e.stateRoot, nil
+	}
+	state, err := e.State(context.Background())
+	if err != nil {
+		return common.Root{}, err
+	}
+	e.stateRoot = state.HashTreeRoot(tree.GetHashFn())
+	return e.stateRoot, nil
+}
+
+func (e *ColdEntry) EpochsContext(ctx context.Context) (*common.EpochsContext, error) {
+	_, epc, err := e.cc.stateAt(ctx, e.step)
+	if err != nil {
+		return nil, err
+	}
+	return epc.Clone(), nil
+}
+
+// State reconstructs the state of the entry, from the closest snapshot and the blocks after it.
+func (e *ColdEntry) State(ctx context.Context) (common.BeaconState, error) {
+	state, _, err := e.cc.stateAt(ctx, e.step)
+	return state, err
+}
+
+// Block loads the block of this entry. Nil if the entry is an empty slot, or if the block is not available.
+func (e *ColdEntry) Block() (*common.BeaconBlockEnvelope, error) {
+	if !e.step.Block() || !e.hasData {
+		return nil, nil
+	}
+	return e.cc.loadBlock(e.blockRoot)
+}
+
+// ColdChain is a persisted chain of finalized entries.
+// It receives the canonical entries that are pruned from the HotChain (it is a PruneSink),
+// and stores the blocks, with a snapshot of the state every snapshotInterval slots.
+// Any other state is reconstructed by replaying blocks on top of the closest snapshot.
+type ColdChain struct {
+	mu sync.RWMutex
+
+	spec *common.Spec
+	kv   KV
+	// Minimum distance between state snapshots
+	snapshotInterval common.Slot
+
+	// nil until the first entry is stored
+	meta    *coldMeta
+	decoder *beacon.ForkDecoder
+	head    *coldBlockMeta
+
+	// The last reconstructed state, for cheap sequential access
+	cacheStep  common.Step
+	cacheState common.BeaconState
+	cacheEpc   *common.EpochsContext
+}
+
+var _ beacon.Chain = (*ColdChain)(nil)
+var _ PruneSink = (*ColdChain)(nil)
+
+// NewColdChain opens the cold chain persisted in the KV store, or starts a new empty one.
+func NewColdChain(spec *common.Spec, kv KV, snapshotInterval common.Slot) (*ColdChain, error) {
+	if snapshotInterval == 0 {
+		return nil, errors.New("snapshot interval must be non-zero")
+	}
+	cc := &ColdChain{
+		spec:             spec,
+		kv:               kv,
+		snapshotInterval: snapshotInterval,
+	}
+	data, ok, err := kv.Get([]byte{coldKeyMeta})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cold chain meta data: %w", err)
+	}
+	if ok {
+		var meta coldMeta
+		if err := meta.decode(data); err != nil {
+			return nil, err
+		}
+		head, err := cc.loadBlockMeta(meta.Head)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load cold chain head: %w", err)
+		}
+		cc.meta = &meta
+		cc.head = head
+		cc.decoder = beacon.NewForkDecoder(spec, meta.GenesisValRoot)
+	}
+	return cc, nil
+}
+
+func (cc *ColdChain) loadBlockMeta(root common.Root) (*coldBlockMeta, error) {
+	data, ok, err := cc.kv.Get(rootKey(coldKeyBlockMeta, root))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s", root)
+	}
+	var m coldBlockMeta
+	if err := m.decode(data); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (cc *ColdChain) loadBlock(root common.Root) (*common.BeaconBlockEnvelope, error) {
+	data, ok, err := cc.kv.Get(rootKey(coldKeyBlockData, root))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("missing block data %s", root)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid block data %s", root)
+	}
+	var digest common.ForkDigest
+	copy(digest[:], data[:4])
+	alloc, err := cc.decoder.BlockAllocator(digest)
+	if err != nil {
+		return nil, err
+	}
+	block := alloc()
+	if err := block.Deserialize(cc.spec, codec.NewDecodingReader(bytes.NewReader(data[4:]), uint64(len(data)-4))); err != nil {
+		return nil, fmt.Errorf("failed to decode block %s: %w", root, err)
+	}
+	return block.Envelope(cc.spec, digest), nil
+}
+
+// slotRef returns the last block root at or before the slot, and whether the block is at the slot itself.
+func (cc *ColdChain) slotRef(slot common.Slot) (root common.Root, block bool, ok bool, err error) {
+	data, ok, err := cc.kv.Get(slotKey(coldKeySlot, slot))
+	if err != nil || !ok {
+		return common.Root{}, false, false, err
+	}
+	if len(data) != 33 {
+		return common.Root{}, false, false, fmt.Errorf("invalid slot data at %d", slot)
+	}
+	copy(root[:], data[:32])
+	return root, data[32] == 1, true, nil
+}
+
+func (cc *ColdChain) putSlotRef(slot common.Slot, root common.Root, block bool) error {
+	var data [33]byte
+	copy(data[:32], root[:])
+	if block {
+		data[32] = 1
+	}
+	return cc.kv.Put(slotKey(coldKeySlot, slot), data[:])
+}
+
+// OnPrunedEntry stores the canonical block entries that are pruned from the hot chain.
+// The first entry that is stored becomes the anchor of the cold chain, and is always snapshot.
+func (cc *ColdChain) OnPrunedEntry(ctx context.Context, entry *HotEntry, canonical bool) error {
+	if !canonical {
+		return nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	slot := entry.step.Slot()
+	meta := cc.meta
+	if meta == nil {
+		genesisTime, err := entry.state.GenesisTime()
+		if err != nil {
+			return err
+		}
+		genesisValRoot, err := entry.state.GenesisValidatorsRoot()
+		if err != nil {
+			return err
+		}
+		meta = &coldMeta{
+			GenesisTime:    genesisTime,
+			GenesisValRoot: genesisValRoot,
+			Start:          entry.step,
+			End:            entry.step,
+		}
+		cc.decoder = beacon.NewForkDecoder(cc.spec, genesisValRoot)
+	} else {
+		// only blocks extend the chain, empty slots can be reconstructed.
+		if !entry.step.Block() || entry.step < meta.End {
+			return nil
+		}
+		if entry.parentRoot != meta.Head {
+			return fmt.Errorf("pruned entry %s at %s does not build on cold chain head %s", entry.blockRoot, entry.step, meta.Head)
+		}
+		// fill the gap slots with references to the last block
+		for s := meta.End.Slot(); s < slot; s++ {
+			if err := cc.putSlotRef(s, meta.Head, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	justified, err := entry.state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return err
+	}
+	finalized, err := entry.state.FinalizedCheckpoint()
+	if err != nil {
+		return err
+	}
+	blockMeta := &coldBlockMeta{
+		Step:       entry.step,
+		ParentRoot: entry.parentRoot,
+		StateRoot:  entry.stateRoot,
+		Justified:  justified,
+		Finalized:  finalized,
+		HasData:    entry.block != nil,
+	}
+	if entry.block != nil {
+		signed, err := beacon.EnvelopeToSignedBeaconBlock(entry.block)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		buf.Write(entry.block.ForkDigest[:])
+		if err := signed.Serialize(cc.spec, codec.NewEncodingWriter(&buf)); err != nil {
+			return fmt.Errorf("failed to encode block %s: %w", entry.blockRoot, err)
+		}
+		if err := cc.kv.Put(rootKey(coldKeyBlockData, entry.blockRoot), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := cc.kv.Put(rootKey(coldKeyBlockMeta, entry.blockRoot), blockMeta.encode()); err != nil {
+		return err
+	}
+	if err := cc.kv.Put(rootKey(coldKeyStateRoot, entry.stateRoot), entry.blockRoot[:]); err != nil {
+		return err
+	}
+	if err := cc.putSlotRef(slot, entry.blockRoot, entry.step.Block()); err != nil {
+		return err
+	}
+	snapshots := meta.Snapshots
+	if len(snapshots) == 0 || snapshots[len(snapshots)-1]+cc.snapshotInterval <= slot {
+		var buf bytes.Buffer
+		digest := cc.decoder.ForkDigest(cc.spec.SlotToEpoch(slot))
+		buf.Write(digest[:])
+		if err := entry.state.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+			return fmt.Errorf("failed to encode state snapshot at slot %d: %w", slot, err)
+		}
+		if err := cc.kv.Put(slotKey(coldKeyState, slot), buf.Bytes()); err != nil {
+			return err
+		}
+		snapshots = append(snapshots, slot)
+	}
+
+	// Update the meta data last: anything written before is unreachable until the meta data is updated.
+	newMeta := *meta
+	newMeta.End = entry.step + 1
+	newMeta.Head = entry.blockRoot
+	newMeta.Snapshots = snapshots
+	if err := cc.kv.Put([]byte{coldKeyMeta}, newMeta.encode()); err != nil {
+		return err
+	}
+	cc.meta = &newMeta
+	cc.head = blockMeta
+	return nil
+}
+
+func (cc *ColdChain) loadSnapshot(slot common.Slot) (common.BeaconState, error) {
+	data, ok, err := cc.kv.Get(slotKey(coldKeyState, slot))
+	if err != nil {
+		return nil, err
+	}
+	if !ok || len(data) < 4 {
+		return nil, fmt.Errorf("missing state snapshot at slot %d", slot)
+	}
+	var digest common.ForkDigest
+	copy(digest[:], data[:4])
+	return cc.decoder.DecodeState(digest, codec.NewDecodingReader(bytes.NewReader(data[4:]), uint64(len(data)-4)))
+}
+
+// stateAt reconstructs the state at the given step. The returned state may not be modified.
+func (cc *ColdChain) stateAt(ctx context.Context, step common.Step) (common.BeaconState, *common.EpochsContext, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.meta == nil {
+		return nil, nil, errors.New("cold chain is empty")
+	}
+	if step < cc.meta.Start {
+		return nil, nil, fmt.Errorf("step %s is before the start of the cold chain %s", step, cc.meta.Start)
+	}
+	if cc.cacheState != nil && cc.cacheStep == step {
+		return cc.cacheState, cc.cacheEpc, nil
+	}
+	slot := step.Slot()
+	snapshots := cc.meta.Snapshots
+	// the last snapshot at or before the requested slot (first snapshot is the anchor)
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i] > slot })
+	// snapshots are post-block states, a pre-block step needs the snapshot before it.
+	if i > 0 && snapshots[i-1] == slot && !step.Block() && step != cc.meta.Start {
+		i--
+	}
+	if i == 0 {
+		return nil, nil, fmt.Errorf("no state snapshot available for slot %d", slot)
+	}
+	snapSlot := snapshots[i-1]
+	var state common.BeaconState
+	var epc *common.EpochsContext
+	// Continue from the cached post-block state if it is closer than the snapshot
+	if cc.cacheState != nil && cc.cacheStep.Block() && cc.cacheStep.Slot() >= snapSlot && cc.cacheStep < step {
+		var err error
+		state, err = cc.cacheState.CopyState()
+		if err != nil {
+			return nil, nil, err
+		}
+		epc = cc.cacheEpc.Clone()
+		snapSlot = cc.cacheStep.Slot()
+	} else {
+		var err error
+		state, err = cc.loadSnapshot(snapSlot)
+		if err != nil {
+			return nil, nil, err
+		}
+		epc, err = common.NewEpochsContext(cc.spec, state)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	upgradeable := &beacon.StandardUpgradeableBeaconState{BeaconState: state}
+	// replay the blocks after the snapshot, up to (and maybe incl.) the requested slot
+	for s := snapSlot + 1; s <= slot; s++ {
+		if s == slot && !step.Block() {
+			break
+		}
+		root, isBlock, ok, err := cc.slotRef(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok || !isBlock {
+			continue
+		}
+		benv, err := cc.loadBlock(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := common.StateTransition(ctx, cc.spec, epc, upgradeable, benv, false); err != nil {
+			return nil, nil, fmt.Errorf("failed to replay block %s at slot %d: %w", root, s, err)
+		}
+	}
+	if current, err := upgradeable.Slot(); err != nil {
+		return nil, nil, err
+	} else if current < slot {
+		if err := common.ProcessSlots(ctx, cc.spec, epc, upgradeable, slot); err != nil {
+			return nil, nil, err
+		}
+	}
+	cc.cacheStep = step
+	cc.cacheState = upgradeable.BeaconState
+	cc.cacheEpc = epc
+	return upgradeable.BeaconState, epc, nil
+}
+
+func (cc *ColdChain) blockEntry(root common.Root) (*ColdEntry, bool) {
+	m, err := cc.loadBlockMeta(root)
+	if err != nil {
+		return nil, false
+	}
+	return &ColdEntry{
+		cc:         cc,
+		step:       m.Step,
+		blockRoot:  root,
+		parentRoot: m.ParentRoot,
+		stateRoot:  m.StateRoot,
+		hasData:    m.HasData,
+	}, true
+}
+
+func (cc *ColdChain) ByStateRoot(root common.Root) (entry beacon.ChainEntry, ok bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	data, ok, err := cc.kv.Get(rootKey(coldKeyStateRoot, root))
+	if err != nil || !ok || len(data) != 32 {
+		return nil, false
+	}
+	var blockRoot common.Root
+	copy(blockRoot[:], data)
+	e, ok := cc.blockEntry(blockRoot)
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+func (cc *ColdChain) ByBlock(root common.Root) (entry beacon.ChainEntry, ok bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	e, ok := cc.blockEntry(root)
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+func (cc *ColdChain) ByBlockSlot(root common.Root, slot common.Slot) (entry beacon.ChainEntry, ok bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	e, ok := cc.closest(root, slot)
+	if !ok || e.step.Slot() != slot {
+		return nil, false
+	}
+	return e, true
+}
+
+// Search the finalized blocks. Without options, the cold chain head is returned.
+// All cold entries are canonical.
+func (cc *ColdChain) Search(parentRoot *common.Root, slot *common.Slot) ([]beacon.SearchEntry, error) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.meta == nil {
+		return nil, nil
+	}
+	var e *ColdEntry
+	if slot != nil {
+		root, isBlock, ok, err := cc.slotRef(*slot)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || !isBlock {
+			return nil, nil
+		}
+		e, ok = cc.blockEntry(root)
+		if !ok {
+			return nil, fmt.Errorf("missing block %s", root)
+		}
+	} else if parentRoot != nil {
+		parent, ok := cc.blockEntry(*parentRoot)
+		if !ok {
+			return nil, nil
+		}
+		// the child is the first block after the parent
+		for s := parent.step.Slot() + 1; s < cc.meta.End.Slot(); s++ {
+			root, isBlock, ok, err := cc.slotRef(s)
+			if err != nil {
+				return nil, err
+			}
+			if ok && isBlock {
+				e, _ = cc.blockEntry(root)
+				break
+			}
+		}
+	} else {
+		e, _ = cc.blockEntry(cc.meta.Head)
+	}
+	if e == nil || (parentRoot != nil && e.parentRoot != *parentRoot) {
+		return nil, nil
+	}
+	return []beacon.SearchEntry{{ChainEntry: e, Canonical: true}}, nil
+}
+
+func (cc *ColdChain) Closest(fromBlockRoot common.Root, toSlot common.Slot) (entry beacon.ChainEntry, ok bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	e, ok := cc.closest(fromBlockRoot, toSlot)
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+// closest returns the entry of the block, or the empty slot after it, closest to the given slot.
+func (cc *ColdChain) closest(fromBlockRoot common.Root, toSlot common.Slot) (*ColdEntry, bool) {
+	e, ok := cc.blockEntry(fromBlockRoot)
+	if !ok || e.step.Slot() > toSlot {
+		return nil, false
+	}
+	if e.step.Slot() == toSlot {
+		return e, true
+	}
+	// empty slots after the block may be used, up to and including the pre-block slot of the next block.
+	last := toSlot
+	for s := e.step.Slot() + 1; s <= toSlot; s++ {
+		root, _, ok, err := cc.slotRef(s)
+		if err != nil {
+			return nil, false
+		}
+		// if the block is the cold head, the slots after it are not stored, but can still be transitioned to.
+		if !ok {
+			break
+		}
+		if root != fromBlockRoot {
+			last = s
+			break
+		}
+	}
+	return &ColdEntry{
+		cc:         cc,
+		step:       common.AsStep(last, false),
+		blockRoot:  fromBlockRoot,
+		parentRoot: fromBlockRoot,
+	}, true
+}
+
+func (cc *ColdChain) InSubtree(anchor common.Root, root common.Root) (unknown bool, inSubtree bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	a, ok := cc.blockEntry(anchor)
+	if !ok {
+		return true, false
+	}
+	b, ok := cc.blockEntry(root)
+	if !ok {
+		return true, false
+	}
+	// There is only one chain, every later block builds on the anchor.
+	return false, a.step <= b.step
+}
+
+func (cc *ColdChain) ByCanonStep(step common.Step) (entry beacon.ChainEntry, ok bool) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	e, ok := cc.byCanonStep(step)
+	if !ok || e == nil {
+		return nil, ok
+	}
+	return e, true
+}
+
+func (cc *ColdChain) byCanonStep(step common.Step) (entry *ColdEntry, ok bool) {
+	if cc.meta == nil || step < cc.meta.Start || step >= cc.meta.End {
+		return nil, false
+	}
+	slot := step.Slot()
+	root, isBlock, ok, err := cc.slotRef(slot)
+	if err != nil || !ok {
+		return nil, false
+	}
+	if step.Block() {
+		if !isBlock {
+			// the slot node exists, but there is no block
+			return nil, true
+		}
+		return cc.blockEntry(root)
+	}
+	if isBlock {
+		// pre-block: the entry is built on the parent block
+		e, ok := cc.blockEntry(root)
+		if !ok {
+			return nil, false
+		}
+		root = e.parentRoot
+	}
+	return &ColdEntry{
+		cc:         cc,
+		step:       step,
+		blockRoot:  root,
+		parentRoot: root,
+	}, true
+}
+
+func (cc *ColdChain) Iter() (beacon.ChainIter, error) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.meta == nil {
+		return nil, errors.New("cold chain is empty")
+	}
+	return &coldChainIter{cc: cc, start: cc.meta.Start, end: cc.meta.End}, nil
+}
+
+func (cc *ColdChain) JustifiedCheckpoint() common.Checkpoint {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.head == nil {
+		return common.Checkpoint{}
+	}
+	return cc.head.Justified
+}
+
+func (cc *ColdChain) FinalizedCheckpoint() common.Checkpoint {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.head == nil {
+		return common.Checkpoint{}
+	}
+	return cc.head.Finalized
+}
+
+func (cc *ColdChain) Justified() (beacon.ChainEntry, error) {
+	return cc.checkpointEntry(cc.JustifiedCheckpoint())
+}
+
+func (cc *ColdChain) Finalized() (beacon.ChainEntry, error) {
+	return cc.checkpointEntry(cc.FinalizedCheckpoint())
+}
+
+func (cc *ColdChain) checkpointEntry(cp common.Checkpoint) (beacon.ChainEntry, error) {
+	slot, err := cc.spec.EpochStartSlot(cp.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	e, ok := cc.closest(cp.Root, slot)
+	if !ok {
+		return nil, fmt.Errorf("unknown checkpoint %s", cp)
+	}
+	return e, nil
+}
+
+func (cc *ColdChain) Head() (beacon.ChainEntry, error) {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.meta == nil {
+		return nil, errors.New("cold chain is empty")
+	}
+	e, ok := cc.blockEntry(cc.meta.Head)
+	if !ok {
+		return nil, fmt.Errorf("missing head block %s", cc.meta.Head)
+	}
+	return e, nil
+}
+
+func (cc *ColdChain) Towards(ctx context.Context, fromBlockRoot common.Root, toSlot common.Slot) (beacon.ChainEntry, error) {
+	cc.mu.RLock()
+	e, ok := cc.closest(fromBlockRoot, toSlot)
+	cc.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("could not find entry of block %s to transition to slot %d", fromBlockRoot, toSlot)
+	}
+	if e.step.Slot() != toSlot {
+		return nil, fmt.Errorf("cannot transition block %s to slot %d, closest is %s, a later block is canonical",
+			fromBlockRoot, toSlot, e.step)
+	}
+	return e, nil
+}
+
+func (cc *ColdChain) Genesis() beacon.GenesisInfo {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if cc.meta == nil {
+		return beacon.GenesisInfo{}
+	}
+	return beacon.GenesisInfo{Time: cc.meta.GenesisTime, ValidatorsRoot: cc.meta.GenesisValRoot}
+}
+
+type coldChainIter struct {
+	cc    *ColdChain
+	start common.Step
+	end   common.Step
+}
+
+func (it *coldChainIter) Start() common.Step {
+	return it.start
+}
+
+func (it *coldChainIter) End() common.Step {
+	return it.end
+}
+
+func (it *coldChainIter) Entry(step common.Step) (entry beacon.ChainEntry, err error) {
+	if step < it.start || step >= it.end {
+		return nil, fmt.Errorf("step %s is out of range %s - %s", step, it.start, it.end)
+	}
+	it.cc.mu.RLock()
+	defer it.cc.mu.RUnlock()
+	e, ok := it.cc.byCanonStep(step)
+	if !ok {
+		return nil, fmt.Errorf("no entry available at step %s", step)
+	}
+	if e == nil {
+		return nil, nil
+	}
+	return e, nil
+}
@@ -0,0 +1,128 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestColdChain(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	kv, err := NewFileKV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, err := NewColdChain(configs.Minimal, kv, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cc.Head(); err == nil {
+		t.Fatal("expected empty cold chain to have no head")
+	}
+	tc := newTestChain(t, cc)
+	// a fork that is pruned as non-canonical, and not stored
+	fork := tc.addBlock(t, tc.head(t), 2, common.Root{0xf0})
+	head := tc.head(t)
+	// skip a slot every epoch, for empty slots in the cold chain
+	for slot := common.Slot(1); slot <= 5*tc.spec.SLOTS_PER_EPOCH; slot++ {
+		if slot%tc.spec.SLOTS_PER_EPOCH == 3 {
+			continue
+		}
+		head = tc.addBlock(t, head, slot, common.Root{})
+		tc.attest(t, head, slot)
+	}
+	if tc.hc.FinalizedCheckpoint().Epoch < 2 {
+		t.Fatalf("expected finalization, got %s", tc.hc.FinalizedCheckpoint())
+	}
+
+	var canonical []prunedEntry
+	var lastBlock prunedEntry
+	for _, p := range tc.sink.pruned {
+		if !p.canonical {
+			continue
+		}
+		canonical = append(canonical, p)
+		if p.step.Block() {
+			lastBlock = p
+		}
+	}
+	if len(canonical) == 0 {
+		t.Fatal("expected pruned canonical entries")
+	}
+	if _, ok := cc.ByBlock(fork); ok {
+		t.Fatal("expected non-canonical fork to not be stored")
+	}
+
+	check := func(cc *ColdChain) {
+		t.Helper()
+		coldHead, err := cc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root, _ := coldHead.BlockRoot(); root != lastBlock.root {
+			t.Fatalf("expected cold head %s, got %s", lastBlock.root, root)
+		}
+		for _, p := range canonical {
+			// the states are reconstructed from the snapshots, by replaying the blocks after them
+			entry, ok := cc.ByCanonStep(p.step)
+			if !ok || entry == nil {
+				t.Fatalf("missing cold entry at step %s", p.step)
+			}
+			state, err := entry.State(ctx)
+			if err != nil {
+				t.Fatalf("failed to get state at step %s: %v", p.step, err)
+			}
+			if root := state.HashTreeRoot(tree.GetHashFn()); root != p.stateRoot {
+				t.Fatalf("step %s: expected state root %s, got %s", p.step, p.stateRoot, root)
+			}
+			if stateRoot, err := entry.StateRoot(); err != nil || stateRoot != p.stateRoot {
+				t.Fatalf("step %s: expected state root %s, got %s (%v)", p.step, p.stateRoot, stateRoot, err)
+			}
+			if !p.step.Block() {
+				continue
+			}
+			byRoot, ok := cc.ByBlock(p.root)
+			if !ok || byRoot.Step() != p.step {
+				t.Fatalf("expected block %s at step %s", p.root, p.step)
+			}
+			byState, ok := cc.ByStateRoot(p.stateRoot)
+			if !ok || byState.Step() != p.step {
+				t.Fatalf("expected block %s by state root %s", p.root, p.stateRoot)
+			}
+			benv, err := byRoot.Block()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the anchor block is not available
+			if p.slot == 0 {
+				if benv != nil {
+					t.Fatal("expected no anchor block data")
+				}
+			} else if benv == nil || benv.BlockRoot != p.root {
+				t.Fatalf("expected block data of %s", p.root)
+			}
+		}
+		// the skipped slots are empty
+		if entry, ok := cc.ByCanonStep(common.AsStep(tc.spec.SLOTS_PER_EPOCH+3, true)); !ok || entry != nil {
+			t.Fatal("expected empty slot")
+		}
+		iter, err := cc.Iter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if iter.Start() != canonical[0].step || iter.End() != lastBlock.step+1 {
+			t.Fatalf("unexpected cold iterator range %s - %s", iter.Start(), iter.End())
+		}
+	}
+	check(cc)
+	// the cold chain is persisted, and can be opened again
+	reopened, err := NewColdChain(tc.spec, kv, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(reopened)
+}
@@ -0,0 +1,694 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+	"github.com/protolambda/zrnt/eth2/forkchoice/proto"
+	"github.com/protolambda/ztyp/tree"
+)
+
+type HotEntry struct {
+	step       common.Step
+	blockRoot  common.Root
+	parentRoot common.Root
+	stateRoot  common.Root
+	epc        *common.EpochsContext
+	state      common.BeaconState
+	// nil if the entry is an empty slot, or if the block is not available (e.g. the anchor)
+	block *common.BeaconBlockEnvelope
+}
+
+var _ beacon.ChainEntry = (*HotEntry)(nil)
+
+func (e *HotEntry) Step() common.Step {
+	return e.step
+}
+
+func (e *HotEntry) BlockRoot() (root common.Root, err error) {
+	return e.blockRoot, nil
+}
+
+func (e *HotEntry) ParentRoot() (root common.Root, err error) {
+	return e.parentRoot, nil
+}
+
+func (e *HotEntry) StateRoot() (common.Root, error) {
+	return e.stateRoot, nil
+}
+
+func (e *HotEntry) EpochsContext(ctx context.Context) (*common.EpochsContext, error) {
+	return e.epc.Clone(), nil
+}
+
+// State returns the post-state of the entry. The state is shared, and must be copied before making any changes.
+func (e *HotEntry) State(ctx context.Context) (common.BeaconState, error) {
+	return e.state, nil
+}
+
+// Block returns the block of this entry, or nil if the slot is empty or if the block is unavailable.
+func (e *HotEntry) Block() (*common.BeaconBlockEnvelope, error) {
+	return e.block, nil
+}
+
+func (e *HotEntry) ref() forkchoice.NodeRef {
+	return forkchoice.NodeRef{Root: e.blockRoot, Slot: e.step.Slot()}
+}
+
+// PruneSink receives the entries that are pruned from the hot chain, e.g. to persist finalized data.
+// If the sink returns an error, the entry and anything after it is not pruned.
+type PruneSink interface {
+	OnPrunedEntry(ctx context.Context, entry *HotEntry, canonical bool) error
+}
+
+// HotChain is an in-memory chain of all unfinalized entries,
+// with a proto-array forkchoice to determine the canonical chain and head.
+// Entries are kept for every block, and for empty slots that were transitioned to with Towards.
+type HotChain struct {
+	mu sync.RWMutex
+
+	spec    *common.Spec
+	genesis beacon.GenesisInfo
+
+	fc forkchoice.Forkchoice
+
+	// All entries, keyed by (block root, slot). A block node and the pre-block slot node
+	// of the same slot are distinguished by root: the pre-block node has the parent root.
+	entries map[forkchoice.NodeRef]*HotEntry
+	// The first (lowest slot) entry of each block root
+	blocks map[common.Root]*HotEntry
+	// Slots of empty-slot entries per block root, sorted in ascending order
+	emptySlots map[common.Root][]common.Slot
+	// State root -> entry
+	stateRoots map[common.Root]*HotEntry
+
+	sink PruneSink
+}
+
+var _ beacon.Chain = (*HotChain)(nil)
+
+// NewHotChain creates a hot chain starting from the given anchor state, which may be a genesis state,
+// or any other trusted (e.g. finalized) state. The anchor state may be at a slot after its latest block.
+// The sink is optional, and receives the entries as they are pruned when finalization progresses.
+func NewHotChain(spec *common.Spec, anchorState common.BeaconState, anchorEpc *common.EpochsContext, sink PruneSink) (*HotChain, error) {
+	slot, err := anchorState.Slot()
+	if err != nil {
+		return nil, err
+	}
+	genesisTime, err := anchorState.GenesisTime()
+	if err != nil {
+		return nil, err
+	}
+	genesisValRoot, err := anchorState.GenesisValidatorsRoot()
+	if err != nil {
+		return nil, err
+	}
+	stateRoot := anchorState.HashTreeRoot(tree.GetHashFn())
+	header, err := anchorState.LatestBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	// The header state-root is filled in during the next slot processing, use the current state root if it's missing.
+	if header.StateRoot == (common.Root{}) {
+		header.StateRoot = stateRoot
+	}
+	blockRoot := header.HashTreeRoot(tree.GetHashFn())
+
+	justified, err := anchorState.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	finalized, err := anchorState.FinalizedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	balances, err := activeBalances(spec, anchorState)
+	if err != nil {
+		return nil, err
+	}
+
+	anchor := &HotEntry{
+		step:      common.AsStep(slot, header.Slot == slot),
+		blockRoot: blockRoot,
+		stateRoot: stateRoot,
+		epc:       anchorEpc,
+		state:     anchorState,
+	}
+	if header.Slot == slot {
+		anchor.parentRoot = header.ParentRoot
+	} else {
+		anchor.parentRoot = blockRoot
+	}
+
+	hc := &HotChain{
+		spec: spec,
+		genesis: beacon.GenesisInfo{
+			Time:           genesisTime,
+			ValidatorsRoot: genesisValRoot,
+		},
+		entries:    make(map[forkchoice.NodeRef]*HotEntry),
+		blocks:     make(map[common.Root]*HotEntry),
+		emptySlots: make(map[common.Root][]common.Slot),
+		stateRoots: make(map[common.Root]*HotEntry),
+		sink:       sink,
+	}
+	hc.putEntry(anchor)
+
+	// The anchor is trusted, the checkpoints are rooted in the anchor block, and the forkchoice is pinned to it,
+	// until the chain finalizes a checkpoint of its own.
+	fc, err := proto.NewProtoForkChoice(spec,
+		common.Checkpoint{Epoch: finalized.Epoch, Root: blockRoot},
+		common.Checkpoint{Epoch: justified.Epoch, Root: blockRoot},
+		blockRoot, slot, anchor.parentRoot, balances, proto.NodeSinkFn(hc.onPrunedNode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to init forkchoice: %w", err)
+	}
+	hc.fc = fc
+	return hc, nil
+}
+
+// putEntry tracks the entry, the lock must be held by the caller
+func (hc *HotChain) putEntry(entry *HotEntry) {
+	ref := entry.ref()
+	hc.entries[ref] = entry
+	hc.stateRoots[entry.stateRoot] = entry
+	if first, ok := hc.blocks[entry.blockRoot]; !ok || first.step.Slot() > ref.Slot {
+		hc.blocks[entry.blockRoot] = entry
+	}
+	if !entry.step.Block() && hc.blocks[entry.blockRoot] != entry {
+		slots := hc.emptySlots[entry.blockRoot]
+		i := sort.Search(len(slots), func(i int) bool { return slots[i] >= ref.Slot })
+		if i == len(slots) || slots[i] != ref.Slot {
+			slots = append(slots, 0)
+			copy(slots[i+1:], slots[i:])
+			slots[i] = ref.Slot
+			hc.emptySlots[entry.blockRoot] = slots
+		}
+	}
+}
+
+// removeEntry drops the entry, the lock must be held by the caller
+func (hc *HotChain) removeEntry(entry *HotEntry) {
+	ref := entry.ref()
+	delete(hc.entries, ref)
+	delete(hc.stateRoots, entry.stateRoot)
+	slots := hc.emptySlots[entry.blockRoot]
+	i := sort.Search(len(slots), func(i int) bool { return slots[i] >= ref.Slot })
+	if i < len(slots) && slots[i] == ref.Slot {
+		slots = append(slots[:i], slots[i+1:]...)
+	}
+	if hc.blocks[entry.blockRoot] == entry {
+		// the next entry of the same block root becomes the first entry
+		if len(slots) > 0 {
+			hc.blocks[entry.blockRoot] = hc.entries[forkchoice.NodeRef{Root: entry.blockRoot, Slot: slots[0]}]
+			slots = slots[1:]
+		} else {
+			delete(hc.blocks, entry.blockRoot)
+		}
+	}
+	if len(slots) == 0 {
+		delete(hc.emptySlots, entry.blockRoot)
+	} else {
+		hc.emptySlots[entry.blockRoot] = slots
+	}
+}
+
+// onPrunedNode is called by the forkchoice when finalization progresses.
+// Pruning only happens during AddBlock, which holds the lock already.
+func (hc *HotChain) onPrunedNode(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+	entry, ok := hc.entries[ref]
+	if !ok {
+		// slot nodes that were never transitioned to have no entry
+		return nil
+	}
+	if hc.sink != nil {
+		if err := hc.sink.OnPrunedEntry(ctx, entry, canonical); err != nil {
+			return err
+		}
+	}
+	hc.removeEntry(entry)
+	return nil
+}
+
+// anchor returns the node the forkchoice starts from, the lock must be held by the caller.
+func (hc *HotChain) anchor() forkchoice.NodeRef {
+	if pin := hc.fc.Pin(); pin != nil {
+		return *pin
+	}
+	fin := hc.fc.Finalized()
+	finSlot, _ := hc.spec.EpochStartSlot(fin.Epoch)
+	return forkchoice.NodeRef{Root: fin.Root, Slot: finSlot}
+}
+
+func (hc *HotChain) ByStateRoot(root common.Root) (entry beacon.ChainEntry, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	e, ok := hc.stateRoots[root]
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+func (hc *HotChain) ByBlock(root common.Root) (entry beacon.ChainEntry, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	e, ok := hc.blocks[root]
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+func (hc *HotChain) ByBlockSlot(root common.Root, slot common.Slot) (entry beacon.ChainEntry, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	e, ok := hc.entries[forkchoice.NodeRef{Root: root, Slot: slot}]
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+func (hc *HotChain) Search(parentRoot *common.Root, slot *common.Slot) ([]beacon.SearchEntry, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	nonCanon, canon, err := hc.fc.Search(hc.anchor(), parentRoot, slot)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]beacon.SearchEntry, 0, len(nonCanon)+len(canon))
+	for _, ref := range canon {
+		if entry, ok := hc.entries[ref]; ok {
+			out = append(out, beacon.SearchEntry{ChainEntry: entry, Canonical: true})
+		}
+	}
+	for _, ref := range nonCanon {
+		if entry, ok := hc.entries[ref]; ok {
+			out = append(out, beacon.SearchEntry{ChainEntry: entry, Canonical: false})
+		}
+	}
+	return out, nil
+}
+
+func (hc *HotChain) Closest(fromBlockRoot common.Root, toSlot common.Slot) (entry beacon.ChainEntry, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	e, ok := hc.closest(fromBlockRoot, toSlot)
+	if !ok {
+		return nil, false
+	}
+	return e, true
+}
+
+// closest finds the closest entry, the lock must be held by the caller.
+func (hc *HotChain) closest(fromBlockRoot common.Root, toSlot common.Slot) (entry *HotEntry, ok bool) {
+	first, ok := hc.blocks[fromBlockRoot]
+	if !ok || first.step.Slot() > toSlot {
+		return nil, false
+	}
+	slots := hc.emptySlots[fromBlockRoot]
+	// index of the first slot after toSlot
+	i := sort.Search(len(slots), func(i int) bool { return slots[i] > toSlot })
+	if i == 0 {
+		return first, true
+	}
+	return hc.entries[forkchoice.NodeRef{Root: fromBlockRoot, Slot: slots[i-1]}], true
+}
+
+func (hc *HotChain) InSubtree(anchor common.Root, root common.Root) (unknown bool, inSubtree bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.fc.InSubtree(anchor, root)
+}
+
+func (hc *HotChain) ByCanonStep(step common.Step) (entry beacon.ChainEntry, ok bool) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.byCanonStep(step)
+}
+
+// byCanonStep looks up the canonical entry, the lock must be held by the caller.
+func (hc *HotChain) byCanonStep(step common.Step) (entry beacon.ChainEntry, ok bool) {
+	ref, err := hc.fc.CanonAtSlot(hc.anchor().Root, step.Slot(), step.Block())
+	if err != nil {
+		return nil, false
+	}
+	if ref == (forkchoice.NodeRef{}) {
+		// slot node exists, but has no block
+		return nil, true
+	}
+	if ref.Slot != step.Slot() {
+		// the canonical chain does not reach the slot yet
+		return nil, false
+	}
+	e, ok := hc.entries[ref]
+	if !ok {
+		return nil, false
+	}
+	if e.step.Block() != step.Block() {
+		if step.Block() {
+			// the canonical chain has an empty slot here
+			return nil, true
+		}
+		// the pre-block entry of the canonical block
+		e, ok = hc.entries[forkchoice.NodeRef{Root: e.parentRoot, Slot: step.Slot()}]
+		if !ok {
+			return nil, false
+		}
+	}
+	return e, true
+}
+
+func (hc *HotChain) Iter() (beacon.ChainIter, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	anchor := hc.anchor()
+	anchorEntry, ok := hc.closest(anchor.Root, anchor.Slot)
+	if !ok {
+		return nil, errors.New("missing anchor entry")
+	}
+	head, err := hc.head()
+	if err != nil {
+		return nil, err
+	}
+	return &hotChainIter{
+		hc:    hc,
+		start: anchorEntry.step,
+		end:   head.step + 1,
+	}, nil
+}
+
+func (hc *HotChain) JustifiedCheckpoint() common.Checkpoint {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.fc.Justified()
+}
+
+func (hc *HotChain) FinalizedCheckpoint() common.Checkpoint {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.fc.Finalized()
+}
+
+func (hc *HotChain) Justified() (beacon.ChainEntry, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.checkpointEntry(hc.fc.Justified())
+}
+
+func (hc *HotChain) Finalized() (beacon.ChainEntry, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.checkpointEntry(hc.fc.Finalized())
+}
+
+// checkpointEntry finds the entry of the checkpoint, the lock must be held by the caller.
+func (hc *HotChain) checkpointEntry(cp common.Checkpoint) (beacon.ChainEntry, error) {
+	slot, err := hc.spec.EpochStartSlot(cp.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	// The entry at the start of the epoch, may be an empty slot.
+	// Fall back to the checkpoint block if the slot was not transitioned to.
+	entry, ok := hc.closest(cp.Root, slot)
+	if !ok {
+		if first, ok := hc.blocks[cp.Root]; ok {
+			return first, nil
+		}
+		return nil, fmt.Errorf("unknown checkpoint %s", cp)
+	}
+	return entry, nil
+}
+
+func (hc *HotChain) Head() (beacon.ChainEntry, error) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	e, err := hc.head()
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// head finds the current head entry, the lock must be held by the caller.
+func (hc *HotChain) head() (*HotEntry, error) {
+	ref, err := hc.fc.Head()
+	if err != nil {
+		return nil, err
+	}
+	// The forkchoice may have empty slot nodes without an entry as head, use the closest entry instead.
+	entry, ok := hc.closest(ref.Root, ref.Slot)
+	if !ok {
+		return nil, fmt.Errorf("missing entry for head %s", ref)
+	}
+	return entry, nil
+}
+
+func (hc *HotChain) Towards(ctx context.Context, fromBlockRoot common.Root, toSlot common.Slot) (beacon.ChainEntry, error) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	e, err := hc.towards(ctx, fromBlockRoot, toSlot)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// towards transitions to the requested slot, the lock must be held by the caller.
+func (hc *HotChain) towards(ctx context.Context, fromBlockRoot common.Root, toSlot common.Slot) (*HotEntry, error) {
+	start, ok := hc.closest(fromBlockRoot, toSlot)
+	if !ok {
+		return nil, fmt.Errorf("could not find entry of block %s to transition to slot %d", fromBlockRoot, toSlot)
+	}
+	if start.step.Slot() == toSlot {
+		return start, nil
+	}
+	state, err := start.state.CopyState()
+	if err != nil {
+		return nil, err
+	}
+	epc := start.epc.Clone()
+	upgradeable := &beacon.StandardUpgradeableBeaconState{BeaconState: state}
+	if err := common.ProcessSlots(ctx, hc.spec, epc, upgradeable, toSlot); err != nil {
+		return nil, err
+	}
+	entry := &HotEntry{
+		step:       common.AsStep(toSlot, false),
+		blockRoot:  fromBlockRoot,
+		parentRoot: fromBlockRoot,
+		stateRoot:  upgradeable.HashTreeRoot(tree.GetHashFn()),
+		epc:        epc,
+		state:      upgradeable.BeaconState,
+	}
+	justifiedEpoch, finalizedEpoch, err := checkpointEpochs(entry.state)
+	if err != nil {
+		return nil, err
+	}
+	hc.fc.ProcessSlot(fromBlockRoot, toSlot, justifiedEpoch, finalizedEpoch)
+	hc.putEntry(entry)
+	return entry, nil
+}
+
+func (hc *HotChain) Genesis() beacon.GenesisInfo {
+	return hc.genesis
+}
+
+// ProcessAttestation adds the vote of a validator to the forkchoice.
+func (hc *HotChain) ProcessAttestation(index common.ValidatorIndex, blockRoot common.Root, headSlot common.Slot, targetEpoch common.Epoch) (ok bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return hc.fc.ProcessAttestation(index, blockRoot, headSlot, targetEpoch)
+}
+
+// ProcessEquivocation discards the forkchoice votes of validators that are proven to equivocate.
+// The indices are trusted as-is: use ProcessAttesterSlashing for evidence that has not been verified yet.
+func (hc *HotChain) ProcessEquivocation(indices []common.ValidatorIndex) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.fc.ProcessEquivocation(indices)
+}
+
+// ProcessAttesterSlashing verifies the attester slashing against the justified state,
+// and discards the forkchoice votes of the equivocating validators.
+func (hc *HotChain) ProcessAttesterSlashing(ctx context.Context, sl *phase0.AttesterSlashing) error {
+	if !phase0.IsSlashableAttestationData(&sl.Attestation1.Data, &sl.Attestation2.Data) {
+		return errors.New("attester slashing is not slashable")
+	}
+	hc.mu.RLock()
+	justified, err := hc.checkpointEntry(hc.fc.Justified())
+	hc.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to get justified entry: %w", err)
+	}
+	state, err := justified.State(ctx)
+	if err != nil {
+		return err
+	}
+	epc, err := justified.EpochsContext(ctx)
+	if err != nil {
+		return err
+	}
+	if err := phase0.ValidateIndexedAttestation(ctx, hc.spec, epc, state, &sl.Attestation1); err != nil {
+		return fmt.Errorf("attestation 1 is invalid: %w", err)
+	}
+	if err := phase0.ValidateIndexedAttestation(ctx, hc.spec, epc, state, &sl.Attestation2); err != nil {
+		return fmt.Errorf("attestation 2 is invalid: %w", err)
+	}
+	hc.ProcessEquivocation(sl.EquivocatingIndices())
+	return nil
+}
+
+// AddBlock processes the block on top of its parent, and adds the result to the chain and forkchoice.
+// Justification and finalization changes are applied to the forkchoice,
+// and finalized entries are pruned (and sent to the sink, if any).
+// If validateResult is true, the block signature and resulting state-root are checked.
+func (hc *HotChain) AddBlock(ctx context.Context, benv *common.BeaconBlockEnvelope, validateResult bool) error {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if _, ok := hc.blocks[benv.BlockRoot]; ok {
+		return nil
+	}
+	pre, err := hc.towards(ctx, benv.ParentRoot, benv.Slot)
+	if err != nil {
+		return fmt.Errorf("failed to get pre-state of block %s: %w", benv.BlockRoot, err)
+	}
+	state, err := pre.state.CopyState()
+	if err != nil {
+		return err
+	}
+	epc := pre.epc.Clone()
+	// Signatures are verified all at once after processing the block, which is much faster than one by one.
+	sigs := common.NewSignatureBatch()
+	if err := common.PostSlotTransition(common.WithSignatureBatch(ctx, sigs), hc.spec, epc, state, benv, validateResult); err != nil {
+		return fmt.Errorf("failed to process block %s: %w", benv.BlockRoot, err)
+	}
+	if err := sigs.Verify(); err != nil {
+		return fmt.Errorf("failed to verify signatures of block %s: %w", benv.BlockRoot, err)
+	}
+	entry := &HotEntry{
+		step:       common.AsStep(benv.Slot, true),
+		blockRoot:  benv.BlockRoot,
+		parentRoot: benv.ParentRoot,
+		stateRoot:  state.HashTreeRoot(tree.GetHashFn()),
+		epc:        epc,
+		state:      state,
+		block:      benv,
+	}
+	justifiedEpoch, finalizedEpoch, err := checkpointEpochs(state)
+	if err != nil {
+		return err
+	}
+	if !hc.fc.ProcessBlock(benv.ParentRoot, benv.BlockRoot, benv.Slot, justifiedEpoch, finalizedEpoch) {
+		return fmt.Errorf("forkchoice rejected block %s", benv.BlockRoot)
+	}
+	hc.putEntry(entry)
+	return hc.updateCheckpoints(ctx, entry)
+}
+
+// updateCheckpoints updates the forkchoice justification and finalization, if the entry improved it.
+// The lock must be held by the caller.
+func (hc *HotChain) updateCheckpoints(ctx context.Context, entry *HotEntry) error {
+	justified, err := entry.state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return err
+	}
+	finalized, err := entry.state.FinalizedCheckpoint()
+	if err != nil {
+		return err
+	}
+	prevJustified := hc.fc.Justified()
+	prevFinalized := hc.fc.Finalized()
+	if justified.Epoch <= prevJustified.Epoch && finalized.Epoch <= prevFinalized.Epoch {
+		return nil
+	}
+	// Checkpoints that do not progress are kept as-is, the forkchoice may have them rooted differently (e.g. anchor).
+	if justified.Epoch <= prevJustified.Epoch {
+		justified = prevJustified
+	}
+	if finalized.Epoch <= prevFinalized.Epoch {
+		finalized = prevFinalized
+	}
+	return hc.fc.UpdateJustified(ctx, entry.blockRoot, justified, finalized, func() ([]forkchoice.Gwei, error) {
+		justifiedSlot, err := hc.spec.EpochStartSlot(justified.Epoch)
+		if err != nil {
+			return nil, err
+		}
+		justifiedEntry, ok := hc.closest(justified.Root, justifiedSlot)
+		if !ok {
+			return nil, fmt.Errorf("missing justified entry %s", justified)
+		}
+		return activeBalances(hc.spec, justifiedEntry.state)
+	})
+}
+
+type hotChainIter struct {
+	hc    *HotChain
+	start common.Step
+	end   common.Step
+}
+
+func (it *hotChainIter) Start() common.Step {
+	return it.start
+}
+
+func (it *hotChainIter) End() common.Step {
+	return it.end
+}
+
+func (it *hotChainIter) Entry(step common.Step) (entry beacon.ChainEntry, err error) {
+	if step < it.start || step >= it.end {
+		return nil, fmt.Errorf("step %s is out of range %s - %s", step, it.start, it.end)
+	}
+	it.hc.mu.RLock()
+	defer it.hc.mu.RUnlock()
+	entry, ok := it.hc.byCanonStep(step)
+	if !ok {
+		return nil, fmt.Errorf("no canonical entry available at step %s", step)
+	}
+	return entry, nil
+}
+
+func checkpointEpochs(state common.BeaconState) (justified common.Epoch, finalized common.Epoch, err error) {
+	j, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return j.Epoch, f.Epoch, nil
+}
+
+// activeBalances returns the effective balances of the validators, zeroed for inactive validators.
+func activeBalances(spec *common.Spec, state common.BeaconState) ([]forkchoice.Gwei, error) {
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	epoch := spec.SlotToEpoch(slot)
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	flat, err := common.FlattenValidators(vals)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]forkchoice.Gwei, len(flat), len(flat))
+	for i := range flat {
+		if flat[i].IsActive(epoch) {
+			out[i] = flat[i].EffectiveBalance
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,523 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+type prunedEntry struct {
+	root      common.Root
+	slot      common.Slot
+	step      common.Step
+	stateRoot common.Root
+	canonical bool
+}
+
+// testSink records the pruned entries, and forwards them to the next sink, if any.
+type testSink struct {
+	pruned []prunedEntry
+	next   PruneSink
+}
+
+func (s *testSink) OnPrunedEntry(ctx context.Context, entry *HotEntry, canonical bool) error {
+	s.pruned = append(s.pruned, prunedEntry{
+		root:      entry.blockRoot,
+		slot:      entry.step.Slot(),
+		step:      entry.step,
+		stateRoot: entry.stateRoot,
+		canonical: canonical,
+	})
+	if s.next != nil {
+		return s.next.OnPrunedEntry(ctx, entry, canonical)
+	}
+	return nil
+}
+
+type testChain struct {
+	spec *common.Spec
+	keys []*blsu.SecretKey
+	hc   *HotChain
+	// pending attestations, to include in the next blocks
+	atts []phase0.Attestation
+	sink *testSink
+}
+
+func newTestChain(t *testing.T, next PruneSink) *testChain {
+	spec := configs.Minimal
+	tc := &testChain{spec: spec, sink: &testSink{next: next}}
+	rawKeys := make([][32]byte, 64)
+	vals := make([]phase0.KickstartValidatorData, len(rawKeys))
+	for i := range rawKeys {
+		rawKeys[i][31] = byte(i + 1)
+		sk := new(blsu.SecretKey)
+		if err := sk.Deserialize(&rawKeys[i]); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.keys = append(tc.keys, sk)
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	genesis, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 1000, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc.hc, err = NewHotChain(spec, genesis, epc, tc.sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tc
+}
+
+func (tc *testChain) sign(index common.ValidatorIndex, root common.Root, domType common.BLSDomainType, slot common.Slot) *blsu.Signature {
+	dom := common.ComputeDomain(domType, tc.spec.ForkVersion(slot), tc.hc.Genesis().ValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(root, dom)
+	return blsu.Sign(tc.keys[index], signingRoot[:])
+}
+
+func (tc *testChain) entry(t *testing.T, root common.Root) beacon.ChainEntry {
+	t.Helper()
+	e, ok := tc.hc.ByBlock(root)
+	if !ok {
+		t.Fatalf("missing block %s", root)
+	}
+	return e
+}
+
+// addBlock builds, signs and adds a block on top of the parent block, with the pending attestations that can be included.
+func (tc *testChain) addBlock(t *testing.T, parentRoot common.Root, slot common.Slot, graffiti common.Root) common.Root {
+	t.Helper()
+	ctx := context.Background()
+	parent := tc.entry(t, parentRoot)
+	pre, err := parent.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc, err := parent.EpochsContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stateCopy, err := pre.CopyState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc = epc.Clone()
+	state := &beacon.StandardUpgradeableBeaconState{BeaconState: stateCopy}
+	if err := common.ProcessSlots(ctx, tc.spec, epc, state, slot); err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := state.LatestBlockHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth1Data, err := state.Eth1Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := tc.spec.SlotToEpoch(slot)
+	block := &phase0.BeaconBlock{
+		Slot:          slot,
+		ProposerIndex: proposer,
+		ParentRoot:    header.HashTreeRoot(tree.GetHashFn()),
+		Body: phase0.BeaconBlockBody{
+			RandaoReveal: tc.sign(proposer, epoch.HashTreeRoot(tree.GetHashFn()), common.DOMAIN_RANDAO, slot).Serialize(),
+			Eth1Data:     eth1Data,
+			Graffiti:     graffiti,
+			Attestations: tc.includable(t, state, slot),
+		},
+	}
+	fork, err := state.Fork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := common.ComputeForkDigest(fork.CurrentVersion, tc.hc.Genesis().ValidatorsRoot)
+	// process the unsigned block to compute the state root
+	unsigned := &phase0.SignedBeaconBlock{Message: *block}
+	if err := common.PostSlotTransition(ctx, tc.spec, epc, state, unsigned.Envelope(tc.spec, digest), false); err != nil {
+		t.Fatal(err)
+	}
+	block.StateRoot = state.HashTreeRoot(tree.GetHashFn())
+	signed := &phase0.SignedBeaconBlock{
+		Message:   *block,
+		Signature: tc.sign(proposer, block.HashTreeRoot(tc.spec, tree.GetHashFn()), common.DOMAIN_BEACON_PROPOSER, slot).Serialize(),
+	}
+	benv := signed.Envelope(tc.spec, digest)
+	if err := tc.hc.AddBlock(ctx, benv, true); err != nil {
+		t.Fatal(err)
+	}
+	return benv.BlockRoot
+}
+
+// includable lists the pending attestations that can be included in a block at the given slot, on top of the state.
+func (tc *testChain) includable(t *testing.T, state common.BeaconState, slot common.Slot) (out phase0.Attestations) {
+	t.Helper()
+	prevJustified, err := state.PreviousJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	currJustified, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := tc.spec.SlotToEpoch(slot)
+	for _, att := range tc.atts {
+		if att.Data.Slot+tc.spec.MIN_ATTESTATION_INCLUSION_DELAY > slot || slot > att.Data.Slot+tc.spec.SLOTS_PER_EPOCH {
+			continue
+		}
+		if att.Data.Target.Epoch == epoch && att.Data.Source == currJustified {
+			out = append(out, att)
+		} else if att.Data.Target.Epoch+1 == epoch && att.Data.Source == prevJustified {
+			out = append(out, att)
+		}
+	}
+	return out
+}
+
+// attest makes every committee of the slot attest to the head block, with forkchoice votes and pending aggregates.
+func (tc *testChain) attest(t *testing.T, headRoot common.Root, slot common.Slot) {
+	t.Helper()
+	ctx := context.Background()
+	head := tc.entry(t, headRoot)
+	state, err := head.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epc, err := head.EpochsContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epoch := tc.spec.SlotToEpoch(slot)
+	source, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := common.Checkpoint{Epoch: epoch, Root: headRoot}
+	if startSlot, _ := tc.spec.EpochStartSlot(epoch); head.Step().Slot() > startSlot {
+		if target.Root, err = common.GetBlockRoot(tc.spec, state, epoch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	count, err := epc.GetCommitteeCountPerSlot(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < count; i++ {
+		committee, err := epc.GetBeaconCommittee(slot, common.CommitteeIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := phase0.AttestationData{
+			Slot:            slot,
+			Index:           common.CommitteeIndex(i),
+			BeaconBlockRoot: headRoot,
+			Source:          source,
+			Target:          target,
+		}
+		bits := make(phase0.AttestationBits, (len(committee)>>3)+1)
+		bits.SetBit(uint64(len(committee)), true)
+		sigs := make([]*blsu.Signature, 0, len(committee))
+		for j, index := range committee {
+			bits.SetBit(uint64(j), true)
+			sigs = append(sigs, tc.sign(index, data.HashTreeRoot(tree.GetHashFn()), common.DOMAIN_BEACON_ATTESTER, slot))
+			tc.hc.ProcessAttestation(index, headRoot, head.Step().Slot(), target.Epoch)
+		}
+		sig, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.atts = append(tc.atts, phase0.Attestation{AggregationBits: bits, Data: data, Signature: sig.Serialize()})
+	}
+}
+
+func (tc *testChain) head(t *testing.T) common.Root {
+	t.Helper()
+	head, err := tc.hc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := head.BlockRoot()
+	return root
+}
+
+func TestHotChainAddBlock(t *testing.T) {
+	tc := newTestChain(t, nil)
+	genesisRoot := tc.head(t)
+	a := tc.addBlock(t, genesisRoot, 1, common.Root{})
+	b := tc.addBlock(t, a, 3, common.Root{})
+	// without votes, the empty slot and the block are tied: vote for the block
+	for i := common.ValidatorIndex(0); i < 8; i++ {
+		tc.hc.ProcessAttestation(i, b, 3, 0)
+	}
+	if head := tc.head(t); head != b {
+		t.Fatalf("expected head %s, got %s", b, head)
+	}
+	entry := tc.entry(t, b)
+	if entry.Step() != common.AsStep(3, true) {
+		t.Fatalf("unexpected step %s", entry.Step())
+	}
+	if parent, _ := entry.ParentRoot(); parent != a {
+		t.Fatalf("expected parent %s, got %s", a, parent)
+	}
+	stateRoot, _ := entry.StateRoot()
+	if e, ok := tc.hc.ByStateRoot(stateRoot); !ok || e != entry {
+		t.Fatal("expected to find the block entry by state root")
+	}
+	// the pre-block slot node of the block, transitioned to by AddBlock
+	if e, ok := tc.hc.ByBlockSlot(a, 3); !ok || e.Step() != common.AsStep(3, false) {
+		t.Fatal("expected empty slot entry before block b")
+	}
+	// adding the same block again is a no-op
+	benv, err := entry.Block()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.hc.AddBlock(context.Background(), benv, true); err != nil {
+		t.Fatal(err)
+	}
+	// blocks with an unknown parent are refused
+	orphan := *benv
+	orphan.ParentRoot = common.Root{0xde, 0xad}
+	orphan.BlockRoot = common.Root{0xbe, 0xef}
+	if err := tc.hc.AddBlock(context.Background(), &orphan, true); err == nil {
+		t.Fatal("expected block with unknown parent to be refused")
+	}
+	if unknown, inSubtree := tc.hc.InSubtree(a, b); unknown || !inSubtree {
+		t.Fatal("expected b to be in the subtree of a")
+	}
+	if unknown, inSubtree := tc.hc.InSubtree(b, a); unknown || inSubtree {
+		t.Fatal("expected a to not be in the subtree of b")
+	}
+}
+
+func TestHotChainCanonical(t *testing.T) {
+	tc := newTestChain(t, nil)
+	ctx := context.Background()
+	genesisRoot := tc.head(t)
+	a := tc.addBlock(t, genesisRoot, 1, common.Root{})
+	b := tc.addBlock(t, a, 3, common.Root{})
+	for i := common.ValidatorIndex(0); i < 8; i++ {
+		tc.hc.ProcessAttestation(i, b, 3, 0)
+	}
+	// the votes are applied when the head is computed
+	if head := tc.head(t); head != b {
+		t.Fatalf("expected head %s, got %s", b, head)
+	}
+
+	// empty slots after the head
+	e, err := tc.hc.Towards(ctx, b, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Step() != common.AsStep(6, false) {
+		t.Fatalf("unexpected step %s", e.Step())
+	}
+	if root, _ := e.BlockRoot(); root != b {
+		t.Fatalf("expected empty slot of block %s, got %s", b, root)
+	}
+	if closest, ok := tc.hc.Closest(b, 8); !ok || closest.Step() != e.Step() {
+		t.Fatal("expected the closest entry to be the last empty slot")
+	}
+	if _, ok := tc.hc.Closest(b, 2); ok {
+		t.Fatal("expected no entry of b before its slot")
+	}
+
+	if e, ok := tc.hc.ByCanonStep(common.AsStep(3, true)); !ok || e.Step() != common.AsStep(3, true) {
+		t.Fatal("expected canonical block entry at slot 3")
+	}
+	if e, ok := tc.hc.ByCanonStep(common.AsStep(3, false)); !ok || e.Step() != common.AsStep(3, false) {
+		t.Fatal("expected canonical pre-block entry at slot 3")
+	}
+	if e, ok := tc.hc.ByCanonStep(common.AsStep(2, true)); !ok || e != nil {
+		t.Fatal("expected empty canonical slot 2")
+	}
+
+	iter, err := tc.hc.Iter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the head is the last empty slot that was transitioned to
+	if iter.Start() != common.AsStep(0, true) || iter.End() != common.AsStep(6, false)+1 {
+		t.Fatalf("unexpected iterator range %s - %s", iter.Start(), iter.End())
+	}
+	var blocks []common.Root
+	for step := iter.Start(); step < iter.End(); step++ {
+		// slots that were never transitioned to have no pre-block entry
+		if !step.Block() {
+			continue
+		}
+		entry, err := iter.Entry(step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			root, _ := entry.BlockRoot()
+			blocks = append(blocks, root)
+		}
+	}
+	if len(blocks) != 3 || blocks[0] != genesisRoot || blocks[1] != a || blocks[2] != b {
+		t.Fatalf("unexpected canonical blocks: %v", blocks)
+	}
+	if _, err := iter.Entry(iter.End()); err == nil {
+		t.Fatal("expected step after the end to be out of range")
+	}
+}
+
+func TestHotChainFinalization(t *testing.T) {
+	tc := newTestChain(t, nil)
+	genesisRoot := tc.head(t)
+	// a fork that does not get any votes, and is pruned when finalization passes it
+	fork := tc.addBlock(t, genesisRoot, 2, common.Root{0xf0})
+
+	head := genesisRoot
+	for slot := common.Slot(1); slot <= 5*tc.spec.SLOTS_PER_EPOCH; slot++ {
+		head = tc.addBlock(t, head, slot, common.Root{})
+		tc.attest(t, head, slot)
+	}
+	if got := tc.head(t); got != head {
+		t.Fatalf("expected head %s, got %s", head, got)
+	}
+	fin := tc.hc.FinalizedCheckpoint()
+	if fin.Epoch < 2 {
+		t.Fatalf("expected finalization, got finalized checkpoint %s", fin)
+	}
+	if tc.hc.JustifiedCheckpoint().Epoch <= fin.Epoch {
+		t.Fatalf("expected justified checkpoint %s after finalized checkpoint", tc.hc.JustifiedCheckpoint())
+	}
+	finEntry, err := tc.hc.Finalized()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, _ := finEntry.BlockRoot(); root != fin.Root {
+		t.Fatalf("expected finalized entry of %s, got %s", fin.Root, root)
+	}
+
+	// everything before the finalized checkpoint is pruned, and sent to the sink
+	finSlot, _ := tc.spec.EpochStartSlot(fin.Epoch)
+	var prunedFork, prunedGenesis bool
+	for _, p := range tc.sink.pruned {
+		// the pre-block entry of the finalized block is pruned, the finalized block itself is not
+		if p.slot > finSlot || (p.slot == finSlot && p.root == fin.Root) {
+			t.Fatalf("unexpected pruned entry %s at slot %d, after finalized slot %d", p.root, p.slot, finSlot)
+		}
+		if p.root == fork {
+			prunedFork = true
+			if p.canonical {
+				t.Fatal("expected the fork to be pruned as non-canonical")
+			}
+		}
+		if p.root == genesisRoot && p.slot == 0 {
+			prunedGenesis = true
+			if !p.canonical {
+				t.Fatal("expected genesis to be pruned as canonical")
+			}
+		}
+	}
+	if !prunedFork || !prunedGenesis {
+		t.Fatalf("expected the fork and genesis to be pruned, got %d pruned entries", len(tc.sink.pruned))
+	}
+	for _, root := range []common.Root{genesisRoot, fork} {
+		if _, ok := tc.hc.ByBlock(root); ok {
+			t.Fatalf("expected pruned block %s to be removed", root)
+		}
+	}
+	iter, err := tc.hc.Iter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iter.Start().Slot() < finSlot {
+		t.Fatalf("expected iteration to start at the finalized checkpoint, got %s", iter.Start())
+	}
+
+	// head selection after pruning: a competing block takes over when it gets the votes
+	headEntry := tc.entry(t, head)
+	parentRoot, _ := headEntry.ParentRoot()
+	nextSlot := headEntry.Step().Slot() + 1
+	competitor := tc.addBlock(t, parentRoot, nextSlot, common.Root{0xc0})
+	if got := tc.head(t); got != head {
+		t.Fatalf("expected head %s to stay, got %s", head, got)
+	}
+	tc.attest(t, competitor, nextSlot)
+	next := tc.addBlock(t, competitor, nextSlot+1, common.Root{})
+	tc.attest(t, next, nextSlot+1)
+	if got := tc.head(t); got != next {
+		t.Fatalf("expected head to move to %s, got %s", next, got)
+	}
+	// blocks cannot build on pruned blocks
+	if _, err := tc.hc.Towards(context.Background(), fork, nextSlot); err == nil {
+		t.Fatal("expected transition from pruned block to fail")
+	}
+}
+
+func TestHotChainAttesterSlashing(t *testing.T) {
+	tc := newTestChain(t, nil)
+	ctx := context.Background()
+	genesisRoot := tc.head(t)
+	a := tc.addBlock(t, genesisRoot, 1, common.Root{0xa})
+	b := tc.addBlock(t, genesisRoot, 1, common.Root{0xb})
+	for i := common.ValidatorIndex(0); i < 4; i++ {
+		tc.hc.ProcessAttestation(i, b, 1, 0)
+	}
+	for i := common.ValidatorIndex(4); i < 6; i++ {
+		tc.hc.ProcessAttestation(i, a, 1, 0)
+	}
+	if head := tc.head(t); head != b {
+		t.Fatalf("expected head %s, got %s", b, head)
+	}
+
+	// validators 0-3 double vote for a and b in the same target epoch
+	indexed := func(blockRoot common.Root, indices ...common.ValidatorIndex) phase0.IndexedAttestation {
+		data := phase0.AttestationData{
+			Slot:            1,
+			BeaconBlockRoot: blockRoot,
+			Target:          common.Checkpoint{Epoch: 0, Root: genesisRoot},
+		}
+		sigs := make([]*blsu.Signature, 0, len(indices))
+		for _, i := range indices {
+			sigs = append(sigs, tc.sign(i, data.HashTreeRoot(tree.GetHashFn()), common.DOMAIN_BEACON_ATTESTER, 1))
+		}
+		sig, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return phase0.IndexedAttestation{AttestingIndices: indices, Data: data, Signature: sig.Serialize()}
+	}
+	sl := &phase0.AttesterSlashing{
+		Attestation1: indexed(a, 0, 1, 2, 3),
+		Attestation2: indexed(b, 0, 1, 2, 3),
+	}
+
+	// unverified evidence is refused, and does not affect the votes
+	notSlashable := &phase0.AttesterSlashing{Attestation1: sl.Attestation1, Attestation2: sl.Attestation1}
+	if err := tc.hc.ProcessAttesterSlashing(ctx, notSlashable); err == nil {
+		t.Fatal("expected non-slashable attestation data to be refused")
+	}
+	badSig := *sl
+	badSig.Attestation2.Signature = sl.Attestation1.Signature
+	if err := tc.hc.ProcessAttesterSlashing(ctx, &badSig); err == nil {
+		t.Fatal("expected invalid signature to be refused")
+	}
+	if head := tc.head(t); head != b {
+		t.Fatalf("expected head %s to stay, got %s", b, head)
+	}
+
+	if err := tc.hc.ProcessAttesterSlashing(ctx, sl); err != nil {
+		t.Fatal(err)
+	}
+	if head := tc.head(t); head != a {
+		t.Fatalf("expected head to move to %s without the equivocating votes, got %s", a, head)
+	}
+}
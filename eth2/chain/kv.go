@@ -0,0 +1,95 @@
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// KV is the minimal key-value storage the cold chain persists its data to.
+type KV interface {
+	// Get the value of the key. If the key does not exist, ok is false, without error.
+	Get(key []byte) (value []byte, ok bool, err error)
+	// Put the value at the given key, overwriting any existing value.
+	Put(key []byte, value []byte) error
+}
+
+// MemKV is an in-memory KV store, safe for concurrent use.
+type MemKV struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+var _ KV = (*MemKV)(nil)
+
+func NewMemKV() *MemKV {
+	return &MemKV{data: make(map[string][]byte)}
+}
+
+func (m *MemKV) Get(key []byte) (value []byte, ok bool, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	v, ok := m.data[string(key)]
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), v...), true, nil
+}
+
+func (m *MemKV) Put(key []byte, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[string(key)] = append([]byte(nil), value...)
+	return nil
+}
+
+// FileKV stores every key as a separate file in a directory, the file name is the hex-encoded key.
+// Writes are atomic: the value is written to a temporary file first, and then moved into place.
+type FileKV struct {
+	dir string
+}
+
+var _ KV = (*FileKV)(nil)
+
+// NewFileKV creates a file-based KV store in the given directory, the directory is created if it does not exist.
+func NewFileKV(dir string) (*FileKV, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileKV{dir: dir}, nil
+}
+
+func (f *FileKV) path(key []byte) string {
+	return filepath.Join(f.dir, hex.EncodeToString(key))
+}
+
+func (f *FileKV) Get(key []byte) (value []byte, ok bool, err error) {
+	value, err = ioutil.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (f *FileKV) Put(key []byte, value []byte) error {
+	tmp, err := ioutil.TempFile(f.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(value); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"bytes"
+	"testing"
+)
+
+func testKV(t *testing.T, kv KV) {
+	if _, ok, err := kv.Get([]byte("missing")); err != nil || ok {
+		t.Fatalf("expected missing key, got ok=%v err=%v", ok, err)
+	}
+	key := []byte{0x00, 'k', 0xff}
+	if err := kv.Put(key, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Put(key, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := kv.Get(key)
+	if err != nil || !ok {
+		t.Fatalf("expected key, got ok=%v err=%v", ok, err)
+	}
+	if !bytes.Equal(value, []byte("second")) {
+		t.Fatalf("expected overwritten value, got %q", value)
+	}
+	// the returned value is a copy, changes do not affect the stored value
+	value[0] = 'x'
+	if value, _, _ := kv.Get(key); !bytes.Equal(value, []byte("second")) {
+		t.Fatalf("expected stored value to be unchanged, got %q", value)
+	}
+	if err := kv.Put([]byte("empty"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := kv.Get([]byte("empty")); err != nil || !ok || len(value) != 0 {
+		t.Fatalf("expected empty value, got %q ok=%v err=%v", value, ok, err)
+	}
+}
+
+func TestMemKV(t *testing.T) {
+	testKV(t, NewMemKV())
+}
+
+func TestFileKV(t *testing.T) {
+	dir := t.TempDir()
+	kv, err := NewFileKV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testKV(t, kv)
+	// the data persists, and is available when opened again
+	reopened, err := NewFileKV(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := reopened.Get([]byte{0x00, 'k', 0xff}); err != nil || !ok || !bytes.Equal(value, []byte("second")) {
+		t.Fatalf("expected persisted value, got %q ok=%v err=%v", value, ok, err)
+	}
+}
@@ -58,6 +58,11 @@ var Minimal = &common.Spec{
 		BYTES_PER_LOGS_BLOOM:                       256,
 		MAX_EXTRA_DATA_BYTES:                       32,
 	},
+	CapellaPreset: common.CapellaPreset{
+		MAX_BLS_TO_EXECUTION_CHANGES:         16,
+		MAX_WITHDRAWALS_PER_PAYLOAD:          4,
+		MAX_VALIDATORS_PER_WITHDRAWALS_SWEEP: 16,
+	},
 	ShardingPreset: common.ShardingPreset{
 		MAX_SHARDS:                          8,
 		INITIAL_ACTIVE_SHARDS:               2,
@@ -81,7 +86,9 @@ var Minimal = &common.Spec{
 		ALTAIR_FORK_EPOCH:                    ^common.Epoch(0),
 		BELLATRIX_FORK_VERSION:               common.Version{0x02, 0x00, 0x00, 0x01},
 		BELLATRIX_FORK_EPOCH:                 ^common.Epoch(0),
-		SHARDING_FORK_VERSION:                common.Version{0x03, 0x00, 0x00, 0x01},
+		CAPELLA_FORK_VERSION:                 common.Version{0x03, 0x00, 0x00, 0x01},
+		CAPELLA_FORK_EPOCH:                   ^common.Epoch(0),
+		SHARDING_FORK_VERSION:                common.Version{0x04, 0x00, 0x00, 0x01},
 		SHARDING_FORK_EPOCH:                  ^common.Epoch(0),
 		TERMINAL_TOTAL_DIFFICULTY:            view.MustUint256("115792089237316195423570985008687907853269984665640564039457584007913129638912"),
 		TERMINAL_BLOCK_HASH:                  common.Bytes32{},
@@ -0,0 +1,195 @@
+package duties
+
+import (
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+)
+
+type AttesterDuty struct {
+	Pubkey           common.BLSPubkey      `json:"pubkey" yaml:"pubkey"`
+	ValidatorIndex   common.ValidatorIndex `json:"validator_index" yaml:"validator_index"`
+	Slot             common.Slot           `json:"slot" yaml:"slot"`
+	CommitteeIndex   common.CommitteeIndex `json:"committee_index" yaml:"committee_index"`
+	CommitteeLength  uint64                `json:"committee_length" yaml:"committee_length"`
+	CommitteesAtSlot uint64                `json:"committees_at_slot" yaml:"committees_at_slot"`
+	// Position of the validator in the committee, i.e. the index of its bit in the aggregation bits.
+	ValidatorCommitteeIndex uint64 `json:"validator_committee_index" yaml:"validator_committee_index"`
+	// Attestation subnet to publish the attestation to.
+	Subnet uint64 `json:"subnet" yaml:"subnet"`
+}
+
+type ProposerDuty struct {
+	Pubkey         common.BLSPubkey      `json:"pubkey" yaml:"pubkey"`
+	ValidatorIndex common.ValidatorIndex `json:"validator_index" yaml:"validator_index"`
+	Slot           common.Slot           `json:"slot" yaml:"slot"`
+}
+
+type SyncCommitteeDuty struct {
+	Pubkey         common.BLSPubkey      `json:"pubkey" yaml:"pubkey"`
+	ValidatorIndex common.ValidatorIndex `json:"validator_index" yaml:"validator_index"`
+	// Sync committee subnets, one for each position of the validator in the sync committee.
+	// A validator may be in the committee multiple times, and subnets may repeat.
+	Subnets []uint64 `json:"subnets" yaml:"subnets"`
+}
+
+// EpochDuties is the reverse index of all duties of a single epoch,
+// to look up the duties of any set of validators without iterating the committees again.
+type EpochDuties struct {
+	Spec  *common.Spec
+	Epoch common.Epoch
+
+	pubkeys   *common.PubkeyCache
+	attesters map[common.ValidatorIndex]*AttesterDuty
+	proposers []common.ValidatorIndex
+	// nil pre-altair
+	syncCommittee *common.IndexedSyncCommittee
+}
+
+// NewEpochDuties computes the duties of the current or next epoch of the epochs-context.
+// The state must be the state the epochs-context was computed for.
+//
+// The proposers of the next epoch are computed with the effective balances of the current epoch:
+// changes to the effective balances during the epoch transition may change them, and must be rechecked at the next epoch.
+func NewEpochDuties(spec *common.Spec, epc *common.EpochsContext, state common.BeaconState, epoch common.Epoch) (*EpochDuties, error) {
+	var shuf *common.ShufflingEpoch
+	var proposers *common.ProposersEpoch
+	var syncCommittee *common.IndexedSyncCommittee
+	switch epoch {
+	case epc.CurrentEpoch.Epoch:
+		shuf = epc.CurrentEpoch
+		proposers = epc.Proposers
+		syncCommittee = epc.CurrentSyncCommittee
+	case epc.NextEpoch.Epoch:
+		shuf = epc.NextEpoch
+		var err error
+		proposers, err = common.ComputeProposers(spec, state, epoch, shuf.ActiveIndices)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute proposers of next epoch %d: %v", epoch, err)
+		}
+		// the sync committee rotates at the start of a sync committee period
+		if epoch%spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD == 0 {
+			syncCommittee = epc.NextSyncCommittee
+		} else {
+			syncCommittee = epc.CurrentSyncCommittee
+		}
+	default:
+		return nil, fmt.Errorf("duties can only be computed for current epoch %d and next epoch %d, not for epoch %d",
+			epc.CurrentEpoch.Epoch, epc.NextEpoch.Epoch, epoch)
+	}
+	if proposers.Epoch != epoch {
+		return nil, fmt.Errorf("proposers are computed for epoch %d, expected epoch %d", proposers.Epoch, epoch)
+	}
+	startSlot, err := spec.EpochStartSlot(epoch)
+	if err != nil {
+		return nil, err
+	}
+
+	attesters := make(map[common.ValidatorIndex]*AttesterDuty, len(shuf.ActiveIndices))
+	for i, slotComms := range shuf.Committees {
+		slot := startSlot + common.Slot(i)
+		committeesAtSlot := uint64(len(slotComms))
+		for j, committee := range slotComms {
+			index := common.CommitteeIndex(j)
+			subnet, err := phase0.ComputeSubnetForAttestation(spec, committeesAtSlot, slot, index)
+			if err != nil {
+				return nil, err
+			}
+			for k, vi := range committee {
+				attesters[vi] = &AttesterDuty{
+					ValidatorIndex:          vi,
+					Slot:                    slot,
+					CommitteeIndex:          index,
+					CommitteeLength:         uint64(len(committee)),
+					CommitteesAtSlot:        committeesAtSlot,
+					ValidatorCommitteeIndex: uint64(k),
+					Subnet:                  subnet,
+				}
+			}
+		}
+	}
+	return &EpochDuties{
+		Spec:          spec,
+		Epoch:         epoch,
+		pubkeys:       epc.ValidatorPubkeyCache,
+		attesters:     attesters,
+		proposers:     proposers.Proposers,
+		syncCommittee: syncCommittee,
+	}, nil
+}
+
+// lookup maps the pubkeys to validator indices, unknown pubkeys are ignored.
+func (d *EpochDuties) lookup(pubkeys []common.BLSPubkey) map[common.ValidatorIndex]common.BLSPubkey {
+	out := make(map[common.ValidatorIndex]common.BLSPubkey, len(pubkeys))
+	for _, pub := range pubkeys {
+		if vi, ok := d.pubkeys.ValidatorIndex(pub); ok {
+			out[vi] = pub
+		}
+	}
+	return out
+}
+
+// AttesterDuties returns the attester duties of the validators with the given pubkeys, in the same order.
+// Pubkeys of unknown or inactive validators are ignored.
+func (d *EpochDuties) AttesterDuties(pubkeys []common.BLSPubkey) []AttesterDuty {
+	out := make([]AttesterDuty, 0, len(pubkeys))
+	for _, pub := range pubkeys {
+		vi, ok := d.pubkeys.ValidatorIndex(pub)
+		if !ok {
+			continue
+		}
+		if duty, ok := d.attesters[vi]; ok {
+			res := *duty
+			res.Pubkey = pub
+			out = append(out, res)
+		}
+	}
+	return out
+}
+
+// ProposerDuties returns the proposer duties of the validators with the given pubkeys, ordered by slot.
+func (d *EpochDuties) ProposerDuties(pubkeys []common.BLSPubkey) []ProposerDuty {
+	indices := d.lookup(pubkeys)
+	startSlot, _ := d.Spec.EpochStartSlot(d.Epoch)
+	var out []ProposerDuty
+	for i, vi := range d.proposers {
+		if pub, ok := indices[vi]; ok {
+			out = append(out, ProposerDuty{Pubkey: pub, ValidatorIndex: vi, Slot: startSlot + common.Slot(i)})
+		}
+	}
+	return out
+}
+
+// AllProposerDuties returns the proposer of each slot of the epoch.
+func (d *EpochDuties) AllProposerDuties() []ProposerDuty {
+	startSlot, _ := d.Spec.EpochStartSlot(d.Epoch)
+	out := make([]ProposerDuty, 0, len(d.proposers))
+	for i, vi := range d.proposers {
+		pub, ok := d.pubkeys.Pubkey(vi)
+		if !ok {
+			continue
+		}
+		out = append(out, ProposerDuty{Pubkey: pub.Compressed, ValidatorIndex: vi, Slot: startSlot + common.Slot(i)})
+	}
+	return out
+}
+
+// SyncCommitteeDuties returns the sync committee duties of the validators with the given pubkeys, in the same order.
+// There are no sync committee duties before the altair fork.
+func (d *EpochDuties) SyncCommitteeDuties(pubkeys []common.BLSPubkey) []SyncCommitteeDuty {
+	if d.syncCommittee == nil {
+		return nil
+	}
+	var out []SyncCommitteeDuty
+	for _, pub := range pubkeys {
+		vi, ok := d.pubkeys.ValidatorIndex(pub)
+		if !ok {
+			continue
+		}
+		if subnets := d.syncCommittee.Subnets(d.Spec, vi); len(subnets) > 0 {
+			out = append(out, SyncCommitteeDuty{Pubkey: pub, ValidatorIndex: vi, Subnets: subnets})
+		}
+	}
+	return out
+}
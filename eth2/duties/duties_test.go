@@ -0,0 +1,133 @@
+package duties_test
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/duties"
+)
+
+func TestEpochDuties(t *testing.T) {
+	spec := configs.Minimal
+	pubkeys := make([]common.BLSPubkey, 64)
+	keys := make([][32]byte, len(pubkeys))
+	vals := make([]phase0.KickstartValidatorData, len(pubkeys))
+	for i := range vals {
+		keys[i][31] = byte(i + 1)
+		var sk blsu.SecretKey
+		if err := sk.Deserialize(&keys[i]); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(&sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubkeys[i] = pub.Serialize()
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pubkeys[i], Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	pre, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 0, vals, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := altair.UpgradeToAltair(spec, epc, pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := epc.LoadSyncCommittees(state); err != nil {
+		t.Fatal(err)
+	}
+	// unknown pubkeys are ignored
+	query := append([]common.BLSPubkey{{0xff}}, pubkeys...)
+
+	for _, epoch := range []common.Epoch{0, 1} {
+		d, err := duties.NewEpochDuties(spec, epc, state, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attesters := d.AttesterDuties(query)
+		if len(attesters) != len(pubkeys) {
+			t.Fatalf("epoch %d: expected an attester duty for each validator, got %d", epoch, len(attesters))
+		}
+		for i, duty := range attesters {
+			if duty.Pubkey != pubkeys[i] || duty.ValidatorIndex != common.ValidatorIndex(i) {
+				t.Fatalf("epoch %d: unexpected attester duty order: %v", epoch, duty)
+			}
+			if spec.SlotToEpoch(duty.Slot) != epoch {
+				t.Fatalf("epoch %d: attester duty at slot %d of other epoch", epoch, duty.Slot)
+			}
+			committee, err := epc.GetBeaconCommittee(duty.Slot, duty.CommitteeIndex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(committee)) != duty.CommitteeLength || committee[duty.ValidatorCommitteeIndex] != duty.ValidatorIndex {
+				t.Fatalf("epoch %d: attester duty does not match committee: %v", epoch, duty)
+			}
+			subnet, err := phase0.ComputeSubnetForAttestation(spec, duty.CommitteesAtSlot, duty.Slot, duty.CommitteeIndex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subnet != duty.Subnet {
+				t.Fatalf("epoch %d: expected subnet %d, got %d", epoch, subnet, duty.Subnet)
+			}
+		}
+
+		proposers := d.ProposerDuties(query)
+		if len(proposers) != int(spec.SLOTS_PER_EPOCH) {
+			t.Fatalf("epoch %d: expected a proposer for each slot, got %d", epoch, len(proposers))
+		}
+		if all := d.AllProposerDuties(); len(all) != len(proposers) {
+			t.Fatalf("epoch %d: expected %d proposers, got %d", epoch, len(proposers), len(all))
+		}
+		for _, duty := range d.ProposerDuties([]common.BLSPubkey{proposers[0].Pubkey}) {
+			if duty.ValidatorIndex != proposers[0].ValidatorIndex {
+				t.Fatalf("epoch %d: unexpected proposer duty of other validator: %v", epoch, duty)
+			}
+		}
+
+		syncDuties := d.SyncCommitteeDuties(query)
+		positions := 0
+		for _, duty := range syncDuties {
+			positions += len(duty.Subnets)
+			for _, subnet := range duty.Subnets {
+				if !epc.CurrentSyncCommittee.InSubnet(spec, duty.ValidatorIndex, subnet) {
+					t.Fatalf("epoch %d: validator %d not in sync subnet %d", epoch, duty.ValidatorIndex, subnet)
+				}
+			}
+		}
+		if positions != int(spec.SYNC_COMMITTEE_SIZE) {
+			t.Fatalf("epoch %d: expected %d sync committee positions, got %d", epoch, spec.SYNC_COMMITTEE_SIZE, positions)
+		}
+	}
+
+	// The predicted proposers of the next epoch match those of the next epoch, without balance changes.
+	next, err := duties.NewEpochDuties(spec, epc, state, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	predicted := next.AllProposerDuties()
+	nextEpc := epc.Clone()
+	nextState := &beacon.StandardUpgradeableBeaconState{BeaconState: state}
+	if err := common.ProcessSlots(context.Background(), spec, nextEpc, nextState, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	for _, duty := range predicted {
+		proposer, err := nextEpc.GetBeaconProposer(duty.Slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proposer != duty.ValidatorIndex {
+			t.Fatalf("slot %d: predicted proposer %d, got %d", duty.Slot, duty.ValidatorIndex, proposer)
+		}
+	}
+
+	if _, err := duties.NewEpochDuties(spec, epc, state, 2); err == nil {
+		t.Fatal("expected duties of later epoch to be rejected")
+	}
+}
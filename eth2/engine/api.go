@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// API is the typed Engine API, as implemented by the JSON-RPC Client and the MockEngine.
+type API interface {
+	NewPayloadV1(ctx context.Context, payload *common.ExecutionPayload) (*PayloadStatusV1, error)
+	ForkchoiceUpdatedV1(ctx context.Context, state *ForkchoiceStateV1, attributes *PayloadAttributesV1) (*ForkchoiceUpdatedResult, error)
+	GetPayloadV1(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error)
+	ExchangeTransitionConfigurationV1(ctx context.Context, conf *TransitionConfigurationV1) (*TransitionConfigurationV1, error)
+}
+
+func executePayload(ctx context.Context, api API, executionPayload *common.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	status, err := api.NewPayloadV1(ctx, executionPayload)
+	if err != nil {
+		return common.PayloadExecutionInvalid, err
+	}
+	switch status.Status {
+	case ExecutionValid:
+		return common.PayloadExecutionValid, nil
+	case ExecutionSyncing, ExecutionAccepted:
+		return common.PayloadExecutionOptimistic, nil
+	case ExecutionInvalid, ExecutionInvalidBlockHash, ExecutionInvalidTerminalBlock:
+		return common.PayloadExecutionInvalid, nil
+	default:
+		return common.PayloadExecutionInvalid, fmt.Errorf("unknown payload status: %q", status.Status)
+	}
+}
+
+func notifyForkchoiceUpdated(ctx context.Context, api API, headBlockHash common.Hash32, safeBlockHash common.Hash32,
+	finalizedBlockHash common.Hash32, attributes *common.PayloadAttributes) (*common.PayloadID, error) {
+	state := &ForkchoiceStateV1{
+		HeadBlockHash:      headBlockHash,
+		SafeBlockHash:      safeBlockHash,
+		FinalizedBlockHash: finalizedBlockHash,
+	}
+	var attrV1 *PayloadAttributesV1
+	if attributes != nil {
+		attrV1 = PayloadAttributesToV1(attributes)
+	}
+	res, err := api.ForkchoiceUpdatedV1(ctx, state, attrV1)
+	if err != nil {
+		return nil, err
+	}
+	if res.PayloadStatus.Status.Invalid() {
+		return nil, fmt.Errorf("forkchoice head %s is invalid: %s", headBlockHash, res.PayloadStatus.Status)
+	}
+	if attributes != nil && res.PayloadID == nil {
+		if res.PayloadStatus.Status == ExecutionSyncing {
+			return nil, errors.New("execution engine is syncing, cannot build payload")
+		}
+		return nil, errors.New("execution engine did not start building a payload")
+	}
+	return res.PayloadID, nil
+}
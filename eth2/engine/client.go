@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// JWTSecret is the shared secret to authenticate with the Engine API, as specified by the execution layer.
+type JWTSecret [32]byte
+
+// Token creates a HS256 JWT token, with the "iat" (issued-at) claim set to the given time.
+func (secret *JWTSecret) Token(issuedAt time.Time) string {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := enc.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, issuedAt.Unix())))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(header + "." + claims))
+	return header + "." + claims + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// RPCError is an error returned by the execution engine.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("engine API error %d: %s", e.Code, e.Message)
+}
+
+// Standard JSON-RPC error codes
+const (
+	ErrCodeInvalidParams = -32602
+)
+
+// Engine API specific error codes
+const (
+	ErrCodeUnknownPayload           = -38001
+	ErrCodeInvalidForkchoiceState   = -38002
+	ErrCodeInvalidPayloadAttributes = -38003
+)
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
+
+// Client is an Engine API JSON-RPC client, to drive an execution engine from the beacon chain.
+type Client struct {
+	endpoint string
+	// nil if no authentication is used
+	secret *JWTSecret
+	http   *http.Client
+	nextID uint64
+}
+
+var (
+	_ API                    = (*Client)(nil)
+	_ common.ExecutionEngine = (*Client)(nil)
+)
+
+// NewClient creates a client for the Engine API at the given HTTP endpoint.
+// The secret may be nil to not authenticate the requests.
+// If the http client is nil, a default client with a 8 second timeout is used, as recommended by the Engine API.
+func NewClient(endpoint string, secret *JWTSecret, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 8 * time.Second}
+	}
+	return &Client{endpoint: endpoint, secret: secret, http: httpClient}
+}
+
+func (c *Client) call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	req := rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  params,
+	}
+	if req.Params == nil {
+		req.Params = []interface{}{}
+	}
+	body, err := json.Marshal(&req)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %v", method, err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.secret != nil {
+		httpReq.Header.Set("Authorization", "Bearer "+c.secret.Token(time.Now()))
+	}
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", method, err)
+	}
+	defer httpResp.Body.Close()
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s request failed with HTTP status %d: %s", method, httpResp.StatusCode, string(data))
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", method, err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if resp.ID != req.ID {
+		return fmt.Errorf("%s response ID %d does not match request ID %d", method, resp.ID, req.ID)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %v", method, err)
+	}
+	return nil
+}
+
+// NewPayloadV1 sends the payload to the execution engine, to be validated and inserted.
+func (c *Client) NewPayloadV1(ctx context.Context, payload *common.ExecutionPayload) (*PayloadStatusV1, error) {
+	var out PayloadStatusV1
+	if err := c.call(ctx, &out, "engine_newPayloadV1", ExecutionPayloadToV1(payload)); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ForkchoiceUpdatedV1 updates the fork-choice state of the execution engine,
+// and starts a payload build process if attributes are specified.
+func (c *Client) ForkchoiceUpdatedV1(ctx context.Context, state *ForkchoiceStateV1, attributes *PayloadAttributesV1) (*ForkchoiceUpdatedResult, error) {
+	var out ForkchoiceUpdatedResult
+	// The attributes are explicitly encoded as null if not specified
+	if err := c.call(ctx, &out, "engine_forkchoiceUpdatedV1", state, attributes); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPayloadV1 retrieves the latest version of the payload of the given build process.
+func (c *Client) GetPayloadV1(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	var out ExecutionPayloadV1
+	if err := c.call(ctx, &out, "engine_getPayloadV1", payloadID); err != nil {
+		return nil, err
+	}
+	return out.Payload(), nil
+}
+
+// ExchangeTransitionConfigurationV1 sends the transition configuration of the beacon node,
+// and returns the configuration of the execution engine, to cross-check them.
+func (c *Client) ExchangeTransitionConfigurationV1(ctx context.Context, conf *TransitionConfigurationV1) (*TransitionConfigurationV1, error) {
+	var out TransitionConfigurationV1
+	if err := c.call(ctx, &out, "engine_exchangeTransitionConfigurationV1", conf); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExecutePayload inserts the payload with NewPayloadV1.
+// Payloads that are not validated yet (SYNCING or ACCEPTED status) are reported as optimistic.
+func (c *Client) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return executePayload(ctx, c, executionPayload)
+}
+
+// NotifyForkchoiceUpdated updates the fork-choice with ForkchoiceUpdatedV1.
+func (c *Client) NotifyForkchoiceUpdated(ctx context.Context, headBlockHash common.Hash32, safeBlockHash common.Hash32,
+	finalizedBlockHash common.Hash32, attributes *common.PayloadAttributes) (*common.PayloadID, error) {
+	return notifyForkchoiceUpdated(ctx, c, headBlockHash, safeBlockHash, finalizedBlockHash, attributes)
+}
+
+// GetPayload retrieves the payload with GetPayloadV1.
+func (c *Client) GetPayload(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	return c.GetPayloadV1(ctx, payloadID)
+}
@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/view"
+)
+
+type mockEngine struct {
+	t        *testing.T
+	secret   *JWTSecret
+	handlers map[string]func(params []json.RawMessage) (interface{}, *RPCError)
+}
+
+func (m *mockEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.secret != nil {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || !validToken(m.secret, auth[len("Bearer "):]) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	var req struct {
+		ID     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		m.t.Errorf("bad request: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+	if h, ok := m.handlers[req.Method]; ok {
+		result, rpcErr := h(req.Params)
+		if rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+	} else {
+		resp["error"] = &RPCError{Code: -32601, Message: "method not found"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func validToken(secret *JWTSecret, token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func testPayload() *common.ExecutionPayload {
+	p := &common.ExecutionPayload{
+		ParentHash:   common.Hash32{0x01},
+		FeeRecipient: common.Eth1Address{0x02},
+		StateRoot:    common.Bytes32{0x03},
+		ReceiptsRoot: common.Bytes32{0x04},
+		PrevRandao:   common.Bytes32{0x05},
+		BlockNumber:  123,
+		GasLimit:     30_000_000,
+		GasUsed:      21_000,
+		Timestamp:    1_600_000_000,
+		ExtraData:    common.ExtraData{0xab, 0xcd},
+		BlockHash:    common.Hash32{0x06},
+		Transactions: common.PayloadTransactions{{0x01, 0x02}, {0x03}},
+	}
+	p.LogsBloom[10] = 0xff
+	p.BaseFeePerGas.SetFromBig(bigFromUint(7_000_000_000))
+	return p
+}
+
+func TestNewPayloadV1(t *testing.T) {
+	secret := &JWTSecret{0x42}
+	var received ExecutionPayloadV1
+	status := ExecutionValid
+	m := &mockEngine{t: t, secret: secret, handlers: map[string]func([]json.RawMessage) (interface{}, *RPCError){
+		"engine_newPayloadV1": func(params []json.RawMessage) (interface{}, *RPCError) {
+			if err := json.Unmarshal(params[0], &received); err != nil {
+				t.Errorf("failed to decode payload: %v", err)
+				return nil, &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+			}
+			latest := received.BlockHash
+			if status.Invalid() {
+				latest = received.ParentHash
+			}
+			return &PayloadStatusV1{Status: status, LatestValidHash: &latest}, nil
+		},
+	}}
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+
+	cl := NewClient(srv.URL, secret, nil)
+	payload := testPayload()
+	ctx := context.Background()
+	res, err := cl.NewPayloadV1(ctx, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != ExecutionValid || *res.LatestValidHash != payload.BlockHash {
+		t.Fatalf("unexpected status: %v", res)
+	}
+	got := received.Payload()
+	if got.BlockNumber != payload.BlockNumber || got.BaseFeePerGas != payload.BaseFeePerGas ||
+		got.LogsBloom != payload.LogsBloom || len(got.Transactions) != 2 || got.Transactions[1][0] != 0x03 {
+		t.Fatalf("payload did not roundtrip: %v", got)
+	}
+
+	for _, tc := range []struct {
+		status   ExecutePayloadStatus
+		expected common.PayloadExecutionStatus
+	}{
+		{ExecutionValid, common.PayloadExecutionValid},
+		{ExecutionSyncing, common.PayloadExecutionOptimistic},
+		{ExecutionAccepted, common.PayloadExecutionOptimistic},
+		{ExecutionInvalid, common.PayloadExecutionInvalid},
+		{ExecutionInvalidBlockHash, common.PayloadExecutionInvalid},
+		{ExecutionInvalidTerminalBlock, common.PayloadExecutionInvalid},
+	} {
+		status = tc.status
+		got, err := cl.ExecutePayload(ctx, payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.expected {
+			t.Errorf("status %s: expected %s, got %s", tc.status, tc.expected, got)
+		}
+	}
+
+	unauthorized := NewClient(srv.URL, &JWTSecret{0x01}, nil)
+	if _, err := unauthorized.NewPayloadV1(ctx, payload); err == nil {
+		t.Fatal("expected request with wrong JWT secret to fail")
+	}
+}
+
+func TestForkchoiceUpdatedAndGetPayload(t *testing.T) {
+	payload := testPayload()
+	id := common.PayloadID{1, 2, 3, 4, 5, 6, 7, 8}
+	m := &mockEngine{t: t, handlers: map[string]func([]json.RawMessage) (interface{}, *RPCError){
+		"engine_forkchoiceUpdatedV1": func(params []json.RawMessage) (interface{}, *RPCError) {
+			var state ForkchoiceStateV1
+			if err := json.Unmarshal(params[0], &state); err != nil {
+				t.Errorf("failed to decode forkchoice state: %v", err)
+				return nil, &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+			}
+			if state.HeadBlockHash != payload.ParentHash {
+				return &ForkchoiceUpdatedResult{PayloadStatus: PayloadStatusV1{Status: ExecutionSyncing}}, nil
+			}
+			res := &ForkchoiceUpdatedResult{PayloadStatus: PayloadStatusV1{Status: ExecutionValid}}
+			if string(params[1]) != "null" {
+				var attr PayloadAttributesV1
+				if err := json.Unmarshal(params[1], &attr); err != nil {
+					t.Errorf("failed to decode payload attributes: %v", err)
+					return nil, &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+				}
+				if attr.Timestamp != Quantity(payload.Timestamp) {
+					t.Errorf("unexpected timestamp: %d", attr.Timestamp)
+					return nil, &RPCError{Code: ErrCodeInvalidPayloadAttributes, Message: "unexpected timestamp"}
+				}
+				res.PayloadID = &id
+			}
+			return res, nil
+		},
+		"engine_getPayloadV1": func(params []json.RawMessage) (interface{}, *RPCError) {
+			var got common.PayloadID
+			if err := json.Unmarshal(params[0], &got); err != nil {
+				t.Errorf("failed to decode payload id: %v", err)
+				return nil, &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+			}
+			if got != id {
+				return nil, &RPCError{Code: -38001, Message: "unknown payload"}
+			}
+			return ExecutionPayloadToV1(payload), nil
+		},
+		"engine_exchangeTransitionConfigurationV1": func(params []json.RawMessage) (interface{}, *RPCError) {
+			var conf TransitionConfigurationV1
+			if err := json.Unmarshal(params[0], &conf); err != nil {
+				t.Errorf("failed to decode transition configuration: %v", err)
+				return nil, &RPCError{Code: ErrCodeInvalidParams, Message: err.Error()}
+			}
+			return &conf, nil
+		},
+	}}
+	srv := httptest.NewServer(m)
+	defer srv.Close()
+	cl := NewClient(srv.URL, nil, nil)
+	ctx := context.Background()
+
+	attr := &common.PayloadAttributes{Timestamp: payload.Timestamp, PrevRandao: payload.PrevRandao}
+	gotID, err := cl.NotifyForkchoiceUpdated(ctx, payload.ParentHash, payload.ParentHash, common.Hash32{}, attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotID == nil || *gotID != id {
+		t.Fatalf("unexpected payload id: %v", gotID)
+	}
+	if gotID, err := cl.NotifyForkchoiceUpdated(ctx, payload.ParentHash, payload.ParentHash, common.Hash32{}, nil); err != nil || gotID != nil {
+		t.Fatalf("expected no payload id without attributes, got %v, err: %v", gotID, err)
+	}
+	if _, err := cl.NotifyForkchoiceUpdated(ctx, common.Hash32{0xff}, common.Hash32{}, common.Hash32{}, attr); err == nil {
+		t.Fatal("expected error when building on a syncing head")
+	}
+
+	got, err := cl.GetPayload(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BlockHash != payload.BlockHash || string(got.ExtraData) != string(payload.ExtraData) {
+		t.Fatalf("unexpected payload: %v", got)
+	}
+	if _, err := cl.GetPayload(ctx, common.PayloadID{}); err == nil {
+		t.Fatal("expected unknown payload error")
+	} else if rpcErr, ok := err.(*RPCError); !ok || rpcErr.Code != -38001 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conf := &TransitionConfigurationV1{TerminalBlockHash: common.Hash32{0x0a}, TerminalBlockNumber: 0}
+	(*view.Uint256View)(&conf.TerminalTotalDifficulty).SetFromBig(bigFromUint(58_750_000_000_000_000))
+	out, err := cl.ExchangeTransitionConfigurationV1(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *out != *conf {
+		t.Fatalf("transition configuration did not roundtrip: %v", out)
+	}
+}
+
+func bigFromUint(v uint64) *big.Int {
+	return new(big.Int).SetUint64(v)
+}
+
+func TestQuantity(t *testing.T) {
+	for _, tc := range []struct {
+		v   Quantity
+		enc string
+	}{{0, `"0x0"`}, {1, `"0x1"`}, {0x400, `"0x400"`}} {
+		data, err := json.Marshal(tc.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.enc {
+			t.Errorf("expected %s, got %s", tc.enc, data)
+		}
+		var out Quantity
+		if err := json.Unmarshal(data, &out); err != nil || out != tc.v {
+			t.Errorf("failed to decode %s: %d, err: %v", data, out, err)
+		}
+	}
+	for _, bad := range []string{`"0x"`, `"0x01"`, `"1"`, `"0xz"`} {
+		var out Quantity
+		if err := json.Unmarshal([]byte(bad), &out); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
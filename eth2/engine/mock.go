@@ -0,0 +1,417 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+// Gas used per transaction in payloads built by the MockEngine.
+const mockTxGas = 21_000
+
+type mockBlock struct {
+	hash      common.Hash32
+	parent    common.Hash32
+	number    uint64
+	timestamp common.Timestamp
+	// nil for PoW blocks
+	payload *common.ExecutionPayload
+	// nil for payloads, the total difficulty of PoW blocks
+	totalDifficulty *big.Int
+	// if the block, or any of its ancestors, is invalid
+	invalid bool
+}
+
+// MockEngine is a deterministic in-process execution engine, to test the beacon chain without an execution client.
+//
+// It tracks a tree of PoW blocks and execution payloads, validates the parent hash, block number,
+// timestamp and block hash of new payloads, and builds payloads on request.
+// The PoW chain is extended with MinePoWBlock, to simulate the transition at the terminal total difficulty.
+//
+// The engine can be scripted to be syncing, or to consider specific payloads invalid, to test fault paths.
+// The block hash of a payload is the hash-tree-root of the payload with a zeroed block hash, see BlockHash.
+type MockEngine struct {
+	sync.Mutex
+
+	spec *common.Spec
+
+	blocks  map[common.Hash32]*mockBlock
+	powHead *mockBlock
+
+	head      common.Hash32
+	safe      common.Hash32
+	finalized common.Hash32
+
+	payloads      map[common.PayloadID]*common.ExecutionPayload
+	nextPayloadID uint64
+
+	// overrides the suggested fee recipient of build processes, if not nil
+	feeRecipient *common.Eth1Address
+	// transactions to include in built payloads
+	transactions []common.Transaction
+	gasLimit     uint64
+	baseFee      view.Uint256View
+
+	syncing        bool
+	scriptedStatus map[common.Hash32]ExecutePayloadStatus
+}
+
+var (
+	_ API                    = (*MockEngine)(nil)
+	_ common.ExecutionEngine = (*MockEngine)(nil)
+)
+
+// NewMockEngine creates a mock engine with a PoW genesis block, mined at the given time with the given difficulty.
+// The terminal total difficulty and terminal block hash are taken from the spec.
+func NewMockEngine(spec *common.Spec, genesisTime common.Timestamp, genesisDifficulty uint64) *MockEngine {
+	m := &MockEngine{
+		spec:           spec,
+		blocks:         make(map[common.Hash32]*mockBlock),
+		payloads:       make(map[common.PayloadID]*common.ExecutionPayload),
+		gasLimit:       30_000_000,
+		scriptedStatus: make(map[common.Hash32]ExecutePayloadStatus),
+	}
+	m.baseFee.SetFromBig(big.NewInt(7))
+	genesis := &mockBlock{
+		number:          0,
+		timestamp:       genesisTime,
+		totalDifficulty: new(big.Int).SetUint64(genesisDifficulty),
+	}
+	genesis.hash = powBlockHash(genesis)
+	m.blocks[genesis.hash] = genesis
+	m.powHead = genesis
+	m.head = genesis.hash
+	return m
+}
+
+func powBlockHash(b *mockBlock) common.Hash32 {
+	h := sha256.New()
+	h.Write(b.parent[:])
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], b.number)
+	h.Write(tmp[:])
+	binary.LittleEndian.PutUint64(tmp[:], uint64(b.timestamp))
+	h.Write(tmp[:])
+	h.Write(b.totalDifficulty.Bytes())
+	var out common.Hash32
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// BlockHash computes the block hash of the payload, as the mock engine expects it to be.
+func (m *MockEngine) BlockHash(payload *common.ExecutionPayload) common.Hash32 {
+	p := *payload
+	p.BlockHash = common.Hash32{}
+	return p.HashTreeRoot(m.spec, tree.GetHashFn())
+}
+
+// Seal sets the block hash of the payload, after any test modifications of the payload.
+func (m *MockEngine) Seal(payload *common.ExecutionPayload) {
+	payload.BlockHash = m.BlockHash(payload)
+}
+
+// MinePoWBlock extends the PoW chain with a block of the given difficulty and time, and returns its hash.
+// No PoW blocks can be mined on top of the terminal PoW block.
+func (m *MockEngine) MinePoWBlock(difficulty uint64, timestamp common.Timestamp) (common.Hash32, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.isTerminal(m.powHead) {
+		return common.Hash32{}, fmt.Errorf("PoW chain already reached the terminal block %s", m.powHead.hash)
+	}
+	if timestamp <= m.powHead.timestamp {
+		return common.Hash32{}, fmt.Errorf("PoW block time %d must be after parent time %d", timestamp, m.powHead.timestamp)
+	}
+	b := &mockBlock{
+		parent:          m.powHead.hash,
+		number:          m.powHead.number + 1,
+		timestamp:       timestamp,
+		totalDifficulty: new(big.Int).Add(m.powHead.totalDifficulty, new(big.Int).SetUint64(difficulty)),
+	}
+	b.hash = powBlockHash(b)
+	m.blocks[b.hash] = b
+	m.powHead = b
+	if m.head == b.parent {
+		m.head = b.hash
+	}
+	return b.hash, nil
+}
+
+// isTerminal checks if the PoW block is the terminal block: the first block to reach the terminal total difficulty,
+// or the block with the configured terminal block hash.
+func (m *MockEngine) isTerminal(b *mockBlock) bool {
+	if b.totalDifficulty == nil {
+		return false
+	}
+	if m.spec.TERMINAL_BLOCK_HASH != (common.Bytes32{}) {
+		return b.hash == m.spec.TERMINAL_BLOCK_HASH
+	}
+	ttd := uint256ToBig(m.spec.TERMINAL_TOTAL_DIFFICULTY)
+	if b.totalDifficulty.Cmp(ttd) < 0 {
+		return false
+	}
+	parent, ok := m.blocks[b.parent]
+	return !ok || parent.totalDifficulty.Cmp(ttd) < 0
+}
+
+// TerminalBlock returns the hash of the terminal PoW block, if the PoW chain reached it.
+func (m *MockEngine) TerminalBlock() (hash common.Hash32, ok bool) {
+	m.Lock()
+	defer m.Unlock()
+	if m.isTerminal(m.powHead) {
+		return m.powHead.hash, true
+	}
+	return common.Hash32{}, false
+}
+
+// Head returns the current head block hash, as last updated by the fork-choice.
+func (m *MockEngine) Head() common.Hash32 {
+	m.Lock()
+	defer m.Unlock()
+	return m.head
+}
+
+// SetFeeRecipient overrides the suggested fee recipient of new build processes. Nil to use the suggested recipient.
+func (m *MockEngine) SetFeeRecipient(addr *common.Eth1Address) {
+	m.Lock()
+	defer m.Unlock()
+	m.feeRecipient = addr
+}
+
+// SetTransactions changes the transactions to include in new payloads.
+func (m *MockEngine) SetTransactions(txs []common.Transaction) {
+	m.Lock()
+	defer m.Unlock()
+	m.transactions = append([]common.Transaction(nil), txs...)
+}
+
+// SetSyncing makes the engine respond with SYNCING to all new payloads and fork-choice updates, while true.
+func (m *MockEngine) SetSyncing(syncing bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.syncing = syncing
+}
+
+// ScriptStatus makes the engine respond with the given status when the payload with the given block hash is inserted.
+// The INVALID status also invalidates the descendants of the payload.
+// Any other status is returned as-is, without inserting the payload.
+func (m *MockEngine) ScriptStatus(blockHash common.Hash32, status ExecutePayloadStatus) {
+	m.Lock()
+	defer m.Unlock()
+	m.scriptedStatus[blockHash] = status
+}
+
+// latestValid returns the hash of the latest valid payload in the chain of the given block,
+// or a zero hash if there is none.
+func (m *MockEngine) latestValid(hash common.Hash32) *common.Hash32 {
+	for {
+		b, ok := m.blocks[hash]
+		if !ok || b.payload == nil {
+			return &common.Hash32{}
+		}
+		if !b.invalid {
+			return &b.hash
+		}
+		hash = b.parent
+	}
+}
+
+func invalidStatus(status ExecutePayloadStatus, latestValid *common.Hash32, msg string, args ...interface{}) *PayloadStatusV1 {
+	err := fmt.Sprintf(msg, args...)
+	return &PayloadStatusV1{Status: status, LatestValidHash: latestValid, ValidationError: &err}
+}
+
+func (m *MockEngine) NewPayloadV1(ctx context.Context, payload *common.ExecutionPayload) (*PayloadStatusV1, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.syncing {
+		return &PayloadStatusV1{Status: ExecutionSyncing}, nil
+	}
+	if h := m.BlockHash(payload); h != payload.BlockHash {
+		return invalidStatus(ExecutionInvalidBlockHash, nil, "expected block hash %s, got %s", h, payload.BlockHash), nil
+	}
+	if b, ok := m.blocks[payload.BlockHash]; ok {
+		if b.invalid {
+			return invalidStatus(ExecutionInvalid, m.latestValid(b.hash), "known invalid payload"), nil
+		}
+		return &PayloadStatusV1{Status: ExecutionValid, LatestValidHash: &b.hash}, nil
+	}
+	parent, ok := m.blocks[payload.ParentHash]
+	if !ok {
+		return &PayloadStatusV1{Status: ExecutionSyncing}, nil
+	}
+	if parent.payload == nil && !m.isTerminal(parent) {
+		return invalidStatus(ExecutionInvalidTerminalBlock, &common.Hash32{},
+			"parent %s is not the terminal PoW block", parent.hash), nil
+	}
+	b := &mockBlock{
+		hash:      payload.BlockHash,
+		parent:    payload.ParentHash,
+		number:    uint64(payload.BlockNumber),
+		timestamp: payload.Timestamp,
+		// copy, the caller may modify the payload after inserting it
+		payload: copyPayload(payload),
+	}
+	var invalidErr *PayloadStatusV1
+	if parent.invalid {
+		invalidErr = invalidStatus(ExecutionInvalid, m.latestValid(parent.hash), "parent %s is invalid", parent.hash)
+	} else if b.number != parent.number+1 {
+		invalidErr = invalidStatus(ExecutionInvalid, &parent.hash,
+			"expected block number %d, got %d", parent.number+1, b.number)
+	} else if b.timestamp <= parent.timestamp {
+		invalidErr = invalidStatus(ExecutionInvalid, &parent.hash,
+			"block time %d must be after parent time %d", b.timestamp, parent.timestamp)
+	} else if payload.GasUsed > payload.GasLimit {
+		invalidErr = invalidStatus(ExecutionInvalid, &parent.hash,
+			"gas used %d exceeds gas limit %d", payload.GasUsed, payload.GasLimit)
+	} else if status, ok := m.scriptedStatus[b.hash]; ok {
+		if status != ExecutionInvalid {
+			return &PayloadStatusV1{Status: status}, nil
+		}
+		invalidErr = invalidStatus(ExecutionInvalid, &parent.hash, "scripted invalid payload")
+	}
+	if invalidErr != nil {
+		// Remember invalid payloads, to invalidate any descendants
+		b.invalid = true
+		m.blocks[b.hash] = b
+		return invalidErr, nil
+	}
+	m.blocks[b.hash] = b
+	return &PayloadStatusV1{Status: ExecutionValid, LatestValidHash: &b.hash}, nil
+}
+
+func (m *MockEngine) ForkchoiceUpdatedV1(ctx context.Context, state *ForkchoiceStateV1, attributes *PayloadAttributesV1) (*ForkchoiceUpdatedResult, error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.syncing {
+		return &ForkchoiceUpdatedResult{PayloadStatus: PayloadStatusV1{Status: ExecutionSyncing}}, nil
+	}
+	head, ok := m.blocks[state.HeadBlockHash]
+	if !ok {
+		return &ForkchoiceUpdatedResult{PayloadStatus: PayloadStatusV1{Status: ExecutionSyncing}}, nil
+	}
+	if head.invalid {
+		return &ForkchoiceUpdatedResult{PayloadStatus: *invalidStatus(ExecutionInvalid,
+			m.latestValid(head.hash), "head %s is invalid", head.hash)}, nil
+	}
+	if head.payload == nil && !m.isTerminal(head) {
+		return &ForkchoiceUpdatedResult{PayloadStatus: *invalidStatus(ExecutionInvalidTerminalBlock,
+			&common.Hash32{}, "head %s is not the terminal PoW block", head.hash)}, nil
+	}
+	for _, h := range []common.Hash32{state.SafeBlockHash, state.FinalizedBlockHash} {
+		if _, ok := m.blocks[h]; !ok && h != (common.Hash32{}) {
+			return nil, &RPCError{Code: ErrCodeInvalidForkchoiceState, Message: fmt.Sprintf("unknown block %s", h)}
+		}
+	}
+	m.head = state.HeadBlockHash
+	m.safe = state.SafeBlockHash
+	m.finalized = state.FinalizedBlockHash
+	res := &ForkchoiceUpdatedResult{PayloadStatus: PayloadStatusV1{Status: ExecutionValid, LatestValidHash: &head.hash}}
+	if attributes != nil {
+		if common.Timestamp(attributes.Timestamp) <= head.timestamp {
+			return nil, &RPCError{Code: ErrCodeInvalidPayloadAttributes,
+				Message: fmt.Sprintf("payload time %d must be after head time %d", attributes.Timestamp, head.timestamp)}
+		}
+		m.nextPayloadID++
+		var id common.PayloadID
+		binary.BigEndian.PutUint64(id[:], m.nextPayloadID)
+		m.payloads[id] = m.buildPayload(head, attributes)
+		res.PayloadID = &id
+	}
+	return res, nil
+}
+
+func (m *MockEngine) buildPayload(parent *mockBlock, attributes *PayloadAttributesV1) *common.ExecutionPayload {
+	feeRecipient := attributes.SuggestedFeeRecipient
+	if m.feeRecipient != nil {
+		feeRecipient = *m.feeRecipient
+	}
+	txs := make(common.PayloadTransactions, len(m.transactions))
+	copy(txs, m.transactions)
+	payload := &common.ExecutionPayload{
+		ParentHash:    parent.hash,
+		FeeRecipient:  feeRecipient,
+		PrevRandao:    attributes.PrevRandao,
+		BlockNumber:   view.Uint64View(parent.number + 1),
+		GasLimit:      view.Uint64View(m.gasLimit),
+		GasUsed:       view.Uint64View(mockTxGas * uint64(len(txs))),
+		Timestamp:     common.Timestamp(attributes.Timestamp),
+		ExtraData:     common.ExtraData("mock"),
+		BaseFeePerGas: m.baseFee,
+		Transactions:  txs,
+	}
+	// The mock does not execute transactions, the state root just commits to the chain of transactions.
+	h := sha256.New()
+	if parent.payload != nil {
+		h.Write(parent.payload.StateRoot[:])
+	}
+	txsRoot := txs.HashTreeRoot(m.spec, tree.GetHashFn())
+	h.Write(txsRoot[:])
+	copy(payload.StateRoot[:], h.Sum(nil))
+	m.Seal(payload)
+	return payload
+}
+
+func (m *MockEngine) GetPayloadV1(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	m.Lock()
+	defer m.Unlock()
+	payload, ok := m.payloads[payloadID]
+	if !ok {
+		return nil, &RPCError{Code: ErrCodeUnknownPayload, Message: fmt.Sprintf("unknown payload %s", payloadID)}
+	}
+	// copy, the caller may modify the payload
+	return copyPayload(payload), nil
+}
+
+func copyPayload(payload *common.ExecutionPayload) *common.ExecutionPayload {
+	out := *payload
+	out.ExtraData = append(common.ExtraData(nil), payload.ExtraData...)
+	out.Transactions = make(common.PayloadTransactions, len(payload.Transactions))
+	for i, tx := range payload.Transactions {
+		out.Transactions[i] = append(common.Transaction(nil), tx...)
+	}
+	return &out
+}
+
+func (m *MockEngine) ExchangeTransitionConfigurationV1(ctx context.Context, conf *TransitionConfigurationV1) (*TransitionConfigurationV1, error) {
+	m.Lock()
+	defer m.Unlock()
+	out := &TransitionConfigurationV1{
+		TerminalTotalDifficulty: Uint256Quantity(m.spec.TERMINAL_TOTAL_DIFFICULTY),
+		TerminalBlockHash:       m.spec.TERMINAL_BLOCK_HASH,
+	}
+	if b, ok := m.blocks[out.TerminalBlockHash]; ok && out.TerminalBlockHash != (common.Hash32{}) {
+		out.TerminalBlockNumber = Quantity(b.number)
+	}
+	if out.TerminalTotalDifficulty != conf.TerminalTotalDifficulty {
+		return nil, &RPCError{Code: ErrCodeInvalidParams, Message: fmt.Sprintf("terminal total difficulty mismatch: %s <> %s",
+			view.Uint256View(out.TerminalTotalDifficulty), view.Uint256View(conf.TerminalTotalDifficulty))}
+	}
+	if out.TerminalBlockHash != conf.TerminalBlockHash {
+		return nil, &RPCError{Code: ErrCodeInvalidParams, Message: fmt.Sprintf("terminal block hash mismatch: %s <> %s",
+			out.TerminalBlockHash, conf.TerminalBlockHash)}
+	}
+	return out, nil
+}
+
+// ExecutePayload inserts the payload with NewPayloadV1.
+func (m *MockEngine) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return executePayload(ctx, m, executionPayload)
+}
+
+// NotifyForkchoiceUpdated updates the fork-choice with ForkchoiceUpdatedV1.
+func (m *MockEngine) NotifyForkchoiceUpdated(ctx context.Context, headBlockHash common.Hash32, safeBlockHash common.Hash32,
+	finalizedBlockHash common.Hash32, attributes *common.PayloadAttributes) (*common.PayloadID, error) {
+	return notifyForkchoiceUpdated(ctx, m, headBlockHash, safeBlockHash, finalizedBlockHash, attributes)
+}
+
+// GetPayload retrieves the payload with GetPayloadV1.
+func (m *MockEngine) GetPayload(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	return m.GetPayloadV1(ctx, payloadID)
+}
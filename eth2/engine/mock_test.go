@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/view"
+)
+
+func TestMockEngineMerge(t *testing.T) {
+	spec := *configs.Minimal
+	spec.TERMINAL_TOTAL_DIFFICULTY = view.MustUint256("100")
+	m := NewMockEngine(&spec, 1000, 10)
+	ctx := context.Background()
+	genesis := m.Head()
+
+	attr := &common.PayloadAttributes{Timestamp: 2000, PrevRandao: common.Bytes32{0x01}, SuggestedFeeRecipient: common.Eth1Address{0xaa}}
+	if _, err := m.NotifyForkchoiceUpdated(ctx, genesis, genesis, common.Hash32{}, attr); err == nil {
+		t.Fatal("expected building on a non-terminal PoW block to fail")
+	}
+
+	// total difficulty: 10, 40, 70, 100
+	var pow []common.Hash32
+	for i := 1; i <= 3; i++ {
+		if _, ok := m.TerminalBlock(); ok {
+			t.Fatalf("unexpected terminal block after %d blocks", i)
+		}
+		h, err := m.MinePoWBlock(30, common.Timestamp(1000+i*10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pow = append(pow, h)
+	}
+	terminal, ok := m.TerminalBlock()
+	if !ok || terminal != pow[2] {
+		t.Fatal("expected last PoW block to be the terminal block")
+	}
+	if _, err := m.MinePoWBlock(30, 1100); err == nil {
+		t.Fatal("expected PoW chain to stop at the terminal block")
+	}
+
+	id, err := m.NotifyForkchoiceUpdated(ctx, terminal, terminal, common.Hash32{}, attr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := m.GetPayload(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ParentHash != terminal || first.BlockNumber != 4 || first.Timestamp != attr.Timestamp ||
+		first.FeeRecipient != attr.SuggestedFeeRecipient || first.PrevRandao != attr.PrevRandao {
+		t.Fatalf("unexpected first payload: %v", first)
+	}
+	if status, err := m.ExecutePayload(ctx, first); err != nil || status != common.PayloadExecutionValid {
+		t.Fatalf("expected first payload to be valid, err: %v", err)
+	}
+
+	override := common.Eth1Address{0xbb}
+	m.SetFeeRecipient(&override)
+	m.SetTransactions([]common.Transaction{{0x01}, {0x02, 0x03}})
+	id, err = m.NotifyForkchoiceUpdated(ctx, first.BlockHash, first.BlockHash, common.Hash32{},
+		&common.PayloadAttributes{Timestamp: 2012, SuggestedFeeRecipient: common.Eth1Address{0xaa}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.GetPayload(ctx, *id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.FeeRecipient != override || len(second.Transactions) != 2 || second.GasUsed != 2*mockTxGas {
+		t.Fatalf("unexpected second payload: %v", second)
+	}
+	if status, err := m.NewPayloadV1(ctx, second); err != nil || status.Status != ExecutionValid {
+		t.Fatalf("expected second payload to be valid: %v, err: %v", status, err)
+	}
+	if m.Head() != first.BlockHash {
+		t.Fatal("inserting a payload should not change the head")
+	}
+
+	// Payloads that build on invalid parents, or have invalid contents, are rejected.
+	child := func(parent *common.ExecutionPayload) *common.ExecutionPayload {
+		p := *parent
+		p.ParentHash = parent.BlockHash
+		p.BlockNumber += 1
+		p.Timestamp += 12
+		m.Seal(&p)
+		return &p
+	}
+	bad := child(second)
+	bad.BlockNumber += 1
+	m.Seal(bad)
+	if status, _ := m.NewPayloadV1(ctx, bad); status.Status != ExecutionInvalid || *status.LatestValidHash != second.BlockHash {
+		t.Fatalf("expected invalid block number to be rejected: %v", status)
+	}
+	bad = child(second)
+	bad.Timestamp = second.Timestamp
+	m.Seal(bad)
+	if status, _ := m.NewPayloadV1(ctx, bad); status.Status != ExecutionInvalid {
+		t.Fatalf("expected invalid timestamp to be rejected: %v", status)
+	}
+	bad = child(second)
+	bad.GasUsed += 1
+	if status, _ := m.NewPayloadV1(ctx, bad); status.Status != ExecutionInvalidBlockHash {
+		t.Fatalf("expected invalid block hash to be rejected: %v", status)
+	}
+	bad = child(first)
+	bad.ParentHash = pow[1]
+	m.Seal(bad)
+	if status, _ := m.NewPayloadV1(ctx, bad); status.Status != ExecutionInvalidTerminalBlock {
+		t.Fatalf("expected payload on non-terminal PoW block to be rejected: %v", status)
+	}
+
+	// Scripted invalid payloads invalidate their descendants.
+	third := child(second)
+	fourth := child(third)
+	m.ScriptStatus(third.BlockHash, ExecutionInvalid)
+	if status, err := m.ExecutePayload(ctx, third); err != nil || status != common.PayloadExecutionInvalid {
+		t.Fatalf("expected scripted payload to be invalid, err: %v", err)
+	}
+	if status, _ := m.NewPayloadV1(ctx, fourth); status.Status != ExecutionInvalid || *status.LatestValidHash != second.BlockHash {
+		t.Fatalf("expected descendant of invalid payload to be invalid: %v", status)
+	}
+	if _, err := m.NotifyForkchoiceUpdated(ctx, fourth.BlockHash, first.BlockHash, common.Hash32{}, nil); err == nil {
+		t.Fatal("expected invalid head to be rejected")
+	}
+
+	// Unknown parents and a syncing engine make payloads optimistic.
+	orphan := child(fourth)
+	if status, _ := m.NewPayloadV1(ctx, child(orphan)); status.Status != ExecutionSyncing {
+		t.Fatalf("expected payload with unknown parent to be syncing: %v", status)
+	}
+	m.SetSyncing(true)
+	other := child(second)
+	other.ExtraData = common.ExtraData("other")
+	m.Seal(other)
+	if status, err := m.ExecutePayload(ctx, other); err != nil || status != common.PayloadExecutionOptimistic {
+		t.Fatalf("expected syncing payload to be optimistic, err: %v", err)
+	}
+	if _, err := m.NotifyForkchoiceUpdated(ctx, second.BlockHash, first.BlockHash, common.Hash32{}, attr); err == nil {
+		t.Fatal("expected no payload to be built while syncing")
+	}
+	m.SetSyncing(false)
+	if status, _ := m.NewPayloadV1(ctx, other); status.Status != ExecutionValid {
+		t.Fatalf("expected payload to be valid after syncing: %v", status)
+	}
+}
+
+func TestMockEngineStoresPayloadCopy(t *testing.T) {
+	spec := *configs.Minimal
+	spec.TERMINAL_TOTAL_DIFFICULTY = view.MustUint256("10")
+	m := NewMockEngine(&spec, 1000, 10)
+	ctx := context.Background()
+	genesis := m.Head()
+	payload := &common.ExecutionPayload{
+		ParentHash:   genesis,
+		BlockNumber:  1,
+		Timestamp:    2000,
+		GasLimit:     mockTxGas,
+		GasUsed:      mockTxGas,
+		ExtraData:    common.ExtraData("mock"),
+		Transactions: common.PayloadTransactions{{0x01}},
+	}
+	m.Seal(payload)
+	if status, err := m.NewPayloadV1(ctx, payload); err != nil || status.Status != ExecutionValid {
+		t.Fatalf("expected payload to be valid: %v, err: %v", status, err)
+	}
+	// modifying the inserted payload must not affect the engine
+	payload.Transactions[0][0] = 0xff
+	payload.StateRoot = common.Bytes32{0xff}
+	stored := m.blocks[payload.BlockHash].payload
+	if stored.StateRoot != (common.Bytes32{}) || stored.Transactions[0][0] != 0x01 {
+		t.Fatal("expected the engine to keep the payload as inserted")
+	}
+}
+
+func TestMockEngineTransitionConfiguration(t *testing.T) {
+	spec := *configs.Minimal
+	spec.TERMINAL_TOTAL_DIFFICULTY = view.MustUint256("100")
+	m := NewMockEngine(&spec, 1000, 10)
+	ctx := context.Background()
+	conf := &TransitionConfigurationV1{TerminalTotalDifficulty: Uint256Quantity(spec.TERMINAL_TOTAL_DIFFICULTY)}
+	if _, err := m.ExchangeTransitionConfigurationV1(ctx, conf); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []*TransitionConfigurationV1{
+		{TerminalTotalDifficulty: Uint256Quantity(view.MustUint256("101"))},
+		{TerminalTotalDifficulty: conf.TerminalTotalDifficulty, TerminalBlockHash: common.Hash32{0x01}},
+	} {
+		_, err := m.ExchangeTransitionConfigurationV1(ctx, bad)
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != ErrCodeInvalidParams {
+			t.Errorf("expected invalid params error for mismatching configuration, got %v", err)
+		}
+	}
+}
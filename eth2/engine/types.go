@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/view"
+)
+
+// Quantity is an Engine API QUANTITY: a 0x-prefixed hex-encoded number, without leading zeroes.
+type Quantity uint64
+
+func (q Quantity) MarshalText() ([]byte, error) {
+	return []byte("0x" + strconv.FormatUint(uint64(q), 16)), nil
+}
+
+func (q *Quantity) UnmarshalText(text []byte) error {
+	s := string(text)
+	if !strings.HasPrefix(s, "0x") || len(s) < 3 {
+		return fmt.Errorf("invalid quantity: %q", s)
+	}
+	if len(s) > 3 && s[2] == '0' {
+		return fmt.Errorf("quantity with leading zeroes: %q", s)
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 64)
+	if err != nil {
+		return fmt.Errorf("invalid quantity: %q: %v", s, err)
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+// Uint256Quantity is a 256 bit Engine API QUANTITY.
+type Uint256Quantity view.Uint256View
+
+func (q Uint256Quantity) MarshalText() ([]byte, error) {
+	return []byte("0x" + uint256ToBig(view.Uint256View(q)).Text(16)), nil
+}
+
+func (q *Uint256Quantity) UnmarshalText(text []byte) error {
+	s := string(text)
+	if !strings.HasPrefix(s, "0x") || len(s) < 3 {
+		return fmt.Errorf("invalid quantity: %q", s)
+	}
+	if len(s) > 3 && s[2] == '0' {
+		return fmt.Errorf("quantity with leading zeroes: %q", s)
+	}
+	x, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok {
+		return fmt.Errorf("invalid quantity: %q", s)
+	}
+	if (*view.Uint256View)(q).SetFromBig(x) {
+		return fmt.Errorf("quantity overflows 256 bits: %q", s)
+	}
+	return nil
+}
+
+func uint256ToBig(v view.Uint256View) *big.Int {
+	le := v.Bytes32()
+	var be [32]byte
+	for i := 0; i < 32; i++ {
+		be[i] = le[31-i]
+	}
+	return new(big.Int).SetBytes(be[:])
+}
+
+// ExecutePayloadStatus is the status of a payload, as reported by the execution engine.
+type ExecutePayloadStatus string
+
+const (
+	// given payload is valid
+	ExecutionValid ExecutePayloadStatus = "VALID"
+	// given payload is invalid
+	ExecutionInvalid ExecutePayloadStatus = "INVALID"
+	// sync process is in progress, the payload cannot be validated yet
+	ExecutionSyncing ExecutePayloadStatus = "SYNCING"
+	// the payload extends a side-chain, it is not executed yet, but the block hash is valid
+	ExecutionAccepted ExecutePayloadStatus = "ACCEPTED"
+	// the block hash of the payload does not match the contents
+	ExecutionInvalidBlockHash ExecutePayloadStatus = "INVALID_BLOCK_HASH"
+	// the payload is not a valid terminal PoW block
+	ExecutionInvalidTerminalBlock ExecutePayloadStatus = "INVALID_TERMINAL_BLOCK"
+)
+
+// Invalid is true for all the statuses that reject the payload.
+func (s ExecutePayloadStatus) Invalid() bool {
+	return s == ExecutionInvalid || s == ExecutionInvalidBlockHash || s == ExecutionInvalidTerminalBlock
+}
+
+type PayloadStatusV1 struct {
+	// the result of the payload execution
+	Status ExecutePayloadStatus `json:"status"`
+	// the hash of the most recent valid block in the branch defined by payload and its ancestors
+	LatestValidHash *common.Hash32 `json:"latestValidHash"`
+	// additional details on the result
+	ValidationError *string `json:"validationError"`
+}
+
+type ForkchoiceStateV1 struct {
+	// block hash of the head of the canonical chain
+	HeadBlockHash common.Hash32 `json:"headBlockHash"`
+	// the "safe" block hash of the canonical chain under certain synchrony and honesty assumptions.
+	// This value MUST be either equal to or an ancestor of headBlockHash
+	SafeBlockHash common.Hash32 `json:"safeBlockHash"`
+	// block hash of the most recent finalized block
+	FinalizedBlockHash common.Hash32 `json:"finalizedBlockHash"`
+}
+
+type PayloadAttributesV1 struct {
+	// value for the timestamp field of the new payload
+	Timestamp Quantity `json:"timestamp"`
+	// value for the prevRandao field of the new payload
+	PrevRandao common.Bytes32 `json:"prevRandao"`
+	// suggested value for the feeRecipient field of the new payload
+	SuggestedFeeRecipient common.Eth1Address `json:"suggestedFeeRecipient"`
+}
+
+func PayloadAttributesToV1(attr *common.PayloadAttributes) *PayloadAttributesV1 {
+	return &PayloadAttributesV1{
+		Timestamp:             Quantity(attr.Timestamp),
+		PrevRandao:            attr.PrevRandao,
+		SuggestedFeeRecipient: attr.SuggestedFeeRecipient,
+	}
+}
+
+type ForkchoiceUpdatedResult struct {
+	// the result of the payload execution
+	PayloadStatus PayloadStatusV1 `json:"payloadStatus"`
+	// the payload id if requested
+	PayloadID *common.PayloadID `json:"payloadId"`
+}
+
+type TransitionConfigurationV1 struct {
+	// maps on the TERMINAL_TOTAL_DIFFICULTY parameter
+	TerminalTotalDifficulty Uint256Quantity `json:"terminalTotalDifficulty"`
+	// maps on the TERMINAL_BLOCK_HASH parameter
+	TerminalBlockHash common.Hash32 `json:"terminalBlockHash"`
+	// number of the terminal block, 0 if the terminal block is not configured
+	TerminalBlockNumber Quantity `json:"terminalBlockNumber"`
+}
+
+// ExecutionPayloadV1 is the JSON representation of the common.ExecutionPayload in the Engine API.
+type ExecutionPayloadV1 struct {
+	ParentHash    common.Hash32        `json:"parentHash"`
+	FeeRecipient  common.Eth1Address   `json:"feeRecipient"`
+	StateRoot     common.Bytes32       `json:"stateRoot"`
+	ReceiptsRoot  common.Bytes32       `json:"receiptsRoot"`
+	LogsBloom     common.LogsBloom     `json:"logsBloom"`
+	PrevRandao    common.Bytes32       `json:"prevRandao"`
+	BlockNumber   Quantity             `json:"blockNumber"`
+	GasLimit      Quantity             `json:"gasLimit"`
+	GasUsed       Quantity             `json:"gasUsed"`
+	Timestamp     Quantity             `json:"timestamp"`
+	ExtraData     common.ExtraData     `json:"extraData"`
+	BaseFeePerGas Uint256Quantity      `json:"baseFeePerGas"`
+	BlockHash     common.Hash32        `json:"blockHash"`
+	Transactions  []common.Transaction `json:"transactions"`
+}
+
+func ExecutionPayloadToV1(p *common.ExecutionPayload) *ExecutionPayloadV1 {
+	txs := make([]common.Transaction, len(p.Transactions))
+	copy(txs, p.Transactions)
+	return &ExecutionPayloadV1{
+		ParentHash:    p.ParentHash,
+		FeeRecipient:  p.FeeRecipient,
+		StateRoot:     p.StateRoot,
+		ReceiptsRoot:  p.ReceiptsRoot,
+		LogsBloom:     p.LogsBloom,
+		PrevRandao:    p.PrevRandao,
+		BlockNumber:   Quantity(p.BlockNumber),
+		GasLimit:      Quantity(p.GasLimit),
+		GasUsed:       Quantity(p.GasUsed),
+		Timestamp:     Quantity(p.Timestamp),
+		ExtraData:     p.ExtraData,
+		BaseFeePerGas: Uint256Quantity(p.BaseFeePerGas),
+		BlockHash:     p.BlockHash,
+		Transactions:  txs,
+	}
+}
+
+func (p *ExecutionPayloadV1) Payload() *common.ExecutionPayload {
+	txs := make(common.PayloadTransactions, len(p.Transactions))
+	copy(txs, p.Transactions)
+	return &common.ExecutionPayload{
+		ParentHash:    p.ParentHash,
+		FeeRecipient:  p.FeeRecipient,
+		StateRoot:     p.StateRoot,
+		ReceiptsRoot:  p.ReceiptsRoot,
+		LogsBloom:     p.LogsBloom,
+		PrevRandao:    p.PrevRandao,
+		BlockNumber:   view.Uint64View(p.BlockNumber),
+		GasLimit:      view.Uint64View(p.GasLimit),
+		GasUsed:       view.Uint64View(p.GasUsed),
+		Timestamp:     common.Timestamp(p.Timestamp),
+		ExtraData:     p.ExtraData,
+		BaseFeePerGas: view.Uint256View(p.BaseFeePerGas),
+		BlockHash:     p.BlockHash,
+		Transactions:  txs,
+	}
+}
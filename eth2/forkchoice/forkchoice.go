@@ -20,6 +20,12 @@ type ProtoForkChoice struct {
 	justified Checkpoint
 	finalized Checkpoint
 	spec      *common.Spec
+
+	currentSlot Slot
+	// The timely block of the current slot, if any
+	proposerBoost *NodeRef
+	// If the proposer boost changed since the last score changes were applied
+	boostChanged bool
 }
 
 var _ Forkchoice = (*ProtoForkChoice)(nil)
@@ -83,7 +89,7 @@ func (fc *ProtoForkChoice) UpdateJustified(ctx context.Context, trigger Root, ju
 	}
 	if fc.pin != nil && trigger != fc.pin.Root {
 		// check trigger against pin, to ensure no justification/finalization of data that conflicts with the pin.
-		if unknown, inSubtree := fc.InSubtree(fc.pin.Root, trigger); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.pin.Root, trigger); unknown {
 			return fmt.Errorf("cannot justify/finalize with unknown trigger when forkchoice is pinned")
 		} else if !inSubtree {
 			return fmt.Errorf("cannot justify/finalize outside of pinned forkchoice tree")
@@ -92,7 +98,7 @@ func (fc *ProtoForkChoice) UpdateJustified(ctx context.Context, trigger Root, ju
 
 	prevFinalized := fc.finalized
 
-	if err := fc.updateJustified(justified, finalized, justifiedStateBalances); err != nil {
+	if err := fc.updateJustified(finalized, justified, justifiedStateBalances); err != nil {
 		return err
 	}
 
@@ -115,7 +121,7 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 
 	// check if new finalized checkpoint is valid
 	if fc.finalized != finalized {
-		if unknown, inSubtree := fc.InSubtree(fc.finalized.Root, finalized.Root); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.finalized.Root, finalized.Root); unknown {
 			return fmt.Errorf("unknown finalized checkpoint: %s", finalized)
 		} else if !inSubtree || fc.finalized.Epoch > finalized.Epoch {
 			return fmt.Errorf("new finalized checkpoint %s is outside of finalized subtree: %s",
@@ -123,7 +129,7 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 		}
 	}
 	if fc.justified != justified {
-		if unknown, inSubtree := fc.InSubtree(fc.finalized.Root, justified.Root); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.finalized.Root, justified.Root); unknown {
 			return fmt.Errorf("unknown justified checkpoint: %s", justified)
 		} else if !inSubtree || fc.finalized.Epoch > justified.Epoch {
 			return fmt.Errorf("new justified checkpoint %s is outside of finalized subtree: %s",
@@ -137,11 +143,12 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 		return err
 	}
 
-	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), oldBals, newBals)
+	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), fc.protoArray.IndexOffset(), oldBals, newBals)
 
-	if err := fc.protoArray.ApplyScoreChanges(deltas, justified.Epoch, finalized.Epoch); err != nil {
+	if err := fc.protoArray.ApplyScoreChanges(deltas, justified.Epoch, finalized.Epoch, fc.boost(newBals)); err != nil {
 		return err
 	}
+	fc.boostChanged = false
 
 	fc.balances = newBals
 	fc.justified = justified
@@ -153,13 +160,57 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 // TODO: skip based on time (like rate limiting) or based on amount of changes
 //  (if not bigger than previous difference between head-node contenders)
 func (fc *ProtoForkChoice) updateVotesMaybe() error {
-	if !fc.voteStore.HasChanges() {
+	if !fc.voteStore.HasChanges() && !fc.boostChanged {
 		return nil
 	}
 
-	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), fc.balances, fc.balances)
+	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), fc.protoArray.IndexOffset(), fc.balances, fc.balances)
 
-	return fc.protoArray.ApplyScoreChanges(deltas, fc.justified.Epoch, fc.finalized.Epoch)
+	if err := fc.protoArray.ApplyScoreChanges(deltas, fc.justified.Epoch, fc.finalized.Epoch, fc.boost(fc.balances)); err != nil {
+		return err
+	}
+	fc.boostChanged = false
+	return nil
+}
+
+// boost computes the proposer boost, a fraction of the average committee weight, given the justified balances.
+func (fc *ProtoForkChoice) boost(balances []Gwei) ProposerBoost {
+	if fc.proposerBoost == nil {
+		return ProposerBoost{}
+	}
+	total := Gwei(0)
+	for _, b := range balances {
+		total += b
+	}
+	committeeWeight := total / Gwei(fc.spec.SLOTS_PER_EPOCH)
+	return ProposerBoost{
+		Ref:   *fc.proposerBoost,
+		Score: committeeWeight * Gwei(fc.spec.PROPOSER_SCORE_BOOST) / 100,
+	}
+}
+
+func (fc *ProtoForkChoice) OnTimelyBlock(blockRoot Root, slot Slot) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	// Only blocks of the current slot can be timely
+	if slot != fc.currentSlot {
+		return
+	}
+	fc.proposerBoost = &NodeRef{Root: blockRoot, Slot: slot}
+	fc.boostChanged = true
+}
+
+func (fc *ProtoForkChoice) OnTick(slot Slot) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if slot == fc.currentSlot {
+		return
+	}
+	fc.currentSlot = slot
+	if fc.proposerBoost != nil {
+		fc.proposerBoost = nil
+		fc.boostChanged = true
+	}
 }
 
 func (fc *ProtoForkChoice) Justified() Checkpoint {
@@ -174,7 +225,7 @@ func (fc *ProtoForkChoice) Finalized() Checkpoint {
 	return fc.finalized
 }
 
-func (fc *ProtoForkChoice) ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot) (ok bool) {
+func (fc *ProtoForkChoice) ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot, targetEpoch Epoch) (ok bool) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
 	// only add the vote if we can. Don't add if it's not within view.
@@ -182,7 +233,13 @@ func (fc *ProtoForkChoice) ProcessAttestation(index ValidatorIndex, blockRoot Ro
 	if !ok || blockSlot < headSlot {
 		return false
 	}
-	return fc.voteStore.ProcessAttestation(index, blockRoot, headSlot)
+	return fc.voteStore.ProcessAttestation(index, blockRoot, headSlot, targetEpoch)
+}
+
+func (fc *ProtoForkChoice) ProcessEquivocation(indices []ValidatorIndex) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.voteStore.ProcessEquivocation(indices)
 }
 
 func (fc *ProtoForkChoice) CanonicalChain(anchorRoot Root, anchorSlot Slot) ([]ExtendedNodeRef, error) {
@@ -203,6 +260,31 @@ func (fc *ProtoForkChoice) ProcessBlock(parentRoot Root, blockRoot Root, blockSl
 	return fc.protoArray.ProcessBlock(parentRoot, blockRoot, blockSlot, justifiedEpoch, finalizedEpoch)
 }
 
+func (fc *ProtoForkChoice) ProcessPayloadBlock(parentRoot Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch,
+	blockHash Root, status PayloadStatus) (ok bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.protoArray.ProcessPayloadBlock(parentRoot, blockRoot, blockSlot, justifiedEpoch, finalizedEpoch, blockHash, status)
+}
+
+func (fc *ProtoForkChoice) ValidatePayload(blockRoot Root) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.protoArray.ValidatePayload(blockRoot)
+}
+
+func (fc *ProtoForkChoice) InvalidatePayload(blockRoot Root, latestValidHash *Root) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.protoArray.InvalidatePayload(blockRoot, latestValidHash)
+}
+
+func (fc *ProtoForkChoice) PayloadStatus(blockRoot Root) (status PayloadStatus, ok bool) {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return fc.protoArray.PayloadStatus(blockRoot)
+}
+
 func (fc *ProtoForkChoice) InSubtree(anchor Root, root Root) (unknown bool, inSubtree bool) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
@@ -245,6 +327,24 @@ func (fc *ProtoForkChoice) FindHead(anchorRoot Root, anchorSlot Slot) (NodeRef,
 func (fc *ProtoForkChoice) Head() (NodeRef, error) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
+	return fc.head()
+}
+
+func (fc *ProtoForkChoice) IsHeadOptimistic() (bool, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	head, err := fc.head()
+	if err != nil {
+		return false, err
+	}
+	status, ok := fc.protoArray.PayloadStatus(head.Root)
+	if !ok {
+		return false, fmt.Errorf("unknown head %s", head)
+	}
+	return status == PayloadOptimistic, nil
+}
+
+func (fc *ProtoForkChoice) head() (NodeRef, error) {
 	if err := fc.updateVotesMaybe(); err != nil {
 		return NodeRef{}, err
 	}
@@ -2,6 +2,7 @@ package forkchoice
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 )
@@ -17,6 +18,32 @@ type ExtendedNodeRef = common.ExtendedNodeRef
 type SignedGwei int64
 type NodeIndex uint64
 
+// PayloadStatus is the execution status of a node, i.e. of the payload of the block, or that of the latest block
+// before an empty slot. Blocks without execution payload (pre-merge) are valid.
+type PayloadStatus uint8
+
+const (
+	// PayloadValid is the status of payloads that are verified by the execution engine, and their ancestors.
+	PayloadValid PayloadStatus = iota
+	// PayloadOptimistic is the status of payloads that were imported, but are not verified yet.
+	PayloadOptimistic
+	// PayloadInvalid is the status of invalid payloads, and all their descendants.
+	PayloadInvalid
+)
+
+func (s PayloadStatus) String() string {
+	switch s {
+	case PayloadValid:
+		return "valid"
+	case PayloadOptimistic:
+		return "optimistic"
+	case PayloadInvalid:
+		return "invalid"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
 type ForkchoiceView interface {
 	CanonicalChain(anchorRoot Root, anchorSlot Slot) ([]ExtendedNodeRef, error)
 	ClosestToSlot(anchor Root, slot Slot) (closest NodeRef, err error)
@@ -32,30 +59,66 @@ type ForkchoiceNodeInput interface {
 	ProcessBlock(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch) (ok bool)
 }
 
+// ProposerBoost is a temporary weight added to a timely block, for the duration of its slot.
+type ProposerBoost struct {
+	Ref   NodeRef
+	Score Gwei
+}
+
+// ForkchoicePayloadInput tracks the execution status of blocks, to support optimistic sync.
+// Invalid nodes, and nodes with invalid ancestors, are not viable for the head.
+type ForkchoicePayloadInput interface {
+	// ProcessPayloadBlock is like ProcessBlock, but for blocks with an execution payload, with the given status.
+	// A valid payload also makes all its ancestors valid.
+	// Descendants of invalid blocks are always invalid.
+	ProcessPayloadBlock(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch,
+		blockHash Root, status PayloadStatus) (ok bool)
+	// ValidatePayload marks the payload of the block, and all its ancestors, as valid.
+	ValidatePayload(blockRoot Root) error
+	// InvalidatePayload marks the payload of the block, and all its descendants, as invalid.
+	// If the latest valid execution block hash is known, ancestors after the block with that hash are invalidated too.
+	// A zero latest valid hash invalidates all optimistic ancestors, e.g. when the terminal PoW block is invalid.
+	InvalidatePayload(blockRoot Root, latestValidHash *Root) error
+	// PayloadStatus returns the payload status of the block, or ok=false if the block is unknown.
+	PayloadStatus(blockRoot Root) (status PayloadStatus, ok bool)
+}
+
 type ForkchoiceGraph interface {
 	ForkchoiceView
 	ForkchoiceNodeInput
+	ForkchoicePayloadInput
+	// Indices maps the nodes to their absolute index, which starts at IndexOffset for the oldest node that was not pruned.
 	Indices() map[NodeRef]NodeIndex
-	ApplyScoreChanges(deltas []SignedGwei, justifiedEpoch Epoch, finalizedEpoch Epoch) error
+	IndexOffset() NodeIndex
+	// ApplyScoreChanges applies the vote deltas, and replaces any previously applied proposer boost with the given boost.
+	ApplyScoreChanges(deltas []SignedGwei, justifiedEpoch Epoch, finalizedEpoch Epoch, boost ProposerBoost) error
 	OnPrune(ctx context.Context, anchorRoot Root, anchorSlot Slot) error
 }
 
 type VoteInput interface {
-	// ProcessAttestation overrides any previous vote, and applies voting weight to the new root/slot.
+	// ProcessAttestation overrides any previous vote of an older target epoch, and applies voting weight to the new root/slot.
+	// The target epoch is that of the attestation data, votes of the same target epoch do not override each other.
 	// If the root/slot combination does not exist, no changes are made, and ok=false is returned.
 	// It is up to the caller if nodes should be added, to then process the attestation.
-	ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot) (ok bool)
+	ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot, targetEpoch Epoch) (ok bool)
+	// ProcessEquivocation permanently discards the votes of the given validators, as they are proven to equivocate.
+	// The indices are trusted: the forkchoice does not verify any evidence.
+	// The caller must only pass the EquivocatingIndices of an attester slashing that passed validation,
+	// i.e. slashable attestation data and valid indexed attestations.
+	ProcessEquivocation(indices []ValidatorIndex)
 }
 
 type VoteStore interface {
 	VoteInput
 	HasChanges() bool
-	ComputeDeltas(indices map[NodeRef]NodeIndex, oldBalances []Gwei, newBalances []Gwei) []SignedGwei
+	// ComputeDeltas computes the weight change of each node, relative to the index offset of the nodes.
+	ComputeDeltas(indices map[NodeRef]NodeIndex, indexOffset NodeIndex, oldBalances []Gwei, newBalances []Gwei) []SignedGwei
 }
 
 type Forkchoice interface {
 	ForkchoiceView
 	ForkchoiceNodeInput
+	ForkchoicePayloadInput
 	VoteInput
 	UpdateJustified(ctx context.Context, trigger Root, justified Checkpoint, finalized Checkpoint,
 		justifiedStateBalances func() ([]Gwei, error)) error
@@ -64,4 +127,13 @@ type Forkchoice interface {
 	Justified() Checkpoint
 	Finalized() Checkpoint
 	Head() (NodeRef, error)
+	// OnTimelyBlock boosts the block with a fraction of the committee weight, as defined by PROPOSER_SCORE_BOOST.
+	// The caller is responsible for checking that the block was received in time, i.e. before the attestation deadline
+	// of the slot of the block. The boost lasts until the forkchoice is moved to the next slot with OnTick.
+	// Blocks that are not of the current slot are ignored.
+	OnTimelyBlock(blockRoot Root, slot Slot)
+	// OnTick updates the current slot of the forkchoice, and resets the proposer boost when the slot changes.
+	OnTick(slot Slot)
+	// IsHeadOptimistic checks if the payload of the head, or any of its ancestors, is not verified yet.
+	IsHeadOptimistic() (bool, error)
 }
@@ -0,0 +1,101 @@
+package fctest
+
+import (
+	"encoding/binary"
+
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+)
+
+// ProposerBoostTestDef follows the proposer boost tests of get_head in the consensus-specs:
+// a timely block wins from an equally weighted competing block, but only during its own slot.
+func ProposerBoostTestDef() *ForkChoiceTestDef {
+	spec := configs.Minimal
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	balances := make([]forkchoice.Gwei, 8)
+	for i := range balances {
+		balances[i] = spec.MAX_EFFECTIVE_BALANCE
+	}
+	init := ForkChoiceTestInit{
+		Spec:         spec,
+		Finalized:    forkchoice.Checkpoint{Root: hash(0), Epoch: 0},
+		Justified:    forkchoice.Checkpoint{Root: hash(0), Epoch: 0},
+		AnchorRoot:   hash(0),
+		AnchorSlot:   0,
+		AnchorParent: hash(0),
+		Balances:     balances,
+	}
+	var ops []Operation
+	add := func(op Operation) {
+		ops = append(ops, op)
+	}
+
+	// Tick to slot 4, the slot of block 1
+	add(&OpOnTick{Slot: 4})
+
+	// Block 2 at slot 3 arrives late, and is not boosted.
+	//
+	//          0
+	//          |
+	//          2
+	add(&OpProcessBlock{
+		Parent:    hash(0),
+		BlockRoot: hash(2),
+		BlockSlot: 3,
+	})
+	add(&OpOnTimelyBlock{BlockRoot: hash(2), Slot: 3})
+	add(&OpHead{
+		ExpectedHead: forkchoice.NodeRef{Root: hash(2), Slot: 3},
+		Ok:           true,
+	})
+
+	// Block 1 at slot 4 competes with block 2, and would lose the tie-break on root.
+	//
+	//          0
+	//         / \
+	//        2   1
+	add(&OpProcessBlock{
+		Parent:    hash(0),
+		BlockRoot: hash(1),
+		BlockSlot: 4,
+	})
+	add(&OpHead{
+		ExpectedHead: forkchoice.NodeRef{Root: hash(2), Slot: 3},
+		Ok:           true,
+	})
+
+	// Block 1 arrived in time, the boost makes it the head.
+	add(&OpOnTimelyBlock{BlockRoot: hash(1), Slot: 4})
+	add(&OpHead{
+		ExpectedHead: forkchoice.NodeRef{Root: hash(1), Slot: 4},
+		Ok:           true,
+	})
+
+	// After the slot of block 1, the boost is removed, and the head reverts to block 2.
+	add(&OpOnTick{Slot: 5})
+	add(&OpHead{
+		ExpectedHead: forkchoice.NodeRef{Root: hash(2), Slot: 3},
+		Ok:           true,
+	})
+
+	// A single vote for block 1 outweighs the tie-break again.
+	add(&OpProcessAttestation{
+		ValidatorIndex: 0,
+		BlockRoot:      hash(1),
+		HeadSlot:       4,
+		TargetEpoch:    0,
+		CanAdd:         true,
+	})
+	add(&OpHead{
+		ExpectedHead: forkchoice.NodeRef{Root: hash(1), Slot: 4},
+		Ok:           true,
+	})
+
+	return &ForkChoiceTestDef{
+		Init:       init,
+		Operations: ops,
+	}
+}
@@ -196,17 +196,37 @@ type OpProcessAttestation struct {
 	ValidatorIndex forkchoice.ValidatorIndex
 	BlockRoot      forkchoice.Root
 	HeadSlot       forkchoice.Slot
+	TargetEpoch    forkchoice.Epoch
 	CanAdd         bool
 }
 
 func (op *OpProcessAttestation) Apply(ft *ForkChoiceTestTarget, fc forkchoice.Forkchoice) error {
-	res := fc.ProcessAttestation(op.ValidatorIndex, op.BlockRoot, op.HeadSlot)
+	res := fc.ProcessAttestation(op.ValidatorIndex, op.BlockRoot, op.HeadSlot, op.TargetEpoch)
 	if res != op.CanAdd {
 		return fmt.Errorf("processing attestation different result: canAdd %v <> %v", res, op.CanAdd)
 	}
 	return nil
 }
 
+type OpOnTick struct {
+	Slot forkchoice.Slot
+}
+
+func (op *OpOnTick) Apply(ft *ForkChoiceTestTarget, fc forkchoice.Forkchoice) error {
+	fc.OnTick(op.Slot)
+	return nil
+}
+
+type OpOnTimelyBlock struct {
+	BlockRoot forkchoice.Root
+	Slot      forkchoice.Slot
+}
+
+func (op *OpOnTimelyBlock) Apply(ft *ForkChoiceTestTarget, fc forkchoice.Forkchoice) error {
+	fc.OnTimelyBlock(op.BlockRoot, op.Slot)
+	return nil
+}
+
 type OpPruneable struct {
 	Pruneable forkchoice.NodeRef
 	Canonical bool
@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/protolambda/zrnt/eth2/configs"
 	"github.com/protolambda/zrnt/eth2/forkchoice"
 	"github.com/protolambda/zrnt/eth2/forkchoice/internal/fctest"
 )
 
-func TestProtoArray(t *testing.T) {
-	lhtest := fctest.LighthouseTestDef()
-	err := lhtest.Run(func(init *fctest.ForkChoiceTestInit, ft *fctest.ForkChoiceTestTarget) (forkchoice.Forkchoice, error) {
+// runTestDef runs the forkchoice test definition against a new ProtoForkChoice.
+func runTestDef(t *testing.T, def *fctest.ForkChoiceTestDef) {
+	err := def.Run(func(init *fctest.ForkChoiceTestInit, ft *fctest.ForkChoiceTestTarget) (forkchoice.Forkchoice, error) {
 		return NewProtoForkChoice(init.Spec, init.Finalized, init.Justified, init.AnchorRoot, init.AnchorSlot, init.AnchorParent, init.Balances,
 			NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
 				// whenever something is pruned, check if it was allowed to be pruned,
@@ -30,3 +31,483 @@ func TestProtoArray(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestProtoArray(t *testing.T) {
+	runTestDef(t, fctest.LighthouseTestDef())
+}
+
+func TestProposerBoostDef(t *testing.T) {
+	runTestDef(t, fctest.ProposerBoostTestDef())
+}
+
+// testChain creates a forkchoice with a chain of blocks, one for each slot from 1 to the given slot (inclusive).
+// The roots of the blocks are returned, the genesis root is the first.
+func testChain(t *testing.T, sink NodeSink, slots forkchoice.Slot) (forkchoice.Forkchoice, []forkchoice.Root) {
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	fc, err := NewProtoForkChoice(configs.Minimal, cp, cp, genesis, 0, forkchoice.Root{},
+		[]forkchoice.Gwei{32, 32, 32}, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := []forkchoice.Root{genesis}
+	for slot := forkchoice.Slot(1); slot <= slots; slot++ {
+		root := forkchoice.Root{0x10, byte(slot)}
+		if !fc.ProcessBlock(roots[slot-1], root, slot, 0, 0) {
+			t.Fatalf("failed to add block %d", slot)
+		}
+		roots = append(roots, root)
+	}
+	return fc, roots
+}
+
+func TestUpdateJustified(t *testing.T) {
+	fc, roots := testChain(t, nil, 20)
+	balances := func() ([]forkchoice.Gwei, error) {
+		return []forkchoice.Gwei{32, 32, 32}, nil
+	}
+	// the blocks that include the justification and finalization
+	a, b := forkchoice.Root{0xaa}, forkchoice.Root{0xbb}
+	fc.ProcessBlock(roots[20], a, 21, 1, 0)
+	fc.ProcessBlock(a, b, 22, 2, 1)
+	genesisCp := forkchoice.Checkpoint{Epoch: 0, Root: roots[0]}
+	justified := forkchoice.Checkpoint{Epoch: 1, Root: roots[8]}
+	if err := fc.UpdateJustified(context.Background(), roots[8], justified, genesisCp, balances); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != justified {
+		t.Fatalf("expected justified %s, got %s", justified, fc.Justified())
+	}
+	if fc.Finalized() != genesisCp {
+		t.Fatalf("expected finalized %s, got %s", genesisCp, fc.Finalized())
+	}
+	// finalizing prunes the nodes before the finalized checkpoint, also without a node sink
+	finalized := justified
+	justified = forkchoice.Checkpoint{Epoch: 2, Root: roots[16]}
+	if err := fc.UpdateJustified(context.Background(), roots[16], justified, finalized, balances); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != justified || fc.Finalized() != finalized {
+		t.Fatalf("expected justified %s and finalized %s, got %s and %s",
+			justified, finalized, fc.Justified(), fc.Finalized())
+	}
+	if unknown, _ := fc.InSubtree(roots[0], roots[8]); !unknown {
+		t.Fatal("expected genesis to be pruned")
+	}
+	if unknown, inSubtree := fc.InSubtree(roots[8], b); unknown || !inSubtree {
+		t.Fatalf("expected head block in finalized subtree, got unknown %v, inSubtree %v", unknown, inSubtree)
+	}
+	head, err := fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != b {
+		t.Fatalf("expected head %s, got %s", b, head)
+	}
+}
+
+func TestPruneSink(t *testing.T) {
+	pruned := make(map[forkchoice.NodeRef]bool)
+	fc, roots := testChain(t, NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+		if _, ok := pruned[ref]; ok {
+			return fmt.Errorf("node %s was pruned twice", ref)
+		}
+		pruned[ref] = canonical
+		return nil
+	}), 12)
+	a := forkchoice.Root{0xaa}
+	fc.ProcessBlock(roots[12], a, 13, 1, 1)
+	cp := forkchoice.Checkpoint{Epoch: 1, Root: roots[8]}
+	if err := fc.UpdateJustified(context.Background(), roots[8], cp, cp, func() ([]forkchoice.Gwei, error) {
+		return []forkchoice.Gwei{32, 32, 32}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// every block before the finalized block is pruned once, and is canonical
+	for slot := forkchoice.Slot(0); slot < 8; slot++ {
+		canonical, ok := pruned[forkchoice.NodeRef{Root: roots[slot], Slot: slot}]
+		if !ok {
+			t.Fatalf("expected block %d to be pruned", slot)
+		}
+		if !canonical {
+			t.Fatalf("expected block %d to be pruned as canonical", slot)
+		}
+	}
+	// the pre-block node of the finalized slot may be pruned, the finalized block itself may not.
+	for ref := range pruned {
+		if ref.Slot > 8 || ref.Root == roots[8] {
+			t.Fatalf("unexpected pruning of %s", ref)
+		}
+	}
+	head, err := fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != a {
+		t.Fatalf("expected head %s, got %s", a, head)
+	}
+}
+
+func TestVotesAfterPrune(t *testing.T) {
+	spec := configs.Minimal
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	balances := []forkchoice.Gwei{32, 32, 32}
+	var prunedCount int
+	fc, err := NewProtoForkChoice(spec, cp, cp, genesis, 0, forkchoice.Root{}, balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			prunedCount++
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a chain of blocks through the first epochs, with a fork at the end:
+	// genesis <- 1 <- 2 <- ... <- 20 <- x <- x2
+	//                                ^--- y
+	roots := []forkchoice.Root{genesis}
+	for slot := forkchoice.Slot(1); slot <= 20; slot++ {
+		root := forkchoice.Root{0x10, byte(slot)}
+		if !fc.ProcessBlock(roots[slot-1], root, slot, 0, 0) {
+			t.Fatalf("failed to add block %d", slot)
+		}
+		roots = append(roots, root)
+	}
+	x, y := forkchoice.Root{0xaa}, forkchoice.Root{0xbb}
+	fc.ProcessBlock(roots[20], x, 21, 1, 1)
+	fc.ProcessBlock(roots[20], y, 21, 1, 1)
+	// votes before pruning, for nodes that are about to be pruned
+	fc.ProcessAttestation(0, roots[3], 3, 0)
+	fc.ProcessAttestation(1, roots[5], 5, 0)
+	if _, err := fc.Head(); err != nil {
+		t.Fatal(err)
+	}
+
+	// finalize epoch 1, the nodes before slot 8 are pruned
+	fin := forkchoice.Checkpoint{Epoch: 1, Root: roots[8]}
+	if err := fc.UpdateJustified(context.Background(), roots[8], fin, fin, func() ([]forkchoice.Gwei, error) {
+		return balances, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if prunedCount == 0 {
+		t.Fatal("expected nodes to be pruned")
+	}
+	expectHead := func(root forkchoice.Root) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Root != root {
+			t.Fatalf("expected head %s, got %s", root, head)
+		}
+	}
+	// the votes move from pruned nodes to the fork, the weight must land on the right nodes
+	// (validator 2 has no previous vote)
+	fc.ProcessAttestation(0, y, 21, 2)
+	fc.ProcessAttestation(1, y, 21, 2)
+	fc.ProcessAttestation(2, x, 21, 2)
+	expectHead(y)
+	// votes of the next epoch, for a child of x
+	x2 := forkchoice.Root{0xac}
+	fc.ProcessBlock(x, x2, 25, 1, 1)
+	fc.ProcessAttestation(1, x2, 25, 3)
+	expectHead(x2)
+}
+
+func TestOptimisticSync(t *testing.T) {
+	spec := configs.Minimal
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	balances := []forkchoice.Gwei{32, 32, 32}
+	fc, err := NewProtoForkChoice(spec, cp, cp, genesis, 0, forkchoice.Root{}, balances, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// genesis <- a <- b <- c
+	//            ^--- d
+	a, b, c, d := forkchoice.Root{0x0a}, forkchoice.Root{0x0b}, forkchoice.Root{0x0c}, forkchoice.Root{0x0d}
+	blocks := []struct {
+		parent, root forkchoice.Root
+		slot         forkchoice.Slot
+	}{{genesis, a, 1}, {a, b, 2}, {b, c, 3}, {a, d, 2}}
+	for _, bl := range blocks {
+		// use the beacon root as execution block hash
+		if !fc.ProcessPayloadBlock(bl.parent, bl.root, bl.slot, 0, 0, bl.root, forkchoice.PayloadOptimistic) {
+			t.Fatalf("failed to add block %s", bl.root)
+		}
+	}
+	expectHead := func(root forkchoice.Root, optimistic bool) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Root != root {
+			t.Fatalf("expected head %s, got %s", root, head)
+		}
+		if opt, err := fc.IsHeadOptimistic(); err != nil {
+			t.Fatal(err)
+		} else if opt != optimistic {
+			t.Fatalf("expected head optimistic: %v, got %v", optimistic, opt)
+		}
+	}
+	expectStatus := func(root forkchoice.Root, status forkchoice.PayloadStatus) {
+		t.Helper()
+		if got, ok := fc.PayloadStatus(root); !ok || got != status {
+			t.Fatalf("expected block %s to be %s, got %s", root, status, got)
+		}
+	}
+	fc.ProcessAttestation(0, c, 3, 0)
+	fc.ProcessAttestation(1, c, 3, 0)
+	fc.ProcessAttestation(2, d, 2, 0)
+	expectHead(c, true)
+
+	// c is invalid, and so is b, after the latest valid block a.
+	if err := fc.InvalidatePayload(c, &a); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(a, forkchoice.PayloadValid)
+	expectStatus(b, forkchoice.PayloadInvalid)
+	expectStatus(c, forkchoice.PayloadInvalid)
+	expectStatus(d, forkchoice.PayloadOptimistic)
+	expectHead(d, true)
+
+	// descendants of invalid blocks are invalid, even if reported as valid
+	e := forkchoice.Root{0x0e}
+	if !fc.ProcessPayloadBlock(c, e, 4, 0, 0, e, forkchoice.PayloadValid) {
+		t.Fatal("failed to add block e")
+	}
+	expectStatus(e, forkchoice.PayloadInvalid)
+	if err := fc.ValidatePayload(b); err == nil {
+		t.Fatal("expected invalid block to not be validated")
+	}
+
+	// a valid child of d validates d
+	f := forkchoice.Root{0x0f}
+	if !fc.ProcessPayloadBlock(d, f, 3, 0, 0, f, forkchoice.PayloadValid) {
+		t.Fatal("failed to add block f")
+	}
+	expectStatus(d, forkchoice.PayloadValid)
+	expectHead(f, false)
+
+	// an invalid terminal block (zero latest valid hash) invalidates all optimistic ancestors
+	g, h := forkchoice.Root{0x10}, forkchoice.Root{0x11}
+	fc.ProcessPayloadBlock(f, g, 4, 0, 0, g, forkchoice.PayloadOptimistic)
+	fc.ProcessPayloadBlock(g, h, 5, 0, 0, h, forkchoice.PayloadOptimistic)
+	fc.ProcessSlot(h, 6, 0, 0)
+	expectHead(h, true)
+	if err := fc.InvalidatePayload(h, &forkchoice.Root{}); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(g, forkchoice.PayloadInvalid)
+	expectStatus(f, forkchoice.PayloadValid)
+	expectHead(f, false)
+}
+
+func TestProposerBoost(t *testing.T) {
+	spec := configs.Minimal
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	balances := make([]forkchoice.Gwei, 64)
+	for i := range balances {
+		balances[i] = 32
+	}
+	fc, err := NewProtoForkChoice(spec, cp, cp, genesis, 0, forkchoice.Root{}, balances, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := forkchoice.Root{0x0a}, forkchoice.Root{0x0b}
+	fc.OnTick(1)
+	fc.ProcessBlock(genesis, a, 1, 0, 0)
+	fc.ProcessBlock(genesis, b, 1, 0, 0)
+	// a single vote for a, but the boost of b is worth more than that.
+	fc.ProcessAttestation(0, a, 1, 0)
+	expectHead := func(root forkchoice.Root) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Root != root {
+			t.Fatalf("expected head %s, got %s", root, head)
+		}
+	}
+	expectHead(a)
+	// blocks of other slots cannot be timely
+	fc.OnTimelyBlock(b, 2)
+	expectHead(a)
+	fc.OnTimelyBlock(b, 1)
+	expectHead(b)
+	// the boost does not last into the next slot
+	fc.OnTick(2)
+	expectHead(a)
+}
+
+func TestApplyProposerBoost(t *testing.T) {
+	genesis := forkchoice.Root{0x01}
+	// genesis <- a <- b
+	//         ^--- c
+	a, b, c := forkchoice.Root{0x0a}, forkchoice.Root{0x0b}, forkchoice.Root{0x0c}
+	aRef, bRef, cRef := forkchoice.NodeRef{Root: a, Slot: 1}, forkchoice.NodeRef{Root: b, Slot: 2}, forkchoice.NodeRef{Root: c, Slot: 1}
+	pr := NewProtoArray(forkchoice.Root{}, genesis, 0, 0, 0, nil)
+	pr.ProcessBlock(genesis, a, 1, 0, 0)
+	pr.ProcessBlock(a, b, 2, 0, 0)
+	pr.ProcessBlock(genesis, c, 1, 0, 0)
+	apply := func(boost forkchoice.ProposerBoost) {
+		t.Helper()
+		if err := pr.ApplyScoreChanges(make([]forkchoice.SignedGwei, len(pr.nodes)), 0, 0, boost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectWeight := func(ref forkchoice.NodeRef, weight forkchoice.SignedGwei) {
+		t.Helper()
+		i, ok := pr.indices[ref]
+		if !ok {
+			t.Fatalf("unknown node %s", ref)
+		}
+		if got := pr.nodes[i-pr.indexOffset].Weight; got != weight {
+			t.Fatalf("expected node %s to have weight %d, got %d", ref, weight, got)
+		}
+	}
+	expectHead := func(root forkchoice.Root, anchor forkchoice.NodeRef) {
+		t.Helper()
+		head, err := pr.FindHead(anchor.Root, anchor.Slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head.Root != root {
+			t.Fatalf("expected head %s, got %s", root, head)
+		}
+	}
+	genesisRef := forkchoice.NodeRef{Root: genesis, Slot: 0}
+
+	// the boost applies to the node and its ancestors
+	apply(forkchoice.ProposerBoost{Ref: cRef, Score: 10})
+	expectWeight(cRef, 10)
+	expectWeight(aRef, 0)
+	expectHead(c, genesisRef)
+	// a new boost replaces the previous one
+	apply(forkchoice.ProposerBoost{Ref: bRef, Score: 10})
+	expectWeight(cRef, 0)
+	expectWeight(bRef, 10)
+	expectWeight(aRef, 10)
+	expectHead(b, genesisRef)
+	// without boost, the boost is undone
+	apply(forkchoice.ProposerBoost{})
+	expectWeight(bRef, 0)
+	expectWeight(aRef, 0)
+	expectWeight(genesisRef, 0)
+	// boosts of unknown nodes are ignored
+	apply(forkchoice.ProposerBoost{Ref: forkchoice.NodeRef{Root: forkchoice.Root{0xff}, Slot: 3}, Score: 10})
+	expectWeight(genesisRef, 0)
+
+	// the boosted node is pruned, the remaining nodes are not affected by undoing the boost
+	apply(forkchoice.ProposerBoost{Ref: aRef, Score: 10})
+	expectWeight(aRef, 10)
+	if err := pr.OnPrune(context.Background(), b, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pr.indices[aRef]; ok {
+		t.Fatal("expected boosted node to be pruned")
+	}
+	apply(forkchoice.ProposerBoost{})
+	expectWeight(bRef, 0)
+	expectHead(b, bRef)
+	// the boost still applies after pruning
+	apply(forkchoice.ProposerBoost{Ref: bRef, Score: 10})
+	expectWeight(bRef, 10)
+	apply(forkchoice.ProposerBoost{})
+	expectWeight(bRef, 0)
+}
+
+func TestEquivocation(t *testing.T) {
+	spec := configs.Minimal
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	balances := []forkchoice.Gwei{32, 32, 32}
+	type doubleVote struct {
+		index                 forkchoice.ValidatorIndex
+		previous, conflicting forkchoice.NodeRef
+	}
+	var doubleVotes []doubleVote
+	votes := NewProtoVoteStoreWithHook(spec, func(index forkchoice.ValidatorIndex, targetEpoch forkchoice.Epoch,
+		previous forkchoice.NodeRef, conflicting forkchoice.NodeRef) {
+		doubleVotes = append(doubleVotes, doubleVote{index, previous, conflicting})
+	})
+	fc, err := forkchoice.NewForkChoice(spec, cp, cp, genesis, 0,
+		NewProtoArray(forkchoice.Root{}, genesis, 0, 0, 0, nil), votes, balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := forkchoice.Root{0x0a}, forkchoice.Root{0x0b}
+	fc.ProcessBlock(genesis, a, 1, 0, 0)
+	fc.ProcessBlock(genesis, b, 1, 0, 0)
+	fc.ProcessAttestation(0, a, 1, 0)
+	fc.ProcessAttestation(1, a, 1, 0)
+	fc.ProcessAttestation(2, b, 1, 0)
+	head, err := fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != a {
+		t.Fatalf("expected head a, got %s", head)
+	}
+	// repeated votes are fine, a conflicting vote in the same epoch is reported
+	fc.ProcessAttestation(0, a, 1, 0)
+	fc.ProcessAttestation(0, b, 1, 0)
+	if len(doubleVotes) != 1 || doubleVotes[0] != (doubleVote{0, forkchoice.NodeRef{Root: a, Slot: 1}, forkchoice.NodeRef{Root: b, Slot: 1}}) {
+		t.Fatalf("unexpected double votes: %v", doubleVotes)
+	}
+	// both validators of a equivocate, their weight is removed, and b becomes the head
+	fc.ProcessEquivocation([]forkchoice.ValidatorIndex{0, 1})
+	head, err = fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != b {
+		t.Fatalf("expected head b, got %s", head)
+	}
+	// new votes of equivocating validators are ignored
+	fc.ProcessAttestation(0, a, 1, 0)
+	fc.ProcessAttestation(1, a, 1, 0)
+	head, err = fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Root != b {
+		t.Fatalf("expected head b to remain, got %s", head)
+	}
+}
+
+func TestDoubleVoteTargetEpoch(t *testing.T) {
+	spec := configs.Minimal
+	genesis := forkchoice.Root{0x01}
+	cp := forkchoice.Checkpoint{Epoch: 0, Root: genesis}
+	var reported []forkchoice.Epoch
+	votes := NewProtoVoteStoreWithHook(spec, func(index forkchoice.ValidatorIndex, targetEpoch forkchoice.Epoch,
+		previous forkchoice.NodeRef, conflicting forkchoice.NodeRef) {
+		reported = append(reported, targetEpoch)
+	})
+	fc, err := forkchoice.NewForkChoice(spec, cp, cp, genesis, 0,
+		NewProtoArray(forkchoice.Root{}, genesis, 0, 0, 0, nil), votes, []forkchoice.Gwei{32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The blocks are of different epochs, but the votes are for the same target epoch:
+	// the head of an attestation may be older than the target.
+	a, b := forkchoice.Root{0x0a}, forkchoice.Root{0x0b}
+	fc.ProcessBlock(genesis, a, 7, 0, 0)
+	fc.ProcessBlock(genesis, b, 9, 0, 0)
+	fc.ProcessAttestation(0, a, 7, 1)
+	fc.ProcessAttestation(0, b, 9, 1)
+	if len(reported) != 1 || reported[0] != 1 {
+		t.Fatalf("expected a double vote for target epoch 1, got %v", reported)
+	}
+	// A vote for a later target is not a double vote
+	fc.ProcessAttestation(0, a, 7, 2)
+	if len(reported) != 1 {
+		t.Fatalf("unexpected double votes: %v", reported)
+	}
+}
@@ -28,6 +28,11 @@ type ProtoNode struct {
 	BestChild NodeIndex
 	// Relative to ForkchoiceParent relations
 	BestDescendant NodeIndex
+	// Execution block hash of the payload of the block, or that of the latest block before an empty slot.
+	// Zero if there is no payload.
+	ExecutionBlockHash Root
+	// Execution status of the payload, inherited by empty slots. Descendants of invalid nodes are invalid.
+	PayloadStatus PayloadStatus
 }
 
 type NodeSinkFn func(ctx context.Context, ref NodeRef, canonical bool) error
@@ -56,6 +61,8 @@ type ProtoArray struct {
 	// The lowest slot for a block does not equal the block.slot itself, that may have been pruned.
 	blockSlots         map[Root]Slot
 	updatedConnections bool
+	// The proposer boost that is currently part of the node weights
+	appliedBoost ProposerBoost
 }
 
 var _ ForkchoiceGraph = (*ProtoArray)(nil)
@@ -95,7 +102,7 @@ func (pr *ProtoArray) getNode(index NodeIndex) (*ProtoNode, error) {
 		return nil, invalidIndexErr
 	}
 	i := index - pr.indexOffset
-	if i > NodeIndex(len(pr.nodes)) {
+	if i >= NodeIndex(len(pr.nodes)) {
 		return nil, invalidIndexErr
 	}
 	return &pr.nodes[i], nil
@@ -105,6 +112,10 @@ func (pr *ProtoArray) Indices() map[NodeRef]NodeIndex {
 	return pr.indices
 }
 
+func (pr *ProtoArray) IndexOffset() NodeIndex {
+	return pr.indexOffset
+}
+
 // From head back to anchor root (including the anchor itself, if present) and anchor slot.
 // Includes nodes with empty block, then followed up by a node with the block if there is any.
 func (pr *ProtoArray) CanonicalChain(anchorRoot Root, anchorSlot Slot) ([]ExtendedNodeRef, error) {
@@ -183,7 +194,10 @@ func (pr *ProtoArray) CanonAtSlot(anchor Root, slot Slot, withBlock bool) (at No
 			if !ok {
 				panic("anchor node is missing")
 			}
-			node := &pr.nodes[i]
+			node, err := pr.getNode(i)
+			if err != nil {
+				return NodeRef{}, err
+			}
 			// Is the anchor a filled node?
 			if node.ParentRoot != anchor {
 				return NodeRef{}, fmt.Errorf("cannot look for pre-block %d at anchor, anchor is post-block", slot)
@@ -253,7 +267,10 @@ func (pr *ProtoArray) Search(anchor NodeRef, parentRoot *Root, slot *Slot) (nonC
 			// if it has no child, it's a head.
 			if node.BestChild != NONE {
 				// if it has only empty slots as children, it's a head.
-				desc := &pr.nodes[node.BestDescendant]
+				desc, err := pr.getNode(node.BestDescendant)
+				if err != nil {
+					return nil, nil, err
+				}
 				if desc.Ref.Root != node.Ref.Root {
 					continue
 				}
@@ -296,10 +313,25 @@ var lengthMismatchErr = errors.New("length mismatch")
 // - Compare the current node with the parents best-child, updating it if the current node
 // should become the best child.
 // - If required, update the parents best-descendant with the current node or its best-descendant.
-func (pr *ProtoArray) ApplyScoreChanges(deltas []SignedGwei, justifiedEpoch Epoch, finalizedEpoch Epoch) error {
+//
+// The previously applied proposer boost is removed, and the new boost (if any) is added, as part of the deltas.
+func (pr *ProtoArray) ApplyScoreChanges(deltas []SignedGwei, justifiedEpoch Epoch, finalizedEpoch Epoch, boost ProposerBoost) error {
 	if len(deltas) != len(pr.nodes) {
 		return lengthMismatchErr
 	}
+	if pr.appliedBoost.Score != 0 {
+		// The boosted node may have been pruned, then there is nothing to undo.
+		if i, ok := pr.indices[pr.appliedBoost.Ref]; ok {
+			deltas[i-pr.indexOffset] -= SignedGwei(pr.appliedBoost.Score)
+		}
+	}
+	pr.appliedBoost = ProposerBoost{}
+	if boost.Score != 0 {
+		if i, ok := pr.indices[boost.Ref]; ok {
+			deltas[i-pr.indexOffset] += SignedGwei(boost.Score)
+			pr.appliedBoost = boost
+		}
+	}
 	if justifiedEpoch != pr.justifiedEpoch || finalizedEpoch != pr.finalizedEpoch {
 		pr.justifiedEpoch = justifiedEpoch
 		pr.finalizedEpoch = finalizedEpoch
@@ -349,9 +381,15 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 		return
 	}
 	parentIndex := NONE
+	parentStatus := PayloadValid
+	var parentHash Root
 	parentSlot, ok := pr.blockSlots[parent]
 	if ok {
 		parentIndex = pr.indices[NodeRef{Root: parent, Slot: parentSlot}]
+		if parentNode, err := pr.getNode(parentIndex); err == nil {
+			parentStatus = parentNode.PayloadStatus
+			parentHash = parentNode.ExecutionBlockHash
+		}
 		for i := parentSlot + 1; i < slot; i++ {
 			nodeRef := NodeRef{Root: parent, Slot: i}
 			// remember the last node before (up to and including same slot)
@@ -365,15 +403,17 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 			nodeIndex = pr.indexOffset + NodeIndex(len(pr.nodes))
 			pr.indices[nodeRef] = nodeIndex
 			pr.nodes = append(pr.nodes, ProtoNode{
-				Ref:              nodeRef,
-				TransitionParent: parentIndex,
-				ForkchoiceParent: parentIndex,
-				ParentRoot:       parent,
-				JustifiedEpoch:   justifiedEpoch,
-				FinalizedEpoch:   finalizedEpoch,
-				Weight:           0,
-				BestChild:        NONE,
-				BestDescendant:   NONE,
+				Ref:                nodeRef,
+				TransitionParent:   parentIndex,
+				ForkchoiceParent:   parentIndex,
+				ParentRoot:         parent,
+				JustifiedEpoch:     justifiedEpoch,
+				FinalizedEpoch:     finalizedEpoch,
+				Weight:             0,
+				BestChild:          NONE,
+				BestDescendant:     NONE,
+				ExecutionBlockHash: parentHash,
+				PayloadStatus:      parentStatus,
 			})
 			// remember the node as parent for the next
 			parentIndex = nodeIndex
@@ -383,15 +423,17 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 	nodeIndex := pr.indexOffset + NodeIndex(len(pr.nodes))
 	pr.indices[nodeRef] = nodeIndex
 	pr.nodes = append(pr.nodes, ProtoNode{
-		Ref:              nodeRef,
-		TransitionParent: parentIndex,
-		ForkchoiceParent: parentIndex,
-		ParentRoot:       parent,
-		JustifiedEpoch:   justifiedEpoch,
-		FinalizedEpoch:   finalizedEpoch,
-		Weight:           0,
-		BestChild:        NONE,
-		BestDescendant:   NONE,
+		Ref:                nodeRef,
+		TransitionParent:   parentIndex,
+		ForkchoiceParent:   parentIndex,
+		ParentRoot:         parent,
+		JustifiedEpoch:     justifiedEpoch,
+		FinalizedEpoch:     finalizedEpoch,
+		Weight:             0,
+		BestChild:          NONE,
+		BestDescendant:     NONE,
+		ExecutionBlockHash: parentHash,
+		PayloadStatus:      parentStatus,
 	})
 	// Connections are out of sync, i.e. array needs work before next find-head can return the proper head.
 	pr.updatedConnections = false
@@ -401,7 +443,17 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 // If justified or finalized in-between, make sure to call OnSlot with accurate details first.
 //
 // The parent root of the genesis block should be zeroed.
+// The block is registered as valid, see ProcessPayloadBlock to register blocks with unverified payloads.
 func (pr *ProtoArray) ProcessBlock(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch) (ok bool) {
+	return pr.ProcessPayloadBlock(parent, blockRoot, blockSlot, justifiedEpoch, finalizedEpoch, Root{}, PayloadValid)
+}
+
+// Register a block with an execution payload with the fork choice, like ProcessBlock.
+// The block hash is the execution block hash of the payload, and the status is the result of the payload execution.
+// If the status is valid, the ancestors of the block are marked as valid too.
+// If the parent is invalid, the block is registered as invalid, regardless of the given status.
+func (pr *ProtoArray) ProcessPayloadBlock(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch,
+	blockHash Root, status PayloadStatus) (ok bool) {
 	blockRef := NodeRef{Root: blockRoot, Slot: blockSlot}
 	// If the block is already known, simply ignore it.
 	if _, ok := pr.indices[blockRef]; ok {
@@ -433,21 +485,159 @@ func (pr *ProtoArray) ProcessBlock(parent Root, blockRoot Root, blockSlot Slot,
 	pr.blockSlots[blockRoot] = blockSlot
 	pr.indices[blockRef] = nodeIndex
 	pr.nodes = append(pr.nodes, ProtoNode{
-		Ref:              blockRef,
-		TransitionParent: transitionParentIndex,
-		ForkchoiceParent: forkchoiceParentIndex,
-		ParentRoot:       parent,
-		JustifiedEpoch:   justifiedEpoch,
-		FinalizedEpoch:   finalizedEpoch,
-		Weight:           0,
-		BestChild:        NONE,
-		BestDescendant:   NONE,
+		Ref:                blockRef,
+		TransitionParent:   transitionParentIndex,
+		ForkchoiceParent:   forkchoiceParentIndex,
+		ParentRoot:         parent,
+		JustifiedEpoch:     justifiedEpoch,
+		FinalizedEpoch:     finalizedEpoch,
+		Weight:             0,
+		BestChild:          NONE,
+		BestDescendant:     NONE,
+		ExecutionBlockHash: blockHash,
+		PayloadStatus:      status,
 	})
 	// Connections are out of sync, i.e. array needs work before next find-head can return the proper head.
 	pr.updatedConnections = false
+	if parentNode, err := pr.getNode(forkchoiceParentIndex); err == nil && parentNode.PayloadStatus == PayloadInvalid {
+		pr.nodes[nodeIndex-pr.indexOffset].PayloadStatus = PayloadInvalid
+	} else if status == PayloadValid {
+		pr.validate(nodeIndex)
+	}
 	return true
 }
 
+// PayloadStatus returns the payload status of the node of the block (or the first node after it, if pruned).
+func (pr *ProtoArray) PayloadStatus(blockRoot Root) (status PayloadStatus, ok bool) {
+	node, err := pr.blockNode(blockRoot)
+	if err != nil {
+		return 0, false
+	}
+	return node.PayloadStatus, true
+}
+
+func (pr *ProtoArray) blockIndex(blockRoot Root) (NodeIndex, error) {
+	slot, ok := pr.blockSlots[blockRoot]
+	if !ok {
+		return NONE, fmt.Errorf("unknown block %s", blockRoot)
+	}
+	index, ok := pr.indices[NodeRef{Root: blockRoot, Slot: slot}]
+	if !ok {
+		return NONE, fmt.Errorf("block %s has no node at slot %d", blockRoot, slot)
+	}
+	return index, nil
+}
+
+func (pr *ProtoArray) blockNode(blockRoot Root) (*ProtoNode, error) {
+	index, err := pr.blockIndex(blockRoot)
+	if err != nil {
+		return nil, err
+	}
+	return pr.getNode(index)
+}
+
+// ValidatePayload marks the block as valid, as well as all its ancestors and the empty slots that follow it.
+func (pr *ProtoArray) ValidatePayload(blockRoot Root) error {
+	index, err := pr.blockIndex(blockRoot)
+	if err != nil {
+		return err
+	}
+	node, err := pr.getNode(index)
+	if err != nil {
+		return err
+	}
+	if node.PayloadStatus == PayloadInvalid {
+		return fmt.Errorf("cannot validate block %s, payload is invalid", blockRoot)
+	}
+	pr.validate(index)
+	return nil
+}
+
+func (pr *ProtoArray) validate(index NodeIndex) {
+	node := &pr.nodes[index-pr.indexOffset]
+	root := node.Ref.Root
+	// Ancestors of a valid node are valid. Stop at the first valid ancestor, its ancestors are valid already.
+	for i := node.TransitionParent; i != NONE && i >= pr.indexOffset; {
+		anc := &pr.nodes[i-pr.indexOffset]
+		if anc.PayloadStatus == PayloadValid {
+			break
+		}
+		anc.PayloadStatus = PayloadValid
+		i = anc.TransitionParent
+	}
+	node.PayloadStatus = PayloadValid
+	// The empty slots after the block inherit the status
+	for i := int(index-pr.indexOffset) + 1; i < len(pr.nodes); i++ {
+		n := &pr.nodes[i]
+		if n.Ref.Root == root && n.PayloadStatus == PayloadOptimistic {
+			n.PayloadStatus = PayloadValid
+		}
+	}
+}
+
+// InvalidatePayload marks the block, and all its descendants, as invalid.
+// If the latest valid hash is not nil, the optimistic ancestors after the block with this execution block hash
+// are invalidated too, and the block with the latest valid hash is validated.
+// The latest valid hash is ignored if it is not found in the ancestors,
+// unless it is zero: then all optimistic ancestors are invalidated, e.g. in case of an invalid terminal PoW block.
+func (pr *ProtoArray) InvalidatePayload(blockRoot Root, latestValidHash *Root) error {
+	index, err := pr.blockIndex(blockRoot)
+	if err != nil {
+		return err
+	}
+	node, err := pr.getNode(index)
+	if err != nil {
+		return err
+	}
+	if node.PayloadStatus == PayloadValid {
+		return fmt.Errorf("cannot invalidate block %s, payload is valid", blockRoot)
+	}
+	invalid := []NodeIndex{index}
+	if latestValidHash != nil {
+		// Walk back the optimistic block ancestors, until we find the latest valid block
+		var ancestors []NodeIndex
+		found := false
+		for i := node.ForkchoiceParent; i != NONE && i >= pr.indexOffset; {
+			anc := &pr.nodes[i-pr.indexOffset]
+			if anc.ExecutionBlockHash == *latestValidHash {
+				if *latestValidHash != (Root{}) {
+					pr.validate(i)
+				}
+				found = true
+				break
+			}
+			if anc.PayloadStatus == PayloadValid {
+				break
+			}
+			ancestors = append(ancestors, i)
+			i = anc.ForkchoiceParent
+		}
+		if found || *latestValidHash == (Root{}) {
+			invalid = append(invalid, ancestors...)
+		}
+	}
+	lowest := index
+	for _, i := range invalid {
+		pr.nodes[i-pr.indexOffset].PayloadStatus = PayloadInvalid
+		if i < lowest {
+			lowest = i
+		}
+	}
+	// Children are always inserted after their parents, so one pass invalidates all descendants.
+	isInvalid := func(i NodeIndex) bool {
+		return i != NONE && i >= pr.indexOffset && pr.nodes[i-pr.indexOffset].PayloadStatus == PayloadInvalid
+	}
+	for i := int(lowest-pr.indexOffset) + 1; i < len(pr.nodes); i++ {
+		n := &pr.nodes[i]
+		if isInvalid(n.TransitionParent) || isInvalid(n.ForkchoiceParent) {
+			n.PayloadStatus = PayloadInvalid
+		}
+	}
+	// best-child and best-descendant links need to be updated to exclude the invalid nodes
+	pr.updatedConnections = false
+	return nil
+}
+
 var UnknownAnchorErr = errors.New("anchor unknown")
 var NoViableHeadErr = errors.New("not a viable head anymore, invalid forkchoice state")
 
@@ -551,7 +741,10 @@ func (pr *ProtoArray) inSubtree(anchorIndex NodeIndex, lookupIndex NodeIndex) (u
 	}
 	// Root may still be on a different non-canonical branch out of the anchor.
 	for i := lookupNode.TransitionParent; i != NONE && i >= anchorIndex; {
-		tmp := &pr.nodes[i]
+		tmp, err := pr.getNode(i)
+		if err != nil {
+			return true, false
+		}
 		// early exit: as soon as we find a node that has the same relative head as the anchor,
 		// we know we are in-between the anchor and the head, thus in the subtree, thus an ancestor.
 		if tmp.BestDescendant == anchorNode.BestDescendant {
@@ -594,35 +787,44 @@ func (pr *ProtoArray) OnPrune(ctx context.Context, anchorRoot Root, anchorSlot S
 		return HeadUnknownErr
 	}
 	// Remove the `self.indices` and `self.blockSlots` key/values for all the to-be-deleted nodes.
-	j := 0
-	var pruned []prunedNode
+	pruned := make([]prunedNode, 0, anchorIndex-pr.indexOffset)
 	for i := pr.indexOffset; i < anchorIndex; i++ {
-		node := &pr.nodes[j]
-		if pr.sink != nil {
-			canonical := node.BestDescendant == headIndex
-			pruned = append(pruned, prunedNode{canonical, node})
-		}
+		node := &pr.nodes[i-pr.indexOffset]
+		canonical := node.BestDescendant == headIndex
+		pruned = append(pruned, prunedNode{canonical, node})
 	}
-	// Send pruned nodes to the node sink (empty if no sink). Continue until it fails.
+	// Send pruned nodes to the node sink (if any). Continue until it fails.
 	// Only prune what we successfully sent to the sink.
 	prunedUpTo := 0
 	for _, p := range pruned {
-		if err = pr.sink.OnPrunedNode(ctx, p.node.Ref, p.canonical); err != nil {
-			break
+		if pr.sink != nil {
+			if err = pr.sink.OnPrunedNode(ctx, p.node.Ref, p.canonical); err != nil {
+				break
+			}
 		}
 		prunedUpTo++
 	}
-	// adjust the slot we know for the anchor root, everything before it was pruned.
-	pr.blockSlots[anchorRoot] = anchorSlot
 	for _, p := range pruned[:prunedUpTo] {
 		delete(pr.indices, p.node.Ref)
 		// Remove the block-slots ref
 		delete(pr.blockSlots, p.node.Ref.Root)
-		// TODO: is this slicing bad for GC?
-		pr.nodes = pr.nodes[1:]
-		// update offset
-		pr.indexOffset++
 	}
+	// TODO: is this slicing bad for GC?
+	pr.nodes = pr.nodes[prunedUpTo:]
+	// update offset
+	pr.indexOffset += NodeIndex(prunedUpTo)
+	// Remaining nodes may still reference pruned parents, detach them.
+	for i := range pr.nodes {
+		node := &pr.nodes[i]
+		if node.TransitionParent != NONE && node.TransitionParent < pr.indexOffset {
+			node.TransitionParent = NONE
+		}
+		if node.ForkchoiceParent != NONE && node.ForkchoiceParent < pr.indexOffset {
+			node.ForkchoiceParent = NONE
+		}
+	}
+	// adjust the slot we know for the anchor root, everything before it was pruned.
+	pr.blockSlots[anchorRoot] = anchorSlot
 	return err
 }
 
@@ -734,7 +936,9 @@ func (pr *ProtoArray) nodeLeadsToViableHead(node *ProtoNode) (bool, error) {
 //https://github.com/ethereum/eth2.0-specs/blob/v0.11.1/specs/phase0/fork-choice.md#filter_block_tree
 //
 //Any node that has a different finalized or justified epoch should not be viable for the head.
+//Nodes with an invalid execution payload, or an invalid ancestor, are not viable either.
 func (pr *ProtoArray) isNodeViableForHead(node *ProtoNode) bool {
-	return (node.JustifiedEpoch == pr.justifiedEpoch || pr.justifiedEpoch == common.GENESIS_EPOCH) &&
+	return node.PayloadStatus != PayloadInvalid &&
+		(node.JustifiedEpoch == pr.justifiedEpoch || pr.justifiedEpoch == common.GENESIS_EPOCH) &&
 		(node.FinalizedEpoch == pr.finalizedEpoch || pr.finalizedEpoch == common.GENESIS_EPOCH)
 }
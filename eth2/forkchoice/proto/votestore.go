@@ -12,20 +12,45 @@ type VoteTracker struct {
 	NextTargetEpoch    Epoch
 }
 
+// DoubleVoteFn is called when a validator votes for two different nodes with the same target epoch.
+// The votes are slashable, but the report is only a hint: the vote store does not keep the attestations,
+// and the caller has to find the conflicting attestations of the validator for the target epoch elsewhere
+// (e.g. in the attestation pool) to build an attester slashing.
+type DoubleVoteFn func(index ValidatorIndex, targetEpoch Epoch, previous NodeRef, conflicting NodeRef)
+
 type ProtoVoteStore struct {
 	spec    *common.Spec
 	votes   []VoteTracker
 	changed bool
+	// Validators that are known to equivocate. Their votes are discarded.
+	equivocating map[ValidatorIndex]struct{}
+	// optional, nil if double votes are not reported
+	onDoubleVote DoubleVoteFn
 }
 
 var _ VoteStore = (*ProtoVoteStore)(nil)
 
 func NewProtoVoteStore(spec *common.Spec) VoteStore {
-	return &ProtoVoteStore{spec: spec, changed: true}
+	return NewProtoVoteStoreWithHook(spec, nil)
+}
+
+// NewProtoVoteStoreWithHook creates a vote store that reports the double votes it observes to the given hook.
+// The hook is called synchronously while processing the attestation, and must not call back into the vote store.
+func NewProtoVoteStoreWithHook(spec *common.Spec, onDoubleVote DoubleVoteFn) VoteStore {
+	return &ProtoVoteStore{
+		spec:         spec,
+		changed:      true,
+		equivocating: make(map[ValidatorIndex]struct{}),
+		onDoubleVote: onDoubleVote,
+	}
 }
 
 // Process an attestation. (Note that the head slot may be for a gap slot after the block root)
-func (st *ProtoVoteStore) ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot) (ok bool) {
+func (st *ProtoVoteStore) ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot, targetEpoch Epoch) (ok bool) {
+	if _, ok := st.equivocating[index]; ok {
+		// Valid, but ignored: the validator is not trusted to vote anymore.
+		return true
+	}
 	if index >= ValidatorIndex(len(st.votes)) {
 		if index < ValidatorIndex(cap(st.votes)) {
 			st.votes = st.votes[:index+1]
@@ -35,28 +60,72 @@ func (st *ProtoVoteStore) ProcessAttestation(index ValidatorIndex, blockRoot Roo
 		}
 	}
 	vote := &st.votes[index]
-	targetEpoch := st.spec.SlotToEpoch(headSlot)
+	vote.checkDoubleVote(st.onDoubleVote, index, targetEpoch, NodeRef{Root: blockRoot, Slot: headSlot})
 	// only update if it's a newer vote, or if it's genesis and no vote has happened yet.
 	if targetEpoch > vote.NextTargetEpoch || (targetEpoch == 0 && *vote == (VoteTracker{})) {
 		vote.NextTargetEpoch = targetEpoch
 		vote.Next = NodeRef{Root: blockRoot, Slot: headSlot}
 		st.changed = true
 	}
-	// TODO: maybe help detect slashable votes on the fly?
 	return true
 }
 
+func (vote *VoteTracker) checkDoubleVote(onDoubleVote DoubleVoteFn, index ValidatorIndex, targetEpoch Epoch, ref NodeRef) {
+	if onDoubleVote == nil {
+		return
+	}
+	// Only the latest vote is tracked, and it may not be applied yet.
+	// The zero vote is the genesis default, not a real vote.
+	if vote.Next != (NodeRef{}) && vote.NextTargetEpoch == targetEpoch && vote.Next != ref {
+		onDoubleVote(index, targetEpoch, vote.Next, ref)
+	}
+}
+
+// ProcessEquivocation discards the votes of the validators: the current vote weight is removed with the next
+// ComputeDeltas, and future attestations of the validators are ignored.
+func (st *ProtoVoteStore) ProcessEquivocation(indices []ValidatorIndex) {
+	for _, i := range indices {
+		if _, ok := st.equivocating[i]; ok {
+			continue
+		}
+		st.equivocating[i] = struct{}{}
+		st.changed = true
+	}
+}
+
 func (st *ProtoVoteStore) HasChanges() bool {
 	return st.changed
 }
 
 // Returns a list of `deltas`, where there is one delta for each of the ProtoArray nodes.
+// The node indices are absolute, the deltas are indexed relative to the indexOffset, the index of the oldest node.
 // The deltas are calculated between `oldBalances` and `newBalances`, and/or a change of vote.
 // The votestore is updated, the next deltas will be 0 if ProcessAttestation is not changing any vote.
-func (st *ProtoVoteStore) ComputeDeltas(indices map[NodeRef]NodeIndex, oldBalances []Gwei, newBalances []Gwei) []SignedGwei {
+func (st *ProtoVoteStore) ComputeDeltas(indices map[NodeRef]NodeIndex, indexOffset NodeIndex, oldBalances []Gwei, newBalances []Gwei) []SignedGwei {
 	deltas := make([]SignedGwei, len(indices), len(indices))
+	// Votes for nodes that are not in `indices` are ignored:
+	// the node was pruned (pre-finalization, not interesting anymore), or is not known yet.
+	deltaIndex := func(ref NodeRef) (int, bool) {
+		index, ok := indices[ref]
+		if !ok || index < indexOffset || index-indexOffset >= NodeIndex(len(deltas)) {
+			return 0, false
+		}
+		return int(index - indexOffset), true
+	}
 	for i := 0; i < len(st.votes); i++ {
 		vote := &st.votes[i]
+		if _, ok := st.equivocating[ValidatorIndex(i)]; ok {
+			// Remove the weight of the applied vote, and forget about the votes, so they are never applied again.
+			if currentIndex, ok := deltaIndex(vote.Current); ok && vote.Current != (NodeRef{}) {
+				oldBal := Gwei(0)
+				if i < len(oldBalances) {
+					oldBal = oldBalances[i]
+				}
+				deltas[currentIndex] -= SignedGwei(oldBal)
+			}
+			*vote = VoteTracker{}
+			continue
+		}
 		// There is no need to create a score change if the validator has never voted (may not be active)
 		// or both their votes are for the zero checkpoint (alias to the genesis block).
 		if vote.Current == (NodeRef{}) && vote.Next == (NodeRef{}) {
@@ -74,15 +143,19 @@ func (st *ProtoVoteStore) ComputeDeltas(indices map[NodeRef]NodeIndex, oldBalanc
 		}
 
 		if vote.Current == (NodeRef{}) || vote.CurrentTargetEpoch < vote.NextTargetEpoch || oldBal != newBal {
-			// Ignore the current or next vote if it is not known in `indices`.
-			// We assume that it is outside of our tree (i.e., pre-finalization) and therefore not interesting.
-			if currentIndex, ok := indices[vote.Current]; ok {
-				deltas[currentIndex] -= SignedGwei(oldBal)
-			}
-			if nextIndex, ok := indices[vote.Next]; ok {
+			currentIndex, currentOk := deltaIndex(vote.Current)
+			if nextIndex, ok := deltaIndex(vote.Next); ok {
+				// Move the weight from the current vote to the next vote.
+				if currentOk {
+					deltas[currentIndex] -= SignedGwei(oldBal)
+				}
 				deltas[nextIndex] += SignedGwei(newBal)
 				vote.Current = vote.Next
 				vote.CurrentTargetEpoch = vote.NextTargetEpoch
+			} else if currentOk {
+				// The next vote cannot be applied (yet), the weight stays with the current vote,
+				// but follows the balance change.
+				deltas[currentIndex] += SignedGwei(newBal) - SignedGwei(oldBal)
 			}
 		}
 	}
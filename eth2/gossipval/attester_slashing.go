@@ -78,10 +78,10 @@ func ValidateAttesterSlashing(ctx context.Context, attSl *phase0.AttesterSlashin
 
 	// [REJECT] All of the conditions within process_attester_slashing pass validation.
 	// Part 3: signature checks
-	if err := phase0.ValidateIndexedAttestation(spec, epc, state, sa1); err != nil {
+	if err := phase0.ValidateIndexedAttestation(ctx, spec, epc, state, sa1); err != nil {
 		return GossipValidatorResult{REJECT, fmt.Errorf("attester slashing att 1 signature is invalid: %v", err)}
 	}
-	if err := phase0.ValidateIndexedAttestation(spec, epc, state, sa2); err != nil {
+	if err := phase0.ValidateIndexedAttestation(ctx, spec, epc, state, sa2); err != nil {
 		return GossipValidatorResult{REJECT, fmt.Errorf("attester slashing att 2 signature is invalid: %v", err)}
 	}
 	attSlVal.MarkAttesterSlashings(slashable)
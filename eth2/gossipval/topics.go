@@ -0,0 +1,306 @@
+package gossipval
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/codec"
+)
+
+// Names of the global gossip topics
+const (
+	BeaconBlockTopic                       = "beacon_block"
+	BeaconAggregateAndProofTopic           = "beacon_aggregate_and_proof"
+	VoluntaryExitTopic                     = "voluntary_exit"
+	ProposerSlashingTopic                  = "proposer_slashing"
+	AttesterSlashingTopic                  = "attester_slashing"
+	SyncCommitteeContributionAndProofTopic = "sync_committee_contribution_and_proof"
+	BLSToExecutionChangeTopic              = "bls_to_execution_change"
+)
+
+// Name prefixes of the subnet topics, the subnet ID is appended to the prefix
+const (
+	AttestationSubnetTopicPrefix   = "beacon_attestation_"
+	SyncCommitteeSubnetTopicPrefix = "sync_committee_"
+)
+
+// GOSSIP_MAX_SIZE is the maximum allowed size of uncompressed gossip messages, up to Bellatrix.
+const GOSSIP_MAX_SIZE = 1 << 20
+
+// GOSSIP_MAX_SIZE_BELLATRIX is the maximum allowed size of uncompressed gossip messages, starting with Bellatrix.
+const GOSSIP_MAX_SIZE_BELLATRIX = 10 * (1 << 20)
+
+// Domains of the message-id, to separate messages with valid and invalid snappy compression.
+var (
+	MESSAGE_DOMAIN_INVALID_SNAPPY = [4]byte{0x00, 0x00, 0x00, 0x00}
+	MESSAGE_DOMAIN_VALID_SNAPPY   = [4]byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// Topic builds the full topic string of the topic name, for the fork digest.
+func Topic(digest common.ForkDigest, name string) string {
+	return "/eth2/" + hex.EncodeToString(digest[:]) + "/" + name + "/ssz_snappy"
+}
+
+// AttestationSubnetTopic builds the full topic string of the attestation subnet.
+func AttestationSubnetTopic(digest common.ForkDigest, subnet uint64) string {
+	return Topic(digest, AttestationSubnetTopicPrefix+strconv.FormatUint(subnet, 10))
+}
+
+// SyncCommitteeSubnetTopic builds the full topic string of the sync committee subnet.
+func SyncCommitteeSubnetTopic(digest common.ForkDigest, subnet uint64) string {
+	return Topic(digest, SyncCommitteeSubnetTopicPrefix+strconv.FormatUint(subnet, 10))
+}
+
+// ParseTopic splits the full topic string into the fork digest and the topic name.
+func ParseTopic(topic string) (digest common.ForkDigest, name string, err error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "eth2" || parts[4] != "ssz_snappy" {
+		return common.ForkDigest{}, "", fmt.Errorf("invalid topic %q", topic)
+	}
+	if len(parts[2]) != 8 {
+		return common.ForkDigest{}, "", fmt.Errorf("invalid fork digest in topic %q", topic)
+	}
+	if _, err := hex.Decode(digest[:], []byte(parts[2])); err != nil {
+		return common.ForkDigest{}, "", fmt.Errorf("invalid fork digest in topic %q: %v", topic, err)
+	}
+	return digest, parts[3], nil
+}
+
+// ParseSubnet parses the subnet ID of the topic name, if the name has the given subnet topic prefix.
+// The subnet ID is not checked against the subnet count.
+func ParseSubnet(name string, prefix string) (subnet uint64, ok bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+	v := name[len(prefix):]
+	// no leading zeroes or signs, the subnet ID must be formatted canonically
+	if v == "" || (len(v) > 1 && v[0] == '0') {
+		return 0, false
+	}
+	subnet, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return subnet, true
+}
+
+// Fork indices, in order of activation, to check if a fork is active with a simple comparison.
+const (
+	unknownFork   = -1
+	phase0Fork    = 0
+	altairFork    = 1
+	bellatrixFork = 2
+	capellaFork   = 3
+)
+
+// forkIndex returns the fork index of the digest, or unknownFork.
+func forkIndex(d *beacon.ForkDecoder, digest common.ForkDigest) int {
+	switch digest {
+	case d.Genesis:
+		return phase0Fork
+	case d.Altair:
+		return altairFork
+	case d.Bellatrix:
+		return bellatrixFork
+	case d.Capella:
+		return capellaFork
+	default:
+		return unknownFork
+	}
+}
+
+// maxGossipSize returns the max uncompressed size of gossip messages of the fork.
+func maxGossipSize(fork int) uint64 {
+	if fork >= bellatrixFork {
+		return GOSSIP_MAX_SIZE_BELLATRIX
+	}
+	return GOSSIP_MAX_SIZE
+}
+
+// decodeSnappy decompresses the data, if the compression is valid and the decompressed size is within maxSize.
+// The size is checked before decompressing, so the allocation is bounded.
+func decodeSnappy(data []byte, maxSize uint64) ([]byte, error) {
+	size, err := snappy.DecodedLen(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy compression: %v", err)
+	}
+	if uint64(size) > maxSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds max size %d", size, maxSize)
+	}
+	dec, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snappy compression: %v", err)
+	}
+	return dec, nil
+}
+
+// Topics lists all topics of the fork of the digest, including every attestation and sync committee subnet.
+func Topics(d *beacon.ForkDecoder, digest common.ForkDigest) ([]string, error) {
+	fork := forkIndex(d, digest)
+	if fork == unknownFork {
+		return nil, fmt.Errorf("unrecognized fork digest: %s", digest)
+	}
+	names := []string{
+		BeaconBlockTopic,
+		BeaconAggregateAndProofTopic,
+		VoluntaryExitTopic,
+		ProposerSlashingTopic,
+		AttesterSlashingTopic,
+	}
+	if fork >= altairFork {
+		names = append(names, SyncCommitteeContributionAndProofTopic)
+	}
+	if fork >= capellaFork {
+		names = append(names, BLSToExecutionChangeTopic)
+	}
+	out := make([]string, 0, len(names)+common.ATTESTATION_SUBNET_COUNT+common.SYNC_COMMITTEE_SUBNET_COUNT)
+	for _, name := range names {
+		out = append(out, Topic(digest, name))
+	}
+	for subnet := uint64(0); subnet < common.ATTESTATION_SUBNET_COUNT; subnet++ {
+		out = append(out, AttestationSubnetTopic(digest, subnet))
+	}
+	if fork >= altairFork {
+		for subnet := uint64(0); subnet < common.SYNC_COMMITTEE_SUBNET_COUNT; subnet++ {
+			out = append(out, SyncCommitteeSubnetTopic(digest, subnet))
+		}
+	}
+	return out, nil
+}
+
+// MessageIDPhase0 computes the phase0 message-id of the snappy-compressed message data:
+// the first 20 bytes of SHA256(domain + data), of the decompressed data if the compression is valid.
+// Data that decompresses to more than GOSSIP_MAX_SIZE bytes is hashed as invalid, without decompressing it.
+func MessageIDPhase0(data []byte) (out [20]byte) {
+	h := sha256.New()
+	if dec, err := decodeSnappy(data, GOSSIP_MAX_SIZE); err == nil {
+		h.Write(MESSAGE_DOMAIN_VALID_SNAPPY[:])
+		h.Write(dec)
+	} else {
+		h.Write(MESSAGE_DOMAIN_INVALID_SNAPPY[:])
+		h.Write(data)
+	}
+	copy(out[:], h.Sum(nil))
+	return
+}
+
+// MessageIDAltair computes the Altair message-id of the snappy-compressed message data on the topic:
+// the first 20 bytes of SHA256(domain + uint64 topic length + topic + data),
+// with the decompressed data if the compression is valid.
+// Data that decompresses to more than GOSSIP_MAX_SIZE_BELLATRIX bytes is hashed as invalid, without decompressing it.
+func MessageIDAltair(topic string, data []byte) (out [20]byte) {
+	h := sha256.New()
+	dec, err := decodeSnappy(data, GOSSIP_MAX_SIZE_BELLATRIX)
+	if err == nil {
+		h.Write(MESSAGE_DOMAIN_VALID_SNAPPY[:])
+	} else {
+		h.Write(MESSAGE_DOMAIN_INVALID_SNAPPY[:])
+		dec = data
+	}
+	var topicLen [8]byte
+	binary.LittleEndian.PutUint64(topicLen[:], uint64(len(topic)))
+	h.Write(topicLen[:])
+	h.Write([]byte(topic))
+	h.Write(dec)
+	copy(out[:], h.Sum(nil))
+	return
+}
+
+// MessageID computes the message-id of the message, with the phase0 or Altair function, by the fork digest of the topic.
+// Topics that cannot be parsed get the Altair message-id.
+func MessageID(d *beacon.ForkDecoder, topic string, data []byte) [20]byte {
+	if digest, _, err := ParseTopic(topic); err == nil && digest == d.Genesis {
+		return MessageIDPhase0(data)
+	}
+	return MessageIDAltair(topic, data)
+}
+
+// DecodeMessage decompresses and decodes the gossip message data of the topic, with the type of the topic and its fork.
+// Blocks are decoded as *common.BeaconBlockEnvelope, other messages are decoded as their phase0, altair or capella type,
+// e.g. *phase0.Attestation for attestation subnets, or *altair.SyncCommitteeMessage for sync committee subnets.
+func DecodeMessage(d *beacon.ForkDecoder, topic string, data []byte) (interface{}, error) {
+	digest, name, err := ParseTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	fork := forkIndex(d, digest)
+	if fork == unknownFork {
+		return nil, fmt.Errorf("unrecognized fork digest: %s", digest)
+	}
+	dec, err := decodeSnappy(data, maxGossipSize(fork))
+	if err != nil {
+		return nil, err
+	}
+
+	var dst interface{}
+	switch name {
+	case BeaconBlockTopic:
+		alloc, err := d.BlockAllocator(digest)
+		if err != nil {
+			return nil, err
+		}
+		block := alloc()
+		if err := decodeSSZ(d.Spec, block, dec); err != nil {
+			return nil, err
+		}
+		return block.Envelope(d.Spec, digest), nil
+	case BeaconAggregateAndProofTopic:
+		dst = new(phase0.SignedAggregateAndProof)
+	case VoluntaryExitTopic:
+		dst = new(phase0.SignedVoluntaryExit)
+	case ProposerSlashingTopic:
+		dst = new(phase0.ProposerSlashing)
+	case AttesterSlashingTopic:
+		dst = new(phase0.AttesterSlashing)
+	case SyncCommitteeContributionAndProofTopic:
+		if fork >= altairFork {
+			dst = new(altair.SignedContributionAndProof)
+		}
+	case BLSToExecutionChangeTopic:
+		if fork >= capellaFork {
+			dst = new(capella.SignedBLSToExecutionChange)
+		}
+	default:
+		if subnet, ok := ParseSubnet(name, AttestationSubnetTopicPrefix); ok && subnet < common.ATTESTATION_SUBNET_COUNT {
+			dst = new(phase0.Attestation)
+		} else if subnet, ok := ParseSubnet(name, SyncCommitteeSubnetTopicPrefix); ok && subnet < common.SYNC_COMMITTEE_SUBNET_COUNT && fork >= altairFork {
+			dst = new(altair.SyncCommitteeMessage)
+		}
+	}
+	if dst == nil {
+		return nil, fmt.Errorf("unknown topic %q for fork digest %s", name, digest)
+	}
+	if err := decodeSSZ(d.Spec, dst, dec); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// decodeSSZ decodes the data into dst, which is either a common.SpecObj or a codec.Deserializable.
+func decodeSSZ(spec *common.Spec, dst interface{}, data []byte) error {
+	dr := codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))
+	var err error
+	switch x := dst.(type) {
+	case common.SpecObj:
+		err = x.Deserialize(spec, dr)
+	case codec.Deserializable:
+		err = x.Deserialize(dr)
+	default:
+		return fmt.Errorf("cannot decode into %T", dst)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %T: %v", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package gossipval
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestTopics(t *testing.T) {
+	digest := common.ForkDigest{0xb5, 0x30, 0x3f, 0x2a}
+	if got := AttestationSubnetTopic(digest, 13); got != "/eth2/b5303f2a/beacon_attestation_13/ssz_snappy" {
+		t.Fatalf("unexpected topic: %s", got)
+	}
+	gotDigest, name, err := ParseTopic(SyncCommitteeSubnetTopic(digest, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotDigest != digest || name != "sync_committee_2" {
+		t.Fatalf("unexpected parsed topic: %s %s", gotDigest, name)
+	}
+	if subnet, ok := ParseSubnet(name, SyncCommitteeSubnetTopicPrefix); !ok || subnet != 2 {
+		t.Fatalf("unexpected subnet: %d %v", subnet, ok)
+	}
+	for _, name := range []string{"sync_committee_", "sync_committee_02", "sync_committee_+2", "beacon_attestation_2"} {
+		if _, ok := ParseSubnet(name, SyncCommitteeSubnetTopicPrefix); ok {
+			t.Fatalf("expected %q to not be a sync committee subnet", name)
+		}
+	}
+	for _, topic := range []string{"/eth2/b5303f2a/beacon_block/ssz", "/eth2/b5303f/beacon_block/ssz_snappy", "eth2/b5303f2a/beacon_block/ssz_snappy"} {
+		if _, _, err := ParseTopic(topic); err == nil {
+			t.Fatalf("expected topic %q to be invalid", topic)
+		}
+	}
+
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	spec.BELLATRIX_FORK_EPOCH = 2
+	spec.CAPELLA_FORK_EPOCH = 3
+	d := beacon.NewForkDecoder(&spec, common.Root{0x42})
+	phase0Topics, err := Topics(d, d.Genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phase0Topics) != 5+common.ATTESTATION_SUBNET_COUNT {
+		t.Fatalf("unexpected phase0 topic count: %d", len(phase0Topics))
+	}
+	capellaTopics, err := Topics(d, d.Capella)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(capellaTopics) != 7+common.ATTESTATION_SUBNET_COUNT+common.SYNC_COMMITTEE_SUBNET_COUNT {
+		t.Fatalf("unexpected capella topic count: %d", len(capellaTopics))
+	}
+	if _, err := Topics(d, common.ForkDigest{}); err == nil {
+		t.Fatal("expected unknown fork digest to be refused")
+	}
+}
+
+func encodeMessage(t *testing.T, obj codec.Serializable) []byte {
+	var buf bytes.Buffer
+	if err := obj.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	return snappy.Encode(nil, buf.Bytes())
+}
+
+func TestMessageID(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	d := beacon.NewForkDecoder(&spec, common.Root{0x42})
+	exit := &phase0.SignedVoluntaryExit{Message: phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 2}}
+	data := encodeMessage(t, exit)
+
+	phase0Topic := Topic(d.Genesis, VoluntaryExitTopic)
+	altairTopic := Topic(d.Altair, VoluntaryExitTopic)
+	if MessageID(d, phase0Topic, data) != MessageIDPhase0(data) {
+		t.Fatal("expected phase0 message-id for phase0 topic")
+	}
+	if MessageID(d, altairTopic, data) != MessageIDAltair(altairTopic, data) {
+		t.Fatal("expected altair message-id for altair topic")
+	}
+	// the altair message-id commits to the topic, the phase0 message-id does not
+	if MessageIDAltair(altairTopic, data) == MessageIDAltair(phase0Topic, data) {
+		t.Fatal("expected altair message-id to depend on the topic")
+	}
+	// invalid compression is hashed with a different domain, of the raw data
+	invalid := []byte{0xff, 0xff, 0xff}
+	if MessageIDPhase0(invalid) == MessageIDPhase0(snappy.Encode(nil, invalid)) {
+		t.Fatal("expected invalid snappy data to get a different message-id")
+	}
+	// a small message that declares a large decompressed length is not decompressed
+	var declared [binary.MaxVarintLen64]byte
+	oversized := declared[:binary.PutUvarint(declared[:], 1<<31)]
+	h := sha256.New()
+	h.Write(MESSAGE_DOMAIN_INVALID_SNAPPY[:])
+	h.Write(oversized)
+	var expected [20]byte
+	copy(expected[:], h.Sum(nil))
+	if MessageIDPhase0(oversized) != expected {
+		t.Fatal("expected oversized message to be hashed as invalid snappy data")
+	}
+	h.Reset()
+	h.Write(MESSAGE_DOMAIN_INVALID_SNAPPY[:])
+	var topicLen [8]byte
+	binary.LittleEndian.PutUint64(topicLen[:], uint64(len(altairTopic)))
+	h.Write(topicLen[:])
+	h.Write([]byte(altairTopic))
+	h.Write(oversized)
+	copy(expected[:], h.Sum(nil))
+	if MessageIDAltair(altairTopic, oversized) != expected {
+		t.Fatal("expected oversized altair message to be hashed as invalid snappy data")
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	d := beacon.NewForkDecoder(&spec, common.Root{0x42})
+
+	exit := &phase0.SignedVoluntaryExit{Message: phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 2}}
+	msg, err := DecodeMessage(d, Topic(d.Altair, VoluntaryExitTopic), encodeMessage(t, exit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := msg.(*phase0.SignedVoluntaryExit); !ok || *got != *exit {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+
+	syncMsg := &altair.SyncCommitteeMessage{Slot: 9, ValidatorIndex: 3}
+	data := encodeMessage(t, syncMsg)
+	msg, err = DecodeMessage(d, SyncCommitteeSubnetTopic(d.Altair, 1), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := msg.(*altair.SyncCommitteeMessage); !ok || *got != *syncMsg {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+	// sync committees do not exist before altair
+	if _, err := DecodeMessage(d, SyncCommitteeSubnetTopic(d.Genesis, 1), data); err == nil {
+		t.Fatal("expected phase0 sync committee topic to be refused")
+	}
+	if _, err := DecodeMessage(d, SyncCommitteeSubnetTopic(d.Altair, common.SYNC_COMMITTEE_SUBNET_COUNT), data); err == nil {
+		t.Fatal("expected out of range subnet to be refused")
+	}
+
+	block := &altair.SignedBeaconBlock{Message: altair.BeaconBlock{Slot: spec.SLOTS_PER_EPOCH, ProposerIndex: 5}}
+	block.Message.Body.SyncAggregate.SyncCommitteeBits = make(altair.SyncCommitteeBits, spec.SYNC_COMMITTEE_SIZE/8)
+	msg, err = DecodeMessage(d, Topic(d.Altair, BeaconBlockTopic), encodeMessage(t, spec.Wrap(block)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	benv, ok := msg.(*common.BeaconBlockEnvelope)
+	if !ok || benv.ForkDigest != d.Altair || benv.BlockRoot != block.Message.HashTreeRoot(&spec, tree.GetHashFn()) {
+		t.Fatalf("unexpected block message: %v", msg)
+	}
+
+	large := snappy.Encode(nil, make([]byte, GOSSIP_MAX_SIZE+1))
+	if _, err := DecodeMessage(d, Topic(d.Altair, BeaconBlockTopic), large); err == nil {
+		t.Fatal("expected oversized message to be refused")
+	}
+}
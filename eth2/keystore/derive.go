@@ -0,0 +1,127 @@
+package keystore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	blsu "github.com/protolambda/bls12-381-util"
+)
+
+// Order of the BLS12-381 curve
+var curveOrder, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+// hkdfModR derives a secret key from the input key material, as defined by EIP-2333.
+func hkdfModR(ikm []byte) *big.Int {
+	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	sk := new(big.Int)
+	ikmPostfixed := append(append([]byte(nil), ikm...), 0)
+	// key_info is empty, followed by the output length L = 48 as 2 bytes
+	info := []byte{0, 48}
+	for sk.Sign() == 0 {
+		h := sha256.Sum256(salt)
+		salt = h[:]
+		prk := hkdfExtract(salt, ikmPostfixed)
+		okm := hkdfExpand(prk, info, 48)
+		sk.SetBytes(okm)
+		sk.Mod(sk, curveOrder)
+	}
+	return sk
+}
+
+func ikmToLamportSK(ikm []byte, salt []byte) []byte {
+	return hkdfExpand(hkdfExtract(salt, ikm), nil, 255*32)
+}
+
+// parentSKToLamportPK computes the compressed Lamport pubkey of the parent key, as defined by EIP-2333.
+func parentSKToLamportPK(parent *big.Int, index uint32) []byte {
+	var salt [4]byte
+	binary.BigEndian.PutUint32(salt[:], index)
+	var ikm, notIKM [32]byte
+	parent.FillBytes(ikm[:])
+	for i := range ikm {
+		notIKM[i] = ^ikm[i]
+	}
+	lamport0 := ikmToLamportSK(ikm[:], salt[:])
+	lamport1 := ikmToLamportSK(notIKM[:], salt[:])
+	h := sha256.New()
+	for _, lamport := range [][]byte{lamport0, lamport1} {
+		for i := 0; i < 255; i++ {
+			chunk := sha256.Sum256(lamport[i*32 : (i+1)*32])
+			h.Write(chunk[:])
+		}
+	}
+	return h.Sum(nil)
+}
+
+func toSecretKey(v *big.Int) (*blsu.SecretKey, error) {
+	var raw [32]byte
+	v.FillBytes(raw[:])
+	var sk blsu.SecretKey
+	if err := sk.Deserialize(&raw); err != nil {
+		return nil, err
+	}
+	return &sk, nil
+}
+
+// DeriveMasterSK derives the EIP-2333 master secret key from the seed, e.g. the seed of a mnemonic.
+func DeriveMasterSK(seed []byte) (*blsu.SecretKey, error) {
+	if len(seed) < 32 {
+		return nil, fmt.Errorf("seed must be at least 32 bytes, got %d", len(seed))
+	}
+	return toSecretKey(hkdfModR(seed))
+}
+
+// DeriveChildSK derives the EIP-2333 child secret key at the index of the parent key.
+func DeriveChildSK(parent *blsu.SecretKey, index uint32) (*blsu.SecretKey, error) {
+	raw := parent.Serialize()
+	return toSecretKey(hkdfModR(parentSKToLamportPK(new(big.Int).SetBytes(raw[:]), index)))
+}
+
+// ParsePath parses an EIP-2334 path, like "m/12381/3600/0/0/0", into the child indices.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("path %q must start with the master node \"m\"", path)
+	}
+	out := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		index, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q in path %q: %v", p, path, err)
+		}
+		out = append(out, uint32(index))
+	}
+	return out, nil
+}
+
+// DeriveSKFromPath derives the secret key at the EIP-2334 path from the seed.
+func DeriveSKFromPath(seed []byte, path string) (*blsu.SecretKey, error) {
+	indices, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	sk, err := DeriveMasterSK(seed)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indices {
+		if sk, err = DeriveChildSK(sk, index); err != nil {
+			return nil, err
+		}
+	}
+	return sk, nil
+}
+
+// SigningKeyPath is the EIP-2334 path of the signing key of the validator with the given account index.
+func SigningKeyPath(index uint32) string {
+	return fmt.Sprintf("m/12381/3600/%d/0/0", index)
+}
+
+// WithdrawalKeyPath is the EIP-2334 path of the withdrawal key of the validator with the given account index.
+func WithdrawalKeyPath(index uint32) string {
+	return fmt.Sprintf("m/12381/3600/%d/0", index)
+}
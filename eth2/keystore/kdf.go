@@ -0,0 +1,24 @@
+package keystore
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The HKDF steps of EIP-2333, with SHA-256.
+
+// hkdfExtract is HKDF-Extract of RFC 5869.
+func hkdfExtract(salt []byte, ikm []byte) []byte {
+	return hkdf.Extract(sha256.New, ikm, salt)
+}
+
+// hkdfExpand is HKDF-Expand of RFC 5869. The length must not exceed 255*32 bytes.
+func hkdfExpand(prk []byte, info []byte, length int) []byte {
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), out); err != nil {
+		panic(err)
+	}
+	return out
+}
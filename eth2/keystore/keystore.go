@@ -0,0 +1,252 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizePassword converts the password to the NFKD normal form,
+// and strips the C0, C1 and Delete control codes, as defined by EIP-2335.
+func NormalizePassword(password string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= 0x1f || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, norm.NFKD.String(password))
+}
+
+type hexBytes []byte
+
+func (b hexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(text, []byte("0x"))
+	out := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(out, text); err != nil {
+		return err
+	}
+	*b = out
+	return nil
+}
+
+type KDFParams struct {
+	DKLen int      `json:"dklen"`
+	Salt  hexBytes `json:"salt"`
+	// scrypt parameters
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+	// pbkdf2 parameters
+	C   int    `json:"c,omitempty"`
+	PRF string `json:"prf,omitempty"`
+}
+
+type KDFModule struct {
+	Function string    `json:"function"`
+	Params   KDFParams `json:"params"`
+	Message  hexBytes  `json:"message"`
+}
+
+// ScryptKDF is the scrypt key derivation module with the given cost parameters.
+// EIP-2335 recommends n=262144, r=8, p=1.
+func ScryptKDF(n int, r int, p int, salt []byte) *KDFModule {
+	return &KDFModule{Function: "scrypt", Params: KDFParams{DKLen: 32, Salt: salt, N: n, R: r, P: p}, Message: hexBytes{}}
+}
+
+// PBKDF2KDF is the PBKDF2 key derivation module with the given iteration count.
+// EIP-2335 recommends c=262144.
+func PBKDF2KDF(c int, salt []byte) *KDFModule {
+	return &KDFModule{Function: "pbkdf2", Params: KDFParams{DKLen: 32, Salt: salt, C: c, PRF: "hmac-sha256"}, Message: hexBytes{}}
+}
+
+func (m *KDFModule) deriveKey(password []byte) ([]byte, error) {
+	p := &m.Params
+	if p.DKLen < 32 {
+		return nil, fmt.Errorf("derived key length %d is too short", p.DKLen)
+	}
+	switch m.Function {
+	case "scrypt":
+		return scrypt.Key(password, p.Salt, p.N, p.R, p.P, p.DKLen)
+	case "pbkdf2":
+		if p.PRF != "hmac-sha256" {
+			return nil, fmt.Errorf("unsupported pbkdf2 PRF %q", p.PRF)
+		}
+		if p.C <= 0 {
+			return nil, fmt.Errorf("invalid pbkdf2 iteration count %d", p.C)
+		}
+		return pbkdf2.Key(password, p.Salt, p.C, p.DKLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %q", m.Function)
+	}
+}
+
+type ChecksumModule struct {
+	Function string   `json:"function"`
+	Params   struct{} `json:"params"`
+	Message  hexBytes `json:"message"`
+}
+
+type CipherParams struct {
+	IV hexBytes `json:"iv"`
+}
+
+type CipherModule struct {
+	Function string       `json:"function"`
+	Params   CipherParams `json:"params"`
+	Message  hexBytes     `json:"message"`
+}
+
+func (m *CipherModule) xorKeyStream(key []byte, dst []byte, src []byte) error {
+	if m.Function != "aes-128-ctr" {
+		return fmt.Errorf("unsupported cipher %q", m.Function)
+	}
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return err
+	}
+	if len(m.Params.IV) != block.BlockSize() {
+		return fmt.Errorf("invalid cipher IV length %d", len(m.Params.IV))
+	}
+	cipher.NewCTR(block, m.Params.IV).XORKeyStream(dst, src)
+	return nil
+}
+
+type Crypto struct {
+	KDF      KDFModule      `json:"kdf"`
+	Checksum ChecksumModule `json:"checksum"`
+	Cipher   CipherModule   `json:"cipher"`
+}
+
+// Keystore is an EIP-2335 BLS12-381 keystore, version 4.
+type Keystore struct {
+	Crypto      Crypto   `json:"crypto"`
+	Description string   `json:"description,omitempty"`
+	Pubkey      hexBytes `json:"pubkey"`
+	// EIP-2334 derivation path of the key, may be empty.
+	Path    string `json:"path"`
+	UUID    string `json:"uuid"`
+	Version uint64 `json:"version"`
+}
+
+func checksum(dk []byte, cipherMessage []byte) []byte {
+	h := sha256.New()
+	h.Write(dk[16:32])
+	h.Write(cipherMessage)
+	return h.Sum(nil)
+}
+
+// ReadKeystore decodes a keystore from JSON.
+func ReadKeystore(r io.Reader) (*Keystore, error) {
+	var ks Keystore
+	if err := json.NewDecoder(r).Decode(&ks); err != nil {
+		return nil, fmt.Errorf("failed to decode keystore: %v", err)
+	}
+	if ks.Version != 4 {
+		return nil, fmt.Errorf("unsupported keystore version %d", ks.Version)
+	}
+	return &ks, nil
+}
+
+// Decrypt the secret key of the keystore with the password.
+// The pubkey of the keystore, if any, is checked against the decrypted secret key.
+func (ks *Keystore) Decrypt(password string) (*blsu.SecretKey, error) {
+	dk, err := ks.Crypto.KDF.deriveKey([]byte(NormalizePassword(password)))
+	if err != nil {
+		return nil, err
+	}
+	if ks.Crypto.Checksum.Function != "sha256" {
+		return nil, fmt.Errorf("unsupported checksum function %q", ks.Crypto.Checksum.Function)
+	}
+	if !bytes.Equal(checksum(dk, ks.Crypto.Cipher.Message), ks.Crypto.Checksum.Message) {
+		return nil, errors.New("invalid keystore password, checksum does not match")
+	}
+	if len(ks.Crypto.Cipher.Message) != 32 {
+		return nil, fmt.Errorf("expected 32 byte encrypted secret key, got %d bytes", len(ks.Crypto.Cipher.Message))
+	}
+	var raw [32]byte
+	if err := ks.Crypto.Cipher.xorKeyStream(dk, raw[:], ks.Crypto.Cipher.Message); err != nil {
+		return nil, err
+	}
+	var sk blsu.SecretKey
+	if err := sk.Deserialize(&raw); err != nil {
+		return nil, fmt.Errorf("invalid secret key: %v", err)
+	}
+	if len(ks.Pubkey) > 0 {
+		pub, err := blsu.SkToPk(&sk)
+		if err != nil {
+			return nil, err
+		}
+		if p := pub.Serialize(); !bytes.Equal(p[:], ks.Pubkey) {
+			return nil, fmt.Errorf("decrypted secret key does not match keystore pubkey %x", []byte(ks.Pubkey))
+		}
+	}
+	return &sk, nil
+}
+
+// Encrypt the secret key into a new keystore, with the given key derivation module
+// (see ScryptKDF and PBKDF2KDF), and randomness for the cipher IV and the keystore UUID.
+func Encrypt(sk *blsu.SecretKey, password string, path string, kdf *KDFModule, rng io.Reader) (*Keystore, error) {
+	dk, err := kdf.deriveKey([]byte(NormalizePassword(password)))
+	if err != nil {
+		return nil, err
+	}
+	var random [16 + 16]byte
+	if _, err := io.ReadFull(rng, random[:]); err != nil {
+		return nil, fmt.Errorf("failed to read randomness: %v", err)
+	}
+	c := CipherModule{Function: "aes-128-ctr", Params: CipherParams{IV: random[:16]}, Message: make(hexBytes, 32)}
+	raw := sk.Serialize()
+	if err := c.xorKeyStream(dk, c.Message, raw[:]); err != nil {
+		return nil, err
+	}
+	pub, err := blsu.SkToPk(sk)
+	if err != nil {
+		return nil, err
+	}
+	pubRaw := pub.Serialize()
+	// random version 4 UUID
+	uuid := random[16:]
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+	return &Keystore{
+		Crypto: Crypto{
+			KDF:      *kdf,
+			Checksum: ChecksumModule{Function: "sha256", Message: checksum(dk, c.Message)},
+			Cipher:   c,
+		},
+		Pubkey:  pubRaw[:],
+		Path:    path,
+		UUID:    fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]),
+		Version: 4,
+	}, nil
+}
+
+// MnemonicToSeed computes the BIP-39 seed of the mnemonic, to derive EIP-2333 keys from.
+// The mnemonic must consist of words of the English BIP-39 wordlist, and have a valid checksum.
+func MnemonicToSeed(mnemonic string, passphrase string) ([]byte, error) {
+	words := strings.Fields(norm.NFKD.String(mnemonic))
+	mnemonic = strings.Join(words, " ")
+	if _, err := bip39.EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, fmt.Errorf("invalid mnemonic: %v", err)
+	}
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(mnemonic), []byte(salt), 2048, 64, sha512.New), nil
+}
@@ -0,0 +1,190 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+)
+
+func TestKDFs(t *testing.T) {
+	if _, err := ScryptKDF(1000, 8, 1, []byte{1, 2, 3}).deriveKey([]byte("password")); err == nil {
+		t.Fatal("expected scrypt n that is not a power of 2 to be rejected")
+	}
+	kdf := PBKDF2KDF(16, []byte{1, 2, 3})
+	kdf.Params.PRF = "hmac-sha512"
+	if _, err := kdf.deriveKey([]byte("password")); err == nil {
+		t.Fatal("expected unsupported pbkdf2 PRF to be rejected")
+	}
+}
+
+func TestNormalizePassword(t *testing.T) {
+	for _, c := range []struct {
+		password, expected string
+	}{
+		{"𝔱𝔢𝔰𝔱", "test"},
+		{"a\x00b\x1fc\x7fd\u0080e\u009ff g", "abcdef g"},
+		{"\u00e9", "e\u0301"},
+	} {
+		if got := NormalizePassword(c.password); got != c.expected {
+			t.Fatalf("password %q: expected %q, got %q", c.password, c.expected, got)
+		}
+	}
+}
+
+// EIP-2335 test vectors
+const (
+	testPassword = "𝔱𝔢𝔰𝔱𝔭𝔞𝔰𝔰𝔴𝔬𝔯𝔡🔑"
+	testSecret   = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+	testScryptKeystore = `{
+    "crypto": {
+        "kdf": {
+            "function": "scrypt",
+            "params": {"dklen": 32, "n": 262144, "p": 1, "r": 8, "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"},
+            "message": ""
+        },
+        "checksum": {"function": "sha256", "params": {}, "message": "d2217fe5f3e9a1e34581ef8a78f7c9928e436d36dacc5e846690a5581e8ea484"},
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {"iv": "264daa3f303d7259501c93d997d84fe6"},
+            "message": "06ae90d55fe0a6e9c5c3bc5b170827b2e5cce3929ed3f116c2811e6366dfe20f"
+        }
+    },
+    "description": "This is a test keystore that uses scrypt to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/3141592653/589793238",
+    "uuid": "1d85ae20-35c5-4611-98e8-aa14a633906f",
+    "version": 4
+}`
+	testPBKDF2Keystore = `{
+    "crypto": {
+        "kdf": {
+            "function": "pbkdf2",
+            "params": {"dklen": 32, "c": 262144, "prf": "hmac-sha256", "salt": "d4e56740f876aef8c010b86a40d5f56745a118d0906a34e69aec8c0db1cb8fa3"},
+            "message": ""
+        },
+        "checksum": {"function": "sha256", "params": {}, "message": "8a9f5d9912ed7e75ea794bc5a89bca5f193721d30868ade6f73043c6ea6febf1"},
+        "cipher": {
+            "function": "aes-128-ctr",
+            "params": {"iv": "264daa3f303d7259501c93d997d84fe6"},
+            "message": "cee03fde2af33149775b7223e7845e4fb2c8ae1792e5f99fe9ecf474cc8c16ad"
+        }
+    },
+    "description": "This is a test keystore that uses PBKDF2 to secure the secret.",
+    "pubkey": "9612d7a727c9d0a22e185a1c768478dfe919cada9266988cb32359c11f2b7b27f4ae4040902382ae2910c15e2b420d07",
+    "path": "m/12381/60/0/0",
+    "uuid": "64625def-3331-4eea-ab6f-782f3ed16a83",
+    "version": 4
+}`
+)
+
+func TestDecrypt(t *testing.T) {
+	for _, data := range []string{testScryptKeystore, testPBKDF2Keystore} {
+		ks, err := ReadKeystore(strings.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sk, err := ks.Decrypt(testPassword)
+		if err != nil {
+			t.Fatalf("%s: %v", ks.Crypto.KDF.Function, err)
+		}
+		raw := sk.Serialize()
+		if got := hex.EncodeToString(raw[:]); got != testSecret {
+			t.Fatalf("%s: unexpected secret key %s", ks.Crypto.KDF.Function, got)
+		}
+	}
+	ks, err := ReadKeystore(strings.NewReader(testPBKDF2Keystore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Decrypt("wrong password"); err == nil {
+		t.Fatal("expected wrong password to be rejected")
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	var raw [32]byte
+	raw[31] = 42
+	var sk blsu.SecretKey
+	if err := sk.Deserialize(&raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, kdf := range []*KDFModule{ScryptKDF(16, 8, 1, []byte{1, 2, 3}), PBKDF2KDF(16, []byte{1, 2, 3})} {
+		ks, err := Encrypt(&sk, "test\x7fpassword", SigningKeyPath(0), kdf, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(ks); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ReadKeystore(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// control codes are stripped from the password
+		out, err := decoded.Decrypt("testpassword")
+		if err != nil {
+			t.Fatalf("%s: %v", kdf.Function, err)
+		}
+		if out.Serialize() != raw {
+			t.Fatalf("%s: secret key did not round-trip", kdf.Function)
+		}
+	}
+}
+
+func TestDerive(t *testing.T) {
+	// EIP-2333 test case 0, the seed is the BIP-39 seed of the mnemonic
+	seed, err := MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(seed); got != "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04" {
+		t.Fatalf("unexpected seed: %s", got)
+	}
+	master, err := DeriveMasterSK(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKey := func(sk *blsu.SecretKey, expected string) {
+		raw := sk.Serialize()
+		if got := new(big.Int).SetBytes(raw[:]).String(); got != expected {
+			t.Fatalf("expected key %s, got %s", expected, got)
+		}
+	}
+	expectKey(master, "6083874454709270928345386274498605044986640685124978867557563392430687146096")
+	child, err := DeriveChildSK(master, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKey(child, "20397789859736650942317412262472558107875392172444076792671091975210932703118")
+	byPath, err := DeriveSKFromPath(seed, "m/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKey(byPath, "20397789859736650942317412262472558107875392172444076792671091975210932703118")
+
+	for _, path := range []string{"", "0/1", "m/x", "m/4294967296"} {
+		if _, err := DeriveSKFromPath(seed, path); err == nil {
+			t.Fatalf("expected path %q to be rejected", path)
+		}
+	}
+	for _, mnemonic := range []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon", // bad checksum
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abuot",   // unknown word
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",           // too short
+	} {
+		if _, err := MnemonicToSeed(mnemonic, ""); err == nil {
+			t.Fatalf("expected mnemonic %q to be rejected", mnemonic)
+		}
+	}
+	if _, err := DeriveMasterSK(seed[:31]); err == nil {
+		t.Fatal("expected short seed to be rejected")
+	}
+}
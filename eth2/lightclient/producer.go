@@ -0,0 +1,198 @@
+package lightclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// BlockHeader returns the header of the block that the state is the post-state of, with the state root filled in.
+// The state must not be processed to a later slot, as the header would then not commit to this state.
+func BlockHeader(state common.BeaconState) (*common.BeaconBlockHeader, error) {
+	header, err := state.LatestBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	stateRoot := state.HashTreeRoot(tree.GetHashFn())
+	if header.StateRoot == (common.Root{}) {
+		header.StateRoot = stateRoot
+	} else if header.StateRoot != stateRoot {
+		return nil, fmt.Errorf("state %s is not the post-state of latest block header %s", stateRoot, header.StateRoot)
+	}
+	return header, nil
+}
+
+// BuildUpdate builds a light client update for the attested state, signed with the given sync aggregate
+// by the sync committee at the signature slot.
+// If the finalized state is not nil, the update proves the finalized header of the attested state.
+// The next sync committee is proven from the attested state, if it is signed by the sync committee of the same period.
+func BuildUpdate(spec *common.Spec, attested common.SyncCommitteeBeaconState, finalized common.SyncCommitteeBeaconState,
+	agg *altair.SyncAggregate, signatureSlot common.Slot) (*altair.LightClientUpdate, error) {
+	hFn := tree.GetHashFn()
+	attestedHeader, err := BlockHeader(attested)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attested state: %v", err)
+	}
+	update := &altair.LightClientUpdate{
+		AttestedHeader: *attestedHeader,
+		SyncAggregate:  *agg,
+		SignatureSlot:  signatureSlot,
+	}
+
+	if finalized != nil {
+		finalizedHeader, err := BlockHeader(finalized)
+		if err != nil {
+			return nil, fmt.Errorf("invalid finalized state: %v", err)
+		}
+		checkpoint, err := attested.FinalizedCheckpoint()
+		if err != nil {
+			return nil, err
+		}
+		if root := finalizedHeader.HashTreeRoot(hFn); root != checkpoint.Root {
+			return nil, fmt.Errorf("finalized state of block %s does not match finalized checkpoint %s", root, checkpoint.Root)
+		}
+		branch, err := merkle.MerkleBranch(attested.Backing(), altair.FINALIZED_ROOT_INDEX, hFn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build finality branch: %v", err)
+		}
+		update.FinalizedHeader = *finalizedHeader
+		copy(update.FinalityBranch[:], branch)
+	}
+
+	// The next sync committee is only useful if the update is signed by the current sync committee
+	if SyncCommitteePeriod(spec, attestedHeader.Slot) != SyncCommitteePeriod(spec, signatureSlot) {
+		return update, nil
+	}
+	next, err := attested.NextSyncCommittee()
+	if err != nil {
+		return nil, err
+	}
+	nextPubkeys, err := next.Pubkeys()
+	if err != nil {
+		return nil, err
+	}
+	if update.NextSyncCommittee.Pubkeys, err = nextPubkeys.Flatten(); err != nil {
+		return nil, err
+	}
+	if update.NextSyncCommittee.AggregatePubkey, err = next.AggregatePubkey(); err != nil {
+		return nil, err
+	}
+	branch, err := merkle.MerkleBranch(attested.Backing(), altair.NEXT_SYNC_COMMITTEE_INDEX, hFn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build next sync committee branch: %v", err)
+	}
+	copy(update.NextSyncCommitteeBranch[:], branch)
+	return update, nil
+}
+
+// UpdateProducer builds light client updates from the chain, and keeps the best update per sync committee period,
+// to serve light clients with.
+type UpdateProducer struct {
+	mu   sync.RWMutex
+	spec *common.Spec
+	// Best update per sync committee period of the attested header of the update
+	best map[uint64]*altair.LightClientUpdate
+}
+
+func NewUpdateProducer(spec *common.Spec) *UpdateProducer {
+	return &UpdateProducer{
+		spec: spec,
+		best: make(map[uint64]*altair.LightClientUpdate),
+	}
+}
+
+// OnSyncAggregate builds an update for the attested block, with the sync aggregate of the block at signatureSlot,
+// and tracks it if it is the best update of its period. The finality proof is included if the finalized block is available.
+func (p *UpdateProducer) OnSyncAggregate(ctx context.Context, ch beacon.Chain, attestedRoot common.Root,
+	signatureSlot common.Slot, agg *altair.SyncAggregate) (*altair.LightClientUpdate, error) {
+	entry, ok := ch.ByBlock(attestedRoot)
+	if !ok {
+		return nil, fmt.Errorf("unknown attested block: %s", attestedRoot)
+	}
+	state, err := entry.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attested, ok := state.(common.SyncCommitteeBeaconState)
+	if !ok {
+		return nil, fmt.Errorf("attested state has no sync committees: %T", state)
+	}
+	var finalized common.SyncCommitteeBeaconState
+	checkpoint, err := attested.FinalizedCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Root != (common.Root{}) {
+		if finEntry, ok := ch.ByBlock(checkpoint.Root); ok {
+			finState, err := finEntry.State(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// Finalized blocks from before the sync committees were introduced cannot be proven.
+			finalized, _ = finState.(common.SyncCommitteeBeaconState)
+		}
+	}
+	update, err := BuildUpdate(p.spec, attested, finalized, agg, signatureSlot)
+	if err != nil {
+		return nil, err
+	}
+	p.AddUpdate(update)
+	return update, nil
+}
+
+// AddUpdate tracks the update, if it is better than the current best update of its period.
+func (p *UpdateProducer) AddUpdate(update *altair.LightClientUpdate) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	period := SyncCommitteePeriod(p.spec, update.AttestedHeader.Slot)
+	if prev, ok := p.best[period]; !ok || IsBetterUpdate(p.spec, update, prev) {
+		p.best[period] = update
+	}
+}
+
+// BestUpdate returns the best update of which the attested header is in the given sync committee period.
+func (p *UpdateProducer) BestUpdate(period uint64) (*altair.LightClientUpdate, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	update, ok := p.best[period]
+	return update, ok
+}
+
+// BestUpdates returns the best updates of up to count consecutive periods, starting at the given period.
+// Periods without an update are skipped.
+func (p *UpdateProducer) BestUpdates(startPeriod uint64, count uint64) []*altair.LightClientUpdate {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var periods []uint64
+	for period := range p.best {
+		if period >= startPeriod && period-startPeriod < count {
+			periods = append(periods, period)
+		}
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i] < periods[j]
+	})
+	out := make([]*altair.LightClientUpdate, 0, len(periods))
+	for _, period := range periods {
+		out = append(out, p.best[period])
+	}
+	return out
+}
+
+// Prune removes the updates of periods before the given period.
+func (p *UpdateProducer) Prune(period uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k := range p.best {
+		if k < period {
+			delete(p.best, k)
+		}
+	}
+}
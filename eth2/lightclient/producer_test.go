@@ -0,0 +1,161 @@
+package lightclient
+
+import (
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func testState(t *testing.T, spec *common.Spec, slot common.Slot, current *testCommittee, next *testCommittee) *altair.BeaconStateView {
+	state := altair.NewBeaconStateView(spec)
+	if err := state.SetSlot(slot); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.SetLatestBlockHeader(&common.BeaconBlockHeader{Slot: slot, BodyRoot: common.Root{byte(slot)}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		committee *testCommittee
+		set       func(v *common.SyncCommitteeView) error
+	}{{current, state.SetCurrentSyncCommittee}, {next, state.SetNextSyncCommittee}} {
+		v, err := c.committee.committee.View(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.set(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return state
+}
+
+func TestBuildUpdate(t *testing.T) {
+	spec := configs.Minimal
+	gvr := common.Root{0x12}
+	a := newTestCommittee(t, spec, 1)
+	b := newTestCommittee(t, spec, 2)
+	c := newTestCommittee(t, spec, 3)
+
+	// The finalized state is in the next period, the attested state finalizes it.
+	finalized := testState(t, spec, 70, b, c)
+	finalizedHeader, err := BlockHeader(finalized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := testState(t, spec, 80, b, c)
+	if err := attested.SetFinalizedCheckpoint(common.Checkpoint{Epoch: 9, Root: finalizedHeader.HashTreeRoot(tree.GetHashFn())}); err != nil {
+		t.Fatal(err)
+	}
+	attestedHeader, err := BlockHeader(attested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := b.sign(t, spec, attestedHeader, 81, gvr, 30)
+
+	if _, err := BuildUpdate(spec, attested, testState(t, spec, 72, b, c), &agg, 81); err == nil {
+		t.Fatal("expected finalized state that does not match the finalized checkpoint to be rejected")
+	}
+	update, err := BuildUpdate(spec, attested, finalized, &agg, 81)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.AttestedHeader != *attestedHeader || update.FinalizedHeader != *finalizedHeader {
+		t.Fatal("unexpected update headers")
+	}
+
+	newStore := func() *Store {
+		return &Store{
+			FinalizedHeader:      common.BeaconBlockHeader{Slot: 8},
+			CurrentSyncCommittee: a.committee,
+			NextSyncCommittee:    b.committee,
+			OptimisticHeader:     common.BeaconBlockHeader{Slot: 8},
+		}
+	}
+	store := newStore()
+	if err := ProcessLightClientUpdate(spec, store, update, 81, gvr); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader != *finalizedHeader {
+		t.Fatal("expected produced update to be applied")
+	}
+	if store.NextSyncCommittee.Pubkeys[0] != c.committee.Pubkeys[0] {
+		t.Fatal("expected next sync committee of produced update to be applied")
+	}
+
+	// Without finality, the next sync committee is proven from the attested state.
+	optimistic, err := BuildUpdate(spec, attested, nil, &agg, 81)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsFinalityUpdate(optimistic) || optimistic.FinalityBranch != (altair.FinalizedRootProofBranch{}) {
+		t.Fatal("expected no finality proof")
+	}
+	store = newStore()
+	if err := ProcessLightClientUpdate(spec, store, optimistic, 81, gvr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Signed in a later period, the next sync committee of the attested state is not useful.
+	late, err := BuildUpdate(spec, attested, finalized, &agg, 130)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsSyncCommitteeUpdate(late) || !IsFinalityUpdate(late) {
+		t.Fatal("expected finality proof without next sync committee")
+	}
+
+	// States processed past their block cannot be proven with the block header.
+	if err := attested.SetSlot(81); err != nil {
+		t.Fatal(err)
+	}
+	header := *attestedHeader
+	if err := attested.SetLatestBlockHeader(&header); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BlockHeader(attested); err == nil {
+		t.Fatal("expected state after the block to be rejected")
+	}
+}
+
+func TestUpdateProducer(t *testing.T) {
+	spec := configs.Minimal
+	p := NewUpdateProducer(spec)
+	update := func(slot common.Slot, finalizedSlot common.Slot, participants uint64) *altair.LightClientUpdate {
+		u := &altair.LightClientUpdate{AttestedHeader: common.BeaconBlockHeader{Slot: slot}}
+		if finalizedSlot != 0 {
+			u.FinalizedHeader.Slot = finalizedSlot
+			u.FinalityBranch[0] = common.Root{0x01}
+		}
+		u.SyncAggregate.SyncCommitteeBits = make(altair.SyncCommitteeBits, (spec.SYNC_COMMITTEE_SIZE+7)/8)
+		for i := uint64(0); i < participants; i++ {
+			u.SyncAggregate.SyncCommitteeBits.SetBit(i, true)
+		}
+		return u
+	}
+	// period 0: most participants wins, then finality, then the oldest update.
+	low := update(10, 0, 10)
+	high := update(11, 0, 20)
+	highFinal := update(12, 5, 20)
+	highLater := update(13, 0, 20)
+	// period 2
+	other := update(140, 130, 5)
+	for _, u := range []*altair.LightClientUpdate{low, high, highFinal, highLater, other} {
+		p.AddUpdate(u)
+	}
+	if best, ok := p.BestUpdate(0); !ok || best != highFinal {
+		t.Fatal("expected finality update to be the best update of period 0")
+	}
+	if _, ok := p.BestUpdate(1); ok {
+		t.Fatal("expected no update for period 1")
+	}
+	if best := p.BestUpdates(0, 3); len(best) != 2 || best[0] != highFinal || best[1] != other {
+		t.Fatalf("unexpected best updates: %v", best)
+	}
+	p.Prune(1)
+	if best := p.BestUpdates(0, 10); len(best) != 1 || best[0] != other {
+		t.Fatalf("unexpected best updates after pruning: %v", best)
+	}
+}
@@ -0,0 +1,386 @@
+package lightclient
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/ztyp/bitfields"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// Store is the state of a light client following the sync protocol.
+// Updates are verified against the sync committees, and move the finalized and optimistic headers forward.
+type Store struct {
+	// Beacon block header that is finalized
+	FinalizedHeader common.BeaconBlockHeader
+	// Sync committees corresponding to the finalized header.
+	// The next sync committee is zero until an update proves it.
+	CurrentSyncCommittee common.SyncCommittee
+	NextSyncCommittee    common.SyncCommittee
+	// Best available header to switch finalized head to if we see nothing else
+	BestValidUpdate *altair.LightClientUpdate
+	// Most recent available reasonably-safe header
+	OptimisticHeader common.BeaconBlockHeader
+	// Max number of active participants in a sync committee (used to calculate safety threshold)
+	PreviousMaxActiveParticipants uint64
+	CurrentMaxActiveParticipants  uint64
+}
+
+// InitializeLightClientStore initializes a light client store from a bootstrap of a trusted block root,
+// e.g. of a weak subjectivity checkpoint. The current sync committee is verified against the state root of the header.
+func InitializeLightClientStore(spec *common.Spec, trustedBlockRoot common.Root, bootstrap *altair.LightClientBootstrap) (*Store, error) {
+	hFn := tree.GetHashFn()
+	if root := bootstrap.Header.HashTreeRoot(hFn); root != trustedBlockRoot {
+		return nil, fmt.Errorf("bootstrap header %s does not match trusted block root %s", root, trustedBlockRoot)
+	}
+	depth, index := gindexDepthAndIndex(altair.CURRENT_SYNC_COMMITTEE_INDEX)
+	if !merkle.VerifyMerkleBranch(bootstrap.CurrentSyncCommittee.HashTreeRoot(spec, hFn), bootstrap.CurrentSyncCommitteeBranch[:],
+		depth, index, bootstrap.Header.StateRoot) {
+		return nil, errors.New("invalid current sync committee branch")
+	}
+	return &Store{
+		FinalizedHeader:      bootstrap.Header,
+		CurrentSyncCommittee: bootstrap.CurrentSyncCommittee,
+		OptimisticHeader:     bootstrap.Header,
+	}, nil
+}
+
+// UpdateTimeout is the number of slots after which the best valid update is applied, if no finality update was seen.
+func UpdateTimeout(spec *common.Spec) common.Slot {
+	return common.Slot(spec.SLOTS_PER_EPOCH) * common.Slot(spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+}
+
+// SyncCommitteePeriod computes the sync committee period of the given slot.
+func SyncCommitteePeriod(spec *common.Spec, slot common.Slot) uint64 {
+	return uint64(spec.SlotToEpoch(slot) / spec.EPOCHS_PER_SYNC_COMMITTEE_PERIOD)
+}
+
+// IsSyncCommitteeUpdate checks if the update proves the next sync committee.
+func IsSyncCommitteeUpdate(update *altair.LightClientUpdate) bool {
+	return !isZeroBranch(update.NextSyncCommitteeBranch[:])
+}
+
+// IsFinalityUpdate checks if the update proves a finalized header.
+func IsFinalityUpdate(update *altair.LightClientUpdate) bool {
+	return !isZeroBranch(update.FinalityBranch[:])
+}
+
+// IsNextSyncCommitteeKnown checks if the store has learned the next sync committee from an update.
+func (s *Store) IsNextSyncCommitteeKnown() bool {
+	return !isZeroSyncCommittee(&s.NextSyncCommittee)
+}
+
+// SafetyThreshold is the number of participants required to update the optimistic header.
+func (s *Store) SafetyThreshold() uint64 {
+	max := s.PreviousMaxActiveParticipants
+	if s.CurrentMaxActiveParticipants > max {
+		max = s.CurrentMaxActiveParticipants
+	}
+	return max / 2
+}
+
+// Participants counts the sync committee members that signed the sync aggregate.
+func Participants(spec *common.Spec, agg *altair.SyncAggregate) uint64 {
+	count := uint64(0)
+	for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
+		if agg.SyncCommitteeBits.GetBit(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// IsBetterUpdate checks if the new update is better than the old update, to keep the best valid update with.
+// Updates with a supermajority are preferred, then updates that prove the sync committee of the signature period,
+// then updates that prove finality (of the same period), then updates with more participants, and then older updates.
+func IsBetterUpdate(spec *common.Spec, newUpdate *altair.LightClientUpdate, oldUpdate *altair.LightClientUpdate) bool {
+	// Compare supermajority (> 2/3) sync committee participation
+	maxParticipants := spec.SYNC_COMMITTEE_SIZE
+	newParticipants := Participants(spec, &newUpdate.SyncAggregate)
+	oldParticipants := Participants(spec, &oldUpdate.SyncAggregate)
+	newSupermajority := newParticipants*3 >= maxParticipants*2
+	oldSupermajority := oldParticipants*3 >= maxParticipants*2
+	if newSupermajority != oldSupermajority {
+		return newSupermajority
+	}
+	if !newSupermajority && newParticipants != oldParticipants {
+		return newParticipants > oldParticipants
+	}
+
+	// Compare presence of relevant sync committee
+	relevantSyncCommittee := func(update *altair.LightClientUpdate) bool {
+		return IsSyncCommitteeUpdate(update) &&
+			SyncCommitteePeriod(spec, update.AttestedHeader.Slot) == SyncCommitteePeriod(spec, update.SignatureSlot)
+	}
+	newRelevant, oldRelevant := relevantSyncCommittee(newUpdate), relevantSyncCommittee(oldUpdate)
+	if newRelevant != oldRelevant {
+		return newRelevant
+	}
+
+	// Compare indication of any finality
+	newFinality, oldFinality := IsFinalityUpdate(newUpdate), IsFinalityUpdate(oldUpdate)
+	if newFinality != oldFinality {
+		return newFinality
+	}
+
+	// Compare sync committee finality
+	if newFinality {
+		syncCommitteeFinality := func(update *altair.LightClientUpdate) bool {
+			return SyncCommitteePeriod(spec, update.FinalizedHeader.Slot) == SyncCommitteePeriod(spec, update.AttestedHeader.Slot)
+		}
+		newCommitteeFinality, oldCommitteeFinality := syncCommitteeFinality(newUpdate), syncCommitteeFinality(oldUpdate)
+		if newCommitteeFinality != oldCommitteeFinality {
+			return newCommitteeFinality
+		}
+	}
+
+	// Tiebreaker 1: Sync committee participation beyond supermajority
+	if newParticipants != oldParticipants {
+		return newParticipants > oldParticipants
+	}
+
+	// Tiebreaker 2: Prefer older data (fewer changes to best)
+	if newUpdate.AttestedHeader.Slot != oldUpdate.AttestedHeader.Slot {
+		return newUpdate.AttestedHeader.Slot < oldUpdate.AttestedHeader.Slot
+	}
+	return newUpdate.SignatureSlot < oldUpdate.SignatureSlot
+}
+
+// gindexDepthAndIndex splits a generalized index into the depth and the index of the node at that depth.
+func gindexDepthAndIndex(gindex tree.Gindex64) (depth uint64, index uint64) {
+	depth = uint64(bits.Len64(uint64(gindex))) - 1
+	return depth, uint64(gindex) ^ (1 << depth)
+}
+
+func isZeroBranch(branch []common.Root) bool {
+	for i := range branch {
+		if branch[i] != (common.Root{}) {
+			return false
+		}
+	}
+	return true
+}
+
+func isZeroSyncCommittee(c *common.SyncCommittee) bool {
+	if c.AggregatePubkey != (common.BLSPubkey{}) {
+		return false
+	}
+	for i := range c.Pubkeys {
+		if c.Pubkeys[i] != (common.BLSPubkey{}) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalSyncCommittees(a *common.SyncCommittee, b *common.SyncCommittee) bool {
+	if a.AggregatePubkey != b.AggregatePubkey || len(a.Pubkeys) != len(b.Pubkeys) {
+		return false
+	}
+	for i := range a.Pubkeys {
+		if a.Pubkeys[i] != b.Pubkeys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateLightClientUpdate checks the update against the store: the slots and period of the update,
+// the merkle branches of the finalized header and next sync committee, and the sync committee signature.
+func ValidateLightClientUpdate(spec *common.Spec, store *Store, update *altair.LightClientUpdate,
+	currentSlot common.Slot, genesisValidatorsRoot common.Root) error {
+	agg := &update.SyncAggregate
+	if err := bitfields.BitvectorCheck(agg.SyncCommitteeBits, spec.SYNC_COMMITTEE_SIZE); err != nil {
+		return fmt.Errorf("invalid sync committee bitvector: %v", err)
+	}
+	// Verify sync committee has sufficient participants
+	if Participants(spec, agg) < spec.MIN_SYNC_COMMITTEE_PARTICIPANTS {
+		return errors.New("insufficient sync committee participants")
+	}
+
+	// Verify update does not skip a sync committee period
+	if !(currentSlot >= update.SignatureSlot && update.SignatureSlot > update.AttestedHeader.Slot &&
+		update.AttestedHeader.Slot >= update.FinalizedHeader.Slot) {
+		return fmt.Errorf("invalid update slots: current %d, signature %d, attested %d, finalized %d",
+			currentSlot, update.SignatureSlot, update.AttestedHeader.Slot, update.FinalizedHeader.Slot)
+	}
+	storePeriod := SyncCommitteePeriod(spec, store.FinalizedHeader.Slot)
+	signaturePeriod := SyncCommitteePeriod(spec, update.SignatureSlot)
+	if store.IsNextSyncCommitteeKnown() {
+		if signaturePeriod != storePeriod && signaturePeriod != storePeriod+1 {
+			return fmt.Errorf("update signature period %d skips sync committee period, store period: %d", signaturePeriod, storePeriod)
+		}
+	} else if signaturePeriod != storePeriod {
+		return fmt.Errorf("update signature period %d is not the store period %d, and the next sync committee is unknown",
+			signaturePeriod, storePeriod)
+	}
+
+	// Verify update is relevant
+	attestedPeriod := SyncCommitteePeriod(spec, update.AttestedHeader.Slot)
+	hasNextSyncCommittee := !store.IsNextSyncCommitteeKnown() && IsSyncCommitteeUpdate(update) && attestedPeriod == storePeriod
+	if update.AttestedHeader.Slot <= store.FinalizedHeader.Slot && !hasNextSyncCommittee {
+		return fmt.Errorf("update attested slot %d is not newer than finalized header slot %d, and proves no next sync committee",
+			update.AttestedHeader.Slot, store.FinalizedHeader.Slot)
+	}
+
+	hFn := tree.GetHashFn()
+	// Verify that the finality branch, if present, confirms the finalized header
+	// to match the finalized checkpoint root saved in the state of the attested header.
+	// Note that the genesis finalized checkpoint root is represented as a zero hash.
+	if !IsFinalityUpdate(update) {
+		if update.FinalizedHeader != (common.BeaconBlockHeader{}) {
+			return errors.New("finalized header must be empty if there is no finality branch")
+		}
+	} else {
+		var finalizedRoot common.Root
+		if update.FinalizedHeader.Slot == common.GENESIS_SLOT {
+			if update.FinalizedHeader != (common.BeaconBlockHeader{}) {
+				return errors.New("genesis finalized header must be empty")
+			}
+		} else {
+			finalizedRoot = update.FinalizedHeader.HashTreeRoot(hFn)
+		}
+		depth, index := gindexDepthAndIndex(altair.FINALIZED_ROOT_INDEX)
+		if !merkle.VerifyMerkleBranch(finalizedRoot, update.FinalityBranch[:], depth, index, update.AttestedHeader.StateRoot) {
+			return errors.New("invalid finality branch")
+		}
+	}
+
+	// Verify that the next sync committee, if present, actually is the next sync committee
+	// saved in the state of the attested header.
+	if !IsSyncCommitteeUpdate(update) {
+		if !isZeroSyncCommittee(&update.NextSyncCommittee) {
+			return errors.New("next sync committee must be empty if there is no next sync committee branch")
+		}
+	} else {
+		if attestedPeriod == storePeriod && store.IsNextSyncCommitteeKnown() &&
+			!equalSyncCommittees(&update.NextSyncCommittee, &store.NextSyncCommittee) {
+			return errors.New("next sync committee does not match the known next sync committee")
+		}
+		depth, index := gindexDepthAndIndex(altair.NEXT_SYNC_COMMITTEE_INDEX)
+		if !merkle.VerifyMerkleBranch(update.NextSyncCommittee.HashTreeRoot(spec, hFn), update.NextSyncCommitteeBranch[:],
+			depth, index, update.AttestedHeader.StateRoot) {
+			return errors.New("invalid next sync committee branch")
+		}
+	}
+
+	// Verify sync committee aggregate signature
+	syncCommittee := &store.CurrentSyncCommittee
+	if signaturePeriod != storePeriod {
+		syncCommittee = &store.NextSyncCommittee
+	}
+	if uint64(len(syncCommittee.Pubkeys)) != spec.SYNC_COMMITTEE_SIZE {
+		return fmt.Errorf("sync committee has %d pubkeys, expected %d", len(syncCommittee.Pubkeys), spec.SYNC_COMMITTEE_SIZE)
+	}
+	participantPubkeys := make([]*blsu.Pubkey, 0, spec.SYNC_COMMITTEE_SIZE)
+	for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
+		if agg.SyncCommitteeBits.GetBit(i) {
+			pub, err := syncCommittee.Pubkeys[i].Pubkey()
+			if err != nil {
+				return fmt.Errorf("failed to decode sync committee pubkey %d: %v", i, err)
+			}
+			participantPubkeys = append(participantPubkeys, pub)
+		}
+	}
+	// The sync committee signs the block of the previous slot, with the fork version of that slot.
+	forkVersion := spec.ForkVersion(update.SignatureSlot.Previous())
+	domain := common.ComputeDomain(common.DOMAIN_SYNC_COMMITTEE, forkVersion, genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(update.AttestedHeader.HashTreeRoot(hFn), domain)
+	sig, err := agg.SyncCommitteeSignature.Signature()
+	if err != nil {
+		return fmt.Errorf("failed to decode sync committee signature: %v", err)
+	}
+	if !blsu.Eth2FastAggregateVerify(participantPubkeys, signingRoot[:], sig) {
+		return errors.New("invalid sync committee signature")
+	}
+	return nil
+}
+
+// ApplyLightClientUpdate moves the finalized header to the finalized header of the (validated) update,
+// learns the next sync committee, and rotates the sync committees if the update finalized the next period.
+func ApplyLightClientUpdate(spec *common.Spec, store *Store, update *altair.LightClientUpdate) error {
+	storePeriod := SyncCommitteePeriod(spec, store.FinalizedHeader.Slot)
+	finalizedPeriod := SyncCommitteePeriod(spec, update.FinalizedHeader.Slot)
+	if !store.IsNextSyncCommitteeKnown() {
+		if finalizedPeriod != storePeriod {
+			return fmt.Errorf("update finalized period %d is not the store period %d, and the next sync committee is unknown",
+				finalizedPeriod, storePeriod)
+		}
+		store.NextSyncCommittee = update.NextSyncCommittee
+	} else if finalizedPeriod == storePeriod+1 {
+		store.CurrentSyncCommittee = store.NextSyncCommittee
+		store.NextSyncCommittee = update.NextSyncCommittee
+		store.PreviousMaxActiveParticipants = store.CurrentMaxActiveParticipants
+		store.CurrentMaxActiveParticipants = 0
+	}
+	if update.FinalizedHeader.Slot > store.FinalizedHeader.Slot {
+		store.FinalizedHeader = update.FinalizedHeader
+		if store.FinalizedHeader.Slot > store.OptimisticHeader.Slot {
+			store.OptimisticHeader = store.FinalizedHeader
+		}
+	}
+	return nil
+}
+
+// ProcessLightClientStoreForceUpdate forces the best valid update when no finality update
+// has been applied for longer than the update timeout.
+func ProcessLightClientStoreForceUpdate(spec *common.Spec, store *Store, currentSlot common.Slot) error {
+	if currentSlot > store.FinalizedHeader.Slot+UpdateTimeout(spec) && store.BestValidUpdate != nil {
+		// Because the apply logic waits for the finalized header slot to indicate sync committee finality,
+		// the attested header may be treated as finalized header in extended periods of non-finality,
+		// to guarantee progression into later sync committee periods.
+		update := *store.BestValidUpdate
+		if update.FinalizedHeader.Slot <= store.FinalizedHeader.Slot {
+			update.FinalizedHeader = update.AttestedHeader
+		}
+		if err := ApplyLightClientUpdate(spec, store, &update); err != nil {
+			return err
+		}
+		store.BestValidUpdate = nil
+	}
+	return nil
+}
+
+// ProcessLightClientUpdate validates the update, and applies it if it has a 2/3 supermajority
+// and finalizes a newer header, or proves the next sync committee for the first time.
+// Other valid updates may move the optimistic header, and are remembered for a forced update after the timeout.
+func ProcessLightClientUpdate(spec *common.Spec, store *Store, update *altair.LightClientUpdate,
+	currentSlot common.Slot, genesisValidatorsRoot common.Root) error {
+	if err := ValidateLightClientUpdate(spec, store, update, currentSlot, genesisValidatorsRoot); err != nil {
+		return err
+	}
+	participants := Participants(spec, &update.SyncAggregate)
+
+	// Update the best update in case we have to force-update to it if the timeout elapses
+	if store.BestValidUpdate == nil || IsBetterUpdate(spec, update, store.BestValidUpdate) {
+		store.BestValidUpdate = update
+	}
+
+	// Track the maximum number of active participants in the committee signatures
+	if participants > store.CurrentMaxActiveParticipants {
+		store.CurrentMaxActiveParticipants = participants
+	}
+
+	// Update the optimistic header
+	if participants > store.SafetyThreshold() && update.AttestedHeader.Slot > store.OptimisticHeader.Slot {
+		store.OptimisticHeader = update.AttestedHeader
+	}
+
+	// Update finalized header through the 2/3 threshold
+	hasFinalizedNextSyncCommittee := !store.IsNextSyncCommitteeKnown() &&
+		IsSyncCommitteeUpdate(update) && IsFinalityUpdate(update) &&
+		SyncCommitteePeriod(spec, update.FinalizedHeader.Slot) == SyncCommitteePeriod(spec, update.AttestedHeader.Slot)
+	if participants*3 >= spec.SYNC_COMMITTEE_SIZE*2 &&
+		(update.FinalizedHeader.Slot > store.FinalizedHeader.Slot || hasFinalizedNextSyncCommittee) {
+		if err := ApplyLightClientUpdate(spec, store, update); err != nil {
+			return err
+		}
+		store.BestValidUpdate = nil
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+package lightclient
+
+import (
+	"bytes"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/hashing"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+type testCommittee struct {
+	keys      []*blsu.SecretKey
+	committee common.SyncCommittee
+}
+
+func newTestCommittee(t *testing.T, spec *common.Spec, seed byte) *testCommittee {
+	tc := &testCommittee{}
+	for i := uint64(0); i < spec.SYNC_COMMITTEE_SIZE; i++ {
+		var raw [32]byte
+		raw[30] = seed
+		raw[31] = byte(i + 1)
+		var sk blsu.SecretKey
+		if err := sk.Deserialize(&raw); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(&sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.keys = append(tc.keys, &sk)
+		tc.committee.Pubkeys = append(tc.committee.Pubkeys, pub.Serialize())
+	}
+	return tc
+}
+
+// sign creates a sync aggregate of the first n members of the committee over the header, as included at the signature slot.
+func (tc *testCommittee) sign(t *testing.T, spec *common.Spec, header *common.BeaconBlockHeader,
+	signatureSlot common.Slot, genesisValidatorsRoot common.Root, n uint64) altair.SyncAggregate {
+	forkVersion := spec.ForkVersion(signatureSlot.Previous())
+	domain := common.ComputeDomain(common.DOMAIN_SYNC_COMMITTEE, forkVersion, genesisValidatorsRoot)
+	signingRoot := common.ComputeSigningRoot(header.HashTreeRoot(tree.GetHashFn()), domain)
+	bits := make(altair.SyncCommitteeBits, (spec.SYNC_COMMITTEE_SIZE+7)/8)
+	var sigs []*blsu.Signature
+	for i := uint64(0); i < n; i++ {
+		bits.SetBit(i, true)
+		sigs = append(sigs, blsu.Sign(tc.keys[i], signingRoot[:]))
+	}
+	sig, err := blsu.Aggregate(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return altair.SyncAggregate{SyncCommitteeBits: bits, SyncCommitteeSignature: sig.Serialize()}
+}
+
+// rootFromBranch computes the root that the branch proves the leaf against, the inverse of VerifyMerkleBranch.
+func rootFromBranch(leaf common.Root, branch []common.Root, gindex tree.Gindex64) common.Root {
+	_, index := gindexDepthAndIndex(gindex)
+	value := leaf
+	for i := range branch {
+		if (index>>uint(i))&1 == 1 {
+			value = hashing.Hash(append(branch[i][:], value[:]...))
+		} else {
+			value = hashing.Hash(append(value[:], branch[i][:]...))
+		}
+	}
+	return value
+}
+
+// signedUpdate creates an update of the attested header, signed by the first n members of the committee.
+func signedUpdate(t *testing.T, spec *common.Spec, tc *testCommittee, attested common.BeaconBlockHeader,
+	signatureSlot common.Slot, gvr common.Root, n uint64) *altair.LightClientUpdate {
+	u := &altair.LightClientUpdate{AttestedHeader: attested, SignatureSlot: signatureSlot}
+	u.SyncAggregate = tc.sign(t, spec, &u.AttestedHeader, signatureSlot, gvr, n)
+	return u
+}
+
+func TestInitializeLightClientStore(t *testing.T) {
+	spec := configs.Minimal
+	current := newTestCommittee(t, spec, 1)
+	branch := altair.SyncCommitteeProofBranch{{0x11}, {0x22}, {0x33}, {0x44}, {0x55}}
+	bootstrap := &altair.LightClientBootstrap{
+		Header: common.BeaconBlockHeader{Slot: 8, StateRoot: rootFromBranch(
+			current.committee.HashTreeRoot(spec, tree.GetHashFn()), branch[:], altair.CURRENT_SYNC_COMMITTEE_INDEX)},
+		CurrentSyncCommittee:       current.committee,
+		CurrentSyncCommitteeBranch: branch,
+	}
+	trusted := bootstrap.Header.HashTreeRoot(tree.GetHashFn())
+	if _, err := InitializeLightClientStore(spec, common.Root{0x01}, bootstrap); err == nil {
+		t.Fatal("expected bootstrap of untrusted block to be rejected")
+	}
+	bad := *bootstrap
+	bad.CurrentSyncCommitteeBranch[0] = common.Root{0x01}
+	if _, err := InitializeLightClientStore(spec, trusted, &bad); err == nil {
+		t.Fatal("expected invalid current sync committee branch to be rejected")
+	}
+	store, err := InitializeLightClientStore(spec, trusted, bootstrap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader != bootstrap.Header || store.OptimisticHeader != bootstrap.Header || store.IsNextSyncCommitteeKnown() {
+		t.Fatal("unexpected store after bootstrap")
+	}
+}
+
+func TestProcessLightClientUpdate(t *testing.T) {
+	spec := configs.Minimal
+	gvr := common.Root{0x12}
+	current := newTestCommittee(t, spec, 1)
+	next := newTestCommittee(t, spec, 2)
+	store := &Store{
+		FinalizedHeader:      common.BeaconBlockHeader{Slot: 8, StateRoot: common.Root{0x01}},
+		CurrentSyncCommittee: current.committee,
+		OptimisticHeader:     common.BeaconBlockHeader{Slot: 8, StateRoot: common.Root{0x01}},
+	}
+
+	// An update without finality, and without 2/3 participation, only moves the optimistic header.
+	optimistic := signedUpdate(t, spec, current, common.BeaconBlockHeader{Slot: 10, StateRoot: common.Root{0x02}}, 11, gvr, 20)
+	if err := ProcessLightClientUpdate(spec, store, optimistic, 12, gvr); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader.Slot != 8 || store.OptimisticHeader.Slot != 10 || store.BestValidUpdate != optimistic {
+		t.Fatalf("unexpected store after optimistic update: finalized %d, optimistic %d",
+			store.FinalizedHeader.Slot, store.OptimisticHeader.Slot)
+	}
+	if store.CurrentMaxActiveParticipants != 20 {
+		t.Fatalf("expected 20 max active participants, got %d", store.CurrentMaxActiveParticipants)
+	}
+
+	// Updates from the future, signed by the wrong committee, or of the next period, are rejected.
+	if err := ProcessLightClientUpdate(spec, store, optimistic, 10, gvr); err == nil {
+		t.Fatal("expected update from the future to be rejected")
+	}
+	forged := signedUpdate(t, spec, next, common.BeaconBlockHeader{Slot: 11}, 12, gvr, 20)
+	if err := ProcessLightClientUpdate(spec, store, forged, 12, gvr); err == nil {
+		t.Fatal("expected update signed by the wrong committee to be rejected")
+	}
+	nextPeriod := signedUpdate(t, spec, next, common.BeaconBlockHeader{Slot: 70}, 71, gvr, 20)
+	if err := ProcessLightClientUpdate(spec, store, nextPeriod, 72, gvr); err == nil {
+		t.Fatal("expected update of the next period to be rejected while the next sync committee is unknown")
+	}
+
+	// An update that proves the next sync committee, but not its finality, is only applied after the timeout.
+	committeeBranch := altair.SyncCommitteeProofBranch{{0x11}, {0x22}, {0x33}, {0x44}, {0x55}}
+	committeeUpdate := signedUpdate(t, spec, current, common.BeaconBlockHeader{Slot: 12, StateRoot: rootFromBranch(
+		next.committee.HashTreeRoot(spec, tree.GetHashFn()), committeeBranch[:], altair.NEXT_SYNC_COMMITTEE_INDEX)}, 13, gvr, 30)
+	committeeUpdate.NextSyncCommittee = next.committee
+	committeeUpdate.NextSyncCommitteeBranch = committeeBranch
+	if err := ProcessLightClientUpdate(spec, store, committeeUpdate, 13, gvr); err != nil {
+		t.Fatal(err)
+	}
+	if store.IsNextSyncCommitteeKnown() || store.BestValidUpdate != committeeUpdate {
+		t.Fatal("expected update with next sync committee to be the best valid update")
+	}
+	if err := ProcessLightClientStoreForceUpdate(spec, store, 8+UpdateTimeout(spec)+1); err != nil {
+		t.Fatal(err)
+	}
+	if !store.IsNextSyncCommitteeKnown() || store.FinalizedHeader != committeeUpdate.AttestedHeader || store.BestValidUpdate != nil {
+		t.Fatal("expected forced update to apply the next sync committee")
+	}
+
+	// A finality update with 2/3 participation moves the finalized header.
+	finalizedHeader := common.BeaconBlockHeader{Slot: 16, StateRoot: common.Root{0x03}}
+	finalityBranch := altair.FinalizedRootProofBranch{{0xaa}, {0xbb}, {0xcc}, {0xdd}, {0xee}, {0xff}}
+	finality := signedUpdate(t, spec, current, common.BeaconBlockHeader{Slot: 24, StateRoot: rootFromBranch(
+		finalizedHeader.HashTreeRoot(tree.GetHashFn()), finalityBranch[:], altair.FINALIZED_ROOT_INDEX)}, 25, gvr, 22)
+	finality.FinalizedHeader = finalizedHeader
+	finality.FinalityBranch = finalityBranch
+	bad := *finality
+	bad.FinalityBranch[0] = common.Root{0x01}
+	if err := ProcessLightClientUpdate(spec, store, &bad, 25, gvr); err == nil {
+		t.Fatal("expected invalid finality branch to be rejected")
+	}
+	if err := ProcessLightClientUpdate(spec, store, finality, 25, gvr); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader != finalizedHeader || store.OptimisticHeader != finality.AttestedHeader || store.BestValidUpdate != nil {
+		t.Fatal("expected finality update to be applied")
+	}
+
+	// A finality update of the next period is signed by the next sync committee, and rotates the committees.
+	nextFinalized := common.BeaconBlockHeader{Slot: 66, StateRoot: common.Root{0x04}}
+	rotation := signedUpdate(t, spec, next, common.BeaconBlockHeader{Slot: 68, StateRoot: rootFromBranch(
+		nextFinalized.HashTreeRoot(tree.GetHashFn()), finalityBranch[:], altair.FINALIZED_ROOT_INDEX)}, 69, gvr, 32)
+	rotation.FinalizedHeader = nextFinalized
+	rotation.FinalityBranch = finalityBranch
+	if err := ProcessLightClientUpdate(spec, store, rotation, 70, gvr); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader != nextFinalized {
+		t.Fatal("expected rotation update to be applied")
+	}
+	if store.CurrentSyncCommittee.Pubkeys[0] != next.committee.Pubkeys[0] || store.IsNextSyncCommitteeKnown() {
+		t.Fatal("expected sync committees to rotate")
+	}
+	if store.PreviousMaxActiveParticipants != 32 || store.CurrentMaxActiveParticipants != 0 {
+		t.Fatalf("expected participation to be tracked per period, got previous %d, current %d",
+			store.PreviousMaxActiveParticipants, store.CurrentMaxActiveParticipants)
+	}
+}
+
+func TestForcedUpdate(t *testing.T) {
+	spec := configs.Minimal
+	gvr := common.Root{0x12}
+	current := newTestCommittee(t, spec, 1)
+	store := &Store{
+		FinalizedHeader:      common.BeaconBlockHeader{Slot: 8},
+		CurrentSyncCommittee: current.committee,
+		NextSyncCommittee:    current.committee,
+		OptimisticHeader:     common.BeaconBlockHeader{Slot: 8},
+	}
+	var updates []*altair.LightClientUpdate
+	for i, n := range []uint64{5, 12, 8} {
+		u := signedUpdate(t, spec, current, common.BeaconBlockHeader{Slot: common.Slot(10 + i)}, common.Slot(11+i), gvr, n)
+		if err := ProcessLightClientUpdate(spec, store, u, 20, gvr); err != nil {
+			t.Fatal(err)
+		}
+		updates = append(updates, u)
+	}
+	if store.BestValidUpdate != updates[1] {
+		t.Fatal("expected update with most participants to be the best valid update")
+	}
+
+	timeout := UpdateTimeout(spec)
+	if err := ProcessLightClientStoreForceUpdate(spec, store, 8+timeout); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader.Slot != 8 {
+		t.Fatal("expected no forced update before the timeout")
+	}
+	if err := ProcessLightClientStoreForceUpdate(spec, store, 8+timeout+1); err != nil {
+		t.Fatal(err)
+	}
+	if store.FinalizedHeader != updates[1].AttestedHeader || store.BestValidUpdate != nil {
+		t.Fatal("expected best valid update to be forced after the timeout")
+	}
+}
+
+func TestIsBetterUpdate(t *testing.T) {
+	spec := configs.Minimal
+	update := func(attested common.Slot, signature common.Slot, participants uint64, syncCommittee bool, finalized common.Slot) *altair.LightClientUpdate {
+		u := &altair.LightClientUpdate{AttestedHeader: common.BeaconBlockHeader{Slot: attested}, SignatureSlot: signature}
+		u.SyncAggregate.SyncCommitteeBits = make(altair.SyncCommitteeBits, (spec.SYNC_COMMITTEE_SIZE+7)/8)
+		for i := uint64(0); i < participants; i++ {
+			u.SyncAggregate.SyncCommitteeBits.SetBit(i, true)
+		}
+		if syncCommittee {
+			u.NextSyncCommitteeBranch[0] = common.Root{0x01}
+		}
+		if finalized != 0 {
+			u.FinalizedHeader.Slot = finalized
+			u.FinalityBranch[0] = common.Root{0x01}
+		}
+		return u
+	}
+	// Sorted from best to worst
+	ranked := []*altair.LightClientUpdate{
+		// supermajority, with relevant sync committee, and sync committee finality
+		update(20, 21, 22, true, 16),
+		// same, with more participants, but finality of the previous period
+		update(70, 71, 32, true, 16),
+		// supermajority with relevant sync committee, without finality
+		update(20, 21, 32, true, 0),
+		// sync committee signed in the next period is not relevant
+		update(60, 65, 32, true, 16),
+		// without sync committee, more participants wins
+		update(20, 21, 31, false, 16),
+		update(20, 21, 30, false, 16),
+		// older data wins
+		update(21, 22, 30, false, 16),
+		update(21, 23, 30, false, 16),
+		// no supermajority, more participants wins, regardless of finality
+		update(20, 21, 21, false, 0),
+		update(20, 21, 20, true, 16),
+	}
+	for i := range ranked {
+		for j := range ranked {
+			if got := IsBetterUpdate(spec, ranked[i], ranked[j]); got != (i < j) {
+				t.Errorf("update %d better than update %d: %v", i, j, got)
+			}
+		}
+	}
+}
+
+// Since v1.3.0 the spec wraps the beacon header of the light client containers in a LightClientHeader,
+// a container with the beacon header as only field, which has the same encoding and hash-tree-root.
+func TestLightClientHeaderLayout(t *testing.T) {
+	spec := configs.Minimal
+	hFn := tree.GetHashFn()
+	headerType := view.ContainerType("LightClientHeader", []view.FieldDef{
+		{"beacon", common.BeaconBlockHeaderType},
+	})
+	header := common.BeaconBlockHeader{Slot: 42, ProposerIndex: 3, ParentRoot: common.Root{1}, StateRoot: common.Root{2}, BodyRoot: common.Root{3}}
+	wrapped, err := headerType.FromFields(header.View())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrapped.HashTreeRoot(hFn) != header.HashTreeRoot(hFn) {
+		t.Fatal("expected light client header to have the root of the beacon header")
+	}
+	var wrappedData, headerData bytes.Buffer
+	if err := wrapped.Serialize(codec.NewEncodingWriter(&wrappedData)); err != nil {
+		t.Fatal(err)
+	}
+	if err := header.Serialize(codec.NewEncodingWriter(&headerData)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(wrappedData.Bytes(), headerData.Bytes()) {
+		t.Fatal("expected light client header to have the encoding of the beacon header")
+	}
+
+	for _, c := range []struct {
+		name     string
+		expected *view.ContainerTypeDef
+		actual   *view.ContainerTypeDef
+	}{
+		{"LightClientBootstrap", view.ContainerType("LightClientBootstrap", []view.FieldDef{
+			{"header", headerType},
+			{"current_sync_committee", common.SyncCommitteeType(spec)},
+			{"current_sync_committee_branch", altair.SyncCommitteeProofBranchType},
+		}), altair.LightClientBootstrapType(spec)},
+		{"LightClientUpdate", view.ContainerType("LightClientUpdate", []view.FieldDef{
+			{"attested_header", headerType},
+			{"next_sync_committee", common.SyncCommitteeType(spec)},
+			{"next_sync_committee_branch", altair.SyncCommitteeProofBranchType},
+			{"finalized_header", headerType},
+			{"finality_branch", altair.FinalizedRootProofBranchType},
+			{"sync_aggregate", altair.SyncAggregateType(spec)},
+			{"signature_slot", common.SlotType},
+		}), altair.LightClientUpdateType(spec)},
+	} {
+		if c.expected.TypeByteLength() != c.actual.TypeByteLength() {
+			t.Fatalf("%s: expected byte length %d, got %d", c.name, c.expected.TypeByteLength(), c.actual.TypeByteLength())
+		}
+		if c.expected.DefaultNode().MerkleRoot(hFn) != c.actual.DefaultNode().MerkleRoot(hFn) {
+			t.Fatalf("%s: default value has a different root", c.name)
+		}
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/util/math"
 	"github.com/protolambda/ztyp/tree"
 )
 
@@ -64,6 +68,7 @@ func NewAttestationPool(spec *common.Spec) *AttestationPool {
 		datas:              make(map[common.Root]*IndexedAttData),
 		individual:         make(map[Assignment]*AttRef),
 		aggregate:          make(map[common.Root]*MinAggregates),
+		aggPerValidator:    make(map[Assignment]common.Root),
 		maxExtraAggregates: 10, // TODO: worth tuning
 	}
 }
@@ -125,6 +130,7 @@ func (ap *AttestationPool) AddAttestation(ctx context.Context, att *phase0.Attes
 			// this aggregate adds additional participants compared to the total we had before, keep it!
 			existing.Aggregates = append(existing.Aggregates,
 				Aggregate{Participants: att.AggregationBits, Sig: att.Signature})
+			existing.Participants.Or(att.AggregationBits)
 
 			// remember the participants attested this epoch
 			key := Assignment{Index: 0, Epoch: att.Data.Target.Epoch}
@@ -182,7 +188,10 @@ func WithCommittee(index common.CommitteeIndex) AttSearchOption {
 	}
 }
 
+// Search lists the aggregate and the individual attestations in the pool that match the search options.
 func (ap *AttestationPool) Search(opts ...AttSearchOption) (out []*phase0.Attestation) {
+	ap.RLock()
+	defer ap.RUnlock()
 	var conf attSearch
 	for _, opt := range opts {
 		opt(&conf)
@@ -194,11 +203,22 @@ func (ap *AttestationPool) Search(opts ...AttSearchOption) (out []*phase0.Attest
 		if conf.comm != nil && d.Data.Index != *conf.comm {
 			continue
 		}
-		agg := ap.aggregate[k]
-		for _, a := range agg.Aggregates {
-			out = append(out, &phase0.Attestation{AggregationBits: a.Participants, Data: d.Data, Signature: a.Sig})
+		if agg, ok := ap.aggregate[k]; ok {
+			for _, a := range agg.Aggregates {
+				out = append(out, &phase0.Attestation{AggregationBits: a.Participants, Data: d.Data, Signature: a.Sig})
+			}
+		}
+		for i, vi := range d.Committee {
+			ref, ok := ap.individual[Assignment{Index: vi, Epoch: d.Data.Target.Epoch}]
+			if !ok || ref.DataRoot != k {
+				continue
+			}
+			bits := make(phase0.AttestationBits, (len(d.Committee)>>3)+1)
+			// delimiter bit
+			bits.SetBit(uint64(len(d.Committee)), true)
+			bits.SetBit(uint64(i), true)
+			out = append(out, &phase0.Attestation{AggregationBits: bits, Data: d.Data, Signature: ref.Sig})
 		}
-		// TODO: could add individual attestations
 	}
 	return out
 }
@@ -224,16 +244,280 @@ func (ap *AttestationPool) Prune(epoch common.Epoch) {
 	}
 }
 
-// Approximation of the optimal attestation packing.
-// Attestations must match source, get prioritized if the target is correct, and more if the head is correct.
+// packCandidate is an attestation that may be packed, with the participants it covers.
+type packCandidate struct {
+	att          phase0.Attestation
+	participants []Assignment
+	// reward weight of every participant that is newly covered by the attestation
+	weight common.Gwei
+}
+
+// packingCandidates collects all aggregates and individual attestations that match the source,
+// and weights them by target and head correctness. Participants that are already included are left out.
+// The aggregates and individual attestations of the same data that do not overlap are also merged into a single candidate.
+// The pool lock must be held by the caller.
+func (ap *AttestationPool) packingCandidates(slot common.Slot, source common.Checkpoint, target common.Checkpoint,
+	blockRootAt func(slot common.Slot) (common.Root, error),
+	included func(epoch common.Epoch, index common.ValidatorIndex) bool) []*packCandidate {
+
+	var out []*packCandidate
+	add := func(d *IndexedAttData, weight common.Gwei, bits phase0.AttestationBits, sig common.BLSSignature) {
+		if bits.BitLen() != uint64(len(d.Committee)) {
+			return
+		}
+		epoch := d.Data.Target.Epoch
+		var participants []Assignment
+		for i, vi := range d.Committee {
+			if !bits.GetBit(uint64(i)) || (included != nil && included(epoch, vi)) {
+				continue
+			}
+			participants = append(participants, Assignment{Index: vi, Epoch: epoch})
+		}
+		if len(participants) == 0 {
+			return
+		}
+		out = append(out, &packCandidate{
+			att:          phase0.Attestation{AggregationBits: bits, Data: d.Data, Signature: sig},
+			participants: participants,
+			weight:       weight,
+		})
+	}
+	datas := make(map[common.Root]common.Gwei, len(ap.datas))
+	merged := make(map[common.Root]phase0.AttestationBits)
+	for k, d := range ap.datas {
+		if d.Data.Source != source {
+			continue
+		}
+		weight := ap.timelyWeight(slot, &d.Data, target, blockRootAt)
+		if weight == 0 {
+			continue
+		}
+		datas[k] = weight
+		if agg, ok := ap.aggregate[k]; ok {
+			for _, a := range agg.Aggregates {
+				add(d, weight, a.Participants, a.Sig)
+			}
+			for _, a := range agg.Extra {
+				add(d, weight, a.Participants, a.Sig)
+			}
+		}
+		// A merge of a single attestation is already a candidate by itself
+		if bits, sigs := ap.mergeAggregate(k, d); len(sigs) > 1 {
+			if agg, err := blsu.Aggregate(sigs); err == nil {
+				add(d, weight, bits, agg.Serialize())
+				merged[k] = bits
+			}
+		}
+	}
+	for key, ref := range ap.individual {
+		weight, ok := datas[ref.DataRoot]
+		if !ok {
+			continue
+		}
+		d := ap.datas[ref.DataRoot]
+		bits := make(phase0.AttestationBits, (len(d.Committee)>>3)+1)
+		mergedBits, covered := merged[ref.DataRoot]
+		for i, vi := range d.Committee {
+			if vi == key.Index {
+				bits.SetBit(uint64(i), true)
+				covered = covered && mergedBits.GetBit(uint64(i))
+				break
+			}
+		}
+		// Individual attestations that were merged are not worth including on their own
+		if covered {
+			continue
+		}
+		// delimiter bit
+		bits.SetBit(uint64(len(d.Committee)), true)
+		add(d, weight, bits, ref.Sig)
+	}
+	return out
+}
+
+// timelyWeight computes the weight of the participation flags that an attestation with a matching source earns
+// when included at the given slot, like get_attestation_participation_flag_indices.
+// The head only counts at the minimum inclusion delay, and the source only if the delay is small enough.
+// Zero is returned if the attestation cannot be included at the slot.
+func (ap *AttestationPool) timelyWeight(slot common.Slot, data *phase0.AttestationData, target common.Checkpoint,
+	blockRootAt func(slot common.Slot) (common.Root, error)) (weight common.Gwei) {
+	if slot < data.Slot+ap.spec.MIN_ATTESTATION_INCLUSION_DELAY || slot > data.Slot+ap.spec.SLOTS_PER_EPOCH {
+		return 0
+	}
+	inclusionDelay := slot - data.Slot
+	if inclusionDelay <= common.Slot(math.IntegerSquareroot(uint64(ap.spec.SLOTS_PER_EPOCH))) {
+		weight += altair.TIMELY_SOURCE_WEIGHT
+	}
+	if data.Target == target {
+		weight += altair.TIMELY_TARGET_WEIGHT
+		// The head is correct if it matches the canonical block at the slot of the attestation
+		if inclusionDelay == ap.spec.MIN_ATTESTATION_INCLUSION_DELAY {
+			if root, err := blockRootAt(data.Slot); err == nil && data.BeaconBlockRoot == root {
+				weight += altair.TIMELY_HEAD_WEIGHT
+			}
+		}
+	}
+	return weight
+}
+
+// packGreedy picks candidates one by one, each time the one that adds the most weight for participants
+// that were not covered yet, or only covered by a lower-weight attestation. Selection stops early if nothing is gained anymore or if the deadline passes.
+// The indices of the selected candidates are returned, in order of selection.
+func packGreedy(ctx context.Context, candidates []*packCandidate, maxCount uint64, deadline time.Time) ([]int, error) {
+	// participant -> best weight covered so far
+	covered := make(map[Assignment]common.Gwei)
+	picked := make([]bool, len(candidates))
+	var out []int
+	for uint64(len(out)) < maxCount {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+		best := -1
+		var bestGain common.Gwei
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+			gain := common.Gwei(0)
+			for _, p := range c.participants {
+				if w := covered[p]; w < c.weight {
+					gain += c.weight - w
+				}
+			}
+			if gain > bestGain {
+				best = i
+				bestGain = gain
+			}
+		}
+		if best < 0 {
+			break
+		}
+		picked[best] = true
+		c := candidates[best]
+		for _, p := range c.participants {
+			if covered[p] < c.weight {
+				covered[p] = c.weight
+			}
+		}
+		out = append(out, best)
+	}
+	return out, nil
+}
+
+// Approximation of the optimal attestation packing, for a block at the given slot.
+// Attestations must match source, and are weighted by the participation flags they earn when included at the slot:
+// a correct target always counts, the source only for short inclusion delays, and a correct head only at the minimum delay.
+// The head is correct if it matches the canonical block root at the slot of the attestation, as returned by blockRootAt.
+// Attestations that cannot be included at the slot, or that earn nothing, are not packed.
 // Attestations may not be included if they already are (checked via included func).
 // Maximum attestation output and packing-time constraints apply.
-func (ap *AttestationPool) Packing(ctx context.Context,
+// Packing is greedy: every next attestation is the one that covers the most weight of new attesters.
+// If the time runs out, the attestations that were selected so far are returned.
+func (ap *AttestationPool) Packing(ctx context.Context, slot common.Slot,
 	source common.Checkpoint, target common.Checkpoint,
-	headRoot common.Root, headSlot common.Slot,
+	blockRootAt func(slot common.Slot) (common.Root, error),
 	maxCount uint64, maxTime time.Duration,
-	included func(epoch common.Epoch, index common.ValidatorIndex)) ([]phase0.Attestation, error) {
+	included func(epoch common.Epoch, index common.ValidatorIndex) bool) ([]phase0.Attestation, error) {
+
+	var deadline time.Time
+	if maxTime > 0 {
+		deadline = time.Now().Add(maxTime)
+	}
+
+	ap.RLock()
+	defer ap.RUnlock()
+
+	candidates := ap.packingCandidates(slot, source, target, blockRootAt, included)
+	picked, err := packGreedy(ctx, candidates, maxCount, deadline)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]phase0.Attestation, 0, len(picked))
+	for _, i := range picked {
+		out = append(out, candidates[i].att)
+	}
+	return out, nil
+}
+
+// PackAggregate aggregates the best attestation for the given attestation data root,
+// for an aggregator to publish. Aggregates that do not overlap are combined, starting with the largest,
+// and the remaining gaps are filled with the individual attestations of the committee members.
+func (ap *AttestationPool) PackAggregate(ctx context.Context, dataRoot common.Root) (*phase0.Attestation, error) {
+	ap.RLock()
+	defer ap.RUnlock()
 
-	// TODO find best attestations to pack for profit.
-	return nil, nil
+	d, ok := ap.datas[dataRoot]
+	if !ok {
+		return nil, fmt.Errorf("no attestations available for data root %s", dataRoot)
+	}
+	bits, sigs := ap.mergeAggregate(dataRoot, d)
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("no valid attestations available for data root %s", dataRoot)
+	}
+	agg, err := blsu.Aggregate(sigs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate signatures: %v", err)
+	}
+	return &phase0.Attestation{AggregationBits: bits, Data: d.Data, Signature: agg.Serialize()}, nil
+}
+
+// mergeAggregate combines the aggregates of the given attestation data that do not overlap, starting with the largest,
+// and fills the remaining gaps with the individual attestations of the committee members.
+// The participants and the signatures to aggregate are returned. Attestations with invalid signatures are skipped.
+// The pool lock must be held by the caller.
+func (ap *AttestationPool) mergeAggregate(dataRoot common.Root, d *IndexedAttData) (phase0.AttestationBits, []*blsu.Signature) {
+	bits := make(phase0.AttestationBits, (len(d.Committee)>>3)+1)
+	// delimiter bit
+	bits.SetBit(uint64(len(d.Committee)), true)
+	var sigs []*blsu.Signature
+
+	if agg, ok := ap.aggregate[dataRoot]; ok {
+		sorted := make([]Aggregate, 0, len(agg.Aggregates)+len(agg.Extra))
+		sorted = append(sorted, agg.Aggregates...)
+		sorted = append(sorted, agg.Extra...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Participants.OnesCount() > sorted[j].Participants.OnesCount()
+		})
+		for _, a := range sorted {
+			if a.Participants.BitLen() != bits.BitLen() {
+				continue
+			}
+			overlap := false
+			for i := 0; i < len(d.Committee); i++ {
+				if bits.GetBit(uint64(i)) && a.Participants.GetBit(uint64(i)) {
+					overlap = true
+					break
+				}
+			}
+			if overlap {
+				continue
+			}
+			sig, err := a.Sig.Signature()
+			if err != nil {
+				continue
+			}
+			bits.Or(a.Participants)
+			sigs = append(sigs, sig)
+		}
+	}
+	epoch := d.Data.Target.Epoch
+	for i, vi := range d.Committee {
+		if bits.GetBit(uint64(i)) {
+			continue
+		}
+		ref, ok := ap.individual[Assignment{Index: vi, Epoch: epoch}]
+		if !ok || ref.DataRoot != dataRoot {
+			continue
+		}
+		sig, err := ref.Sig.Signature()
+		if err != nil {
+			continue
+		}
+		bits.SetBit(uint64(i), true)
+		sigs = append(sigs, sig)
+	}
+	return bits, sigs
 }
@@ -0,0 +1,415 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+var (
+	testSource = common.Checkpoint{Epoch: 1, Root: common.Root{0x01}}
+	testTarget = common.Checkpoint{Epoch: 2, Root: common.Root{0x02}}
+	testHead   = common.Root{0x03}
+)
+
+const testHeadSlot = common.Slot(17)
+
+// testInclusionSlot is the slot of the block the test attestations are packed into, the minimum inclusion delay after the head.
+const testInclusionSlot = testHeadSlot + 1
+
+// testCanonical is the canonical chain the test attestations are packed for, the head is the last block.
+var testCanonical = map[common.Slot]common.Root{
+	testHeadSlot - 1: {0x04},
+	testHeadSlot:     testHead,
+}
+
+func testBlockRootAt(slot common.Slot) (common.Root, error) {
+	root, ok := testCanonical[slot]
+	if !ok {
+		return common.Root{}, fmt.Errorf("no canonical block root at slot %d", slot)
+	}
+	return root, nil
+}
+
+func testBits(size int, participants ...int) phase0.AttestationBits {
+	bits := make(phase0.AttestationBits, (size>>3)+1)
+	for _, p := range participants {
+		bits.SetBit(uint64(p), true)
+	}
+	bits.SetBit(uint64(size), true)
+	return bits
+}
+
+func TestAddAggregates(t *testing.T) {
+	ap := NewAttestationPool(configs.Minimal)
+	committee := common.CommitteeIndices{10, 11, 12, 13}
+	data := phase0.AttestationData{Slot: 17, Target: common.Checkpoint{Epoch: 2}}
+	add := func(participants ...int) error {
+		att := &phase0.Attestation{AggregationBits: testBits(len(committee), participants...), Data: data}
+		return ap.AddAttestation(context.Background(), att, committee)
+	}
+	if err := add(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := add(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	// covered by the previous two aggregates together, kept as extra
+	if err := add(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	agg := ap.aggregate[data.HashTreeRoot(tree.GetHashFn())]
+	if len(agg.Aggregates) != 2 || len(agg.Extra) != 1 {
+		t.Fatalf("expected 2 aggregates and 1 extra, got %d and %d", len(agg.Aggregates), len(agg.Extra))
+	}
+	for i := range committee {
+		if agg.Participants.GetBit(uint64(i)) != (i < 3) {
+			t.Fatalf("unexpected participation of committee member %d", i)
+		}
+	}
+}
+
+// randomPool fills a pool with attestations of a few different datas, with overlapping committees,
+// some correct, some with a wrong target or head, and some with a different source.
+func randomPool(rng *rand.Rand) *AttestationPool {
+	ap := NewAttestationPool(configs.Minimal)
+	for d := 0; d < 4; d++ {
+		data := phase0.AttestationData{
+			Slot:            testHeadSlot,
+			Index:           common.CommitteeIndex(d),
+			BeaconBlockRoot: testHead,
+			Source:          testSource,
+			Target:          testTarget,
+		}
+		switch rng.Intn(4) {
+		case 0:
+			data.BeaconBlockRoot = common.Root{0xff}
+		case 1:
+			data.Target.Root = common.Root{0xff}
+		case 2:
+			if rng.Intn(2) == 0 {
+				data.Source.Root = common.Root{0xff}
+			}
+		}
+		size := 4 + rng.Intn(4)
+		committee := make(common.CommitteeIndices, size)
+		for i, vi := range rng.Perm(12)[:size] {
+			committee[i] = common.ValidatorIndex(vi)
+		}
+		for a := 0; a < 1+rng.Intn(4); a++ {
+			var participants []int
+			for i := 0; i < size; i++ {
+				if rng.Intn(2) == 0 {
+					participants = append(participants, i)
+				}
+			}
+			if len(participants) == 0 {
+				continue
+			}
+			att := &phase0.Attestation{AggregationBits: testBits(size, participants...), Data: data}
+			// double votes and useless aggregates are expected to be rejected, that is fine.
+			_ = ap.AddAttestation(context.Background(), att, committee)
+		}
+	}
+	return ap
+}
+
+func packValue(candidates []*packCandidate, picked []int) (out common.Gwei) {
+	covered := make(map[Assignment]common.Gwei)
+	for _, i := range picked {
+		c := candidates[i]
+		for _, p := range c.participants {
+			if covered[p] < c.weight {
+				covered[p] = c.weight
+			}
+		}
+	}
+	for _, w := range covered {
+		out += w
+	}
+	return out
+}
+
+func bruteForcePacking(candidates []*packCandidate, maxCount uint64) (best common.Gwei) {
+	n := uint(len(candidates))
+	for subset := uint64(0); subset < 1<<n; subset++ {
+		var picked []int
+		for i := uint(0); i < n; i++ {
+			if subset&(1<<i) != 0 {
+				picked = append(picked, int(i))
+			}
+		}
+		if uint64(len(picked)) > maxCount {
+			continue
+		}
+		if v := packValue(candidates, picked); v > best {
+			best = v
+		}
+	}
+	return best
+}
+
+func TestPackGreedyVsBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1234))
+	for i := 0; i < 200; i++ {
+		ap := randomPool(rng)
+		candidates := ap.packingCandidates(testInclusionSlot, testSource, testTarget, testBlockRootAt, nil)
+		if len(candidates) > 14 {
+			candidates = candidates[:14]
+		}
+		maxCount := uint64(1 + rng.Intn(4))
+		t.Run(fmt.Sprintf("case_%d", i), func(t *testing.T) {
+			picked, err := packGreedy(context.Background(), candidates, maxCount, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(picked)) > maxCount {
+				t.Fatalf("picked %d attestations, max is %d", len(picked), maxCount)
+			}
+			got := packValue(candidates, picked)
+			opt := bruteForcePacking(candidates, maxCount)
+			if got > opt {
+				t.Fatalf("greedy packing %d is better than the optimum %d", got, opt)
+			}
+			// greedy max-coverage is guaranteed to be within (1 - 1/e) of the optimum
+			if float64(got) < float64(opt)*(1-1/2.718281828) {
+				t.Fatalf("greedy packing %d is too far from the optimum %d", got, opt)
+			}
+		})
+	}
+}
+
+func TestPackGreedyDisjointIsOptimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	for i := 0; i < 50; i++ {
+		var candidates []*packCandidate
+		next := common.ValidatorIndex(0)
+		for c := 0; c < 2+rng.Intn(8); c++ {
+			cand := &packCandidate{weight: common.Gwei(14 + 26*rng.Intn(2) + 14*rng.Intn(2))}
+			for p := 0; p < 1+rng.Intn(6); p++ {
+				cand.participants = append(cand.participants, Assignment{Index: next, Epoch: 2})
+				next++
+			}
+			candidates = append(candidates, cand)
+		}
+		maxCount := uint64(1 + rng.Intn(len(candidates)))
+		picked, err := packGreedy(context.Background(), candidates, maxCount, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, opt := packValue(candidates, picked), bruteForcePacking(candidates, maxCount); got != opt {
+			t.Fatalf("case %d: greedy packing of disjoint attestations %d is not optimal %d", i, got, opt)
+		}
+	}
+}
+
+func TestPacking(t *testing.T) {
+	ap := NewAttestationPool(configs.Minimal)
+	committee := common.CommitteeIndices{10, 11, 12, 13, 14, 15}
+	data := phase0.AttestationData{
+		Slot:            testHeadSlot,
+		BeaconBlockRoot: testHead,
+		Source:          testSource,
+		Target:          testTarget,
+	}
+	otherSource := data
+	otherSource.Index = 1
+	otherSource.Source.Root = common.Root{0xff}
+	ctx := context.Background()
+	for _, a := range []struct {
+		data         phase0.AttestationData
+		participants []int
+	}{
+		{data, []int{0, 1, 2}},
+		{data, []int{2, 3}},
+		{data, []int{4}},
+		{otherSource, []int{5}},
+	} {
+		att := &phase0.Attestation{AggregationBits: testBits(len(committee), a.participants...), Data: a.data}
+		if err := ap.AddAttestation(ctx, att, committee); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := ap.Packing(ctx, testInclusionSlot, testSource, testTarget, testBlockRootAt, 10, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("expected 3 attestations, got %d", len(out))
+	}
+	if out[0].AggregationBits.OnesCount() != 3 {
+		t.Fatalf("expected largest aggregate first, got %s", out[0].AggregationBits)
+	}
+	for _, att := range out {
+		if att.Data.Source != testSource {
+			t.Fatalf("packed attestation with wrong source: %s", att.Data.Source)
+		}
+	}
+
+	out, err = ap.Packing(ctx, testInclusionSlot, testSource, testTarget, testBlockRootAt, 1, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected 1 attestation, got %d", len(out))
+	}
+
+	// If validators 10 and 11 are already included, the {2, 3} aggregate is worth more than {0, 1, 2}.
+	included := func(epoch common.Epoch, index common.ValidatorIndex) bool {
+		return epoch == testTarget.Epoch && (index == 10 || index == 11)
+	}
+	out, err = ap.Packing(ctx, testInclusionSlot, testSource, testTarget, testBlockRootAt, 10, time.Second, included)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 attestations, got %d", len(out))
+	}
+	if !out[0].AggregationBits.GetBit(3) {
+		t.Fatalf("expected {2, 3} aggregate first, got %s", out[0].AggregationBits)
+	}
+}
+
+func TestPackingHeadAtSlot(t *testing.T) {
+	ap := NewAttestationPool(configs.Minimal)
+	ctx := context.Background()
+	// Both votes were correct for their own slot, the head of the chain moved on since.
+	prevHead := phase0.AttestationData{
+		Slot:            testHeadSlot - 1,
+		BeaconBlockRoot: testCanonical[testHeadSlot-1],
+		Source:          testSource,
+		Target:          testTarget,
+	}
+	wrongHead := prevHead
+	wrongHead.Index = 1
+	wrongHead.BeaconBlockRoot = testHead
+	for i, data := range []phase0.AttestationData{prevHead, wrongHead} {
+		committee := common.CommitteeIndices{common.ValidatorIndex(10 + i)}
+		att := &phase0.Attestation{AggregationBits: testBits(len(committee), 0), Data: data}
+		if err := ap.AddAttestation(ctx, att, committee); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Included at the minimum delay, the head counts if it is correct.
+	candidates := ap.packingCandidates(testHeadSlot, testSource, testTarget, testBlockRootAt, nil)
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(candidates))
+	}
+	for _, c := range candidates {
+		expected := altair.TIMELY_SOURCE_WEIGHT + altair.TIMELY_TARGET_WEIGHT
+		if c.att.Data.Index == prevHead.Index {
+			expected += altair.TIMELY_HEAD_WEIGHT
+		}
+		if c.weight != expected {
+			t.Fatalf("committee %d: expected weight %d, got %d", c.att.Data.Index, expected, c.weight)
+		}
+	}
+}
+
+func TestPackingInclusionDelay(t *testing.T) {
+	spec := configs.Minimal
+	ap := NewAttestationPool(spec)
+	ctx := context.Background()
+	data := phase0.AttestationData{
+		Slot:            testHeadSlot - 1,
+		BeaconBlockRoot: testCanonical[testHeadSlot-1],
+		Source:          testSource,
+		Target:          testTarget,
+	}
+	att := &phase0.Attestation{AggregationBits: testBits(1, 0), Data: data}
+	if err := ap.AddAttestation(ctx, att, common.CommitteeIndices{10}); err != nil {
+		t.Fatal(err)
+	}
+	// minimal preset: the source is timely up to a delay of isqrt(8) = 2, the target up to a delay of 8.
+	for _, tc := range []struct {
+		delay  common.Slot
+		weight common.Gwei
+	}{
+		{0, 0},
+		{1, altair.TIMELY_SOURCE_WEIGHT + altair.TIMELY_TARGET_WEIGHT + altair.TIMELY_HEAD_WEIGHT},
+		{2, altair.TIMELY_SOURCE_WEIGHT + altair.TIMELY_TARGET_WEIGHT},
+		{3, altair.TIMELY_TARGET_WEIGHT},
+		{spec.SLOTS_PER_EPOCH, altair.TIMELY_TARGET_WEIGHT},
+		{spec.SLOTS_PER_EPOCH + 1, 0},
+	} {
+		candidates := ap.packingCandidates(data.Slot+tc.delay, testSource, testTarget, testBlockRootAt, nil)
+		if tc.weight == 0 {
+			if len(candidates) != 0 {
+				t.Errorf("delay %d: expected no candidates, got %d", tc.delay, len(candidates))
+			}
+			continue
+		}
+		if len(candidates) != 1 || candidates[0].weight != tc.weight {
+			t.Errorf("delay %d: expected a candidate with weight %d, got %d candidates", tc.delay, tc.weight, len(candidates))
+		}
+	}
+}
+
+func TestPackingMerged(t *testing.T) {
+	ap := NewAttestationPool(configs.Minimal)
+	committee := common.CommitteeIndices{10, 11, 12, 13, 14, 15}
+	data := phase0.AttestationData{
+		Slot:            testHeadSlot,
+		BeaconBlockRoot: testHead,
+		Source:          testSource,
+		Target:          testTarget,
+	}
+	msg := []byte("attestation")
+	var pubs []*blsu.Pubkey
+	sign := func(participants ...int) common.BLSSignature {
+		var sigs []*blsu.Signature
+		for _, p := range participants {
+			var raw [32]byte
+			raw[31] = byte(p + 1)
+			sk := new(blsu.SecretKey)
+			if err := sk.Deserialize(&raw); err != nil {
+				t.Fatal(err)
+			}
+			pub, err := blsu.SkToPk(sk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubs = append(pubs, pub)
+			sigs = append(sigs, blsu.Sign(sk, msg))
+		}
+		agg, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return agg.Serialize()
+	}
+	ctx := context.Background()
+	for _, participants := range [][]int{{0, 1, 2}, {3}, {4}} {
+		att := &phase0.Attestation{AggregationBits: testBits(len(committee), participants...), Data: data, Signature: sign(participants...)}
+		if err := ap.AddAttestation(ctx, att, committee); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := ap.Packing(ctx, testInclusionSlot, testSource, testTarget, testBlockRootAt, 1, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected 1 attestation, got %d", len(out))
+	}
+	if got := out[0].AggregationBits.OnesCount(); got != 5 {
+		t.Fatalf("expected aggregate and individual attestations to be merged, got %s", out[0].AggregationBits)
+	}
+	sig, err := out[0].Signature.Signature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blsu.FastAggregateVerify(pubs, msg, sig) {
+		t.Fatal("merged attestation signature is invalid")
+	}
+}
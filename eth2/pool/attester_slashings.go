@@ -3,6 +3,7 @@ package pool
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -14,12 +15,16 @@ type AttesterSlashingPool struct {
 	sync.RWMutex
 	spec      *common.Spec
 	slashings map[common.Root]*phase0.AttesterSlashing
+	// Slashings of which both indexed attestations were validated.
+	// The indices and signatures stay valid: validator pubkeys never change once registered.
+	validated map[common.Root]struct{}
 }
 
 func NewAttesterSlashingPool(spec *common.Spec) *AttesterSlashingPool {
 	return &AttesterSlashingPool{
 		spec:      spec,
 		slashings: make(map[common.Root]*phase0.AttesterSlashing),
+		validated: make(map[common.Root]struct{}),
 	}
 }
 
@@ -46,9 +51,138 @@ func (asp *AttesterSlashingPool) All() []*phase0.AttesterSlashing {
 	return out
 }
 
-// Pack n slashings, removes the slashings from the pool. A reward estimator is used to pick the best slashings.
+// slashableIndices returns the validators that are slashable in the given epoch and attested to both attestations.
+// Indices that are unknown to the state are returned separately.
+func slashableIndices(sl *phase0.AttesterSlashing, vals common.ValidatorRegistry, epoch common.Epoch) (slashable []common.ValidatorIndex, unknown []common.ValidatorIndex, err error) {
+	common.ValidatorSet(sl.Attestation1.AttestingIndices).ZigZagJoin(common.ValidatorSet(sl.Attestation2.AttestingIndices), func(i common.ValidatorIndex) {
+		if err != nil {
+			return
+		}
+		if valid, e := vals.IsValidIndex(i); e != nil {
+			err = e
+			return
+		} else if !valid {
+			unknown = append(unknown, i)
+			return
+		}
+		v, e := vals.Validator(i)
+		if e != nil {
+			err = e
+			return
+		}
+		if ok, e := phase0.IsSlashable(v, epoch); e != nil {
+			err = e
+		} else if ok {
+			slashable = append(slashable, i)
+		}
+	}, nil)
+	return
+}
+
+// Pack n slashings. A reward estimator is used to pick the best slashings.
+// The packed slashings stay in the pool, until removed with Remove, e.g. when the block is processed successfully.
 // Slashings with negative rewards will not be packed.
-func (asp *AttesterSlashingPool) Pack(estReward func(sl *phase0.AttesterSlashing) int, n uint) []*phase0.AttesterSlashing {
-	// TODO
+// Slashings that are not valid anymore against the given state (the pre-state of the block to pack into)
+// are dropped from the pool. The indexed attestations of a slashing are only validated the first time it is packed.
+// A slashing is not packed if all its slashable validators are already slashed by the other packed slashings.
+func (asp *AttesterSlashingPool) Pack(epc *common.EpochsContext, state common.BeaconState,
+	estReward func(sl *phase0.AttesterSlashing) int, n uint) ([]*phase0.AttesterSlashing, error) {
+	asp.Lock()
+	defer asp.Unlock()
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	type ranked struct {
+		root      common.Root
+		sl        *phase0.AttesterSlashing
+		slashable []common.ValidatorIndex
+		reward    int
+	}
+	candidates := make([]ranked, 0, len(asp.slashings))
+	for root, sl := range asp.slashings {
+		if _, ok := asp.validated[root]; !ok {
+			if !phase0.IsSlashableAttestationData(&sl.Attestation1.Data, &sl.Attestation2.Data) ||
+				phase0.ValidateIndexedAttestation(context.Background(), asp.spec, epc, state, &sl.Attestation1) != nil ||
+				phase0.ValidateIndexedAttestation(context.Background(), asp.spec, epc, state, &sl.Attestation2) != nil {
+				delete(asp.slashings, root)
+				continue
+			}
+			asp.validated[root] = struct{}{}
+		}
+		slashable, _, err := slashableIndices(sl, vals, epc.CurrentEpoch.Epoch)
+		if err != nil {
+			return nil, err
+		}
+		if len(slashable) == 0 {
+			delete(asp.slashings, root)
+			delete(asp.validated, root)
+			continue
+		}
+		if reward := estReward(sl); reward >= 0 {
+			candidates = append(candidates, ranked{root: root, sl: sl, slashable: slashable, reward: reward})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].reward > candidates[j].reward
+	})
+	covered := make(map[common.ValidatorIndex]struct{})
+	out := make([]*phase0.AttesterSlashing, 0, len(candidates))
+	for _, c := range candidates {
+		if uint(len(out)) >= n {
+			break
+		}
+		redundant := true
+		for _, i := range c.slashable {
+			if _, ok := covered[i]; !ok {
+				redundant = false
+				break
+			}
+		}
+		if redundant {
+			continue
+		}
+		for _, i := range c.slashable {
+			covered[i] = struct{}{}
+		}
+		out = append(out, c.sl)
+	}
+	return out, nil
+}
+
+// Remove removes the slashings from the pool, e.g. after they were included in a block.
+func (asp *AttesterSlashingPool) Remove(slashings ...*phase0.AttesterSlashing) {
+	asp.Lock()
+	defer asp.Unlock()
+	for _, sl := range slashings {
+		root := sl.HashTreeRoot(asp.spec, tree.GetHashFn())
+		delete(asp.slashings, root)
+		delete(asp.validated, root)
+	}
+}
+
+// Prune removes the slashings that cannot slash any validator anymore, according to the given finalized state.
+func (asp *AttesterSlashingPool) Prune(finalized common.BeaconState) error {
+	asp.Lock()
+	defer asp.Unlock()
+	slot, err := finalized.Slot()
+	if err != nil {
+		return err
+	}
+	epoch := asp.spec.SlotToEpoch(slot)
+	vals, err := finalized.Validators()
+	if err != nil {
+		return err
+	}
+	for root, sl := range asp.slashings {
+		slashable, unknown, err := slashableIndices(sl, vals, epoch)
+		if err != nil {
+			return err
+		}
+		if len(slashable) == 0 && len(unknown) == 0 {
+			delete(asp.slashings, root)
+			delete(asp.validated, root)
+		}
+	}
 	return nil
 }
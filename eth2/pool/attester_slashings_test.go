@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestPackAttesterSlashings(t *testing.T) {
+	spec := configs.Minimal
+	keys := testSyncKeys(t, 64)
+	rawKeys := make([][32]byte, len(keys))
+	vals := make([]phase0.KickstartValidatorData, len(keys))
+	for i, sk := range keys {
+		rawKeys[i] = sk.Serialize()
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	state, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 0, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dom, err := common.GetDomain(state, common.DOMAIN_BEACON_ATTESTER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed := func(data phase0.AttestationData, indices ...common.ValidatorIndex) phase0.IndexedAttestation {
+		root := common.ComputeSigningRoot(data.HashTreeRoot(tree.GetHashFn()), dom)
+		var sigs []*blsu.Signature
+		for _, i := range indices {
+			sigs = append(sigs, blsu.Sign(keys[i], root[:]))
+		}
+		agg, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return phase0.IndexedAttestation{AttestingIndices: indices, Data: data, Signature: agg.Serialize()}
+	}
+	slashing := func(indices ...common.ValidatorIndex) *phase0.AttesterSlashing {
+		a := phase0.AttestationData{Slot: 1, BeaconBlockRoot: common.Root{0x0a}}
+		b := phase0.AttestationData{Slot: 1, BeaconBlockRoot: common.Root{0x0b}}
+		return &phase0.AttesterSlashing{Attestation1: indexed(a, indices...), Attestation2: indexed(b, indices...)}
+	}
+
+	asp := NewAttesterSlashingPool(spec)
+	large := slashing(1, 2, 3)
+	subset := slashing(2, 3)
+	other := slashing(4, 5)
+	for _, sl := range []*phase0.AttesterSlashing{large, subset, other} {
+		if err := asp.AddAttesterSlashing(context.Background(), sl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	estReward := func(sl *phase0.AttesterSlashing) int {
+		return len(sl.Attestation1.AttestingIndices)
+	}
+	out, err := asp.Pack(epc, state, estReward, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0] != large || out[1] != other {
+		t.Fatalf("expected the large and the other slashing, got %d slashings", len(out))
+	}
+	// Packed slashings stay in the pool, until removed.
+	if remaining := asp.All(); len(remaining) != 3 {
+		t.Fatalf("expected packed slashings to remain, got %d slashings", len(remaining))
+	}
+	// The indexed attestations are validated only once.
+	if len(asp.validated) != 3 {
+		t.Fatalf("expected all slashings to be validated, got %d", len(asp.validated))
+	}
+	// The redundant slashing stays in the pool, until pruned.
+	asp.Remove(out...)
+	if remaining := asp.All(); len(remaining) != 1 || remaining[0] != subset {
+		t.Fatalf("expected only the redundant slashing to remain, got %d slashings", len(remaining))
+	}
+	if len(asp.validated) != 1 {
+		t.Fatalf("expected the validation of removed slashings to be forgotten, got %d", len(asp.validated))
+	}
+}
@@ -3,6 +3,7 @@ package pool
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -44,9 +45,80 @@ func (psp *ProposerSlashingPool) All() []*phase0.ProposerSlashing {
 	return out
 }
 
-// Pack n slashings, removes the slashings from the pool. A reward estimator is used to pick the best slashings.
+// Pack n slashings. A reward estimator is used to pick the best slashings.
+// The packed slashings stay in the pool, until removed with Remove, e.g. when the block is processed successfully.
 // Slashings with negative rewards will not be packed.
-func (psp *ProposerSlashingPool) Pack(estReward func(sl *phase0.ProposerSlashing) int, n uint) []*phase0.ProposerSlashing {
-	// TODO
+// Slashings that are not valid anymore against the given state (the pre-state of the block to pack into)
+// are dropped from the pool.
+func (psp *ProposerSlashingPool) Pack(epc *common.EpochsContext, state common.BeaconState,
+	estReward func(sl *phase0.ProposerSlashing) int, n uint) ([]*phase0.ProposerSlashing, error) {
+	psp.Lock()
+	defer psp.Unlock()
+	type ranked struct {
+		sl     *phase0.ProposerSlashing
+		reward int
+	}
+	candidates := make([]ranked, 0, len(psp.slashings))
+	for key, sl := range psp.slashings {
+		if err := phase0.ValidateProposerSlashing(context.Background(), psp.spec, epc, state, sl); err != nil {
+			delete(psp.slashings, key)
+			continue
+		}
+		if reward := estReward(sl); reward >= 0 {
+			candidates = append(candidates, ranked{sl: sl, reward: reward})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].reward > candidates[j].reward
+	})
+	if uint(len(candidates)) > n {
+		candidates = candidates[:n]
+	}
+	out := make([]*phase0.ProposerSlashing, 0, len(candidates))
+	for _, c := range candidates {
+		out = append(out, c.sl)
+	}
+	return out, nil
+}
+
+// Remove removes the slashings from the pool, e.g. after they were included in a block.
+func (psp *ProposerSlashingPool) Remove(slashings ...*phase0.ProposerSlashing) {
+	psp.Lock()
+	defer psp.Unlock()
+	for _, sl := range slashings {
+		delete(psp.slashings, sl.SignedHeader1.Message.ProposerIndex)
+	}
+}
+
+// Prune removes the slashings of proposers that are not slashable anymore in the given finalized state.
+func (psp *ProposerSlashingPool) Prune(finalized common.BeaconState) error {
+	psp.Lock()
+	defer psp.Unlock()
+	slot, err := finalized.Slot()
+	if err != nil {
+		return err
+	}
+	epoch := psp.spec.SlotToEpoch(slot)
+	vals, err := finalized.Validators()
+	if err != nil {
+		return err
+	}
+	for key := range psp.slashings {
+		if valid, err := vals.IsValidIndex(key); err != nil {
+			return err
+		} else if !valid {
+			// the proposer may not be known yet to the finalized state
+			continue
+		}
+		v, err := vals.Validator(key)
+		if err != nil {
+			return err
+		}
+		if slashable, err := phase0.IsSlashable(v, epoch); err != nil {
+			return err
+		} else if !slashable {
+			delete(psp.slashings, key)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,114 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestProposerSlashingPool(t *testing.T) {
+	spec := configs.Minimal
+	keys := testSyncKeys(t, 64)
+	rawKeys := make([][32]byte, len(keys))
+	vals := make([]phase0.KickstartValidatorData, len(keys))
+	for i, sk := range keys {
+		rawKeys[i] = sk.Serialize()
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	state, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 0, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dom, err := common.GetDomain(state, common.DOMAIN_BEACON_PROPOSER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := func(signer common.ValidatorIndex, header common.BeaconBlockHeader) common.SignedBeaconBlockHeader {
+		root := common.ComputeSigningRoot(header.HashTreeRoot(tree.GetHashFn()), dom)
+		return common.SignedBeaconBlockHeader{Message: header, Signature: blsu.Sign(keys[signer], root[:]).Serialize()}
+	}
+	slashing := func(proposer common.ValidatorIndex, signer common.ValidatorIndex) *phase0.ProposerSlashing {
+		a := common.BeaconBlockHeader{Slot: 1, ProposerIndex: proposer, BodyRoot: common.Root{0x0a}}
+		b := common.BeaconBlockHeader{Slot: 1, ProposerIndex: proposer, BodyRoot: common.Root{0x0b}}
+		return &phase0.ProposerSlashing{SignedHeader1: signed(signer, a), SignedHeader2: signed(signer, b)}
+	}
+
+	psp := NewProposerSlashingPool(spec)
+	low := slashing(1, 1)
+	high := slashing(2, 2)
+	negative := slashing(3, 3)
+	badSig := slashing(4, 5)
+	unknown := slashing(100, 1)
+	for _, sl := range []*phase0.ProposerSlashing{low, high, negative, badSig, unknown} {
+		if err := psp.AddProposerSlashing(context.Background(), sl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := psp.AddProposerSlashing(context.Background(), slashing(1, 1)); err == nil {
+		t.Fatal("expected duplicate slashing of the same proposer to be refused")
+	}
+	estReward := func(sl *phase0.ProposerSlashing) int {
+		if sl == negative {
+			return -1
+		}
+		return int(sl.SignedHeader1.Message.ProposerIndex)
+	}
+	out, err := psp.Pack(epc, state, estReward, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0] != high {
+		t.Fatalf("expected the slashing with the highest reward, got %d slashings", len(out))
+	}
+	out, err = psp.Pack(epc, state, estReward, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0] != high || out[1] != low {
+		t.Fatalf("expected the valid slashings with positive rewards, got %d slashings", len(out))
+	}
+	// Invalid slashings are dropped, the others stay in the pool until removed.
+	if remaining := psp.All(); len(remaining) != 3 {
+		t.Fatalf("expected the invalid slashings to be dropped, got %d slashings", len(remaining))
+	}
+	psp.Remove(out...)
+	if remaining := psp.All(); len(remaining) != 1 || remaining[0] != negative {
+		t.Fatalf("expected only the unpacked slashing to remain, got %d slashings", len(remaining))
+	}
+
+	// Prune drops the slashings of proposers that are slashed in the finalized state, unknown proposers are kept.
+	for _, sl := range []*phase0.ProposerSlashing{low, unknown} {
+		if err := psp.AddProposerSlashing(context.Background(), sl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []common.ValidatorIndex{1, 3} {
+		v, err := registry.Validator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.MakeSlashed(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := psp.Prune(state); err != nil {
+		t.Fatal(err)
+	}
+	if remaining := psp.All(); len(remaining) != 1 || remaining[0] != unknown {
+		t.Fatalf("expected only the slashing of the unknown proposer to remain, got %d slashings", len(remaining))
+	}
+}
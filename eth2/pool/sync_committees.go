@@ -2,13 +2,21 @@ package pool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 
+	blsu "github.com/protolambda/bls12-381-util"
+
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/view"
 )
 
+// UnavailableSlotErr is returned when packing for a slot outside of the previous, current and next slot of the pool.
+var UnavailableSlotErr = errors.New("slot is not buffered by the sync committee pool")
+
 // beacon root -> subnet -> contributions
 type SyncCommitteeContributions map[common.Root]map[uint64][]*SubnetContrib
 
@@ -17,8 +25,8 @@ type SyncCommitteeMessages map[common.ValidatorIndex]*altair.SyncCommitteeMessag
 func (msgs SyncCommitteeMessages) Select(root common.Root, members []common.ValidatorIndex) []*altair.SyncCommitteeMessage {
 	out := make([]*altair.SyncCommitteeMessage, 0, len(members))
 	for _, vi := range members {
-		msg := msgs[vi]
-		if msg.BeaconBlockRoot == root {
+		msg, ok := msgs[vi]
+		if ok && msg.BeaconBlockRoot == root {
 			out = append(out, msg)
 		}
 	}
@@ -102,21 +110,170 @@ func (sp *SyncCommitteePool) AddSyncCommitteeMessage(ctx context.Context, msg *a
 	return nil
 }
 
+// buffers returns the contributions and messages buffered for the given slot. The lock must be held by the caller.
+func (sp *SyncCommitteePool) buffers(slot common.Slot) (SyncCommitteeContributions, SyncCommitteeMessages, error) {
+	if sp.currentSlot == slot+1 {
+		return sp.prevContribs, sp.prevMsgs, nil
+	} else if sp.currentSlot == slot {
+		return sp.currentContribs, sp.currentMsgs, nil
+	} else if sp.currentSlot+1 == slot {
+		return sp.nextContribs, sp.nextMsgs, nil
+	} else {
+		return nil, nil, fmt.Errorf("%w: current sync committee pool is at slot %d, cannot pack for slot %d", UnavailableSlotErr, sp.currentSlot, slot)
+	}
+}
+
+// packSubnet combines the complementary contributions of the subnet, starting with the largest,
+// and fills the remaining gaps with individual messages of the subcommittee members.
+// Nil bits are returned if there is nothing to pack.
+func packSubnet(contribs []*SubnetContrib, msgs SyncCommitteeMessages, root common.Root,
+	subComm []common.ValidatorIndex) (altair.SyncCommitteeSubnetBits, common.BLSSignature, error) {
+
+	bits := make(altair.SyncCommitteeSubnetBits, (len(subComm)+7)/8)
+	var sigs []*blsu.Signature
+
+	// Largest contributions first, then greedily add the ones that do not overlap
+	sorted := make([]*SubnetContrib, len(contribs))
+	copy(sorted, contribs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].AggregationBits.OnesCount() > sorted[j].AggregationBits.OnesCount()
+	})
+	for _, c := range sorted {
+		if len(c.AggregationBits) != len(bits) {
+			continue
+		}
+		overlap := false
+		for i := range bits {
+			if bits[i]&c.AggregationBits[i] != 0 {
+				overlap = true
+				break
+			}
+		}
+		if overlap || c.AggregationBits.OnesCount() == 0 {
+			continue
+		}
+		sig, err := c.Signature.Signature()
+		if err != nil {
+			continue
+		}
+		for i := range bits {
+			bits[i] |= c.AggregationBits[i]
+		}
+		sigs = append(sigs, sig)
+	}
+	// Fill the gaps. A validator may be in the subcommittee multiple times, the message counts for each position.
+	for i, vi := range subComm {
+		if bits.GetBit(uint64(i)) {
+			continue
+		}
+		msg, ok := msgs[vi]
+		if !ok || msg.BeaconBlockRoot != root {
+			continue
+		}
+		sig, err := msg.Signature.Signature()
+		if err != nil {
+			continue
+		}
+		bits.SetBit(uint64(i), true)
+		sigs = append(sigs, sig)
+	}
+	if len(sigs) == 0 {
+		return nil, common.BLSSignature{}, nil
+	}
+	agg, err := blsu.Aggregate(sigs)
+	if err != nil {
+		return nil, common.BLSSignature{}, fmt.Errorf("failed to aggregate signatures: %v", err)
+	}
+	return bits, agg.Serialize(), nil
+}
+
+// PackContribution aggregates the best contribution for the given subnet,
+// from the complementary contributions and individual messages of the subcommittee members.
 func (sp *SyncCommitteePool) PackContribution(ctx context.Context, slot common.Slot, beaconBlockRoot common.Root, subnet uint64, subComm []common.ValidatorIndex) (*altair.SyncCommitteeContribution, error) {
 	sp.Lock()
 	defer sp.Unlock()
-	// TODO: select messages & aggregate a contribution
-	return nil, nil
+	if subnet >= common.SYNC_COMMITTEE_SUBNET_COUNT {
+		return nil, fmt.Errorf("invalid subnet: %d", subnet)
+	}
+	if expected := sp.spec.SYNC_COMMITTEE_SIZE / common.SYNC_COMMITTEE_SUBNET_COUNT; uint64(len(subComm)) != expected {
+		return nil, fmt.Errorf("expected subcommittee of %d members, got %d", expected, len(subComm))
+	}
+	contribs, msgs, err := sp.buffers(slot)
+	if err != nil {
+		return nil, err
+	}
+	bits, sig, err := packSubnet(contribs[beaconBlockRoot][subnet], msgs, beaconBlockRoot, subComm)
+	if err != nil {
+		return nil, err
+	}
+	if bits == nil {
+		return nil, fmt.Errorf("no sync committee messages or contributions available for slot %d, subnet %d, block %s", slot, subnet, beaconBlockRoot)
+	}
+	return &altair.SyncCommitteeContribution{
+		Slot:              slot,
+		BeaconBlockRoot:   beaconBlockRoot,
+		SubcommitteeIndex: view.Uint64View(subnet),
+		AggregationBits:   bits,
+		Signature:         sig,
+	}, nil
 }
 
+// PackAggregate packs every subnet, from the complementary subnet contributions and individual messages,
+// and merges them into a full sync aggregate. If nothing is available, the aggregate is empty,
+// with the point at infinity as signature.
 func (sp *SyncCommitteePool) PackAggregate(ctx context.Context, slot common.Slot, beaconBlockRoot common.Root, syncCommittee []common.ValidatorIndex) (*altair.SyncAggregate, error) {
 	sp.Lock()
 	defer sp.Unlock()
-	// TODO: select complimentary subnet contributions, fill gaps with any individual messages, and return it
-	return nil, nil
+	if uint64(len(syncCommittee)) != sp.spec.SYNC_COMMITTEE_SIZE {
+		return nil, fmt.Errorf("expected sync committee of %d members, got %d", sp.spec.SYNC_COMMITTEE_SIZE, len(syncCommittee))
+	}
+	contribs, msgs, err := sp.buffers(slot)
+	if err != nil {
+		return nil, err
+	}
+	subSize := sp.spec.SYNC_COMMITTEE_SIZE / common.SYNC_COMMITTEE_SUBNET_COUNT
+	out := &altair.SyncAggregate{
+		SyncCommitteeBits: make(altair.SyncCommitteeBits, (sp.spec.SYNC_COMMITTEE_SIZE+7)/8),
+	}
+	var sigs []*blsu.Signature
+	for subnet := uint64(0); subnet < common.SYNC_COMMITTEE_SUBNET_COUNT; subnet++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		subComm := syncCommittee[subnet*subSize : (subnet+1)*subSize]
+		bits, sig, err := packSubnet(contribs[beaconBlockRoot][subnet], msgs, beaconBlockRoot, subComm)
+		if err != nil {
+			return nil, err
+		}
+		if bits == nil {
+			continue
+		}
+		for i := uint64(0); i < subSize; i++ {
+			if bits.GetBit(i) {
+				out.SyncCommitteeBits.SetBit(subnet*subSize+i, true)
+			}
+		}
+		s, err := sig.Signature()
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, s)
+	}
+	if len(sigs) == 0 {
+		out.SyncCommitteeSignature[0] = 0xc0
+		return out, nil
+	}
+	agg, err := blsu.Aggregate(sigs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate subnet signatures: %v", err)
+	}
+	out.SyncCommitteeSignature = agg.Serialize()
+	return out, nil
 }
 
 func (sp *SyncCommitteePool) Reset(slot common.Slot) {
+	sp.Lock()
+	defer sp.Unlock()
 	if sp.currentSlot == slot+1 {
 		sp.nextMsgs = sp.currentMsgs
 		sp.currentMsgs = sp.prevMsgs
@@ -0,0 +1,134 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/configs"
+)
+
+func testSyncKeys(t *testing.T, n int) []*blsu.SecretKey {
+	keys := make([]*blsu.SecretKey, n)
+	for i := range keys {
+		var raw [32]byte
+		raw[31] = byte(i + 1)
+		var sk blsu.SecretKey
+		if err := sk.Deserialize(&raw); err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = &sk
+	}
+	return keys
+}
+
+func TestPackSyncAggregate(t *testing.T) {
+	spec := configs.Minimal
+	subSize := spec.SYNC_COMMITTEE_SIZE / common.SYNC_COMMITTEE_SUBNET_COUNT
+	keys := testSyncKeys(t, 8)
+	// validators are in the committee multiple times
+	committee := make([]common.ValidatorIndex, spec.SYNC_COMMITTEE_SIZE)
+	for i := range committee {
+		committee[i] = common.ValidatorIndex(i % len(keys))
+	}
+	root := common.Root{0x42}
+	slot := common.Slot(10)
+	ctx := context.Background()
+
+	sp := NewSyncCommitteePool(spec)
+	sp.Reset(slot)
+
+	// validators 0, 1, 2 send individual messages, 2 also for another block
+	for _, vi := range []common.ValidatorIndex{0, 1, 2} {
+		msgRoot := root
+		if vi == 2 {
+			msgRoot = common.Root{0xff}
+		}
+		msg := &altair.SyncCommitteeMessage{
+			Slot:            slot,
+			BeaconBlockRoot: msgRoot,
+			ValidatorIndex:  vi,
+			Signature:       blsu.Sign(keys[vi], msgRoot[:]).Serialize(),
+		}
+		if err := sp.AddSyncCommitteeMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// subnet 1 has a contribution of positions 3 and 4 (validators 3, 4 in subnet 1 when subSize is 8)
+	{
+		subComm := committee[subSize : 2*subSize]
+		bits := make(altair.SyncCommitteeSubnetBits, (subSize+7)/8)
+		var sigs []*blsu.Signature
+		for _, i := range []uint64{3, 4} {
+			bits.SetBit(i, true)
+			sigs = append(sigs, blsu.Sign(keys[subComm[i]], root[:]))
+		}
+		agg, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contrib := &altair.SyncCommitteeContribution{
+			Slot:              slot,
+			BeaconBlockRoot:   root,
+			SubcommitteeIndex: 1,
+			AggregationBits:   bits,
+			Signature:         agg.Serialize(),
+		}
+		if err := sp.AddSyncCommitteeContribution(ctx, contrib); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contrib, err := sp.PackContribution(ctx, slot, root, 1, committee[subSize:2*subSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := contrib.AggregationBits.OnesCount(); count != 4 {
+		t.Fatalf("expected 4 participants in subnet contribution, got %d: %s", count, contrib.AggregationBits)
+	}
+
+	agg, err := sp.PackAggregate(ctx, slot, root, committee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pubs []*blsu.Pubkey
+	for i, vi := range committee {
+		expected := vi == 0 || vi == 1 || (uint64(i) >= subSize && uint64(i) < 2*subSize && (vi == 3 || vi == 4))
+		if agg.SyncCommitteeBits.GetBit(uint64(i)) != expected {
+			t.Fatalf("unexpected participation bit %d (validator %d) in %s", i, vi, agg.SyncCommitteeBits)
+		}
+		if expected {
+			pub, err := blsu.SkToPk(keys[vi])
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubs = append(pubs, pub)
+		}
+	}
+	sig, err := agg.SyncCommitteeSignature.Signature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blsu.FastAggregateVerify(pubs, root[:], sig) {
+		t.Fatal("invalid sync aggregate signature")
+	}
+
+	empty, err := sp.PackAggregate(ctx, slot, common.Root{0x01}, committee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blsu.Eth2FastAggregateVerify(nil, nil, mustSig(t, empty.SyncCommitteeSignature)) {
+		t.Fatal("expected empty aggregate with infinity signature")
+	}
+}
+
+func mustSig(t *testing.T, sig common.BLSSignature) *blsu.Signature {
+	s, err := sig.Signature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
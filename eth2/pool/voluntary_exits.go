@@ -3,6 +3,7 @@ package pool
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -43,9 +44,102 @@ func (vep *VoluntaryExitPool) All() []*phase0.SignedVoluntaryExit {
 	return out
 }
 
-// Pack n exits, removes the exits from the pool. A ranking function is used to pick the best exits.
+// Pack n exits. A ranking function is used to pick the best exits.
+// The packed exits stay in the pool, until removed with Remove, e.g. when the block is processed successfully.
 // Exits with negative rank function outputs will not be packed.
-func (vep *VoluntaryExitPool) Pack(rank func(sl *phase0.SignedVoluntaryExit) int, n uint) []*phase0.SignedVoluntaryExit {
-	// TODO
+// Exits that can never be valid anymore, since the validator already initiated an exit or is slashed
+// in the given state (the pre-state of the block to pack into), are dropped from the pool.
+// Other invalid exits may become valid later, e.g. exits for a future epoch,
+// or exits of validators that have not been active for SHARD_COMMITTEE_PERIOD yet: these are kept.
+func (vep *VoluntaryExitPool) Pack(epc *common.EpochsContext, state common.BeaconState,
+	rank func(sl *phase0.SignedVoluntaryExit) int, n uint) ([]*phase0.SignedVoluntaryExit, error) {
+	vep.Lock()
+	defer vep.Unlock()
+	vals, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	type ranked struct {
+		exit *phase0.SignedVoluntaryExit
+		rank int
+	}
+	candidates := make([]ranked, 0, len(vep.exits))
+	for key, exit := range vep.exits {
+		if exit.Message.Epoch > epc.CurrentEpoch.Epoch {
+			continue
+		}
+		if err := phase0.ValidateVoluntaryExit(context.Background(), vep.spec, epc, state, exit); err != nil {
+			if exited, err := hasExited(vals, key); err != nil {
+				return nil, err
+			} else if exited {
+				delete(vep.exits, key)
+			}
+			continue
+		}
+		if r := rank(exit); r >= 0 {
+			candidates = append(candidates, ranked{exit: exit, rank: r})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].rank > candidates[j].rank
+	})
+	if uint(len(candidates)) > n {
+		candidates = candidates[:n]
+	}
+	out := make([]*phase0.SignedVoluntaryExit, 0, len(candidates))
+	for _, c := range candidates {
+		out = append(out, c.exit)
+	}
+	return out, nil
+}
+
+// Remove removes the exits from the pool, e.g. after they were included in a block.
+func (vep *VoluntaryExitPool) Remove(exits ...*phase0.SignedVoluntaryExit) {
+	vep.Lock()
+	defer vep.Unlock()
+	for _, exit := range exits {
+		delete(vep.exits, exit.Message.ValidatorIndex)
+	}
+}
+
+// Prune removes the exits of validators that already initiated an exit, or are slashed, in the given finalized state.
+func (vep *VoluntaryExitPool) Prune(finalized common.BeaconState) error {
+	vep.Lock()
+	defer vep.Unlock()
+	vals, err := finalized.Validators()
+	if err != nil {
+		return err
+	}
+	for key := range vep.exits {
+		if exited, err := hasExited(vals, key); err != nil {
+			return err
+		} else if exited {
+			delete(vep.exits, key)
+		}
+	}
 	return nil
 }
+
+// hasExited checks if the validator already initiated an exit, or is slashed, in which case an exit cannot be valid anymore.
+// Validators unknown to the registry have not exited.
+func hasExited(vals common.ValidatorRegistry, index common.ValidatorIndex) (bool, error) {
+	if valid, err := vals.IsValidIndex(index); err != nil {
+		return false, err
+	} else if !valid {
+		return false, nil
+	}
+	v, err := vals.Validator(index)
+	if err != nil {
+		return false, err
+	}
+	if slashed, err := v.Slashed(); err != nil {
+		return false, err
+	} else if slashed {
+		return true, nil
+	}
+	exitEpoch, err := v.ExitEpoch()
+	if err != nil {
+		return false, err
+	}
+	return exitEpoch != common.FAR_FUTURE_EPOCH, nil
+}
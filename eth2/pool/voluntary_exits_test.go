@@ -0,0 +1,81 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+)
+
+func TestPackVoluntaryExits(t *testing.T) {
+	spec := configs.Minimal
+	keys := testSyncKeys(t, 64)
+	rawKeys := make([][32]byte, len(keys))
+	vals := make([]phase0.KickstartValidatorData, len(keys))
+	for i, sk := range keys {
+		rawKeys[i] = sk.Serialize()
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pub.Serialize(), Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	state, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 0, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slashed, err := registry.Validator(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := slashed.MakeSlashed(); err != nil {
+		t.Fatal(err)
+	}
+	exited, err := registry.Validator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exited.SetExitEpoch(10); err != nil {
+		t.Fatal(err)
+	}
+
+	vep := NewVoluntaryExitPool(spec)
+	exits := []*phase0.SignedVoluntaryExit{
+		// not valid yet: the validator has not been active for SHARD_COMMITTEE_PERIOD
+		{Message: phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 1}},
+		// never valid: the validator is slashed, or already exiting
+		{Message: phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 2}},
+		{Message: phase0.VoluntaryExit{Epoch: 0, ValidatorIndex: 3}},
+		// not valid yet: the exit is for a future epoch
+		{Message: phase0.VoluntaryExit{Epoch: 5, ValidatorIndex: 4}},
+	}
+	for _, exit := range exits {
+		if err := vep.AddVoluntaryExit(context.Background(), exit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := vep.Pack(epc, state, func(exit *phase0.SignedVoluntaryExit) int { return 0 }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Fatalf("expected no valid exits, got %d", len(out))
+	}
+	remaining := vep.All()
+	if len(remaining) != 2 {
+		t.Fatalf("expected the exits that may become valid to remain, got %d exits", len(remaining))
+	}
+	for _, exit := range remaining {
+		if i := exit.Message.ValidatorIndex; i != 1 && i != 4 {
+			t.Fatalf("unexpected remaining exit of validator %d", i)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package reqresp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/ztyp/codec"
+)
+
+// ResponseCode is the result byte that starts each response chunk.
+type ResponseCode byte
+
+const (
+	SuccessCode             ResponseCode = 0
+	InvalidRequestCode      ResponseCode = 1
+	ServerErrorCode         ResponseCode = 2
+	ResourceUnavailableCode ResponseCode = 3
+)
+
+func (c ResponseCode) String() string {
+	switch c {
+	case SuccessCode:
+		return "success"
+	case InvalidRequestCode:
+		return "invalid request"
+	case ServerErrorCode:
+		return "server error"
+	case ResourceUnavailableCode:
+		return "resource unavailable"
+	default:
+		return fmt.Sprintf("unknown response code %d", byte(c))
+	}
+}
+
+// MaxErrorMessageLen is the maximum byte length of the error message of an error response chunk.
+const MaxErrorMessageLen = 256
+
+// ResponseError is an error response chunk.
+type ResponseError struct {
+	Code    ResponseCode
+	Message string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+var (
+	PayloadSizeErr  = errors.New("payload size out of bounds")
+	TrailingDataErr = errors.New("unexpected data after payload")
+)
+
+// maxFramedLen is the maximum length of the snappy frames of an uncompressed payload of length n:
+// the stream identifier, and the header and worst-case compressed data of each frame.
+func maxFramedLen(n uint64) int64 {
+	const maxFrameData = 65536
+	frames := n/maxFrameData + 1
+	return int64(10 + frames*(4+4) + uint64(snappy.MaxEncodedLen(int(n))) + frames*32)
+}
+
+// writePayload writes the uvarint length prefix of the SSZ encoding of the object, followed by the snappy frames of the encoding.
+func writePayload(w io.Writer, obj codec.Serializable) error {
+	var buf bytes.Buffer
+	if err := obj.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	if uint64(buf.Len()) > common.MAX_CHUNK_SIZE {
+		return fmt.Errorf("%w: %d bytes", PayloadSizeErr, buf.Len())
+	}
+	var prefix [binary.MaxVarintLen64]byte
+	if _, err := w.Write(prefix[:binary.PutUvarint(prefix[:], uint64(buf.Len()))]); err != nil {
+		return err
+	}
+	sw := snappy.NewBufferedWriter(w)
+	if _, err := sw.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	// Close flushes the last frame, it does not close the underlying writer.
+	return sw.Close()
+}
+
+// readUvarint reads the length prefix byte by byte, to not read into the payload that follows.
+func readUvarint(r io.Reader) (uint64, error) {
+	var b [1]byte
+	var x uint64
+	for i, s := 0, uint(0); i < binary.MaxVarintLen64; i, s = i+1, s+7 {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			if i > 0 && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if b[0] < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b[0] > 1 {
+				break
+			}
+			return x | uint64(b[0])<<s, nil
+		}
+		x |= uint64(b[0]&0x7f) << s
+	}
+	return 0, errors.New("invalid length prefix: varint overflow")
+}
+
+// readPayload reads a length-prefixed, snappy-framed SSZ payload into dst.
+// The length must be within the SSZ bounds of dst, and not exceed maxLen or MAX_CHUNK_SIZE.
+// No more than the snappy frames of the payload are read from r.
+func readPayload(r io.Reader, dst codec.Deserializable, maxLen uint64) error {
+	n, err := readUvarint(r)
+	if err != nil {
+		return err
+	}
+	if fixed := dst.FixedLength(); fixed != 0 && n != fixed {
+		return fmt.Errorf("%w: got %d bytes, expected %d", PayloadSizeErr, n, fixed)
+	}
+	if n > maxLen || n > common.MAX_CHUNK_SIZE {
+		return fmt.Errorf("%w: got %d bytes, max %d", PayloadSizeErr, n, maxLen)
+	}
+	data := make([]byte, n)
+	sr := snappy.NewReader(io.LimitReader(r, maxFramedLen(n)))
+	if _, err := io.ReadFull(sr, data); err != nil {
+		return fmt.Errorf("failed to read snappy frames of %d byte payload: %w", n, err)
+	}
+	if err := dst.Deserialize(codec.NewDecodingReader(bytes.NewReader(data), n)); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+	return nil
+}
+
+// WriteRequest writes the request payload. The writer should close the stream after the request.
+func WriteRequest(w io.Writer, req codec.Serializable) error {
+	return writePayload(w, req)
+}
+
+// ReadRequest reads the request payload into dst, and checks that the stream ends after the request.
+func ReadRequest(r io.Reader, dst codec.Deserializable, maxLen uint64) error {
+	if err := readPayload(r, dst, maxLen); err != nil {
+		return err
+	}
+	var b [1]byte
+	if n, _ := r.Read(b[:]); n != 0 {
+		return TrailingDataErr
+	}
+	return nil
+}
+
+// WriteResponseChunk writes a success response chunk, with the context bytes (if any) before the payload.
+func WriteResponseChunk(w io.Writer, context []byte, obj codec.Serializable) error {
+	if _, err := w.Write(append([]byte{byte(SuccessCode)}, context...)); err != nil {
+		return err
+	}
+	return writePayload(w, obj)
+}
+
+// errorMessage is the SSZ List[byte, MaxErrorMessageLen] payload of error responses.
+type errorMessage []byte
+
+func (m *errorMessage) Deserialize(dr *codec.DecodingReader) error {
+	return dr.ByteList((*[]byte)(m), MaxErrorMessageLen)
+}
+
+func (m errorMessage) Serialize(w *codec.EncodingWriter) error {
+	return w.Write(m)
+}
+
+func (m errorMessage) ByteLength() uint64 {
+	return uint64(len(m))
+}
+
+func (errorMessage) FixedLength() uint64 {
+	return 0
+}
+
+// WriteErrorChunk writes an error response chunk. Error responses have no context bytes.
+// The message is truncated to MaxErrorMessageLen bytes.
+func WriteErrorChunk(w io.Writer, code ResponseCode, msg string) error {
+	if code == SuccessCode {
+		return errors.New("error response cannot have the success code")
+	}
+	if len(msg) > MaxErrorMessageLen {
+		msg = msg[:MaxErrorMessageLen]
+	}
+	if _, err := w.Write([]byte{byte(code)}); err != nil {
+		return err
+	}
+	return writePayload(w, errorMessage(msg))
+}
+
+// ChunkAllocator picks the destination of the payload of a response chunk, and its max length, by the context bytes of the chunk.
+type ChunkAllocator func(context []byte) (dst codec.Deserializable, maxLen uint64, err error)
+
+// ReadResponseChunk reads the next response chunk, with contextLen context bytes, and returns the decoded payload.
+// io.EOF is returned if the stream ended before the chunk. An error response is returned as *ResponseError.
+func ReadResponseChunk(r io.Reader, contextLen int, alloc ChunkAllocator) (codec.Deserializable, error) {
+	var code [1]byte
+	if _, err := io.ReadFull(r, code[:]); err != nil {
+		return nil, err
+	}
+	if ResponseCode(code[0]) != SuccessCode {
+		var msg errorMessage
+		if err := readPayload(r, &msg, MaxErrorMessageLen); err != nil {
+			return nil, fmt.Errorf("failed to read error response (%s): %w", ResponseCode(code[0]), err)
+		}
+		return nil, &ResponseError{Code: ResponseCode(code[0]), Message: string(msg)}
+	}
+	context := make([]byte, contextLen)
+	if _, err := io.ReadFull(r, context); err != nil {
+		return nil, fmt.Errorf("failed to read context bytes: %w", noEOF(err))
+	}
+	dst, maxLen, err := alloc(context)
+	if err != nil {
+		return nil, err
+	}
+	if err := readPayload(r, dst, maxLen); err != nil {
+		return nil, noEOF(err)
+	}
+	return dst, nil
+}
+
+// noEOF converts an EOF within a chunk to an unexpected EOF, EOF only marks the end of the response.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
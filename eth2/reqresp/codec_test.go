@@ -0,0 +1,169 @@
+package reqresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	status := common.Status{
+		ForkDigest:     common.ForkDigest{1, 2, 3, 4},
+		FinalizedRoot:  common.Root{0xaa},
+		FinalizedEpoch: 3,
+		HeadRoot:       common.Root{0xbb},
+		HeadSlot:       123,
+	}
+	var buf bytes.Buffer
+	if err := WriteRequest(&buf, &status); err != nil {
+		t.Fatal(err)
+	}
+	// length prefix, followed by the snappy stream identifier
+	if !bytes.HasPrefix(buf.Bytes(), []byte{common.StatusByteLen, 0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}) {
+		t.Fatalf("unexpected encoding: %x", buf.Bytes())
+	}
+	var got common.Status
+	if err := ReadRequest(bytes.NewReader(buf.Bytes()), &got, MaxStatusRequestLen); err != nil {
+		t.Fatal(err)
+	}
+	if got != status {
+		t.Fatalf("got %s, expected %s", &got, &status)
+	}
+
+	// trailing data after the request is invalid
+	trailing := append(append([]byte{}, buf.Bytes()...), 0)
+	if err := ReadRequest(bytes.NewReader(trailing), &got, MaxStatusRequestLen); !errors.Is(err, TrailingDataErr) {
+		t.Fatalf("expected trailing data error, got %v", err)
+	}
+
+	// the length prefix must match the fixed length of the type
+	var ping common.Ping
+	if err := ReadRequest(bytes.NewReader(buf.Bytes()), &ping, MaxPingRequestLen); !errors.Is(err, PayloadSizeErr) {
+		t.Fatalf("expected payload size error, got %v", err)
+	}
+
+	roots := common.BeaconBlocksByRootRequest{{1}, {2}, {3}}
+	buf.Reset()
+	if err := WriteRequest(&buf, roots); err != nil {
+		t.Fatal(err)
+	}
+	var gotRoots common.BeaconBlocksByRootRequest
+	if err := ReadRequest(bytes.NewReader(buf.Bytes()), &gotRoots, MaxBlocksByRootRequestLen); err != nil {
+		t.Fatal(err)
+	}
+	if len(gotRoots) != 3 || gotRoots[2] != roots[2] {
+		t.Fatalf("unexpected roots: %v", gotRoots)
+	}
+	if err := ReadRequest(bytes.NewReader(buf.Bytes()), &gotRoots, 2*32); !errors.Is(err, PayloadSizeErr) {
+		t.Fatalf("expected payload size error, got %v", err)
+	}
+
+	// a large length prefix is refused before anything is decompressed
+	oversized := []byte{0x80, 0x80, 0x80, 0x08}
+	if err := ReadRequest(bytes.NewReader(oversized), &gotRoots, MaxBlocksByRootRequestLen); !errors.Is(err, PayloadSizeErr) {
+		t.Fatalf("expected payload size error, got %v", err)
+	}
+}
+
+func TestResponseChunks(t *testing.T) {
+	var buf bytes.Buffer
+	pong := common.Pong(42)
+	if err := WriteResponseChunk(&buf, nil, pong); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteErrorChunk(&buf, ResourceUnavailableCode, strings.Repeat("x", 300)); err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(buf.Bytes())
+	got, err := ReadPong(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != pong {
+		t.Fatalf("got pong %d, expected %d", got, pong)
+	}
+	_, err = ReadPong(r)
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.Code != ResourceUnavailableCode || len(respErr.Message) != MaxErrorMessageLen {
+		t.Fatalf("expected truncated error response, got %v", err)
+	}
+	if _, err := ReadPong(r); err != io.EOF {
+		t.Fatalf("expected EOF after last chunk, got %v", err)
+	}
+}
+
+func TestBlockChunks(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 1
+	decoder := beacon.NewForkDecoder(&spec, common.Root{0x42})
+
+	phase0Block := &phase0.SignedBeaconBlock{Message: phase0.BeaconBlock{Slot: 3, ProposerIndex: 1}}
+	altairBlock := &altair.SignedBeaconBlock{Message: altair.BeaconBlock{Slot: spec.SLOTS_PER_EPOCH + 1, ProposerIndex: 2}}
+	altairBlock.Message.Body.SyncAggregate.SyncCommitteeBits = make(altair.SyncCommitteeBits, spec.SYNC_COMMITTEE_SIZE/8)
+	envs := []*common.BeaconBlockEnvelope{
+		phase0Block.Envelope(&spec, decoder.Genesis),
+		altairBlock.Envelope(&spec, decoder.Altair),
+	}
+
+	v2 := &BlockCodec{Decoder: decoder, Version: 2}
+	var buf bytes.Buffer
+	for _, benv := range envs {
+		if err := v2.WriteBlock(&buf, benv); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := bytes.NewReader(buf.Bytes())
+	for i, expected := range envs {
+		got, err := v2.ReadBlock(r)
+		if err != nil {
+			t.Fatalf("block %d: %v", i, err)
+		}
+		if got.ForkDigest != expected.ForkDigest || got.BlockRoot != expected.BlockRoot {
+			t.Fatalf("block %d: got block %s of fork %s, expected %s of fork %s",
+				i, got.BlockRoot, got.ForkDigest, expected.BlockRoot, expected.ForkDigest)
+		}
+	}
+	if _, err := v2.ReadBlock(r); err != io.EOF {
+		t.Fatalf("expected EOF after last block, got %v", err)
+	}
+
+	// version 1 has no context bytes, and only supports phase0 blocks
+	v1 := &BlockCodec{Decoder: decoder, Version: 1}
+	buf.Reset()
+	if err := v1.WriteBlock(&buf, envs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.WriteBlock(&buf, envs[1]); err == nil {
+		t.Fatal("expected altair block to be refused by version 1")
+	}
+	got, err := v1.ReadBlock(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BlockRoot != envs[0].BlockRoot {
+		t.Fatalf("got block %s, expected %s", got.BlockRoot, envs[0].BlockRoot)
+	}
+
+	// a chunk cut off within the payload is not a clean end of the response
+	buf.Reset()
+	if err := v2.WriteBlock(&buf, envs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v2.ReadBlock(bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil || err == io.EOF {
+		t.Fatalf("expected error for truncated chunk, got %v", err)
+	}
+	// unknown fork digests are refused
+	data := buf.Bytes()
+	copy(data[1:5], []byte{0xde, 0xad, 0xbe, 0xef})
+	if _, err := v2.ReadBlock(bytes.NewReader(data)); err == nil {
+		t.Fatal("expected unknown fork digest to be refused")
+	}
+}
@@ -0,0 +1,139 @@
+package reqresp
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/ztyp/codec"
+)
+
+// Protocol IDs of the req/resp methods, with ssz_snappy encoding.
+const (
+	StatusProtocolID          = "/eth2/beacon_chain/req/status/1/ssz_snappy"
+	GoodbyeProtocolID         = "/eth2/beacon_chain/req/goodbye/1/ssz_snappy"
+	BlocksByRangeProtocolID   = "/eth2/beacon_chain/req/beacon_blocks_by_range/1/ssz_snappy"
+	BlocksByRangeV2ProtocolID = "/eth2/beacon_chain/req/beacon_blocks_by_range/2/ssz_snappy"
+	BlocksByRootProtocolID    = "/eth2/beacon_chain/req/beacon_blocks_by_root/1/ssz_snappy"
+	BlocksByRootV2ProtocolID  = "/eth2/beacon_chain/req/beacon_blocks_by_root/2/ssz_snappy"
+	PingProtocolID            = "/eth2/beacon_chain/req/ping/1/ssz_snappy"
+	MetaDataProtocolID        = "/eth2/beacon_chain/req/metadata/1/ssz_snappy"
+)
+
+// Max byte lengths of the requests of each method. MetaData requests have no payload.
+const (
+	MaxStatusRequestLen        = common.StatusByteLen
+	MaxGoodbyeRequestLen       = 8
+	MaxBlocksByRangeRequestLen = common.BeaconBlocksByRangeRequestByteLen
+	MaxBlocksByRootRequestLen  = common.MAX_REQUEST_BLOCKS * 32
+	MaxPingRequestLen          = 8
+)
+
+// ReadStatus reads a single Status chunk, as responded to a Status request.
+func ReadStatus(r io.Reader) (*common.Status, error) {
+	var out common.Status
+	if _, err := ReadResponseChunk(r, 0, fixedChunk(&out)); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReadPong reads a single Pong chunk, as responded to a Ping request.
+func ReadPong(r io.Reader) (common.Pong, error) {
+	var out common.Pong
+	if _, err := ReadResponseChunk(r, 0, fixedChunk(&out)); err != nil {
+		return 0, err
+	}
+	return out, nil
+}
+
+// ReadMetaData reads a single MetaData chunk, as responded to a MetaData request.
+func ReadMetaData(r io.Reader) (*common.MetaData, error) {
+	var out common.MetaData
+	if _, err := ReadResponseChunk(r, 0, fixedChunk(&out)); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func fixedChunk(dst codec.Deserializable) ChunkAllocator {
+	return func(context []byte) (codec.Deserializable, uint64, error) {
+		return dst, dst.FixedLength(), nil
+	}
+}
+
+// BlockCodec encodes and decodes the block response chunks of BeaconBlocksByRange and BeaconBlocksByRoot.
+// Version 1 of the methods only supports phase0 blocks, without context bytes.
+// Version 2 prefixes each block with the fork digest of the block, to decode the block with the type of its fork.
+type BlockCodec struct {
+	Decoder *beacon.ForkDecoder
+	Version int
+}
+
+func (c *BlockCodec) contextLen() int {
+	if c.Version >= 2 {
+		return 4
+	}
+	return 0
+}
+
+// WriteBlock writes a success chunk with the block of the envelope.
+func (c *BlockCodec) WriteBlock(w io.Writer, benv *common.BeaconBlockEnvelope) error {
+	block, err := beacon.EnvelopeToSignedBeaconBlock(benv)
+	if err != nil {
+		return err
+	}
+	var context []byte
+	if c.Version >= 2 {
+		context = benv.ForkDigest[:]
+	} else if benv.ForkDigest != c.Decoder.Genesis {
+		return fmt.Errorf("block %s at slot %d of fork %s is not supported by version %d", benv.BlockRoot, benv.Slot, benv.ForkDigest, c.Version)
+	}
+	return WriteResponseChunk(w, context, c.Decoder.Spec.Wrap(block))
+}
+
+// ReadBlock reads the next block chunk, and returns the envelope of the block.
+// io.EOF is returned if the response ended before the chunk. An error response is returned as *ResponseError.
+func (c *BlockCodec) ReadBlock(r io.Reader) (*common.BeaconBlockEnvelope, error) {
+	var block beacon.OpaqueBlock
+	var digest common.ForkDigest
+	alloc := func(context []byte) (codec.Deserializable, uint64, error) {
+		if c.Version >= 2 {
+			copy(digest[:], context)
+		} else {
+			digest = c.Decoder.Genesis
+		}
+		blockAlloc, err := c.Decoder.BlockAllocator(digest)
+		if err != nil {
+			return nil, 0, err
+		}
+		block = blockAlloc()
+		return c.Decoder.Spec.Wrap(block), c.maxBlockLen(digest), nil
+	}
+	if _, err := ReadResponseChunk(r, c.contextLen(), alloc); err != nil {
+		return nil, err
+	}
+	return block.Envelope(c.Decoder.Spec, digest), nil
+}
+
+// maxBlockLen is the max SSZ byte length of a signed block of the fork, as bounded by the preset.
+func (c *BlockCodec) maxBlockLen(digest common.ForkDigest) uint64 {
+	d := c.Decoder
+	switch digest {
+	case d.Genesis:
+		return phase0.SignedBeaconBlockType(d.Spec).MaxByteLength()
+	case d.Altair:
+		return altair.SignedBeaconBlockType(d.Spec).MaxByteLength()
+	case d.Bellatrix:
+		return bellatrix.SignedBeaconBlockType(d.Spec).MaxByteLength()
+	case d.Capella:
+		return capella.SignedBeaconBlockType(d.Spec).MaxByteLength()
+	default:
+		return common.MAX_CHUNK_SIZE
+	}
+}
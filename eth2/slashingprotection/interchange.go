@@ -0,0 +1,71 @@
+package slashingprotection
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// INTERCHANGE_FORMAT_VERSION is the supported version of the EIP-3076 interchange format.
+const INTERCHANGE_FORMAT_VERSION = "5"
+
+// Interchange is the EIP-3076 slashing protection interchange format, complete variant.
+type Interchange struct {
+	Metadata InterchangeMetadata `json:"metadata"`
+	Data     []ValidatorHistory  `json:"data"`
+}
+
+type InterchangeMetadata struct {
+	InterchangeFormatVersion string      `json:"interchange_format_version"`
+	GenesisValidatorsRoot    common.Root `json:"genesis_validators_root"`
+}
+
+// ValidatorHistory is the signing history of a single validator.
+type ValidatorHistory struct {
+	Pubkey             common.BLSPubkey    `json:"pubkey"`
+	SignedBlocks       []SignedBlock       `json:"signed_blocks"`
+	SignedAttestations []SignedAttestation `json:"signed_attestations"`
+}
+
+func (h *ValidatorHistory) Copy() *ValidatorHistory {
+	return &ValidatorHistory{
+		Pubkey:             h.Pubkey,
+		SignedBlocks:       append([]SignedBlock(nil), h.SignedBlocks...),
+		SignedAttestations: append([]SignedAttestation(nil), h.SignedAttestations...),
+	}
+}
+
+type SignedBlock struct {
+	Slot common.Slot `json:"slot"`
+	// Optional, nil if unknown. A block can only be signed again if the signing root is known and the same.
+	SigningRoot *common.Root `json:"signing_root,omitempty"`
+}
+
+type SignedAttestation struct {
+	SourceEpoch common.Epoch `json:"source_epoch"`
+	TargetEpoch common.Epoch `json:"target_epoch"`
+	// Optional, nil if unknown. An attestation can only be signed again if the signing root is known and the same.
+	SigningRoot *common.Root `json:"signing_root,omitempty"`
+}
+
+// ReadInterchange decodes and checks the version of EIP-3076 interchange JSON.
+func ReadInterchange(r io.Reader) (*Interchange, error) {
+	var out Interchange
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode interchange: %v", err)
+	}
+	if v := out.Metadata.InterchangeFormatVersion; v != INTERCHANGE_FORMAT_VERSION {
+		return nil, fmt.Errorf("unsupported interchange format version %q, expected %q", v, INTERCHANGE_FORMAT_VERSION)
+	}
+	return &out, nil
+}
+
+// WriteInterchange encodes the interchange as JSON.
+func WriteInterchange(w io.Writer, interchange *Interchange) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(interchange)
+}
@@ -0,0 +1,218 @@
+package slashingprotection
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// SlashableErr is wrapped by all errors of signing requests that are refused by the slashing protection.
+var SlashableErr = errors.New("refused to sign slashable message")
+
+// SlashingProtection checks messages against the signing history of the validator before they are signed,
+// and records them in the history, following the conditions of EIP-3076.
+// Checking and recording is atomic, and safe for concurrent signing.
+type SlashingProtection struct {
+	mu                    sync.Mutex
+	genesisValidatorsRoot common.Root
+	store                 Store
+}
+
+func NewSlashingProtection(genesisValidatorsRoot common.Root, store Store) *SlashingProtection {
+	return &SlashingProtection{genesisValidatorsRoot: genesisValidatorsRoot, store: store}
+}
+
+func (sp *SlashingProtection) history(pubkey common.BLSPubkey) (*ValidatorHistory, error) {
+	h, err := sp.store.Load(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of %s: %v", pubkey, err)
+	}
+	if h == nil {
+		h = &ValidatorHistory{Pubkey: pubkey}
+	}
+	return h, nil
+}
+
+func sameRoot(a *common.Root, b common.Root) bool {
+	return a != nil && *a == b
+}
+
+// CheckAndRecordBlock checks if the block proposal at the slot is safe to sign, and records it if so.
+// The block must only be signed if no error is returned.
+// Signing the same block (same signing root) again is allowed.
+func (sp *SlashingProtection) CheckAndRecordBlock(pubkey common.BLSPubkey, slot common.Slot, signingRoot common.Root) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	h, err := sp.history(pubkey)
+	if err != nil {
+		return err
+	}
+	for _, b := range h.SignedBlocks {
+		if b.Slot == slot {
+			if sameRoot(b.SigningRoot, signingRoot) {
+				return nil
+			}
+			return fmt.Errorf("%w: double block proposal at slot %d", SlashableErr, slot)
+		}
+	}
+	// The history may be incomplete, e.g. after importing a minimal interchange:
+	// never sign anything older than the lowest recorded slot.
+	if len(h.SignedBlocks) > 0 {
+		min := h.SignedBlocks[0].Slot
+		for _, b := range h.SignedBlocks[1:] {
+			if b.Slot < min {
+				min = b.Slot
+			}
+		}
+		if slot < min {
+			return fmt.Errorf("%w: block slot %d is lower than lowest signed slot %d", SlashableErr, slot, min)
+		}
+	}
+	root := signingRoot
+	h.SignedBlocks = append(h.SignedBlocks, SignedBlock{Slot: slot, SigningRoot: &root})
+	if err := sp.store.Save(h); err != nil {
+		return fmt.Errorf("failed to record block of %s at slot %d: %v", pubkey, slot, err)
+	}
+	return nil
+}
+
+// CheckAndRecordAttestation checks if the attestation with the given source and target is safe to sign,
+// and records it if so. The attestation must only be signed if no error is returned.
+// Signing the same attestation (same signing root) again is allowed.
+func (sp *SlashingProtection) CheckAndRecordAttestation(pubkey common.BLSPubkey, source common.Epoch, target common.Epoch, signingRoot common.Root) error {
+	if source > target {
+		return fmt.Errorf("%w: attestation source epoch %d is higher than target epoch %d", SlashableErr, source, target)
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	h, err := sp.history(pubkey)
+	if err != nil {
+		return err
+	}
+	for _, a := range h.SignedAttestations {
+		if a.TargetEpoch == target {
+			if sameRoot(a.SigningRoot, signingRoot) {
+				return nil
+			}
+			return fmt.Errorf("%w: double vote for target epoch %d", SlashableErr, target)
+		}
+	}
+	for _, a := range h.SignedAttestations {
+		if a.SourceEpoch < source && target < a.TargetEpoch {
+			return fmt.Errorf("%w: attestation %d -> %d is surrounded by previous attestation %d -> %d",
+				SlashableErr, source, target, a.SourceEpoch, a.TargetEpoch)
+		}
+		if source < a.SourceEpoch && a.TargetEpoch < target {
+			return fmt.Errorf("%w: attestation %d -> %d surrounds previous attestation %d -> %d",
+				SlashableErr, source, target, a.SourceEpoch, a.TargetEpoch)
+		}
+	}
+	// The history may be incomplete, e.g. after importing a minimal interchange:
+	// never sign anything older than the lowest recorded source and target.
+	if len(h.SignedAttestations) > 0 {
+		minSource, minTarget := h.SignedAttestations[0].SourceEpoch, h.SignedAttestations[0].TargetEpoch
+		for _, a := range h.SignedAttestations[1:] {
+			if a.SourceEpoch < minSource {
+				minSource = a.SourceEpoch
+			}
+			if a.TargetEpoch < minTarget {
+				minTarget = a.TargetEpoch
+			}
+		}
+		if source < minSource {
+			return fmt.Errorf("%w: attestation source %d is lower than lowest signed source %d", SlashableErr, source, minSource)
+		}
+		if target <= minTarget {
+			return fmt.Errorf("%w: attestation target %d is not higher than lowest signed target %d", SlashableErr, target, minTarget)
+		}
+	}
+	root := signingRoot
+	h.SignedAttestations = append(h.SignedAttestations, SignedAttestation{SourceEpoch: source, TargetEpoch: target, SigningRoot: &root})
+	if err := sp.store.Save(h); err != nil {
+		return fmt.Errorf("failed to record attestation of %s with target %d: %v", pubkey, target, err)
+	}
+	return nil
+}
+
+// Import merges the history of the interchange into the signing history.
+// The interchange must be of the same chain, i.e. have the same genesis validators root.
+func (sp *SlashingProtection) Import(interchange *Interchange) error {
+	if v := interchange.Metadata.InterchangeFormatVersion; v != INTERCHANGE_FORMAT_VERSION {
+		return fmt.Errorf("unsupported interchange format version %q, expected %q", v, INTERCHANGE_FORMAT_VERSION)
+	}
+	if root := interchange.Metadata.GenesisValidatorsRoot; root != sp.genesisValidatorsRoot {
+		return fmt.Errorf("interchange genesis validators root %s does not match %s", root, sp.genesisValidatorsRoot)
+	}
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for i := range interchange.Data {
+		imported := &interchange.Data[i]
+		for _, a := range imported.SignedAttestations {
+			if a.SourceEpoch > a.TargetEpoch {
+				return fmt.Errorf("invalid attestation of %s in interchange, source %d > target %d",
+					imported.Pubkey, a.SourceEpoch, a.TargetEpoch)
+			}
+		}
+		h, err := sp.history(imported.Pubkey)
+		if err != nil {
+			return err
+		}
+		// Records are only added, never removed or replaced: the history can only become more restrictive.
+	blocks:
+		for _, b := range imported.SignedBlocks {
+			for _, existing := range h.SignedBlocks {
+				if existing.Slot == b.Slot && (existing.SigningRoot == nil || (b.SigningRoot != nil && *existing.SigningRoot == *b.SigningRoot)) {
+					continue blocks
+				}
+			}
+			h.SignedBlocks = append(h.SignedBlocks, b)
+		}
+	attestations:
+		for _, a := range imported.SignedAttestations {
+			for _, existing := range h.SignedAttestations {
+				if existing.SourceEpoch == a.SourceEpoch && existing.TargetEpoch == a.TargetEpoch &&
+					(existing.SigningRoot == nil || (a.SigningRoot != nil && *existing.SigningRoot == *a.SigningRoot)) {
+					continue attestations
+				}
+			}
+			h.SignedAttestations = append(h.SignedAttestations, a)
+		}
+		if err := sp.store.Save(h); err != nil {
+			return fmt.Errorf("failed to import history of %s: %v", imported.Pubkey, err)
+		}
+	}
+	return nil
+}
+
+// Export returns the complete signing history as interchange.
+func (sp *SlashingProtection) Export() (*Interchange, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	pubkeys, err := sp.store.Pubkeys()
+	if err != nil {
+		return nil, err
+	}
+	out := &Interchange{
+		Metadata: InterchangeMetadata{
+			InterchangeFormatVersion: INTERCHANGE_FORMAT_VERSION,
+			GenesisValidatorsRoot:    sp.genesisValidatorsRoot,
+		},
+		Data: make([]ValidatorHistory, 0, len(pubkeys)),
+	}
+	for _, pub := range pubkeys {
+		h, err := sp.history(pub)
+		if err != nil {
+			return nil, err
+		}
+		if h.SignedBlocks == nil {
+			h.SignedBlocks = []SignedBlock{}
+		}
+		if h.SignedAttestations == nil {
+			h.SignedAttestations = []SignedAttestation{}
+		}
+		out.Data = append(out.Data, *h)
+	}
+	return out, nil
+}
@@ -0,0 +1,265 @@
+package slashingprotection
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+var (
+	testGenesisRoot = common.Root{0x04, 0x70}
+	testPubkey      = common.BLSPubkey{0xa1}
+)
+
+func TestBlocks(t *testing.T) {
+	sp := NewSlashingProtection(testGenesisRoot, NewMemoryStore())
+	for _, c := range []struct {
+		slot      common.Slot
+		root      common.Root
+		slashable bool
+	}{
+		{10, common.Root{1}, false},
+		{10, common.Root{1}, false}, // repeat of the same block
+		{10, common.Root{2}, true},  // double proposal
+		{12, common.Root{3}, false},
+		{11, common.Root{4}, false}, // above the lowest slot, not slashable
+		{9, common.Root{5}, true},   // below the lowest slot
+	} {
+		err := sp.CheckAndRecordBlock(testPubkey, c.slot, c.root)
+		if c.slashable != errors.Is(err, SlashableErr) || (!c.slashable && err != nil) {
+			t.Fatalf("block at slot %d with root %s: unexpected result: %v", c.slot, c.root, err)
+		}
+	}
+	// other validators are not affected
+	if err := sp.CheckAndRecordBlock(common.BLSPubkey{0xb2}, 9, common.Root{5}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttestations(t *testing.T) {
+	sp := NewSlashingProtection(testGenesisRoot, NewMemoryStore())
+	for _, c := range []struct {
+		source, target common.Epoch
+		root           common.Root
+		slashable      bool
+	}{
+		{2, 3, common.Root{1}, false},
+		{2, 3, common.Root{1}, false}, // repeat of the same attestation
+		{2, 3, common.Root{2}, true},  // double vote
+		{4, 3, common.Root{3}, true},  // source after target
+		{3, 10, common.Root{4}, false},
+		{4, 9, common.Root{5}, true},  // surrounded by 3 -> 10
+		{2, 11, common.Root{6}, true}, // surrounds 3 -> 10
+		{5, 11, common.Root{7}, false},
+		{1, 4, common.Root{8}, true}, // below the lowest source
+		{2, 3, common.Root{9}, true}, // at the lowest target
+	} {
+		err := sp.CheckAndRecordAttestation(testPubkey, c.source, c.target, c.root)
+		if c.slashable != errors.Is(err, SlashableErr) || (!c.slashable && err != nil) {
+			t.Fatalf("attestation %d -> %d with root %s: unexpected result: %v", c.source, c.target, c.root, err)
+		}
+	}
+}
+
+func TestConcurrentSigning(t *testing.T) {
+	sp := NewSlashingProtection(testGenesisRoot, NewMemoryStore())
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	signed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := sp.CheckAndRecordAttestation(testPubkey, 1, 2, common.Root{byte(i)}); err == nil {
+				mu.Lock()
+				signed++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if signed != 1 {
+		t.Fatalf("expected exactly one of the conflicting attestations to be signed, got %d", signed)
+	}
+}
+
+const testInterchange = `{
+  "metadata": {
+    "interchange_format_version": "5",
+    "genesis_validators_root": "0x0470000000000000000000000000000000000000000000000000000000000000"
+  },
+  "data": [
+    {
+      "pubkey": "0xa10000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000",
+      "signed_blocks": [
+        {"slot": "81952", "signing_root": "0x0400000000000000000000000000000000000000000000000000000000000000"},
+        {"slot": "81951"}
+      ],
+      "signed_attestations": [
+        {"source_epoch": "2290", "target_epoch": "3007", "signing_root": "0x0587000000000000000000000000000000000000000000000000000000000000"},
+        {"source_epoch": "2290", "target_epoch": "3008"}
+      ]
+    }
+  ]
+}`
+
+func TestInterchange(t *testing.T) {
+	interchange, err := ReadInterchange(strings.NewReader(testInterchange))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewSlashingProtection(common.Root{0xff}, NewMemoryStore()).Import(interchange); err == nil {
+		t.Fatal("expected interchange of other chain to be rejected")
+	}
+	sp := NewSlashingProtection(testGenesisRoot, NewMemoryStore())
+	if err := sp.Import(interchange); err != nil {
+		t.Fatal(err)
+	}
+	// importing twice does not duplicate records
+	if err := sp.Import(interchange); err != nil {
+		t.Fatal(err)
+	}
+	// known signing root may be signed again, unknown signing roots may not
+	if err := sp.CheckAndRecordBlock(testPubkey, 81952, common.Root{0x04}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.CheckAndRecordBlock(testPubkey, 81951, common.Root{}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected block without known signing root to be refused, got %v", err)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 2290, 3008, common.Root{}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected attestation without known signing root to be refused, got %v", err)
+	}
+	// the imported history is incomplete: older messages are refused
+	if err := sp.CheckAndRecordAttestation(testPubkey, 2000, 2001, common.Root{1}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected attestation below the imported history to be refused, got %v", err)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 3008, 3009, common.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := sp.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Data) != 1 || len(exported.Data[0].SignedBlocks) != 2 || len(exported.Data[0].SignedAttestations) != 3 {
+		t.Fatalf("unexpected exported history: %v", exported.Data)
+	}
+	var buf bytes.Buffer
+	if err := WriteInterchange(&buf, exported); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := ReadInterchange(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reread.Metadata != exported.Metadata || len(reread.Data[0].SignedAttestations) != 3 {
+		t.Fatalf("interchange did not round-trip: %v", reread)
+	}
+	if _, err := ReadInterchange(strings.NewReader(strings.Replace(testInterchange, `"5"`, `"4"`, 1))); err == nil {
+		t.Fatal("expected unsupported interchange version to be rejected")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slashing-protection")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp := NewSlashingProtection(testGenesisRoot, store)
+	if err := sp.CheckAndRecordBlock(testPubkey, 10, common.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 2, 3, common.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.CheckAndRecordBlock(common.BLSPubkey{0xb2}, 10, common.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The history survives a restart
+	store, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp = NewSlashingProtection(testGenesisRoot, store)
+	if err := sp.CheckAndRecordBlock(testPubkey, 10, common.Root{2}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected double proposal after restart to be refused, got %v", err)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 2, 3, common.Root{2}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected double vote after restart to be refused, got %v", err)
+	}
+	// Every validator has its own history file
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected a history file per validator, got %d files", len(files))
+	}
+
+	// The history is pruned to the low watermarks
+	if err := sp.CheckAndRecordBlock(testPubkey, 12, common.Root{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.CheckAndRecordBlock(testPubkey, 11, common.Root{4}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected block below the highest signed slot to be refused, got %v", err)
+	}
+	for _, c := range []struct {
+		source, target common.Epoch
+	}{{5, 6}, {6, 8}} {
+		if err := sp.CheckAndRecordAttestation(testPubkey, c.source, c.target, common.Root{byte(c.target)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 6 -> 7 is not slashable, but below the highest target
+	if err := sp.CheckAndRecordAttestation(testPubkey, 6, 7, common.Root{7}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected attestation below the low watermarks to be refused, got %v", err)
+	}
+	h, err := store.Load(testPubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.SignedBlocks) != 1 || h.SignedBlocks[0].Slot != 12 || *h.SignedBlocks[0].SigningRoot != (common.Root{3}) {
+		t.Fatalf("unexpected pruned blocks: %v", h.SignedBlocks)
+	}
+	if len(h.SignedAttestations) != 1 || h.SignedAttestations[0].TargetEpoch != 8 || *h.SignedAttestations[0].SigningRoot != (common.Root{8}) {
+		t.Fatalf("unexpected pruned attestations: %v", h.SignedAttestations)
+	}
+	// An imported history may have the highest source and target in different attestations
+	root := common.Root{9}
+	if err := sp.Import(&Interchange{
+		Metadata: InterchangeMetadata{InterchangeFormatVersion: INTERCHANGE_FORMAT_VERSION, GenesisValidatorsRoot: testGenesisRoot},
+		Data: []ValidatorHistory{{Pubkey: testPubkey, SignedAttestations: []SignedAttestation{
+			{SourceEpoch: 7, TargetEpoch: 9, SigningRoot: &root},
+			{SourceEpoch: 4, TargetEpoch: 10},
+		}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = store.Load(testPubkey); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.SignedAttestations) != 1 || h.SignedAttestations[0] != (SignedAttestation{SourceEpoch: 7, TargetEpoch: 10}) {
+		t.Fatalf("unexpected pruned attestations: %v", h.SignedAttestations)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 6, 11, common.Root{11}); !errors.Is(err, SlashableErr) {
+		t.Fatalf("expected attestation below the highest source to be refused, got %v", err)
+	}
+	if err := sp.CheckAndRecordAttestation(testPubkey, 7, 11, common.Root{11}); err != nil {
+		t.Fatal(err)
+	}
+}
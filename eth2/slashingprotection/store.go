@@ -0,0 +1,192 @@
+package slashingprotection
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Store persists the signing history of validators.
+// The slashing protection holds a lock while using the store, implementations do not have to be safe for concurrent use.
+type Store interface {
+	// Load returns the history of the validator, or nil if there is no history.
+	Load(pubkey common.BLSPubkey) (*ValidatorHistory, error)
+	// Save replaces the history of the validator. The store must not return before the history is persisted.
+	Save(history *ValidatorHistory) error
+	// Pubkeys lists the validators with a history.
+	Pubkeys() ([]common.BLSPubkey, error)
+}
+
+// MemoryStore keeps the signing history in memory only.
+type MemoryStore struct {
+	histories map[common.BLSPubkey]*ValidatorHistory
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{histories: make(map[common.BLSPubkey]*ValidatorHistory)}
+}
+
+func (s *MemoryStore) Load(pubkey common.BLSPubkey) (*ValidatorHistory, error) {
+	h, ok := s.histories[pubkey]
+	if !ok {
+		return nil, nil
+	}
+	return h.Copy(), nil
+}
+
+func (s *MemoryStore) Save(history *ValidatorHistory) error {
+	s.histories[history.Pubkey] = history.Copy()
+	return nil
+}
+
+func (s *MemoryStore) Pubkeys() ([]common.BLSPubkey, error) {
+	out := make([]common.BLSPubkey, 0, len(s.histories))
+	for k := range s.histories {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return string(out[i][:]) < string(out[j][:])
+	})
+	return out, nil
+}
+
+// FileStore keeps the signing history in memory, and writes the history of a validator to its own JSON file
+// in the store directory on every change. Only the changed validator is written, and the file is replaced atomically,
+// so a crash never leaves a partially written history behind.
+//
+// The history is pruned to the EIP-3076 low watermarks before it is saved: only the block with the highest slot,
+// and an attestation with the highest source and target epoch are kept. Slashing protection only refuses more
+// messages with a pruned history: nothing at or below the highest signed slot and target, or below the highest source,
+// can be signed anymore, except for a repeat of the latest message with a known signing root.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+	mem *MemoryStore
+}
+
+var _ Store = (*FileStore)(nil)
+
+// OpenFileStore loads the histories in the given directory, or starts an empty history if the directory does not exist yet.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, mem: NewMemoryStore()}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var h ValidatorHistory
+		if err := json.Unmarshal(data, &h); err != nil {
+			return nil, fmt.Errorf("failed to decode slashing protection history %q: %v", path, err)
+		}
+		if name := s.historyPath(h.Pubkey); name != path {
+			return nil, fmt.Errorf("slashing protection history of %s is stored in unexpected file %q", h.Pubkey, path)
+		}
+		s.mem.histories[h.Pubkey] = &h
+	}
+	return s, nil
+}
+
+func (s *FileStore) Load(pubkey common.BLSPubkey) (*ValidatorHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Load(pubkey)
+}
+
+func (s *FileStore) Save(history *ValidatorHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruned := lowWatermark(history)
+	if err := s.flush(pruned); err != nil {
+		return err
+	}
+	s.mem.histories[pruned.Pubkey] = pruned
+	return nil
+}
+
+func (s *FileStore) Pubkeys() ([]common.BLSPubkey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.Pubkeys()
+}
+
+func (s *FileStore) historyPath(pubkey common.BLSPubkey) string {
+	return filepath.Join(s.dir, pubkey.String()+".json")
+}
+
+// lowWatermark prunes a copy of the history to the records that keep the same lowest signable slot, source and target.
+func lowWatermark(history *ValidatorHistory) *ValidatorHistory {
+	out := &ValidatorHistory{Pubkey: history.Pubkey}
+	if len(history.SignedBlocks) > 0 {
+		latest := history.SignedBlocks[0]
+		for _, b := range history.SignedBlocks[1:] {
+			if b.Slot > latest.Slot {
+				latest = b
+			}
+		}
+		out.SignedBlocks = []SignedBlock{latest}
+	}
+	if len(history.SignedAttestations) > 0 {
+		latest := history.SignedAttestations[0]
+		maxSource := latest.SourceEpoch
+		for _, a := range history.SignedAttestations[1:] {
+			if a.TargetEpoch > latest.TargetEpoch {
+				latest = a
+			}
+			if a.SourceEpoch > maxSource {
+				maxSource = a.SourceEpoch
+			}
+		}
+		// The highest source and target may be of different attestations:
+		// then no attestation is the same as the combined record, and its signing root is unknown.
+		if latest.SourceEpoch != maxSource {
+			latest = SignedAttestation{SourceEpoch: maxSource, TargetEpoch: latest.TargetEpoch}
+		}
+		out.SignedAttestations = []SignedAttestation{latest}
+	}
+	return out
+}
+
+func (s *FileStore) flush(history *ValidatorHistory) error {
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.historyPath(history.Pubkey))
+}
@@ -0,0 +1,24 @@
+package merkle
+
+import (
+	"fmt"
+
+	"github.com/protolambda/ztyp/tree"
+)
+
+// MerkleBranch collects the sibling roots along the path from the node at the generalized index up to the root node.
+// The branch is ordered bottom-up, like VerifyMerkleBranch expects, with depth = gindex.Depth().
+func MerkleBranch(root tree.Node, gindex tree.Gindex64, hFn tree.HashFn) ([]tree.Root, error) {
+	if gindex == 0 {
+		return nil, fmt.Errorf("invalid generalized index: %d", gindex)
+	}
+	branch := make([]tree.Root, 0, gindex.Depth())
+	for g := gindex; g > tree.RootGindex; g >>= 1 {
+		sibling, err := root.Getter(g ^ 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sibling %d of %d: %v", g^1, gindex, err)
+		}
+		branch = append(branch, sibling.MerkleRoot(hFn))
+	}
+	return branch, nil
+}
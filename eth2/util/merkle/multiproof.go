@@ -0,0 +1,102 @@
+package merkle
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/protolambda/zrnt/eth2/util/hashing"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// HelperIndices returns the generalized indices of the nodes that are needed to prove the given indices together,
+// excluding the nodes that can be computed from the indices themselves. Ordered by descending generalized index.
+func HelperIndices(indices []tree.Gindex64) []tree.Gindex64 {
+	helpers := make(map[tree.Gindex64]struct{})
+	paths := make(map[tree.Gindex64]struct{})
+	for _, index := range indices {
+		for g := index; g > tree.RootGindex; g >>= 1 {
+			helpers[g^1] = struct{}{}
+			paths[g] = struct{}{}
+		}
+	}
+	out := make([]tree.Gindex64, 0, len(helpers))
+	for g := range helpers {
+		if _, ok := paths[g]; !ok {
+			out = append(out, g)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i] > out[j]
+	})
+	return out
+}
+
+// MultiBranch collects the roots of the helper nodes of the given indices, ordered like HelperIndices.
+func MultiBranch(root tree.Node, indices []tree.Gindex64, hFn tree.HashFn) ([]tree.Root, error) {
+	helpers := HelperIndices(indices)
+	branch := make([]tree.Root, 0, len(helpers))
+	for _, g := range helpers {
+		node, err := root.Getter(g)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get helper node %d: %v", g, err)
+		}
+		branch = append(branch, node.MerkleRoot(hFn))
+	}
+	return branch, nil
+}
+
+// CalculateMultiMerkleRoot computes the root that the leaves at the given indices are proven against,
+// with the branch of helper nodes as ordered by HelperIndices.
+func CalculateMultiMerkleRoot(leaves []tree.Root, branch []tree.Root, indices []tree.Gindex64) (tree.Root, error) {
+	if len(leaves) != len(indices) {
+		return tree.Root{}, fmt.Errorf("got %d leaves for %d indices", len(leaves), len(indices))
+	}
+	helpers := HelperIndices(indices)
+	if len(branch) != len(helpers) {
+		return tree.Root{}, fmt.Errorf("expected %d helper nodes, got %d", len(helpers), len(branch))
+	}
+	objects := make(map[tree.Gindex64]tree.Root, len(leaves)+len(branch))
+	keys := make([]tree.Gindex64, 0, len(leaves)+len(branch))
+	for i, g := range indices {
+		if g == 0 {
+			return tree.Root{}, fmt.Errorf("invalid generalized index: %d", g)
+		}
+		if _, ok := objects[g]; ok {
+			return tree.Root{}, fmt.Errorf("duplicate index: %d", g)
+		}
+		objects[g] = leaves[i]
+		keys = append(keys, g)
+	}
+	for i, g := range helpers {
+		objects[g] = branch[i]
+		keys = append(keys, g)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] > keys[j]
+	})
+	// Computed parents are appended, to be visited after the nodes they were computed from.
+	for pos := 0; pos < len(keys); pos++ {
+		k := keys[pos]
+		if k <= tree.RootGindex {
+			continue
+		}
+		_, hasSibling := objects[k^1]
+		_, hasParent := objects[k>>1]
+		if hasSibling && !hasParent {
+			left, right := objects[k&^1], objects[k|1]
+			objects[k>>1] = hashing.Hash(append(left[:], right[:]...))
+			keys = append(keys, k>>1)
+		}
+	}
+	root, ok := objects[tree.RootGindex]
+	if !ok {
+		return tree.Root{}, fmt.Errorf("leaves and branch do not cover the root")
+	}
+	return root, nil
+}
+
+// VerifyMerkleMultiproof verifies that the leaves at the given indices are in the tree with the given root.
+func VerifyMerkleMultiproof(leaves []tree.Root, branch []tree.Root, indices []tree.Gindex64, root tree.Root) bool {
+	computed, err := CalculateMultiMerkleRoot(leaves, branch, indices)
+	return err == nil && computed == root
+}
@@ -0,0 +1,231 @@
+package merkle
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+)
+
+type pathElem struct {
+	name    string
+	index   uint64
+	isIndex bool
+}
+
+// parsePath splits a path like "validators[123].effective_balance" into field names and indices.
+func parsePath(path string) ([]pathElem, error) {
+	var out []pathElem
+	for i, part := range strings.Split(path, ".") {
+		name := part
+		if j := strings.IndexByte(part, '['); j >= 0 {
+			name = part[:j]
+			part = part[j:]
+		} else {
+			part = ""
+		}
+		if name != "" {
+			out = append(out, pathElem{name: name})
+		} else if i > 0 || part == "" {
+			return nil, fmt.Errorf("empty field name in path %q", path)
+		}
+		for part != "" {
+			end := strings.IndexByte(part, ']')
+			if part[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("malformed index in path %q", path)
+			}
+			index, err := strconv.ParseUint(part[1:end], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in path %q: %v", path, err)
+			}
+			out = append(out, pathElem{index: index, isIndex: true})
+			part = part[end+1:]
+		}
+	}
+	return out, nil
+}
+
+// subGindex returns the generalized index of the node at the given index, at the given depth in the subtree at g.
+func subGindex(g tree.Gindex64, depth uint8, index uint64) (tree.Gindex64, error) {
+	if g.Depth()+uint32(depth) >= 64 {
+		return 0, fmt.Errorf("generalized index overflow: %d with %d more levels", g, depth)
+	}
+	return g<<depth | tree.Gindex64(index), nil
+}
+
+// listLength reads the length mixed into the list at g.
+func listLength(root tree.Node, g tree.Gindex64) (uint64, error) {
+	node, err := root.Getter(g<<1 | 1)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get list length: %v", err)
+	}
+	r, ok := node.(*tree.Root)
+	if !ok {
+		return 0, fmt.Errorf("list length node is not a leaf: %T", node)
+	}
+	return binary.LittleEndian.Uint64(r[:8]), nil
+}
+
+// PathGindex resolves a path of field names and element indices, like "validators[123].effective_balance",
+// to the generalized index of the node in the tree of the view.
+// Elements of lists and vectors of basic types are packed: their path resolves to the chunk that contains them.
+// List indices are checked against the current list length, to not prove empty elements past the end of the list.
+func PathGindex(v view.View, path string) (tree.Gindex64, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return 0, err
+	}
+	root := v.Backing()
+	typ := v.Type()
+	g := tree.RootGindex
+	for _, elem := range elems {
+		if c, ok := typ.(*view.ContainerTypeDef); ok {
+			if elem.isIndex {
+				return 0, fmt.Errorf("cannot index container %s with [%d]", c.ContainerName, elem.index)
+			}
+			found := false
+			for i, f := range c.Fields {
+				if f.Name == elem.name {
+					if g, err = subGindex(g, tree.CoverDepth(c.FieldCount()), uint64(i)); err != nil {
+						return 0, err
+					}
+					typ = f.Type
+					found = true
+					break
+				}
+			}
+			if !found {
+				return 0, fmt.Errorf("container %s has no field %q", c.ContainerName, elem.name)
+			}
+			continue
+		}
+		if !elem.isIndex {
+			return 0, fmt.Errorf("cannot get field %q of non-container type %s", elem.name, typ.String())
+		}
+		var length, limit, chunk uint64
+		isList := false
+		switch t := typ.(type) {
+		case *view.ComplexListTypeDef:
+			isList, limit, chunk, typ = true, t.ListLimit, elem.index, t.ElemType
+		case *view.ComplexVectorTypeDef:
+			length, limit, chunk, typ = t.VectorLength, t.VectorLength, elem.index, t.ElemType
+		case *view.BasicListTypeDef:
+			isList, limit, chunk, typ = true, t.BottomNodeLimit(), elem.index/t.ElementsPerBottomNode(), t.ElemType
+		case *view.BasicVectorTypeDef:
+			length, limit, chunk, typ = t.VectorLength, t.BottomNodeLength(), elem.index/t.ElementsPerBottomNode(), t.ElemType
+		case *view.BitListTypeDef:
+			isList, limit, chunk, typ = true, t.BottomNodeLimit(), elem.index/256, view.BoolType
+		case *view.BitVectorTypeDef:
+			length, limit, chunk, typ = t.BitLength, t.BottomNodeLength(), elem.index/256, view.BoolType
+		default:
+			return 0, fmt.Errorf("cannot index type %s with [%d]", typ.String(), elem.index)
+		}
+		if isList {
+			if length, err = listLength(root, g); err != nil {
+				return 0, err
+			}
+			// Lists mix in their length on the right, the contents are on the left.
+			if g, err = subGindex(g, 1, 0); err != nil {
+				return 0, err
+			}
+		}
+		if elem.index >= length {
+			return 0, fmt.Errorf("index %d out of range, length is %d", elem.index, length)
+		}
+		if g, err = subGindex(g, tree.CoverDepth(limit), chunk); err != nil {
+			return 0, err
+		}
+	}
+	return g, nil
+}
+
+// Proof is a merkle proof of a single node, the leaf, in a tree.
+type Proof struct {
+	Leaf   tree.Root
+	Branch []tree.Root
+	Gindex tree.Gindex64
+}
+
+// Verify checks the proof against the root of the tree.
+func (p *Proof) Verify(root tree.Root) bool {
+	if p.Gindex == 0 {
+		return false
+	}
+	depth := uint64(p.Gindex.Depth())
+	if uint64(len(p.Branch)) != depth {
+		return false
+	}
+	return VerifyMerkleBranch(p.Leaf, p.Branch, depth, uint64(p.Gindex)^(1<<depth), root)
+}
+
+// ProvePath builds a proof of the node at the path in the view. See PathGindex for the path format.
+func ProvePath(v view.View, path string, hFn tree.HashFn) (*Proof, error) {
+	g, err := PathGindex(v, path)
+	if err != nil {
+		return nil, err
+	}
+	root := v.Backing()
+	leaf, err := root.Getter(g)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaf %d: %v", g, err)
+	}
+	branch, err := MerkleBranch(root, g, hFn)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{Leaf: leaf.MerkleRoot(hFn), Branch: branch, Gindex: g}, nil
+}
+
+// Multiproof is a merkle proof of multiple nodes in a tree, sharing the helper nodes.
+// The branch is ordered like HelperIndices of the indices.
+type Multiproof struct {
+	Leaves  []tree.Root
+	Branch  []tree.Root
+	Indices []tree.Gindex64
+}
+
+// Verify checks the multiproof against the root of the tree.
+func (m *Multiproof) Verify(root tree.Root) bool {
+	return VerifyMerkleMultiproof(m.Leaves, m.Branch, m.Indices, root)
+}
+
+// ProvePaths builds a multiproof of the nodes at the paths in the view. See PathGindex for the path format.
+// Paths that resolve to the same node, like packed basic elements in the same chunk, are proven once.
+// A path may not resolve to an ancestor of the node of another path.
+func ProvePaths(v view.View, paths []string, hFn tree.HashFn) (*Multiproof, error) {
+	root := v.Backing()
+	out := new(Multiproof)
+	seen := make(map[tree.Gindex64]struct{}, len(paths))
+	for _, path := range paths {
+		g, err := PathGindex(v, path)
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %v", path, err)
+		}
+		if _, ok := seen[g]; ok {
+			continue
+		}
+		seen[g] = struct{}{}
+		leaf, err := root.Getter(g)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get leaf %d: %v", g, err)
+		}
+		out.Leaves = append(out.Leaves, leaf.MerkleRoot(hFn))
+		out.Indices = append(out.Indices, g)
+	}
+	for _, g := range out.Indices {
+		for p := g >> 1; p >= tree.RootGindex; p >>= 1 {
+			if _, ok := seen[p]; ok {
+				return nil, fmt.Errorf("index %d is an ancestor of index %d", p, g)
+			}
+		}
+	}
+	branch, err := MultiBranch(root, out.Indices, hFn)
+	if err != nil {
+		return nil, err
+	}
+	out.Branch = branch
+	return out, nil
+}
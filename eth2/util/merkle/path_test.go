@@ -0,0 +1,165 @@
+package merkle_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestPathGindex(t *testing.T) {
+	spec := configs.Minimal
+	altairState := altair.NewBeaconStateView(spec)
+	for _, c := range []struct {
+		path   string
+		gindex tree.Gindex64
+	}{
+		{"finalized_checkpoint.root", altair.FINALIZED_ROOT_INDEX},
+		{"next_sync_committee", altair.NEXT_SYNC_COMMITTEE_INDEX},
+		{"slot", 32 + 2},
+		{"fork.current_version", (32+3)*4 + 1},
+		// block_roots is a vector of 64 roots in the minimal config
+		{"block_roots[5]", (32+5)*64 + 5},
+		// randao_mixes is a vector of 64 roots in the minimal config, the 32 byte element is not packed
+		{"randao_mixes[63]", (32+13)*64 + 63},
+		// slashings is a vector of 64 uint64 in the minimal config, packed 4 per chunk
+		{"slashings[5]", (32+14)*16 + 1},
+	} {
+		g, err := merkle.PathGindex(altairState, c.path)
+		if err != nil {
+			t.Fatalf("path %q: %v", c.path, err)
+		}
+		if g != c.gindex {
+			t.Errorf("path %q: expected gindex %d, got %d", c.path, c.gindex, g)
+		}
+	}
+	for _, path := range []string{
+		"",
+		"unknown",
+		"slot.foo",
+		"slot[0]",
+		"fork[0]",
+		"validators[0]", // empty list
+		"block_roots[64]",
+		"block_roots[x]",
+		"block_roots[1",
+		"fork..epoch",
+	} {
+		if _, err := merkle.PathGindex(altairState, path); err == nil {
+			t.Errorf("expected path %q to be rejected", path)
+		}
+	}
+}
+
+func TestProvePath(t *testing.T) {
+	spec := configs.Minimal
+	state := phase0.NewBeaconStateView(spec)
+	for i := 0; i < 7; i++ {
+		balance := spec.MAX_EFFECTIVE_BALANCE - common.Gwei(i)*spec.EFFECTIVE_BALANCE_INCREMENT
+		if err := state.AddValidator(spec, common.BLSPubkey{byte(i)}, common.Root{}, balance+common.Gwei(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hFn := tree.GetHashFn()
+	root := state.HashTreeRoot(hFn)
+
+	proof, err := merkle.ProvePath(state, "validators[3].effective_balance", hFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(root) {
+		t.Fatal("invalid effective balance proof")
+	}
+	expected := spec.MAX_EFFECTIVE_BALANCE - 3*spec.EFFECTIVE_BALANCE_INCREMENT
+	if got := common.Gwei(binary.LittleEndian.Uint64(proof.Leaf[:8])); got != expected {
+		t.Fatalf("expected effective balance %d, got %d", expected, got)
+	}
+	proof.Leaf[0] ^= 1
+	if proof.Verify(root) {
+		t.Fatal("expected modified leaf to be rejected")
+	}
+
+	// Balances are packed 4 per chunk: balance 5 is the second in the second chunk.
+	proof, err = merkle.ProvePath(state, "balances[5]", hFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proof.Verify(root) {
+		t.Fatal("invalid balance proof")
+	}
+	expected = spec.MAX_EFFECTIVE_BALANCE - 5*spec.EFFECTIVE_BALANCE_INCREMENT + 5
+	if got := common.Gwei(binary.LittleEndian.Uint64(proof.Leaf[8:16])); got != expected {
+		t.Fatalf("expected balance %d, got %d", expected, got)
+	}
+	if _, err := merkle.ProvePath(state, "balances[7]", hFn); err == nil {
+		t.Fatal("expected balance past the end of the list to be rejected")
+	}
+}
+
+func TestProvePaths(t *testing.T) {
+	spec := configs.Minimal
+	state := bellatrix.NewBeaconStateView(spec)
+	for i := 0; i < 3; i++ {
+		if err := state.AddValidator(spec, common.BLSPubkey{byte(i)}, common.Root{}, spec.MAX_EFFECTIVE_BALANCE); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.SetFinalizedCheckpoint(common.Checkpoint{Epoch: 3, Root: common.Root{0x42}}); err != nil {
+		t.Fatal(err)
+	}
+	hFn := tree.GetHashFn()
+	root := state.HashTreeRoot(hFn)
+
+	paths := []string{
+		"validators[0].effective_balance",
+		"validators[2].pubkey",
+		"balances[0]",
+		"balances[1]", // same chunk as balances[0]
+		"finalized_checkpoint.root",
+		"latest_execution_payload_header.block_hash",
+	}
+	multi, err := merkle.ProvePaths(state, paths, hFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(multi.Leaves) != 5 || len(multi.Indices) != 5 {
+		t.Fatalf("expected 5 distinct leaves, got %d", len(multi.Leaves))
+	}
+	if !multi.Verify(root) {
+		t.Fatal("invalid multiproof")
+	}
+	if multi.Leaves[3] != (common.Root{0x42}) {
+		t.Fatal("unexpected finalized root leaf")
+	}
+	// The multiproof is smaller than the single proofs combined.
+	total := 0
+	for _, path := range paths {
+		proof, err := merkle.ProvePath(state, path, hFn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		total += len(proof.Branch)
+	}
+	if len(multi.Branch) >= total {
+		t.Fatalf("expected multiproof branch to be smaller than %d, got %d", total, len(multi.Branch))
+	}
+
+	multi.Branch[0][0] ^= 1
+	if multi.Verify(root) {
+		t.Fatal("expected modified branch to be rejected")
+	}
+	multi.Branch[0][0] ^= 1
+	if merkle.VerifyMerkleMultiproof(multi.Leaves[1:], multi.Branch, multi.Indices[1:], root) {
+		t.Fatal("expected proof with missing leaf to be rejected")
+	}
+
+	if _, err := merkle.ProvePaths(state, []string{"finalized_checkpoint", "finalized_checkpoint.root"}, hFn); err == nil {
+		t.Fatal("expected overlapping paths to be rejected")
+	}
+}
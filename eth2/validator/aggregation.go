@@ -0,0 +1,131 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/duties"
+	"github.com/protolambda/zrnt/eth2/pool"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// AggregateSelectionProof signs the slot, to select the validator as aggregator of its committee at the slot.
+func AggregateSelectionProof(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	pubkey common.BLSPubkey, slot common.Slot) (common.BLSSignature, error) {
+	sigRoot, err := phase0.AggregateSelectionProofSigningRoot(spec, domainFn, slot)
+	if err != nil {
+		return common.BLSSignature{}, err
+	}
+	return signer.Sign(ctx, pubkey, sigRoot)
+}
+
+// IsAttestationAggregator computes the selection proof for the attester duty,
+// and checks if it selects the validator as aggregator of the committee.
+func IsAttestationAggregator(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	duty *duties.AttesterDuty) (selectionProof common.BLSSignature, ok bool, err error) {
+	selectionProof, err = AggregateSelectionProof(ctx, spec, signer, domainFn, duty.Pubkey, duty.Slot)
+	if err != nil {
+		return common.BLSSignature{}, false, fmt.Errorf("failed to sign selection proof: %v", err)
+	}
+	return selectionProof, phase0.IsAggregator(spec, duty.CommitteeLength, selectionProof), nil
+}
+
+// ProduceAggregateAndProof packs the best aggregate of the attestation data from the pool,
+// and signs it as aggregate-and-proof of the aggregator duty.
+func ProduceAggregateAndProof(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	ap *pool.AttestationPool, duty *duties.AttesterDuty, selectionProof common.BLSSignature,
+	dataRoot common.Root) (*phase0.SignedAggregateAndProof, error) {
+	if !phase0.IsAggregator(spec, duty.CommitteeLength, selectionProof) {
+		return nil, fmt.Errorf("validator %d is not selected as aggregator at slot %d", duty.ValidatorIndex, duty.Slot)
+	}
+	agg, err := ap.PackAggregate(ctx, dataRoot)
+	if err != nil {
+		return nil, err
+	}
+	if agg.Data.Slot != duty.Slot || agg.Data.Index != duty.CommitteeIndex {
+		return nil, fmt.Errorf("attestation data of slot %d committee %d does not match aggregator duty of slot %d committee %d",
+			agg.Data.Slot, agg.Data.Index, duty.Slot, duty.CommitteeIndex)
+	}
+	msg := phase0.AggregateAndProof{
+		AggregatorIndex: duty.ValidatorIndex,
+		Aggregate:       *agg,
+		SelectionProof:  selectionProof,
+	}
+	sig, err := signObject(ctx, signer, domainFn, duty.Pubkey, common.DOMAIN_AGGREGATE_AND_PROOF,
+		spec.SlotToEpoch(duty.Slot), msg.HashTreeRoot(spec, tree.GetHashFn()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign aggregate and proof: %v", err)
+	}
+	return &phase0.SignedAggregateAndProof{Message: msg, Signature: sig}, nil
+}
+
+// SyncCommitteeSelectionProof signs the slot and subcommittee index,
+// to select the validator as aggregator of the sync subcommittee at the slot.
+func SyncCommitteeSelectionProof(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	pubkey common.BLSPubkey, slot common.Slot, subcommitteeIndex uint64) (common.BLSSignature, error) {
+	sigRoot, err := altair.SyncAggregatorSelectionSigningRoot(spec, domainFn, slot, subcommitteeIndex)
+	if err != nil {
+		return common.BLSSignature{}, err
+	}
+	return signer.Sign(ctx, pubkey, sigRoot)
+}
+
+type SyncAggregatorSelection struct {
+	SubcommitteeIndex uint64
+	SelectionProof    common.BLSSignature
+}
+
+// SyncCommitteeAggregatorSelections computes the selection proofs for each subnet of the sync committee duty,
+// and returns the subnets that the validator is selected as aggregator for at the given slot.
+func SyncCommitteeAggregatorSelections(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	duty *duties.SyncCommitteeDuty, slot common.Slot) ([]SyncAggregatorSelection, error) {
+	var out []SyncAggregatorSelection
+	seen := make(map[uint64]struct{}, len(duty.Subnets))
+	for _, subnet := range duty.Subnets {
+		if _, ok := seen[subnet]; ok {
+			continue
+		}
+		seen[subnet] = struct{}{}
+		proof, err := SyncCommitteeSelectionProof(ctx, spec, signer, domainFn, duty.Pubkey, slot, subnet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign sync committee selection proof for subnet %d: %v", subnet, err)
+		}
+		if altair.IsSyncCommitteeAggregator(spec, proof) {
+			out = append(out, SyncAggregatorSelection{SubcommitteeIndex: subnet, SelectionProof: proof})
+		}
+	}
+	return out, nil
+}
+
+// ProduceContributionAndProof packs the best contribution of the subcommittee from the pool,
+// and signs it as contribution-and-proof of the sync committee aggregator.
+func ProduceContributionAndProof(ctx context.Context, spec *common.Spec, signer Signer, domainFn common.BLSDomainFn,
+	sp *pool.SyncCommitteePool, syncCommittee *common.IndexedSyncCommittee, duty *duties.SyncCommitteeDuty,
+	selection *SyncAggregatorSelection, slot common.Slot, beaconBlockRoot common.Root) (*altair.SignedContributionAndProof, error) {
+	if !altair.IsSyncCommitteeAggregator(spec, selection.SelectionProof) {
+		return nil, fmt.Errorf("validator %d is not selected as sync committee aggregator of subnet %d at slot %d",
+			duty.ValidatorIndex, selection.SubcommitteeIndex, slot)
+	}
+	_, subComm, err := syncCommittee.Subcommittee(spec, selection.SubcommitteeIndex)
+	if err != nil {
+		return nil, err
+	}
+	contrib, err := sp.PackContribution(ctx, slot, beaconBlockRoot, selection.SubcommitteeIndex, subComm)
+	if err != nil {
+		return nil, err
+	}
+	msg := altair.ContributionAndProof{
+		AggregatorIndex: duty.ValidatorIndex,
+		Contribution:    *contrib,
+		SelectionProof:  selection.SelectionProof,
+	}
+	sig, err := signObject(ctx, signer, domainFn, duty.Pubkey, common.DOMAIN_CONTRIBUTION_AND_PROOF,
+		spec.SlotToEpoch(slot), msg.HashTreeRoot(spec, tree.GetHashFn()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign contribution and proof: %v", err)
+	}
+	return &altair.SignedContributionAndProof{Message: msg, Signature: sig}, nil
+}
@@ -0,0 +1,249 @@
+package validator_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/duties"
+	"github.com/protolambda/zrnt/eth2/pool"
+	"github.com/protolambda/zrnt/eth2/validator"
+	"github.com/protolambda/ztyp/tree"
+)
+
+type testKeys map[common.BLSPubkey]*blsu.SecretKey
+
+func (keys testKeys) Sign(ctx context.Context, pubkey common.BLSPubkey, signingRoot common.Root) (common.BLSSignature, error) {
+	sk, ok := keys[pubkey]
+	if !ok {
+		return common.BLSSignature{}, fmt.Errorf("unknown pubkey %s", pubkey)
+	}
+	return blsu.Sign(sk, signingRoot[:]).Serialize(), nil
+}
+
+func testGenesis(t *testing.T, spec *common.Spec) (*altair.BeaconStateView, *common.EpochsContext, testKeys, []common.BLSPubkey) {
+	keys := make(testKeys)
+	pubkeys := make([]common.BLSPubkey, 64)
+	rawKeys := make([][32]byte, len(pubkeys))
+	vals := make([]phase0.KickstartValidatorData, len(pubkeys))
+	for i := range vals {
+		rawKeys[i][31] = byte(i + 1)
+		sk := new(blsu.SecretKey)
+		if err := sk.Deserialize(&rawKeys[i]); err != nil {
+			t.Fatal(err)
+		}
+		pub, err := blsu.SkToPk(sk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubkeys[i] = pub.Serialize()
+		keys[pubkeys[i]] = sk
+		vals[i] = phase0.KickstartValidatorData{Pubkey: pubkeys[i], Balance: spec.MAX_EFFECTIVE_BALANCE}
+	}
+	pre, epc, err := phase0.KickStartStateWithSignatures(spec, common.Root{0x01}, 0, vals, rawKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := altair.UpgradeToAltair(spec, epc, pre)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := epc.LoadSyncCommittees(state); err != nil {
+		t.Fatal(err)
+	}
+	return state, epc, keys, pubkeys
+}
+
+func TestProduceAggregateAndProof(t *testing.T) {
+	spec := configs.Minimal
+	state, epc, keys, pubkeys := testGenesis(t, spec)
+	ctx := context.Background()
+	domainFn := func(typ common.BLSDomainType, epoch common.Epoch) (common.BLSDomain, error) {
+		return common.GetDomain(state, typ, epoch)
+	}
+	d, err := duties.NewEpochDuties(spec, epc, state, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duty := d.AttesterDuties(pubkeys[:1])[0]
+	committee, err := epc.GetBeaconCommittee(duty.Slot, duty.CommitteeIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := phase0.AttestationData{
+		Slot:            duty.Slot,
+		Index:           duty.CommitteeIndex,
+		BeaconBlockRoot: common.Root{0x42},
+		Target:          common.Checkpoint{Root: common.Root{0x42}},
+	}
+	dataRoot := data.HashTreeRoot(tree.GetHashFn())
+	dom, err := domainFn(common.DOMAIN_BEACON_ATTESTER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRoot := common.ComputeSigningRoot(dataRoot, dom)
+
+	ap := pool.NewAttestationPool(spec)
+	if _, err := ap.PackAggregate(ctx, dataRoot); err == nil {
+		t.Fatal("expected empty pool to have no aggregate")
+	}
+	// An aggregate of the first two members, and individual attestations of the others.
+	for i := 1; i < len(committee); i++ {
+		bits := make(phase0.AttestationBits, (len(committee)>>3)+1)
+		bits.SetBit(uint64(len(committee)), true)
+		var sigs []*blsu.Signature
+		for j := 0; j <= i; j++ {
+			if i > 1 && j != i {
+				continue
+			}
+			bits.SetBit(uint64(j), true)
+			sigs = append(sigs, blsu.Sign(keys[pubkeys[committee[j]]], sigRoot[:]))
+		}
+		sig, err := blsu.Aggregate(sigs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		att := &phase0.Attestation{AggregationBits: bits, Data: data, Signature: sig.Serialize()}
+		if err := ap.AddAttestation(ctx, att, committee); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With the minimal config committees are small, and every member is selected as aggregator.
+	proof, ok, err := validator.IsAttestationAggregator(ctx, spec, keys, domainFn, &duty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected validator to be selected as aggregator")
+	}
+	if valid, err := phase0.ValidateAggregateSelectionProof(spec, epc, state, duty.Slot, duty.CommitteeIndex, duty.ValidatorIndex, proof); err != nil {
+		t.Fatal(err)
+	} else if !valid {
+		t.Fatal("invalid selection proof")
+	}
+	signed, err := validator.ProduceAggregateAndProof(ctx, spec, keys, domainFn, ap, &duty, proof, dataRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	agg := &signed.Message.Aggregate
+	if agg.AggregationBits.OnesCount() != uint64(len(committee)) {
+		t.Fatalf("expected aggregate of the full committee, got %s", agg.AggregationBits)
+	}
+	var pubs []*blsu.Pubkey
+	for _, vi := range committee {
+		pub, err := blsu.SkToPk(keys[pubkeys[vi]])
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+	}
+	aggSig, err := agg.Signature.Signature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blsu.FastAggregateVerify(pubs, sigRoot[:], aggSig) {
+		t.Fatal("invalid aggregate signature")
+	}
+	aggDom, err := domainFn(common.DOMAIN_AGGREGATE_AND_PROOF, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgRoot := common.ComputeSigningRoot(signed.Message.HashTreeRoot(spec, tree.GetHashFn()), aggDom)
+	sig, err := signed.Signature.Signature()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := blsu.SkToPk(keys[duty.Pubkey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blsu.Verify(pub, msgRoot[:], sig) {
+		t.Fatal("invalid aggregate and proof signature")
+	}
+
+	other := duty
+	other.CommitteeIndex += 1
+	if _, err := validator.ProduceAggregateAndProof(ctx, spec, keys, domainFn, ap, &other, proof, dataRoot); err == nil {
+		t.Fatal("expected aggregate of other committee to be rejected")
+	}
+}
+
+func TestProduceContributionAndProof(t *testing.T) {
+	spec := configs.Minimal
+	state, epc, keys, pubkeys := testGenesis(t, spec)
+	ctx := context.Background()
+	domainFn := func(typ common.BLSDomainType, epoch common.Epoch) (common.BLSDomain, error) {
+		return common.GetDomain(state, typ, epoch)
+	}
+	d, err := duties.NewEpochDuties(spec, epc, state, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncDuties := d.SyncCommitteeDuties(pubkeys)
+	if len(syncDuties) == 0 {
+		t.Fatal("expected sync committee duties")
+	}
+	duty := syncDuties[0]
+	slot := common.Slot(3)
+	blockRoot := common.Root{0x42}
+
+	sp := pool.NewSyncCommitteePool(spec)
+	sp.Reset(slot)
+	dom, err := domainFn(common.DOMAIN_SYNC_COMMITTEE, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRoot := common.ComputeSigningRoot(blockRoot, dom)
+	for _, vi := range epc.CurrentSyncCommittee.Indices {
+		msg := &altair.SyncCommitteeMessage{
+			Slot:            slot,
+			BeaconBlockRoot: blockRoot,
+			ValidatorIndex:  vi,
+			Signature:       blsu.Sign(keys[pubkeys[vi]], sigRoot[:]).Serialize(),
+		}
+		if err := sp.AddSyncCommitteeMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	selections, err := validator.SyncCommitteeAggregatorSelections(ctx, spec, keys, domainFn, &duty, slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// With the minimal config subcommittees are small, and every member is selected as aggregator.
+	if len(selections) == 0 {
+		t.Fatal("expected validator to be selected as sync committee aggregator")
+	}
+	for _, sel := range selections {
+		if err := altair.ValidateSyncAggregatorSelectionProof(spec, epc, domainFn,
+			duty.ValidatorIndex, sel.SelectionProof, slot, sel.SubcommitteeIndex); err != nil {
+			t.Fatal(err)
+		}
+		signed, err := validator.ProduceContributionAndProof(ctx, spec, keys, domainFn, sp,
+			epc.CurrentSyncCommittee, &duty, &sel, slot, blockRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contrib := &signed.Message.Contribution
+		if contrib.AggregationBits.OnesCount() != spec.SYNC_COMMITTEE_SIZE/common.SYNC_COMMITTEE_SUBNET_COUNT {
+			t.Fatalf("expected full subcommittee contribution, got %s", contrib.AggregationBits)
+		}
+		subPubs, _, err := epc.CurrentSyncCommittee.Subcommittee(spec, sel.SubcommitteeIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := contrib.VerifySignature(spec, subPubs, domainFn); err != nil {
+			t.Fatal(err)
+		}
+		if err := signed.VerifySignature(spec, epc, domainFn); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
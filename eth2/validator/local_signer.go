@@ -0,0 +1,70 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	blsu "github.com/protolambda/bls12-381-util"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/keystore"
+)
+
+// LocalSigner signs with secret keys held in memory. It is safe for concurrent use.
+type LocalSigner struct {
+	mu   sync.RWMutex
+	keys map[common.BLSPubkey]*blsu.SecretKey
+}
+
+var _ Signer = (*LocalSigner)(nil)
+
+func NewLocalSigner() *LocalSigner {
+	return &LocalSigner{keys: make(map[common.BLSPubkey]*blsu.SecretKey)}
+}
+
+// AddKey adds the secret key to the signer, and returns its pubkey.
+func (s *LocalSigner) AddKey(sk *blsu.SecretKey) (common.BLSPubkey, error) {
+	pub, err := blsu.SkToPk(sk)
+	if err != nil {
+		return common.BLSPubkey{}, err
+	}
+	pubkey := common.BLSPubkey(pub.Serialize())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[pubkey] = sk
+	return pubkey, nil
+}
+
+// AddKeystore decrypts the EIP-2335 keystore, and adds the secret key to the signer.
+func (s *LocalSigner) AddKeystore(ks *keystore.Keystore, password string) (common.BLSPubkey, error) {
+	sk, err := ks.Decrypt(password)
+	if err != nil {
+		return common.BLSPubkey{}, fmt.Errorf("failed to decrypt keystore %s: %v", ks.UUID, err)
+	}
+	return s.AddKey(sk)
+}
+
+// Pubkeys lists the pubkeys of all keys of the signer.
+func (s *LocalSigner) Pubkeys() []common.BLSPubkey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]common.BLSPubkey, 0, len(s.keys))
+	for k := range s.keys {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return string(out[i][:]) < string(out[j][:])
+	})
+	return out
+}
+
+func (s *LocalSigner) Sign(ctx context.Context, pubkey common.BLSPubkey, signingRoot common.Root) (common.BLSSignature, error) {
+	s.mu.RLock()
+	sk, ok := s.keys[pubkey]
+	s.mu.RUnlock()
+	if !ok {
+		return common.BLSSignature{}, fmt.Errorf("no secret key for pubkey %s", pubkey)
+	}
+	return blsu.Sign(sk, signingRoot[:]).Serialize(), nil
+}
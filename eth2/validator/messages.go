@@ -0,0 +1,110 @@
+package validator
+
+import (
+	"context"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/slashingprotection"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// MessageSigner signs each type of consensus message with its own domain,
+// versioned with the fork of the spec that is active at the epoch of the message.
+type MessageSigner struct {
+	Spec                  *common.Spec
+	GenesisValidatorsRoot common.Root
+	Signer                Signer
+	// Optional. If set, blocks and attestations are checked against the history of the validator before signing.
+	Protection *slashingprotection.SlashingProtection
+}
+
+// Domain computes the domain of the given type at the given epoch.
+func (ms *MessageSigner) Domain(typ common.BLSDomainType, epoch common.Epoch) (common.BLSDomain, error) {
+	slot, err := ms.Spec.EpochStartSlot(epoch)
+	if err != nil {
+		return common.BLSDomain{}, err
+	}
+	return common.ComputeDomain(typ, ms.Spec.ForkVersion(slot), ms.GenesisValidatorsRoot), nil
+}
+
+func (ms *MessageSigner) sign(ctx context.Context, pubkey common.BLSPubkey, typ common.BLSDomainType,
+	epoch common.Epoch, objRoot common.Root) (common.BLSSignature, error) {
+	return signObject(ctx, ms.Signer, ms.Domain, pubkey, typ, epoch, objRoot)
+}
+
+// SignBlock signs the block by its header, the header has the same root as the full block.
+func (ms *MessageSigner) SignBlock(ctx context.Context, pubkey common.BLSPubkey, header *common.BeaconBlockHeader) (common.BLSSignature, error) {
+	epoch := ms.Spec.SlotToEpoch(header.Slot)
+	dom, err := ms.Domain(common.DOMAIN_BEACON_PROPOSER, epoch)
+	if err != nil {
+		return common.BLSSignature{}, err
+	}
+	sigRoot := common.ComputeSigningRoot(header.HashTreeRoot(tree.GetHashFn()), dom)
+	if ms.Protection != nil {
+		if err := ms.Protection.CheckAndRecordBlock(pubkey, header.Slot, sigRoot); err != nil {
+			return common.BLSSignature{}, err
+		}
+	}
+	return ms.Signer.Sign(ctx, pubkey, sigRoot)
+}
+
+func (ms *MessageSigner) SignAttestationData(ctx context.Context, pubkey common.BLSPubkey, data *phase0.AttestationData) (common.BLSSignature, error) {
+	dom, err := ms.Domain(common.DOMAIN_BEACON_ATTESTER, data.Target.Epoch)
+	if err != nil {
+		return common.BLSSignature{}, err
+	}
+	sigRoot := common.ComputeSigningRoot(data.HashTreeRoot(tree.GetHashFn()), dom)
+	if ms.Protection != nil {
+		if err := ms.Protection.CheckAndRecordAttestation(pubkey, data.Source.Epoch, data.Target.Epoch, sigRoot); err != nil {
+			return common.BLSSignature{}, err
+		}
+	}
+	return ms.Signer.Sign(ctx, pubkey, sigRoot)
+}
+
+func (ms *MessageSigner) SignRandaoReveal(ctx context.Context, pubkey common.BLSPubkey, epoch common.Epoch) (common.BLSSignature, error) {
+	return ms.sign(ctx, pubkey, common.DOMAIN_RANDAO, epoch, epoch.HashTreeRoot(tree.GetHashFn()))
+}
+
+func (ms *MessageSigner) SignAggregateSelection(ctx context.Context, pubkey common.BLSPubkey, slot common.Slot) (common.BLSSignature, error) {
+	return AggregateSelectionProof(ctx, ms.Spec, ms.Signer, ms.Domain, pubkey, slot)
+}
+
+func (ms *MessageSigner) SignAggregateAndProof(ctx context.Context, pubkey common.BLSPubkey, msg *phase0.AggregateAndProof) (common.BLSSignature, error) {
+	return ms.sign(ctx, pubkey, common.DOMAIN_AGGREGATE_AND_PROOF, ms.Spec.SlotToEpoch(msg.Aggregate.Data.Slot),
+		msg.HashTreeRoot(ms.Spec, tree.GetHashFn()))
+}
+
+func (ms *MessageSigner) SignSyncCommitteeMessage(ctx context.Context, pubkey common.BLSPubkey, slot common.Slot, beaconBlockRoot common.Root) (common.BLSSignature, error) {
+	return ms.sign(ctx, pubkey, common.DOMAIN_SYNC_COMMITTEE, ms.Spec.SlotToEpoch(slot), beaconBlockRoot)
+}
+
+func (ms *MessageSigner) SignSyncCommitteeSelection(ctx context.Context, pubkey common.BLSPubkey, slot common.Slot, subcommitteeIndex uint64) (common.BLSSignature, error) {
+	return SyncCommitteeSelectionProof(ctx, ms.Spec, ms.Signer, ms.Domain, pubkey, slot, subcommitteeIndex)
+}
+
+func (ms *MessageSigner) SignContributionAndProof(ctx context.Context, pubkey common.BLSPubkey, msg *altair.ContributionAndProof) (common.BLSSignature, error) {
+	return ms.sign(ctx, pubkey, common.DOMAIN_CONTRIBUTION_AND_PROOF, ms.Spec.SlotToEpoch(msg.Contribution.Slot),
+		msg.HashTreeRoot(ms.Spec, tree.GetHashFn()))
+}
+
+func (ms *MessageSigner) SignVoluntaryExit(ctx context.Context, pubkey common.BLSPubkey, exit *phase0.VoluntaryExit) (common.BLSSignature, error) {
+	return ms.sign(ctx, pubkey, common.DOMAIN_VOLUNTARY_EXIT, exit.Epoch, exit.HashTreeRoot(tree.GetHashFn()))
+}
+
+// SignDeposit signs the deposit message. Deposits are valid across forks and chains:
+// the domain uses the genesis fork version and an empty genesis validators root.
+func (ms *MessageSigner) SignDeposit(ctx context.Context, pubkey common.BLSPubkey, msg *common.DepositMessage) (common.BLSSignature, error) {
+	dom := common.ComputeDomain(common.DOMAIN_DEPOSIT, ms.Spec.GENESIS_FORK_VERSION, common.Root{})
+	return ms.Signer.Sign(ctx, pubkey, common.ComputeSigningRoot(msg.HashTreeRoot(tree.GetHashFn()), dom))
+}
+
+// SignBLSToExecutionChange signs the withdrawal credentials change with the withdrawal key.
+// Changes are valid across forks: the domain uses the genesis fork version.
+func (ms *MessageSigner) SignBLSToExecutionChange(ctx context.Context, pubkey common.BLSPubkey, change *capella.BLSToExecutionChange) (common.BLSSignature, error) {
+	dom := common.ComputeDomain(common.DOMAIN_BLS_TO_EXECUTION_CHANGE, ms.Spec.GENESIS_FORK_VERSION, ms.GenesisValidatorsRoot)
+	return ms.Signer.Sign(ctx, pubkey, common.ComputeSigningRoot(change.HashTreeRoot(tree.GetHashFn()), dom))
+}
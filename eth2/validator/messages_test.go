@@ -0,0 +1,129 @@
+package validator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	blsu "github.com/protolambda/bls12-381-util"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/keystore"
+	"github.com/protolambda/zrnt/eth2/slashingprotection"
+	"github.com/protolambda/zrnt/eth2/validator"
+	"github.com/protolambda/ztyp/tree"
+)
+
+func TestMessageSigner(t *testing.T) {
+	spec := *configs.Minimal
+	spec.ALTAIR_FORK_EPOCH = 2
+	ctx := context.Background()
+
+	seed, err := keystore.MnemonicToSeed("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, err := keystore.DeriveSKFromPath(seed, keystore.SigningKeyPath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := validator.NewLocalSigner()
+	pubkey, err := signer.AddKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := signer.Pubkeys(); len(got) != 1 || got[0] != pubkey {
+		t.Fatalf("unexpected pubkeys: %v", got)
+	}
+	gvr := common.Root{0x42}
+	ms := &validator.MessageSigner{
+		Spec:                  &spec,
+		GenesisValidatorsRoot: gvr,
+		Signer:                signer,
+		Protection:            slashingprotection.NewSlashingProtection(gvr, slashingprotection.NewMemoryStore()),
+	}
+
+	verify := func(name string, sig common.BLSSignature, objRoot common.Root, dom common.BLSDomain) {
+		t.Helper()
+		pub, err := pubkey.Pubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := sig.Signature()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sigRoot := common.ComputeSigningRoot(objRoot, dom)
+		if !blsu.Verify(pub, sigRoot[:], s) {
+			t.Fatalf("%s: invalid signature", name)
+		}
+	}
+
+	// blocks before and after the fork are signed with the fork version of their epoch
+	for _, slot := range []common.Slot{1, spec.SLOTS_PER_EPOCH * 2} {
+		header := &common.BeaconBlockHeader{Slot: slot, ProposerIndex: 3, BodyRoot: common.Root{0x01}}
+		sig, err := ms.SignBlock(ctx, pubkey, header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version := spec.GENESIS_FORK_VERSION
+		if slot >= spec.SLOTS_PER_EPOCH*2 {
+			version = spec.ALTAIR_FORK_VERSION
+		}
+		verify("block", sig, header.HashTreeRoot(tree.GetHashFn()),
+			common.ComputeDomain(common.DOMAIN_BEACON_PROPOSER, version, gvr))
+	}
+	// a different block at the same slot is refused
+	_, err = ms.SignBlock(ctx, pubkey, &common.BeaconBlockHeader{Slot: 1, ProposerIndex: 3, BodyRoot: common.Root{0x02}})
+	if !errors.Is(err, slashingprotection.SlashableErr) {
+		t.Fatalf("expected slashable double proposal, got %v", err)
+	}
+
+	data := &phase0.AttestationData{
+		Slot:   3,
+		Source: common.Checkpoint{Epoch: 0},
+		Target: common.Checkpoint{Epoch: 0, Root: common.Root{0x03}},
+	}
+	sig, err := ms.SignAttestationData(ctx, pubkey, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify("attestation", sig, data.HashTreeRoot(tree.GetHashFn()),
+		common.ComputeDomain(common.DOMAIN_BEACON_ATTESTER, spec.GENESIS_FORK_VERSION, gvr))
+	data2 := *data
+	data2.BeaconBlockRoot = common.Root{0x04}
+	if _, err := ms.SignAttestationData(ctx, pubkey, &data2); !errors.Is(err, slashingprotection.SlashableErr) {
+		t.Fatalf("expected slashable double vote, got %v", err)
+	}
+
+	epoch := common.Epoch(3)
+	sig, err = ms.SignRandaoReveal(ctx, pubkey, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify("randao", sig, epoch.HashTreeRoot(tree.GetHashFn()),
+		common.ComputeDomain(common.DOMAIN_RANDAO, spec.ALTAIR_FORK_VERSION, gvr))
+
+	exit := &phase0.VoluntaryExit{Epoch: 1, ValidatorIndex: 3}
+	sig, err = ms.SignVoluntaryExit(ctx, pubkey, exit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify("exit", sig, exit.HashTreeRoot(tree.GetHashFn()),
+		common.ComputeDomain(common.DOMAIN_VOLUNTARY_EXIT, spec.GENESIS_FORK_VERSION, gvr))
+
+	// deposits are verified the same way as by the state transition
+	msg := &common.DepositMessage{Pubkey: pubkey, Amount: spec.MAX_EFFECTIVE_BALANCE}
+	sig, err = ms.SignDeposit(ctx, pubkey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify("deposit", sig, msg.HashTreeRoot(tree.GetHashFn()),
+		common.ComputeDomain(common.DOMAIN_DEPOSIT, spec.GENESIS_FORK_VERSION, common.Root{}))
+
+	if _, err := ms.SignRandaoReveal(ctx, common.BLSPubkey{0x01}, epoch); err == nil {
+		t.Fatal("expected unknown pubkey to be rejected")
+	}
+}
@@ -0,0 +1,31 @@
+package validator
+
+import (
+	"context"
+
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+)
+
+// Signer signs messages of validators. Implementations may hold the keys locally,
+// or forward the signing root to a remote signer.
+type Signer interface {
+	// Sign the signing root, which already includes the domain of the message, with the key of the given pubkey.
+	Sign(ctx context.Context, pubkey common.BLSPubkey, signingRoot common.Root) (common.BLSSignature, error)
+}
+
+// SignerFunc is a function that implements the Signer interface.
+type SignerFunc func(ctx context.Context, pubkey common.BLSPubkey, signingRoot common.Root) (common.BLSSignature, error)
+
+func (fn SignerFunc) Sign(ctx context.Context, pubkey common.BLSPubkey, signingRoot common.Root) (common.BLSSignature, error) {
+	return fn(ctx, pubkey, signingRoot)
+}
+
+// signObject computes the signing root of the object with the domain of the given type and epoch, and signs it.
+func signObject(ctx context.Context, signer Signer, domainFn common.BLSDomainFn, pubkey common.BLSPubkey,
+	typ common.BLSDomainType, epoch common.Epoch, objRoot common.Root) (common.BLSSignature, error) {
+	dom, err := domainFn(typ, epoch)
+	if err != nil {
+		return common.BLSSignature{}, err
+	}
+	return signer.Sign(ctx, pubkey, common.ComputeSigningRoot(objRoot, dom))
+}
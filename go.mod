@@ -9,5 +9,9 @@ require (
 	github.com/protolambda/bls12-381-util v0.0.0-20210720105258-a772f2aac13e
 	github.com/protolambda/messagediff v1.4.0
 	github.com/protolambda/ztyp v0.2.2
+	github.com/tyler-smith/go-bip39 v1.1.0
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	golang.org/x/sys v0.10.0 // indirect
+	golang.org/x/text v0.13.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
 	"github.com/protolambda/zrnt/tests/spec/test_util"
@@ -57,12 +58,23 @@ func TestEth1DataReset(t *testing.T) {
 }
 
 func TestHistoricalRootsUpdate(t *testing.T) {
-	test_util.RunTransitionTest(t, test_util.AllForks, "epoch_processing", "historical_roots_update",
+	test_util.RunTransitionTest(t, []test_util.ForkName{"phase0", "altair", "bellatrix"}, "epoch_processing", "historical_roots_update",
 		NewEpochTest(func(spec *common.Spec, state common.BeaconState, epc *common.EpochsContext, flats []common.FlatValidator) error {
 			return phase0.ProcessHistoricalRootsUpdate(context.Background(), spec, epc, state)
 		}))
 }
 
+func TestHistoricalSummariesUpdate(t *testing.T) {
+	test_util.RunTransitionTest(t, []test_util.ForkName{"capella"}, "epoch_processing", "historical_summaries_update",
+		NewEpochTest(func(spec *common.Spec, state common.BeaconState, epc *common.EpochsContext, flats []common.FlatValidator) error {
+			if s, ok := state.(capella.HistoricalSummariesBeaconState); ok {
+				return capella.ProcessHistoricalSummariesUpdate(context.Background(), spec, epc, s)
+			} else {
+				return fmt.Errorf("unrecognized state type: %T", state)
+			}
+		}))
+}
+
 func TestJustificationAndFinalization(t *testing.T) {
 	test_util.RunTransitionTest(t, test_util.AllForks, "epoch_processing", "justification_and_finalization",
 		NewEpochTest(func(spec *common.Spec, state common.BeaconState, epc *common.EpochsContext, flats []common.FlatValidator) error {
@@ -108,7 +120,7 @@ func TestParticipationRecordUpdates(t *testing.T) {
 }
 
 func TestParticipationFlagUpdates(t *testing.T) {
-	test_util.RunTransitionTest(t, []test_util.ForkName{"altair", "bellatrix"}, "epoch_processing", "participation_flag_updates",
+	test_util.RunTransitionTest(t, []test_util.ForkName{"altair", "bellatrix", "capella"}, "epoch_processing", "participation_flag_updates",
 		NewEpochTest(func(spec *common.Spec, state common.BeaconState, epc *common.EpochsContext, flats []common.FlatValidator) error {
 			if s, ok := state.(altair.AltairLikeBeaconState); ok {
 				return altair.ProcessParticipationFlagUpdates(context.Background(), spec, s)
@@ -168,7 +180,7 @@ func TestSlashingsReset(t *testing.T) {
 }
 
 func TestSyncCommitteeUpdates(t *testing.T) {
-	test_util.RunTransitionTest(t, []test_util.ForkName{"altair", "bellatrix"}, "epoch_processing", "sync_committee_updates",
+	test_util.RunTransitionTest(t, []test_util.ForkName{"altair", "bellatrix", "capella"}, "epoch_processing", "sync_committee_updates",
 		NewEpochTest(func(spec *common.Spec, state common.BeaconState, epc *common.EpochsContext, flats []common.FlatValidator) error {
 			if s, ok := state.(common.SyncCommitteeBeaconState); ok {
 				return altair.ProcessSyncCommitteeUpdates(context.Background(), spec, epc, s)
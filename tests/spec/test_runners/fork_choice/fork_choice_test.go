@@ -0,0 +1,703 @@
+package fork_choice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/beacon/phase0"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/forkchoice"
+	"github.com/protolambda/zrnt/eth2/forkchoice/proto"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"gopkg.in/yaml.v3"
+)
+
+// INTERVALS_PER_SLOT is a constant in the fork choice spec, not a config var.
+const intervalsPerSlot = 3
+
+type PowBlock struct {
+	BlockHash       common.Hash32    `yaml:"block_hash"`
+	ParentHash      common.Hash32    `yaml:"parent_hash"`
+	TotalDifficulty view.Uint256View `yaml:"total_difficulty"`
+}
+
+func (b *PowBlock) Deserialize(dr *codec.DecodingReader) error {
+	return dr.FixedLenContainer(&b.BlockHash, &b.ParentHash, &b.TotalDifficulty)
+}
+
+func (b *PowBlock) FixedLength() uint64 {
+	return 32 + 32 + 32
+}
+
+type HeadCheck struct {
+	Slot common.Slot `yaml:"slot"`
+	Root common.Root `yaml:"root"`
+}
+
+type Checks struct {
+	Time                    *common.Timestamp  `yaml:"time"`
+	GenesisTime             *common.Timestamp  `yaml:"genesis_time"`
+	Head                    *HeadCheck         `yaml:"head"`
+	JustifiedCheckpoint     *common.Checkpoint `yaml:"justified_checkpoint"`
+	FinalizedCheckpoint     *common.Checkpoint `yaml:"finalized_checkpoint"`
+	BestJustifiedCheckpoint *common.Checkpoint `yaml:"best_justified_checkpoint"`
+	ProposerBoostRoot       *common.Root       `yaml:"proposer_boost_root"`
+}
+
+type Step struct {
+	Tick             *common.Timestamp `yaml:"tick"`
+	Block            *string           `yaml:"block"`
+	Attestation      *string           `yaml:"attestation"`
+	AttesterSlashing *string           `yaml:"attester_slashing"`
+	PowBlock         *string           `yaml:"pow_block"`
+	// Steps are valid unless specified otherwise
+	Valid  *bool   `yaml:"valid"`
+	Checks *Checks `yaml:"checks"`
+}
+
+type blockInfo struct {
+	slot   common.Slot
+	parent common.Root
+}
+
+type checkpointState struct {
+	state common.BeaconState
+	epc   *common.EpochsContext
+}
+
+// store mirrors the Store of the fork choice spec, and keeps the ProtoForkChoice in sync with it.
+// The spec store is the reference for the checkpoints and proposer boost, the head is computed by the forkchoice.
+type store struct {
+	spec *common.Spec
+
+	genesisTime common.Timestamp
+	time        common.Timestamp
+
+	justified         common.Checkpoint
+	finalized         common.Checkpoint
+	bestJustified     common.Checkpoint
+	proposerBoostRoot common.Root
+
+	blocks           map[common.Root]blockInfo
+	states           map[common.Root]common.BeaconState
+	checkpointStates map[common.Checkpoint]*checkpointState
+	powBlocks        map[common.Hash32]*PowBlock
+
+	fc forkchoice.Forkchoice
+}
+
+func newStore(spec *common.Spec, anchorState common.BeaconState, anchorBlock *common.BeaconBlockHeader) (*store, error) {
+	anchorRoot := anchorBlock.HashTreeRoot(tree.GetHashFn())
+	slot, err := anchorState.Slot()
+	if err != nil {
+		return nil, err
+	}
+	genesisTime, err := anchorState.GenesisTime()
+	if err != nil {
+		return nil, err
+	}
+	now, err := spec.TimeAtSlot(slot, genesisTime)
+	if err != nil {
+		return nil, err
+	}
+	anchorCp := common.Checkpoint{Epoch: spec.SlotToEpoch(slot), Root: anchorRoot}
+	s := &store{
+		spec:             spec,
+		genesisTime:      genesisTime,
+		time:             now,
+		justified:        anchorCp,
+		finalized:        anchorCp,
+		bestJustified:    anchorCp,
+		blocks:           map[common.Root]blockInfo{anchorRoot: {slot: anchorBlock.Slot, parent: anchorBlock.ParentRoot}},
+		states:           map[common.Root]common.BeaconState{anchorRoot: anchorState},
+		checkpointStates: make(map[common.Checkpoint]*checkpointState),
+		powBlocks:        make(map[common.Hash32]*PowBlock),
+	}
+	balances, err := s.justifiedBalances()
+	if err != nil {
+		return nil, err
+	}
+	s.fc, err = proto.NewProtoForkChoice(spec, anchorCp, anchorCp,
+		anchorRoot, anchorBlock.Slot, anchorBlock.ParentRoot, balances, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.fc.OnTick(s.currentSlot())
+	return s, nil
+}
+
+func (s *store) currentSlot() common.Slot {
+	return s.spec.TimeToSlot(s.time, s.genesisTime)
+}
+
+// ancestor returns the root of the block at the given slot, or the latest block before it, if the slot is empty.
+func (s *store) ancestor(root common.Root, slot common.Slot) common.Root {
+	for {
+		b, ok := s.blocks[root]
+		if !ok || b.slot <= slot {
+			return root
+		}
+		if _, ok := s.blocks[b.parent]; !ok {
+			// The anchor is the oldest block we know of
+			return root
+		}
+		root = b.parent
+	}
+}
+
+func (s *store) checkpointState(cp common.Checkpoint) (*checkpointState, error) {
+	if cs, ok := s.checkpointStates[cp]; ok {
+		return cs, nil
+	}
+	base, ok := s.states[cp.Root]
+	if !ok {
+		return nil, fmt.Errorf("unknown checkpoint block: %s", cp.Root)
+	}
+	state, err := base.CopyState()
+	if err != nil {
+		return nil, err
+	}
+	epc, err := common.NewEpochsContext(s.spec, state)
+	if err != nil {
+		return nil, err
+	}
+	slot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	targetSlot, err := s.spec.EpochStartSlot(cp.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	if slot < targetSlot {
+		ustate := &beacon.StandardUpgradeableBeaconState{BeaconState: state}
+		if err := common.ProcessSlots(context.Background(), s.spec, epc, ustate, targetSlot); err != nil {
+			return nil, err
+		}
+		state = ustate.BeaconState
+	}
+	cs := &checkpointState{state: state, epc: epc}
+	s.checkpointStates[cp] = cs
+	return cs, nil
+}
+
+func (s *store) justifiedBalances() ([]forkchoice.Gwei, error) {
+	cs, err := s.checkpointState(s.justified)
+	if err != nil {
+		return nil, err
+	}
+	vals, err := cs.state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	flat, err := common.FlattenValidators(vals)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]forkchoice.Gwei, len(flat), len(flat))
+	for i := range flat {
+		if flat[i].IsActive(s.justified.Epoch) {
+			out[i] = flat[i].EffectiveBalance
+		}
+	}
+	return out, nil
+}
+
+// updateForkchoice moves the justified and finalized checkpoints of the forkchoice to those of the store.
+func (s *store) updateForkchoice(trigger common.Root) error {
+	// Checkpoints refer to the start of the epoch, which may be an empty slot after the checkpoint block.
+	for _, cp := range []common.Checkpoint{s.finalized, s.justified} {
+		b, ok := s.blocks[cp.Root]
+		if !ok {
+			return fmt.Errorf("unknown checkpoint block: %s", cp.Root)
+		}
+		slot, err := s.spec.EpochStartSlot(cp.Epoch)
+		if err != nil {
+			return err
+		}
+		if slot > b.slot {
+			je, fe, err := checkpointEpochs(s.states[cp.Root])
+			if err != nil {
+				return err
+			}
+			s.fc.ProcessSlot(cp.Root, slot, je, fe)
+		}
+	}
+	return s.fc.UpdateJustified(context.Background(), trigger, s.justified, s.finalized, s.justifiedBalances)
+}
+
+func checkpointEpochs(state common.BeaconState) (justified common.Epoch, finalized common.Epoch, err error) {
+	j, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return j.Epoch, f.Epoch, nil
+}
+
+func (s *store) onTick(time common.Timestamp) error {
+	prevSlot := s.currentSlot()
+	s.time = time
+	currentSlot := s.currentSlot()
+	justifiedChanged := false
+	for slot := prevSlot + 1; slot <= currentSlot; slot++ {
+		s.proposerBoostRoot = common.Root{}
+		if s.spec.SlotToEpoch(slot) != s.spec.SlotToEpoch(slot-1) && s.bestJustified.Epoch > s.justified.Epoch {
+			s.justified = s.bestJustified
+			justifiedChanged = true
+		}
+	}
+	s.fc.OnTick(currentSlot)
+	if justifiedChanged {
+		return s.updateForkchoice(s.justified.Root)
+	}
+	return nil
+}
+
+func (s *store) shouldUpdateJustified(newJustified common.Checkpoint) (bool, error) {
+	epochStart, err := s.spec.EpochStartSlot(s.spec.SlotToEpoch(s.currentSlot()))
+	if err != nil {
+		return false, err
+	}
+	if s.currentSlot()-epochStart < common.Slot(s.spec.SAFE_SLOTS_TO_UPDATE_JUSTIFIED) {
+		return true, nil
+	}
+	justifiedSlot, err := s.spec.EpochStartSlot(s.justified.Epoch)
+	if err != nil {
+		return false, err
+	}
+	return s.ancestor(newJustified.Root, justifiedSlot) == s.justified.Root, nil
+}
+
+func blockOperations(body common.SpecObj) (phase0.Attestations, phase0.AttesterSlashings, error) {
+	switch b := body.(type) {
+	case *phase0.BeaconBlockBody:
+		return b.Attestations, b.AttesterSlashings, nil
+	case *altair.BeaconBlockBody:
+		return b.Attestations, b.AttesterSlashings, nil
+	case *bellatrix.BeaconBlockBody:
+		return b.Attestations, b.AttesterSlashings, nil
+	case *capella.BeaconBlockBody:
+		return b.Attestations, b.AttesterSlashings, nil
+	default:
+		return nil, nil, fmt.Errorf("unrecognized block body type: %T", body)
+	}
+}
+
+func (s *store) validateMergeBlock(slot common.Slot, payload *common.ExecutionPayload) error {
+	if s.spec.TERMINAL_BLOCK_HASH != (common.Hash32{}) {
+		if s.spec.SlotToEpoch(slot) < common.Epoch(s.spec.TERMINAL_BLOCK_HASH_ACTIVATION_EPOCH) {
+			return errors.New("terminal block hash is not activated yet")
+		}
+		if payload.ParentHash != s.spec.TERMINAL_BLOCK_HASH {
+			return errors.New("merge block does not build on terminal block hash")
+		}
+		return nil
+	}
+	powBlock, ok := s.powBlocks[payload.ParentHash]
+	if !ok {
+		return fmt.Errorf("unknown terminal pow block: %s", payload.ParentHash)
+	}
+	powParent, ok := s.powBlocks[powBlock.ParentHash]
+	if !ok {
+		return fmt.Errorf("unknown parent of terminal pow block: %s", powBlock.ParentHash)
+	}
+	ttd := s.spec.TERMINAL_TOTAL_DIFFICULTY
+	if lessUint256(powBlock.TotalDifficulty, ttd) || !lessUint256(powParent.TotalDifficulty, ttd) {
+		return errors.New("invalid terminal pow block")
+	}
+	return nil
+}
+
+func lessUint256(a, b view.Uint256View) bool {
+	for i := 3; i >= 0; i-- {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+func (s *store) onBlock(benv *common.BeaconBlockEnvelope) error {
+	preState, ok := s.states[benv.ParentRoot]
+	if !ok {
+		return fmt.Errorf("unknown parent block: %s", benv.ParentRoot)
+	}
+	if s.currentSlot() < benv.Slot {
+		return fmt.Errorf("block slot %d is in the future, current slot: %d", benv.Slot, s.currentSlot())
+	}
+	finalizedSlot, err := s.spec.EpochStartSlot(s.finalized.Epoch)
+	if err != nil {
+		return err
+	}
+	if benv.Slot <= finalizedSlot {
+		return fmt.Errorf("block slot %d is not after finalized slot %d", benv.Slot, finalizedSlot)
+	}
+	if s.ancestor(benv.ParentRoot, finalizedSlot) != s.finalized.Root {
+		return errors.New("block does not descend from finalized checkpoint")
+	}
+
+	state, err := preState.CopyState()
+	if err != nil {
+		return err
+	}
+	epc, err := common.NewEpochsContext(s.spec, state)
+	if err != nil {
+		return err
+	}
+	ustate := &beacon.StandardUpgradeableBeaconState{BeaconState: state}
+	if err := common.StateTransition(context.Background(), s.spec, epc, ustate, benv, true); err != nil {
+		return err
+	}
+	if body, ok := benv.Body.(*bellatrix.BeaconBlockBody); ok {
+		if pre, ok := preState.(bellatrix.ExecutionUpgradeBeaconState); ok {
+			isMergeBlock, err := pre.IsTransitionBlock(s.spec, &bellatrix.BeaconBlock{Body: *body})
+			if err != nil {
+				return err
+			}
+			if isMergeBlock {
+				if err := s.validateMergeBlock(benv.Slot, &body.ExecutionPayload); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	post := ustate.BeaconState
+	root := benv.BlockRoot
+	s.blocks[root] = blockInfo{slot: benv.Slot, parent: benv.ParentRoot}
+	s.states[root] = post
+
+	je, fe, err := checkpointEpochs(post)
+	if err != nil {
+		return err
+	}
+	if !s.fc.ProcessBlock(benv.ParentRoot, root, benv.Slot, je, fe) {
+		return fmt.Errorf("forkchoice did not accept block %s", root)
+	}
+
+	timeIntoSlot := (s.time - s.genesisTime) % s.spec.SECONDS_PER_SLOT
+	if s.currentSlot() == benv.Slot && timeIntoSlot < s.spec.SECONDS_PER_SLOT/intervalsPerSlot {
+		s.proposerBoostRoot = root
+		s.fc.OnTimelyBlock(root, benv.Slot)
+	}
+
+	justified, err := post.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return err
+	}
+	finalized, err := post.FinalizedCheckpoint()
+	if err != nil {
+		return err
+	}
+	if justified.Epoch > s.bestJustified.Epoch {
+		s.bestJustified = justified
+	}
+	if justified.Epoch > s.justified.Epoch {
+		if ok, err := s.shouldUpdateJustified(justified); err != nil {
+			return err
+		} else if ok {
+			s.justified = justified
+		}
+	}
+	if finalized.Epoch > s.finalized.Epoch {
+		s.finalized = finalized
+		s.justified = justified
+	}
+	if err := s.updateForkchoice(root); err != nil {
+		return err
+	}
+
+	atts, slashings, err := blockOperations(benv.Body)
+	if err != nil {
+		return err
+	}
+	for i := range atts {
+		if err := s.onAttestation(&atts[i], true); err != nil {
+			return fmt.Errorf("block attestation %d: %w", i, err)
+		}
+	}
+	for i := range slashings {
+		if err := s.onAttesterSlashing(&slashings[i]); err != nil {
+			return fmt.Errorf("block attester slashing %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (s *store) onAttestation(att *phase0.Attestation, fromBlock bool) error {
+	target := att.Data.Target
+	if !fromBlock {
+		currentEpoch := s.spec.SlotToEpoch(s.currentSlot())
+		previousEpoch := currentEpoch.Previous()
+		if target.Epoch != currentEpoch && target.Epoch != previousEpoch {
+			return fmt.Errorf("attestation target epoch %d is not current or previous epoch", target.Epoch)
+		}
+	}
+	if target.Epoch != s.spec.SlotToEpoch(att.Data.Slot) {
+		return errors.New("attestation target epoch does not match slot")
+	}
+	if _, ok := s.blocks[target.Root]; !ok {
+		return fmt.Errorf("unknown attestation target: %s", target.Root)
+	}
+	b, ok := s.blocks[att.Data.BeaconBlockRoot]
+	if !ok {
+		return fmt.Errorf("unknown attestation head: %s", att.Data.BeaconBlockRoot)
+	}
+	if b.slot > att.Data.Slot {
+		return errors.New("attestation head block is newer than attestation")
+	}
+	targetSlot, err := s.spec.EpochStartSlot(target.Epoch)
+	if err != nil {
+		return err
+	}
+	if s.ancestor(att.Data.BeaconBlockRoot, targetSlot) != target.Root {
+		return errors.New("attestation head does not descend from target")
+	}
+	if s.currentSlot() < att.Data.Slot+1 {
+		return errors.New("attestation can only affect forkchoice in later slots")
+	}
+
+	cs, err := s.checkpointState(target)
+	if err != nil {
+		return err
+	}
+	committee, err := cs.epc.GetBeaconCommittee(att.Data.Slot, att.Data.Index)
+	if err != nil {
+		return err
+	}
+	indexed, err := att.ConvertToIndexed(s.spec, committee)
+	if err != nil {
+		return err
+	}
+	if err := phase0.ValidateIndexedAttestation(context.Background(), s.spec, cs.epc, cs.state, indexed); err != nil {
+		return err
+	}
+	// The spec votes for blocks, not for empty slots after them, so the vote is applied to the block node itself.
+	for _, index := range indexed.AttestingIndices {
+		s.fc.ProcessAttestation(index, att.Data.BeaconBlockRoot, b.slot, att.Data.Target.Epoch)
+	}
+	return nil
+}
+
+func (s *store) onAttesterSlashing(sl *phase0.AttesterSlashing) error {
+	if !phase0.IsSlashableAttestationData(&sl.Attestation1.Data, &sl.Attestation2.Data) {
+		return errors.New("attester slashing is not slashable")
+	}
+	state, ok := s.states[s.justified.Root]
+	if !ok {
+		return fmt.Errorf("unknown justified state: %s", s.justified.Root)
+	}
+	epc, err := common.NewEpochsContext(s.spec, state)
+	if err != nil {
+		return err
+	}
+	if err := phase0.ValidateIndexedAttestation(context.Background(), s.spec, epc, state, &sl.Attestation1); err != nil {
+		return fmt.Errorf("attestation 1 is invalid: %w", err)
+	}
+	if err := phase0.ValidateIndexedAttestation(context.Background(), s.spec, epc, state, &sl.Attestation2); err != nil {
+		return fmt.Errorf("attestation 2 is invalid: %w", err)
+	}
+	s.fc.ProcessEquivocation(sl.EquivocatingIndices())
+	return nil
+}
+
+func (s *store) check(t *testing.T, c *Checks) {
+	if c.Time != nil && *c.Time != s.time {
+		t.Errorf("expected time %d, got %d", *c.Time, s.time)
+	}
+	if c.GenesisTime != nil && *c.GenesisTime != s.genesisTime {
+		t.Errorf("expected genesis time %d, got %d", *c.GenesisTime, s.genesisTime)
+	}
+	if c.Head != nil {
+		head, err := s.fc.Head()
+		if err != nil {
+			t.Errorf("failed to get head: %v", err)
+		} else if slot := s.blocks[head.Root].slot; head.Root != c.Head.Root || slot != c.Head.Slot {
+			t.Errorf("expected head %s at slot %d, got %s at slot %d", c.Head.Root, c.Head.Slot, head.Root, slot)
+		}
+	}
+	if c.JustifiedCheckpoint != nil && *c.JustifiedCheckpoint != s.justified {
+		t.Errorf("expected justified checkpoint %s, got %s", c.JustifiedCheckpoint, &s.justified)
+	}
+	if c.JustifiedCheckpoint != nil && *c.JustifiedCheckpoint != s.fc.Justified() {
+		fcJustified := s.fc.Justified()
+		t.Errorf("expected forkchoice justified checkpoint %s, got %s", c.JustifiedCheckpoint, &fcJustified)
+	}
+	if c.FinalizedCheckpoint != nil && *c.FinalizedCheckpoint != s.finalized {
+		t.Errorf("expected finalized checkpoint %s, got %s", c.FinalizedCheckpoint, &s.finalized)
+	}
+	if c.FinalizedCheckpoint != nil && *c.FinalizedCheckpoint != s.fc.Finalized() {
+		fcFinalized := s.fc.Finalized()
+		t.Errorf("expected forkchoice finalized checkpoint %s, got %s", c.FinalizedCheckpoint, &fcFinalized)
+	}
+	if c.BestJustifiedCheckpoint != nil && *c.BestJustifiedCheckpoint != s.bestJustified {
+		t.Errorf("expected best justified checkpoint %s, got %s", c.BestJustifiedCheckpoint, &s.bestJustified)
+	}
+	if c.ProposerBoostRoot != nil && *c.ProposerBoostRoot != s.proposerBoostRoot {
+		t.Errorf("expected proposer boost root %s, got %s", c.ProposerBoostRoot, s.proposerBoostRoot)
+	}
+}
+
+type ForkChoiceTestCase struct {
+	Spec        *common.Spec
+	Fork        test_util.ForkName
+	AnchorState common.BeaconState
+	AnchorBlock *common.BeaconBlockHeader
+	Steps       []Step
+}
+
+func (c *ForkChoiceTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+	c.Spec = readPart.Spec()
+	c.Fork = forkName
+	if state := test_util.LoadState(t, forkName, "anchor_state", readPart); state != nil {
+		c.AnchorState = state
+	} else {
+		t.Fatalf("failed to load anchor state")
+	}
+	switch forkName {
+	case "phase0":
+		dst := new(phase0.BeaconBlock)
+		test_util.LoadSpecObj(t, "anchor_block", dst, readPart)
+		c.AnchorBlock = dst.Header(c.Spec)
+	case "altair":
+		dst := new(altair.BeaconBlock)
+		test_util.LoadSpecObj(t, "anchor_block", dst, readPart)
+		c.AnchorBlock = dst.Header(c.Spec)
+	case "bellatrix":
+		dst := new(bellatrix.BeaconBlock)
+		test_util.LoadSpecObj(t, "anchor_block", dst, readPart)
+		c.AnchorBlock = dst.Header(c.Spec)
+	case "capella":
+		dst := new(capella.BeaconBlock)
+		test_util.LoadSpecObj(t, "anchor_block", dst, readPart)
+		c.AnchorBlock = dst.Header(c.Spec)
+	default:
+		t.Fatalf("unrecognized fork name: %s", forkName)
+	}
+	p := readPart.Part("steps.yaml")
+	dec := yaml.NewDecoder(p)
+	test_util.Check(t, dec.Decode(&c.Steps))
+	test_util.Check(t, p.Close())
+}
+
+func (c *ForkChoiceTestCase) loadBlock(t *testing.T, name string, readPart test_util.TestPartReader) *common.BeaconBlockEnvelope {
+	valRoot, err := c.AnchorState.GenesisValidatorsRoot()
+	test_util.Check(t, err)
+	switch c.Fork {
+	case "phase0":
+		dst := new(phase0.SignedBeaconBlock)
+		test_util.LoadSpecObj(t, name, dst, readPart)
+		digest := common.ComputeForkDigest(c.Spec.GENESIS_FORK_VERSION, valRoot)
+		return dst.Envelope(c.Spec, digest)
+	case "altair":
+		dst := new(altair.SignedBeaconBlock)
+		test_util.LoadSpecObj(t, name, dst, readPart)
+		digest := common.ComputeForkDigest(c.Spec.ALTAIR_FORK_VERSION, valRoot)
+		return dst.Envelope(c.Spec, digest)
+	case "bellatrix":
+		dst := new(bellatrix.SignedBeaconBlock)
+		test_util.LoadSpecObj(t, name, dst, readPart)
+		digest := common.ComputeForkDigest(c.Spec.BELLATRIX_FORK_VERSION, valRoot)
+		return dst.Envelope(c.Spec, digest)
+	case "capella":
+		dst := new(capella.SignedBeaconBlock)
+		test_util.LoadSpecObj(t, name, dst, readPart)
+		digest := common.ComputeForkDigest(c.Spec.CAPELLA_FORK_VERSION, valRoot)
+		return dst.Envelope(c.Spec, digest)
+	default:
+		t.Fatalf("unrecognized fork name: %s", c.Fork)
+		return nil
+	}
+}
+
+func (c *ForkChoiceTestCase) Run(t *testing.T, readPart test_util.TestPartReader) {
+	s, err := newStore(c.Spec, c.AnchorState, c.AnchorBlock)
+	test_util.Check(t, err)
+	for i, step := range c.Steps {
+		var err error
+		switch {
+		case step.Tick != nil:
+			err = s.onTick(*step.Tick)
+		case step.Block != nil:
+			err = s.onBlock(c.loadBlock(t, *step.Block, readPart))
+		case step.Attestation != nil:
+			att := new(phase0.Attestation)
+			test_util.LoadSpecObj(t, *step.Attestation, att, readPart)
+			err = s.onAttestation(att, false)
+		case step.AttesterSlashing != nil:
+			sl := new(phase0.AttesterSlashing)
+			test_util.LoadSpecObj(t, *step.AttesterSlashing, sl, readPart)
+			err = s.onAttesterSlashing(sl)
+		case step.PowBlock != nil:
+			b := new(PowBlock)
+			test_util.LoadSSZ(t, *step.PowBlock, b, readPart)
+			s.powBlocks[b.BlockHash] = b
+		case step.Checks != nil:
+			s.check(t, step.Checks)
+			continue
+		default:
+			t.Fatalf("step %d: unrecognized step", i)
+		}
+		valid := step.Valid == nil || *step.Valid
+		if valid && err != nil {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		} else if !valid && err == nil {
+			t.Fatalf("step %d: expected step to be invalid", i)
+		}
+	}
+}
+
+func runForkChoiceTest(t *testing.T, forks []test_util.ForkName, handlerName string) {
+	caseRunner := test_util.HandleBLS(func(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+		c := new(ForkChoiceTestCase)
+		c.Load(t, forkName, readPart)
+		c.Run(t, readPart)
+	})
+	t.Run("minimal", func(t *testing.T) {
+		spec := *configs.Minimal
+		spec.ExecutionEngine = &test_util.NoOpExecutionEngine{}
+		for _, fork := range forks {
+			t.Run(string(fork), func(t *testing.T) {
+				test_util.RunHandler(t, "fork_choice/"+handlerName, caseRunner, &spec, fork)
+			})
+		}
+	})
+	t.Run("mainnet", func(t *testing.T) {
+		spec := *configs.Mainnet
+		spec.ExecutionEngine = &test_util.NoOpExecutionEngine{}
+		for _, fork := range forks {
+			t.Run(string(fork), func(t *testing.T) {
+				test_util.RunHandler(t, "fork_choice/"+handlerName, caseRunner, &spec, fork)
+			})
+		}
+	})
+}
+
+func TestGetHead(t *testing.T) {
+	runForkChoiceTest(t, test_util.AllForks, "get_head")
+}
+
+func TestOnBlock(t *testing.T) {
+	runForkChoiceTest(t, test_util.AllForks, "on_block")
+}
+
+func TestExAnte(t *testing.T) {
+	runForkChoiceTest(t, test_util.AllForks, "ex_ante")
+}
+
+func TestOnMergeBlock(t *testing.T) {
+	runForkChoiceTest(t, []test_util.ForkName{"bellatrix"}, "on_merge_block")
+}
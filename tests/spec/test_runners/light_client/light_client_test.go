@@ -0,0 +1,28 @@
+package light_client
+
+import (
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+)
+
+// The light client containers of later forks are not supported yet, only their state proofs are.
+var lightClientForks = []test_util.ForkName{"altair", "bellatrix"}
+
+func runLightClientTest(t *testing.T, forks []test_util.ForkName, handlerName string, caseRunner test_util.CaseRunner) {
+	t.Run("minimal", func(t *testing.T) {
+		for _, fork := range forks {
+			t.Run(string(fork), func(t *testing.T) {
+				test_util.RunHandler(t, "light_client/"+handlerName, caseRunner, configs.Minimal, fork)
+			})
+		}
+	})
+	t.Run("mainnet", func(t *testing.T) {
+		for _, fork := range forks {
+			t.Run(string(fork), func(t *testing.T) {
+				test_util.RunHandler(t, "light_client/"+handlerName, caseRunner, configs.Mainnet, fork)
+			})
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package light_client
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+	"github.com/protolambda/ztyp/tree"
+	"gopkg.in/yaml.v3"
+)
+
+type SingleProof struct {
+	Leaf      common.Root   `yaml:"leaf"`
+	LeafIndex uint64        `yaml:"leaf_index"`
+	Branch    []common.Root `yaml:"branch"`
+}
+
+type SingleProofTestCase struct {
+	State common.BeaconState
+	Proof SingleProof
+}
+
+func (c *SingleProofTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+	if state := test_util.LoadState(t, forkName, "object", readPart); state != nil {
+		c.State = state
+	} else {
+		t.Fatalf("failed to load state")
+	}
+	p := readPart.Part("proof.yaml")
+	dec := yaml.NewDecoder(p)
+	test_util.Check(t, dec.Decode(&c.Proof))
+	test_util.Check(t, p.Close())
+}
+
+func (c *SingleProofTestCase) Run(t *testing.T) {
+	// The light client proofs are the only single proofs covered by the spec tests.
+	var expectedLeaf common.Root
+	var path string
+	switch tree.Gindex64(c.Proof.LeafIndex) {
+	case altair.CURRENT_SYNC_COMMITTEE_INDEX:
+		path = "current_sync_committee"
+		state, ok := c.State.(common.SyncCommitteeBeaconState)
+		if !ok {
+			t.Fatalf("state has no sync committees: %T", c.State)
+		}
+		current, err := state.CurrentSyncCommittee()
+		test_util.Check(t, err)
+		expectedLeaf = current.HashTreeRoot(tree.GetHashFn())
+	case altair.NEXT_SYNC_COMMITTEE_INDEX:
+		path = "next_sync_committee"
+		state, ok := c.State.(common.SyncCommitteeBeaconState)
+		if !ok {
+			t.Fatalf("state has no sync committees: %T", c.State)
+		}
+		next, err := state.NextSyncCommittee()
+		test_util.Check(t, err)
+		expectedLeaf = next.HashTreeRoot(tree.GetHashFn())
+	case altair.FINALIZED_ROOT_INDEX:
+		path = "finalized_checkpoint.root"
+		finalized, err := c.State.FinalizedCheckpoint()
+		test_util.Check(t, err)
+		expectedLeaf = finalized.Root
+	default:
+		t.Fatalf("unrecognized proof leaf index: %d", c.Proof.LeafIndex)
+	}
+	if c.Proof.Leaf != expectedLeaf {
+		t.Fatalf("expected leaf %s, got %s", expectedLeaf, c.Proof.Leaf)
+	}
+	depth := uint64(bits.Len64(c.Proof.LeafIndex)) - 1
+	if uint64(len(c.Proof.Branch)) != depth {
+		t.Fatalf("expected branch of length %d, got %d", depth, len(c.Proof.Branch))
+	}
+	index := c.Proof.LeafIndex ^ (1 << depth)
+	if !merkle.VerifyMerkleBranch(c.Proof.Leaf, c.Proof.Branch, depth, index, c.State.HashTreeRoot(tree.GetHashFn())) {
+		t.Fatalf("invalid merkle branch")
+	}
+	// The proof must match the proof built from the state.
+	proof, err := merkle.ProvePath(c.State, path, tree.GetHashFn())
+	test_util.Check(t, err)
+	if uint64(proof.Gindex) != c.Proof.LeafIndex || proof.Leaf != c.Proof.Leaf {
+		t.Fatalf("expected proof of %d, built proof of %d", c.Proof.LeafIndex, proof.Gindex)
+	}
+	for i := range proof.Branch {
+		if proof.Branch[i] != c.Proof.Branch[i] {
+			t.Fatalf("branch node %d differs: expected %s, got %s", i, c.Proof.Branch[i], proof.Branch[i])
+		}
+	}
+}
+
+func TestSingleMerkleProof(t *testing.T) {
+	runLightClientTest(t, []test_util.ForkName{"altair", "bellatrix", "capella"}, "single_merkle_proof", func(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+		c := new(SingleProofTestCase)
+		c.Load(t, forkName, readPart)
+		c.Run(t)
+	})
+}
@@ -0,0 +1,114 @@
+package light_client
+
+import (
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/lightclient"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+	"github.com/protolambda/ztyp/tree"
+	"gopkg.in/yaml.v3"
+)
+
+type SyncMeta struct {
+	GenesisValidatorsRoot common.Root `yaml:"genesis_validators_root"`
+	TrustedBlockRoot      common.Root `yaml:"trusted_block_root"`
+}
+
+type HeaderCheck struct {
+	Slot       common.Slot `yaml:"slot"`
+	BeaconRoot common.Root `yaml:"beacon_root"`
+}
+
+type SyncChecks struct {
+	FinalizedHeader  *HeaderCheck `yaml:"finalized_header"`
+	OptimisticHeader *HeaderCheck `yaml:"optimistic_header"`
+}
+
+type ProcessUpdateStep struct {
+	Update      string      `yaml:"update"`
+	CurrentSlot common.Slot `yaml:"current_slot"`
+	Checks      SyncChecks  `yaml:"checks"`
+}
+
+type ForceUpdateStep struct {
+	CurrentSlot common.Slot `yaml:"current_slot"`
+	Checks      SyncChecks  `yaml:"checks"`
+}
+
+type SyncStep struct {
+	ProcessUpdate *ProcessUpdateStep `yaml:"process_update"`
+	ForceUpdate   *ForceUpdateStep   `yaml:"force_update"`
+}
+
+type SyncTestCase struct {
+	Spec      *common.Spec
+	Meta      SyncMeta
+	Bootstrap altair.LightClientBootstrap
+	Steps     []SyncStep
+}
+
+func (c *SyncTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+	c.Spec = readPart.Spec()
+	p := readPart.Part("meta.yaml")
+	dec := yaml.NewDecoder(p)
+	test_util.Check(t, dec.Decode(&c.Meta))
+	test_util.Check(t, p.Close())
+	if !test_util.LoadSpecObj(t, "bootstrap", &c.Bootstrap, readPart) {
+		t.Fatalf("failed to load bootstrap")
+	}
+	p = readPart.Part("steps.yaml")
+	dec = yaml.NewDecoder(p)
+	test_util.Check(t, dec.Decode(&c.Steps))
+	test_util.Check(t, p.Close())
+}
+
+func checkHeader(t *testing.T, name string, header *common.BeaconBlockHeader, expected *HeaderCheck) {
+	if expected == nil {
+		return
+	}
+	if header.Slot != expected.Slot {
+		t.Fatalf("expected %s slot %d, got %d", name, expected.Slot, header.Slot)
+	}
+	if root := header.HashTreeRoot(tree.GetHashFn()); root != expected.BeaconRoot {
+		t.Fatalf("expected %s root %s, got %s", name, expected.BeaconRoot, root)
+	}
+}
+
+func (c *SyncTestCase) Run(t *testing.T, readPart test_util.TestPartReader) {
+	store, err := lightclient.InitializeLightClientStore(c.Spec, c.Meta.TrustedBlockRoot, &c.Bootstrap)
+	test_util.Check(t, err)
+	for i, step := range c.Steps {
+		var checks *SyncChecks
+		switch {
+		case step.ProcessUpdate != nil:
+			update := new(altair.LightClientUpdate)
+			if !test_util.LoadSpecObj(t, step.ProcessUpdate.Update, update, readPart) {
+				t.Fatalf("step %d: failed to load update %s", i, step.ProcessUpdate.Update)
+			}
+			if err := lightclient.ProcessLightClientUpdate(c.Spec, store, update,
+				step.ProcessUpdate.CurrentSlot, c.Meta.GenesisValidatorsRoot); err != nil {
+				t.Fatalf("step %d: failed to process update: %v", i, err)
+			}
+			checks = &step.ProcessUpdate.Checks
+		case step.ForceUpdate != nil:
+			if err := lightclient.ProcessLightClientStoreForceUpdate(c.Spec, store, step.ForceUpdate.CurrentSlot); err != nil {
+				t.Fatalf("step %d: failed to force update: %v", i, err)
+			}
+			checks = &step.ForceUpdate.Checks
+		default:
+			t.Fatalf("step %d: unrecognized step", i)
+		}
+		checkHeader(t, "finalized header", &store.FinalizedHeader, checks.FinalizedHeader)
+		checkHeader(t, "optimistic header", &store.OptimisticHeader, checks.OptimisticHeader)
+	}
+}
+
+func TestSync(t *testing.T) {
+	runLightClientTest(t, lightClientForks, "sync", func(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+		c := new(SyncTestCase)
+		c.Load(t, forkName, readPart)
+		c.Run(t, readPart)
+	})
+}
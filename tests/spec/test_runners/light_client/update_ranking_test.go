@@ -0,0 +1,57 @@
+package light_client
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/altair"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/eth2/lightclient"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+	"gopkg.in/yaml.v3"
+)
+
+type UpdateRankingMeta struct {
+	UpdatesCount uint64 `yaml:"updates_count"`
+}
+
+type UpdateRankingTestCase struct {
+	Spec *common.Spec
+	// Sorted from best to worst
+	Updates []*altair.LightClientUpdate
+}
+
+func (c *UpdateRankingTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+	c.Spec = readPart.Spec()
+	var meta UpdateRankingMeta
+	p := readPart.Part("meta.yaml")
+	dec := yaml.NewDecoder(p)
+	test_util.Check(t, dec.Decode(&meta))
+	test_util.Check(t, p.Close())
+	for i := uint64(0); i < meta.UpdatesCount; i++ {
+		update := new(altair.LightClientUpdate)
+		if !test_util.LoadSpecObj(t, fmt.Sprintf("updates_%d", i), update, readPart) {
+			t.Fatalf("failed to load update %d", i)
+		}
+		c.Updates = append(c.Updates, update)
+	}
+}
+
+func (c *UpdateRankingTestCase) Run(t *testing.T) {
+	// Sorting with IsBetterUpdate must not change the order
+	for i := range c.Updates {
+		for j := i + 1; j < len(c.Updates); j++ {
+			if lightclient.IsBetterUpdate(c.Spec, c.Updates[j], c.Updates[i]) {
+				t.Fatalf("update %d is ranked better than update %d", j, i)
+			}
+		}
+	}
+}
+
+func TestUpdateRanking(t *testing.T) {
+	runLightClientTest(t, lightClientForks, "update_ranking", func(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+		c := new(UpdateRankingTestCase)
+		c.Load(t, forkName, readPart)
+		c.Run(t)
+	})
+}
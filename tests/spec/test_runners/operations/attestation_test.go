@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -26,9 +27,9 @@ func (c *AttestationTestCase) Run() error {
 		return err
 	}
 	if s, ok := c.Pre.(phase0.Phase0PendingAttestationsBeaconState); ok {
-		return phase0.ProcessAttestation(c.Spec, epc, s, &c.Attestation)
+		return phase0.ProcessAttestation(context.Background(), c.Spec, epc, s, &c.Attestation)
 	} else if s, ok := c.Pre.(altair.AltairLikeBeaconState); ok {
-		return altair.ProcessAttestation(c.Spec, epc, s, &c.Attestation)
+		return altair.ProcessAttestation(context.Background(), c.Spec, epc, s, &c.Attestation)
 	} else {
 		return fmt.Errorf("unrecognized state type: %T", s)
 	}
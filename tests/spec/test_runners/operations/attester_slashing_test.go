@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"testing"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -23,7 +24,7 @@ func (c *AttesterSlashingTestCase) Run() error {
 	if err != nil {
 		return err
 	}
-	return phase0.ProcessAttesterSlashing(c.Spec, epc, c.Pre, &c.AttesterSlashing)
+	return phase0.ProcessAttesterSlashing(context.Background(), c.Spec, epc, c.Pre, &c.AttesterSlashing)
 }
 
 func TestAttesterSlashing(t *testing.T) {
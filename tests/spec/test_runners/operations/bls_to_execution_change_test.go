@@ -0,0 +1,33 @@
+package operations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
+	"github.com/protolambda/zrnt/eth2/beacon/common"
+	"github.com/protolambda/zrnt/tests/spec/test_util"
+)
+
+type BLSToExecutionChangeTestCase struct {
+	test_util.BaseTransitionTest
+	Change capella.SignedBLSToExecutionChange
+}
+
+func (c *BLSToExecutionChangeTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
+	c.BaseTransitionTest.Load(t, forkName, readPart)
+	test_util.LoadSSZ(t, "address_change", &c.Change, readPart)
+}
+
+func (c *BLSToExecutionChangeTestCase) Run() error {
+	epc, err := common.NewEpochsContext(c.Spec, c.Pre)
+	if err != nil {
+		return err
+	}
+	return capella.ProcessBLSToExecutionChange(context.Background(), c.Spec, epc, c.Pre, &c.Change)
+}
+
+func TestBLSToExecutionChange(t *testing.T) {
+	test_util.RunTransitionTest(t, []test_util.ForkName{"capella"}, "operations", "bls_to_execution_change",
+		func() test_util.TransitionTest { return new(BLSToExecutionChangeTestCase) })
+}
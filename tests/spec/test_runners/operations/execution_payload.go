@@ -2,10 +2,12 @@ package operations
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/tests/spec/test_util"
 	"gopkg.in/yaml.v3"
@@ -15,21 +17,50 @@ type MockExecEngine struct {
 	Valid bool `yaml:"execution_valid"`
 }
 
-func (m *MockExecEngine) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (valid bool, err error) {
-	return m.Valid, nil
+func (m *MockExecEngine) status() common.PayloadExecutionStatus {
+	if m.Valid {
+		return common.PayloadExecutionValid
+	}
+	return common.PayloadExecutionInvalid
+}
+
+func (m *MockExecEngine) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return m.status(), nil
+}
+
+func (m *MockExecEngine) ExecuteCapellaPayload(ctx context.Context, executionPayload *capella.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return m.status(), nil
+}
+
+func (m *MockExecEngine) NotifyForkchoiceUpdated(ctx context.Context, headBlockHash common.Hash32, safeBlockHash common.Hash32,
+	finalizedBlockHash common.Hash32, attributes *common.PayloadAttributes) (*common.PayloadID, error) {
+	return nil, errors.New("not supported")
+}
+
+func (m *MockExecEngine) GetPayload(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	return nil, errors.New("not supported")
 }
 
 var _ common.ExecutionEngine = (*MockExecEngine)(nil)
+var _ capella.ExecutionEngine = (*MockExecEngine)(nil)
 
 type ExecutionPayloadTestCase struct {
 	test_util.BaseTransitionTest
-	ExecutionPayload common.ExecutionPayload
-	Execution        MockExecEngine
+	ExecutionPayload        common.ExecutionPayload
+	CapellaExecutionPayload capella.ExecutionPayload
+	Execution               MockExecEngine
 }
 
 func (c *ExecutionPayloadTestCase) Load(t *testing.T, forkName test_util.ForkName, readPart test_util.TestPartReader) {
 	c.BaseTransitionTest.Load(t, forkName, readPart)
-	test_util.LoadSSZ(t, "sync_aggregate", c.Spec.Wrap(&c.ExecutionPayload), readPart)
+	switch forkName {
+	case "bellatrix":
+		test_util.LoadSSZ(t, "execution_payload", c.Spec.Wrap(&c.ExecutionPayload), readPart)
+	case "capella":
+		test_util.LoadSSZ(t, "execution_payload", c.Spec.Wrap(&c.CapellaExecutionPayload), readPart)
+	default:
+		t.Fatalf("unrecognized fork: %s", forkName)
+	}
 	part := readPart.Part("execution.yml")
 	dec := yaml.NewDecoder(part)
 	dec.KnownFields(true)
@@ -37,14 +68,17 @@ func (c *ExecutionPayloadTestCase) Load(t *testing.T, forkName test_util.ForkNam
 }
 
 func (c *ExecutionPayloadTestCase) Run() error {
-	s, ok := c.Pre.(*bellatrix.BeaconStateView)
-	if !ok {
+	switch s := c.Pre.(type) {
+	case *bellatrix.BeaconStateView:
+		return bellatrix.ProcessExecutionPayload(context.Background(), c.Spec, s, &c.ExecutionPayload, &c.Execution)
+	case *capella.BeaconStateView:
+		return capella.ProcessExecutionPayload(context.Background(), c.Spec, s, &c.CapellaExecutionPayload, &c.Execution)
+	default:
 		return fmt.Errorf("unrecognized state type: %T", c.Pre)
 	}
-	return bellatrix.ProcessExecutionPayload(context.Background(), c.Spec, s, &c.ExecutionPayload, &c.Execution)
 }
 
 func TestExecutionPayload(t *testing.T) {
-	test_util.RunTransitionTest(t, []test_util.ForkName{"bellatrix"}, "operations", "execution_payload",
+	test_util.RunTransitionTest(t, []test_util.ForkName{"bellatrix", "capella"}, "operations", "execution_payload",
 		func() test_util.TransitionTest { return new(ExecutionPayloadTestCase) })
 }
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"testing"
 
 	"github.com/protolambda/zrnt/eth2/beacon/common"
@@ -23,7 +24,7 @@ func (c *ProposerSlashingTestCase) Run() error {
 	if err != nil {
 		return err
 	}
-	return phase0.ProcessProposerSlashing(c.Spec, epc, c.Pre, &c.ProposerSlashing)
+	return phase0.ProcessProposerSlashing(context.Background(), c.Spec, epc, c.Pre, &c.ProposerSlashing)
 }
 
 func TestProposerSlashing(t *testing.T) {
@@ -7,6 +7,12 @@ import (
 )
 
 func TestRandomBlocks(t *testing.T) {
-	test_util.RunTransitionTest(t, test_util.AllForks, "random", "random",
-		func() test_util.TransitionTest { return new(test_util.BlocksTestCase) })
+	t.Run("individual", func(t *testing.T) {
+		test_util.RunTransitionTest(t, test_util.AllForks, "random", "random",
+			func() test_util.TransitionTest { return new(test_util.BlocksTestCase) })
+	})
+	t.Run("batched", func(t *testing.T) {
+		test_util.RunTransitionTest(t, test_util.AllForks, "random", "random",
+			func() test_util.TransitionTest { return &test_util.BlocksTestCase{BatchSignatures: true} })
+	})
 }
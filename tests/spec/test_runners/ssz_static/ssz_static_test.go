@@ -9,6 +9,7 @@ import (
 	"github.com/golang/snappy"
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
 	"github.com/protolambda/zrnt/eth2/configs"
@@ -98,6 +99,7 @@ var objs = map[test_util.ForkName]map[string]ObjAllocator{
 	"phase0":    {},
 	"altair":    {},
 	"bellatrix": {},
+	"capella":   {},
 }
 
 func init() {
@@ -129,6 +131,7 @@ func init() {
 		objs["phase0"][k] = v
 		objs["altair"][k] = v
 		objs["bellatrix"][k] = v
+		objs["capella"][k] = v
 	}
 	objs["phase0"]["BeaconBlockBody"] = func() interface{} { return new(phase0.BeaconBlockBody) }
 	objs["phase0"]["BeaconBlock"] = func() interface{} { return new(phase0.BeaconBlock) }
@@ -141,8 +144,11 @@ func init() {
 	objs["altair"]["SignedBeaconBlock"] = func() interface{} { return new(altair.SignedBeaconBlock) }
 	objs["altair"]["SyncAggregate"] = func() interface{} { return new(altair.SyncAggregate) }
 
+	objs["altair"]["LightClientBootstrap"] = func() interface{} { return new(altair.LightClientBootstrap) }
 	objs["altair"]["LightClientSnapshot"] = func() interface{} { return new(altair.LightClientSnapshot) }
 	objs["altair"]["LightClientUpdate"] = func() interface{} { return new(altair.LightClientUpdate) }
+	// The light client header only wraps the beacon block header, with the same encoding and root.
+	objs["altair"]["LightClientHeader"] = func() interface{} { return new(common.BeaconBlockHeader) }
 	objs["altair"]["SyncAggregatorSelectionData"] = func() interface{} { return new(altair.SyncAggregatorSelectionData) }
 	objs["altair"]["SyncCommitteeContribution"] = func() interface{} { return new(altair.SyncCommitteeContribution) }
 	objs["altair"]["ContributionAndProof"] = func() interface{} { return new(altair.ContributionAndProof) }
@@ -156,8 +162,22 @@ func init() {
 	objs["bellatrix"]["SignedBeaconBlock"] = func() interface{} { return new(bellatrix.SignedBeaconBlock) }
 	objs["bellatrix"]["ExecutionPayload"] = func() interface{} { return new(common.ExecutionPayload) }
 	objs["bellatrix"]["ExecutionPayloadHeader"] = func() interface{} { return new(common.ExecutionPayloadHeader) }
+	objs["bellatrix"]["LightClientBootstrap"] = func() interface{} { return new(altair.LightClientBootstrap) }
+	objs["bellatrix"]["LightClientUpdate"] = func() interface{} { return new(altair.LightClientUpdate) }
+	objs["bellatrix"]["LightClientHeader"] = func() interface{} { return new(common.BeaconBlockHeader) }
 	//objs["bellatrix"]["PowBlock"] = func() interface{} { return new(bellatrix.PowBlock) }
 
+	objs["capella"]["BeaconBlockBody"] = func() interface{} { return new(capella.BeaconBlockBody) }
+	objs["capella"]["BeaconBlock"] = func() interface{} { return new(capella.BeaconBlock) }
+	objs["capella"]["BeaconState"] = func() interface{} { return new(capella.BeaconState) }
+	objs["capella"]["SignedBeaconBlock"] = func() interface{} { return new(capella.SignedBeaconBlock) }
+	objs["capella"]["ExecutionPayload"] = func() interface{} { return new(capella.ExecutionPayload) }
+	objs["capella"]["ExecutionPayloadHeader"] = func() interface{} { return new(capella.ExecutionPayloadHeader) }
+	objs["capella"]["Withdrawal"] = func() interface{} { return new(common.Withdrawal) }
+	objs["capella"]["BLSToExecutionChange"] = func() interface{} { return new(capella.BLSToExecutionChange) }
+	objs["capella"]["SignedBLSToExecutionChange"] = func() interface{} { return new(capella.SignedBLSToExecutionChange) }
+	objs["capella"]["HistoricalSummary"] = func() interface{} { return new(capella.HistoricalSummary) }
+
 }
 
 type RootsYAML struct {
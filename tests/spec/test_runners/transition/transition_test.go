@@ -8,6 +8,7 @@ import (
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
 	"github.com/protolambda/zrnt/tests/spec/test_util"
@@ -47,6 +48,9 @@ func (c *TransitionTestCase) Load(t *testing.T, testFork test_util.ForkName, rea
 	case "bellatrix":
 		preForkName = "altair"
 		c.Spec.BELLATRIX_FORK_EPOCH = common.Epoch(m.ForkEpoch)
+	case "capella":
+		preForkName = "bellatrix"
+		c.Spec.CAPELLA_FORK_EPOCH = common.Epoch(m.ForkEpoch)
 	default:
 		t.Fatalf("unsupported fork %s", testFork)
 	}
@@ -90,6 +94,11 @@ func (c *TransitionTestCase) Load(t *testing.T, testFork test_util.ForkName, rea
 			test_util.LoadSpecObj(t, fmt.Sprintf("blocks_%d", i), dst, readPart)
 			digest := common.ComputeForkDigest(c.Spec.BELLATRIX_FORK_VERSION, valRoot)
 			return dst.Envelope(c.Spec, digest)
+		case "capella":
+			dst := new(capella.SignedBeaconBlock)
+			test_util.LoadSpecObj(t, fmt.Sprintf("blocks_%d", i), dst, readPart)
+			digest := common.ComputeForkDigest(c.Spec.CAPELLA_FORK_VERSION, valRoot)
+			return dst.Envelope(c.Spec, digest)
 		default:
 			t.Fatalf("unrecognized fork name: %s", forkName)
 			return nil
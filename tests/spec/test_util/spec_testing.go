@@ -68,8 +68,9 @@ func RunHandler(t *testing.T, handlerPath string, caseRunner CaseRunner, spec *c
 	// get the current path, go to the root, and get the tests path
 	_, filename, _, _ := runtime.Caller(0)
 	basepath := filepath.Dir(filepath.Dir(filename))
-	handlerAbsPath := filepath.Join(basepath, "eth2.0-spec-tests", "tests",
-		spec.PRESET_BASE, string(fork), filepath.FromSlash(handlerPath))
+	presetPath := filepath.Join(basepath, "eth2.0-spec-tests", "tests", spec.PRESET_BASE)
+	forkPath := filepath.Join(presetPath, string(fork))
+	handlerAbsPath := filepath.Join(forkPath, filepath.FromSlash(handlerPath))
 
 	forEachDir := func(t *testing.T, path string, callItem func(t *testing.T, path string)) {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -110,6 +111,14 @@ func RunHandler(t *testing.T, handlerPath string, caseRunner CaseRunner, spec *c
 
 	t.Run(handlerPath, func(t *testing.T) {
 		//t.Parallel()
+		// Not every handler has vectors for every fork and preset, but every fork has vectors:
+		// if the fork is missing, the vectors are of a spec version that does not support the fork.
+		if _, err := os.Stat(presetPath); err == nil {
+			if _, err := os.Stat(forkPath); os.IsNotExist(err) {
+				t.Fatalf("missing %s tests of the %s preset, download the vectors of a spec version with %s: %s",
+					fork, spec.PRESET_BASE, fork, forkPath)
+			}
+		}
 		forEachDir(t, handlerAbsPath, runSuite)
 	})
 }
@@ -3,6 +3,7 @@ package test_util
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/zrnt/eth2/beacon/altair"
 	"github.com/protolambda/zrnt/eth2/beacon/bellatrix"
+	"github.com/protolambda/zrnt/eth2/beacon/capella"
 	"github.com/protolambda/zrnt/eth2/beacon/common"
 	"github.com/protolambda/zrnt/eth2/beacon/phase0"
 	"github.com/protolambda/zrnt/eth2/configs"
@@ -23,7 +25,7 @@ import (
 // Fork where the test is organized, and thus the state/block/etc. types default to.
 type ForkName string
 
-var AllForks = []ForkName{"phase0", "altair", "bellatrix"}
+var AllForks = []ForkName{"phase0", "altair", "bellatrix", "capella"}
 
 type BaseTransitionTest struct {
 	Spec *common.Spec
@@ -54,6 +56,8 @@ func LoadState(t *testing.T, fork ForkName, name string, readPart TestPartReader
 			state, err = altair.AsBeaconStateView(altair.BeaconStateType(spec).Deserialize(decodingReader))
 		case "bellatrix":
 			state, err = bellatrix.AsBeaconStateView(bellatrix.BeaconStateType(spec).Deserialize(decodingReader))
+		case "capella":
+			state, err = capella.AsBeaconStateView(capella.BeaconStateType(spec).Deserialize(decodingReader))
 		default:
 			t.Fatalf("unrecognized fork name: %s", fork)
 			return nil
@@ -96,6 +100,9 @@ func (c *BaseTransitionTest) Check(t *testing.T) {
 type BlocksTestCase struct {
 	BaseTransitionTest
 	Blocks []*common.BeaconBlockEnvelope
+	// BatchSignatures runs the transition like block import does: with batched signature verification.
+	// Otherwise every signature is verified during the transition, like the spec does.
+	BatchSignatures bool
 }
 
 type BlocksCountMeta struct {
@@ -130,6 +137,11 @@ func (c *BlocksTestCase) Load(t *testing.T, forkName ForkName, readPart TestPart
 			LoadSpecObj(t, fmt.Sprintf("blocks_%d", i), dst, readPart)
 			digest := common.ComputeForkDigest(c.Spec.BELLATRIX_FORK_VERSION, valRoot)
 			return dst.Envelope(c.Spec, digest)
+		case "capella":
+			dst := new(capella.SignedBeaconBlock)
+			LoadSpecObj(t, fmt.Sprintf("blocks_%d", i), dst, readPart)
+			digest := common.ComputeForkDigest(c.Spec.CAPELLA_FORK_VERSION, valRoot)
+			return dst.Envelope(c.Spec, digest)
 		default:
 			t.Fatalf("unrecognized fork name: %s", forkName)
 			return nil
@@ -150,7 +162,17 @@ func (c *BlocksTestCase) Run() error {
 		c.Pre = state.BeaconState
 	}()
 	for _, b := range c.Blocks {
-		if err := common.StateTransition(context.Background(), c.Spec, epc, state, b, true); err != nil {
+		if !c.BatchSignatures {
+			if err := common.StateTransition(context.Background(), c.Spec, epc, state, b, true); err != nil {
+				return err
+			}
+			continue
+		}
+		sigs := common.NewSignatureBatch()
+		if err := common.StateTransition(common.WithSignatureBatch(context.Background(), sigs), c.Spec, epc, state, b, true); err != nil {
+			return err
+		}
+		if err := sigs.Verify(); err != nil {
 			return err
 		}
 	}
@@ -165,6 +187,8 @@ func encodeStateForDiff(spec *common.Spec, state common.BeaconState) (interface{
 		return s.Raw(spec)
 	case *bellatrix.BeaconStateView:
 		return s.Raw(spec)
+	case *capella.BeaconStateView:
+		return s.Raw(spec)
 	default:
 		return nil, fmt.Errorf("unrecognized beacon state type: %T", s)
 	}
@@ -234,8 +258,22 @@ func RunTransitionTest(t *testing.T, forks []ForkName, runnerName string, handle
 
 type NoOpExecutionEngine struct{}
 
-func (m *NoOpExecutionEngine) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (valid bool, err error) {
-	return true, nil
+func (m *NoOpExecutionEngine) ExecutePayload(ctx context.Context, executionPayload *common.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return common.PayloadExecutionValid, nil
+}
+
+func (m *NoOpExecutionEngine) ExecuteCapellaPayload(ctx context.Context, executionPayload *capella.ExecutionPayload) (common.PayloadExecutionStatus, error) {
+	return common.PayloadExecutionValid, nil
+}
+
+func (m *NoOpExecutionEngine) NotifyForkchoiceUpdated(ctx context.Context, headBlockHash common.Hash32, safeBlockHash common.Hash32,
+	finalizedBlockHash common.Hash32, attributes *common.PayloadAttributes) (*common.PayloadID, error) {
+	return nil, errors.New("not supported")
+}
+
+func (m *NoOpExecutionEngine) GetPayload(ctx context.Context, payloadID common.PayloadID) (*common.ExecutionPayload, error) {
+	return nil, errors.New("not supported")
 }
 
 var _ common.ExecutionEngine = (*NoOpExecutionEngine)(nil)
+var _ capella.ExecutionEngine = (*NoOpExecutionEngine)(nil)